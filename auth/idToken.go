@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is the tolerated difference between the clocks of the provider and of the application
+const clockSkew = time.Minute
+
+// Identity is the signed in user, as described by the ID token
+type Identity struct {
+	Subject string
+	Name    string
+	Email   string
+	Groups  []string
+}
+
+// signingAlgorithm verifies the signature of tokens signed with one of the JWS algorithms
+type signingAlgorithm struct {
+	hash crypto.Hash
+	// verify checks the signature of the hashed header and payload with the key
+	verify func(key any, hashed, signature []byte, hash crypto.Hash) error
+}
+
+// signingAlgorithms are the supported JWS algorithms. Symmetric and unsigned tokens are rejected.
+var signingAlgorithms = map[string]signingAlgorithm{
+	"RS256": {crypto.SHA256, verifyPKCS1}, "RS384": {crypto.SHA384, verifyPKCS1}, "RS512": {crypto.SHA512, verifyPKCS1},
+	"PS256": {crypto.SHA256, verifyPSS}, "PS384": {crypto.SHA384, verifyPSS}, "PS512": {crypto.SHA512, verifyPSS},
+	"ES256": {crypto.SHA256, verifyECDSA}, "ES384": {crypto.SHA384, verifyECDSA}, "ES512": {crypto.SHA512, verifyECDSA},
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token and returns its identity
+func (p *Provider) verifyIDToken(raw, nonce string) (Identity, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return Identity{}, fmt.Errorf("malformed ID token")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Identity{}, fmt.Errorf("malformed ID token header: %v", err)
+	}
+	algorithm, supported := signingAlgorithms[header.Algorithm]
+	if !supported {
+		return Identity{}, fmt.Errorf("unsupported ID token algorithm %q", header.Algorithm)
+	}
+
+	key, err := p.signingKey(header.KeyID)
+	if err != nil {
+		return Identity{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("malformed ID token signature: %v", err)
+	}
+	hasher := algorithm.hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if err := algorithm.verify(key, hasher.Sum(nil), signature, algorithm.hash); err != nil {
+		return Identity{}, fmt.Errorf("invalid ID token signature: %v", err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Identity{}, fmt.Errorf("malformed ID token claims: %v", err)
+	}
+	if err := p.checkClaims(claims, nonce); err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{
+		Subject: stringClaim(claims, "sub"),
+		Name:    stringClaim(claims, "preferred_username"),
+		Email:   stringClaim(claims, "email"),
+		Groups:  stringsClaim(claims, p.config.GroupsClaim),
+	}
+	if identity.Name == "" {
+		identity.Name = stringClaim(claims, "name")
+	}
+	if identity.Name == "" {
+		identity.Name = identity.Subject
+	}
+	return identity, nil
+}
+
+// checkClaims verifies that the token was issued by the provider for this application and this login
+func (p *Provider) checkClaims(claims map[string]any, nonce string) error {
+	if issuer := stringClaim(claims, "iss"); issuer != p.discovery.Issuer {
+		return fmt.Errorf("ID token issued by %q instead of %q", issuer, p.discovery.Issuer)
+	}
+
+	audiences := stringsClaim(claims, "aud")
+	found := false
+	for _, audience := range audiences {
+		found = found || audience == p.config.ClientID
+	}
+	if !found {
+		return fmt.Errorf("ID token issued for %v instead of %s", audiences, p.config.ClientID)
+	}
+
+	expiry, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("ID token has no expiry")
+	}
+	if time.Unix(int64(expiry), 0).Add(clockSkew).Before(time.Now()) {
+		return fmt.Errorf("ID token expired")
+	}
+
+	if stringClaim(claims, "nonce") != nonce {
+		return fmt.Errorf("ID token nonce does not match the login")
+	}
+	return nil
+}
+
+// signingKey returns the provider key with the ID, fetching the keys again when it is unknown,
+// as providers rotate them
+func (p *Provider) signingKey(keyID string) (any, error) {
+	p.keysMutex.Lock()
+	defer p.keysMutex.Unlock()
+
+	if key, found := p.findKey(keyID); found {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	if key, found := p.findKey(keyID); found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown ID token signing key %q", keyID)
+}
+
+// findKey returns the key with the ID, or the only key when the token does not name one
+func (p *Provider) findKey(keyID string) (any, bool) {
+	if keyID == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, found := p.keys[keyID]
+	return key, found
+}
+
+// jsonWebKey is a public key of a JSON Web Key Set
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// Elliptic curve keys
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// fetchKeys fetches the signing keys of the provider by key ID. Keys of unknown types are ignored.
+func (p *Provider) fetchKeys() (map[string]any, error) {
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	resp, err := p.client.R().
+		ForceContentType("application/json").
+		SetResult(&keySet).
+		Get(p.discovery.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %v", err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("failed to fetch signing keys: HTTP request failed with status %d", resp.StatusCode())
+	}
+
+	keys := make(map[string]any)
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	return keys, nil
+}
+
+// publicKey decodes an RSA or elliptic curve key
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, found := curves[k.Curve]
+		if !found {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+func verifyPKCS1(key any, hashed, signature []byte, hash crypto.Hash) error {
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing key is not an RSA key")
+	}
+	return rsa.VerifyPKCS1v15(rsaKey, hash, hashed, signature)
+}
+
+func verifyPSS(key any, hashed, signature []byte, hash crypto.Hash) error {
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("signing key is not an RSA key")
+	}
+	return rsa.VerifyPSS(rsaKey, hash, hashed, signature, nil)
+}
+
+// verifyECDSA checks a JWS elliptic curve signature, the concatenation of r and s
+func verifyECDSA(key any, hashed, signature []byte, _ crypto.Hash) error {
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("signing key is not an elliptic curve key")
+	}
+	size := (ecKey.Curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*size {
+		return errors.New("signature has an invalid length")
+	}
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	if !ecdsa.Verify(ecKey, hashed, r, s) {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed key: %v", err)
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// stringsClaim reads a claim which is either a string or a list of strings, such as the audience or the groups
+func stringsClaim(claims map[string]any, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	confModels "jellyfin-duplicate/configuration/models"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// discovery is the part of the OpenID Connect discovery document used to sign users in
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	// EndSessionEndpoint is optional, users are only signed out of the application without it
+	EndSessionEndpoint string `json:"end_session_endpoint"`
+}
+
+// Provider signs users in with the authorization code flow of an OpenID Connect provider,
+// such as Authelia or Keycloak
+type Provider struct {
+	config    confModels.AuthConfig
+	discovery discovery
+	client    *resty.Client
+	// keys are the signing keys of the provider by key ID, fetched again when an unknown key is used
+	keysMutex sync.Mutex
+	keys      map[string]any
+}
+
+// NewProvider fetches the discovery document of the provider
+func NewProvider(config confModels.AuthConfig) (*Provider, error) {
+	provider := &Provider{config: config, client: resty.New()}
+
+	resp, err := provider.client.R().
+		ForceContentType("application/json").
+		SetResult(&provider.discovery).
+		Get(config.DiscoveryURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %v", err)
+	}
+	if resp.StatusCode() != 200 {
+		return nil, fmt.Errorf("failed to fetch discovery document: HTTP request failed with status %d", resp.StatusCode())
+	}
+
+	d := provider.discovery
+	if d.Issuer == "" || d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document %s misses the issuer, authorization, token or JWKS endpoint", config.DiscoveryURL)
+	}
+
+	logrus.Infof("OpenID Connect provider %s discovered", d.Issuer)
+	return provider, nil
+}
+
+// LoginRequest is the state of a login in progress, kept by the browser between
+// the redirection to the provider and the callback
+type LoginRequest struct {
+	State string `json:"state"`
+	Nonce string `json:"nonce"`
+	// Verifier is the PKCE code verifier, only its hash is sent to the provider
+	Verifier string `json:"verifier"`
+	// ReturnTo is the page to show once signed in
+	ReturnTo string `json:"return_to"`
+}
+
+// NewLoginRequest generates the random values of a new login
+func NewLoginRequest(returnTo string) (LoginRequest, error) {
+	values := make([]string, 3)
+	for i := range values {
+		bytes := make([]byte, 32)
+		if _, err := rand.Read(bytes); err != nil {
+			return LoginRequest{}, fmt.Errorf("failed to generate login state: %v", err)
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(bytes)
+	}
+	return LoginRequest{State: values[0], Nonce: values[1], Verifier: values[2], ReturnTo: returnTo}, nil
+}
+
+// AuthCodeURL returns the provider page where the user signs in
+func (p *Provider) AuthCodeURL(login LoginRequest) string {
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {login.State},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	return withQuery(p.discovery.AuthorizationEndpoint, query)
+}
+
+// LogoutURL returns the provider page signing the user out, empty when the provider has none
+func (p *Provider) LogoutURL() string {
+	if p.discovery.EndSessionEndpoint == "" {
+		return ""
+	}
+	return withQuery(p.discovery.EndSessionEndpoint, url.Values{"client_id": {p.config.ClientID}})
+}
+
+// Exchange trades the authorization code of the callback for the verified identity of the user
+func (p *Provider) Exchange(code string, login LoginRequest) (Identity, error) {
+	var result struct {
+		IDToken string `json:"id_token"`
+	}
+
+	resp, err := p.client.R().
+		ForceContentType("application/json").
+		SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret)).
+		SetFormData(map[string]string{
+			"grant_type":    "authorization_code",
+			"code":          code,
+			"redirect_uri":  p.config.RedirectURL,
+			"code_verifier": login.Verifier,
+		}).
+		SetResult(&result).
+		Post(p.discovery.TokenEndpoint)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to call token endpoint: %v", err)
+	}
+	if resp.StatusCode() != 200 {
+		logrus.Debugf("Response body: %s", string(resp.Body()))
+		return Identity{}, fmt.Errorf("failed to exchange authorization code: HTTP request failed with status %d", resp.StatusCode())
+	}
+	if result.IDToken == "" {
+		return Identity{}, fmt.Errorf("token response has no ID token")
+	}
+
+	return p.verifyIDToken(result.IDToken, login.Nonce)
+}
+
+// withQuery adds query parameters to a URL which may already have some
+func withQuery(endpoint string, query url.Values) string {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + query.Encode()
+}
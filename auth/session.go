@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCookie is returned when a cookie was not signed by the application, or has expired
+var ErrInvalidCookie = errors.New("invalid or expired cookie")
+
+// Session is the signed in user, stored in a signed cookie
+type Session struct {
+	Subject string         `json:"sub"`
+	Name    string         `json:"name"`
+	Email   string         `json:"email"`
+	Role    constants.Role `json:"role"`
+}
+
+// IsAdmin checks if the user may run every action
+func (s Session) IsAdmin() bool {
+	return s.Role == constants.AdminRole
+}
+
+// Signer signs the values stored in cookies, so that they cannot be forged by the browser
+type Signer struct {
+	key []byte
+}
+
+// NewSigner signs with the secret, or with a random key when it is empty
+func NewSigner(secret string) (*Signer, error) {
+	if secret != "" {
+		return &Signer{key: []byte(secret)}, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %v", err)
+	}
+	return &Signer{key: key}, nil
+}
+
+// signedValue is a value with the time after which it is rejected
+type signedValue struct {
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// Encode serializes and signs a value valid until the expiry
+func (s *Signer) Encode(value any, expiresAt time.Time) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(signedValue{Value: data, ExpiresAt: expiresAt})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+// Decode checks the signature and expiry of an encoded value and deserializes it
+func (s *Signer) Decode(encoded string, value any) error {
+	payload, signature, found := strings.Cut(encoded, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return ErrInvalidCookie
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrInvalidCookie
+	}
+	var signed signedValue
+	if err := json.Unmarshal(data, &signed); err != nil || time.Now().After(signed.ExpiresAt) {
+		return ErrInvalidCookie
+	}
+	return json.Unmarshal(signed.Value, value)
+}
+
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
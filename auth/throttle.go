@@ -0,0 +1,134 @@
+package auth
+
+import (
+	confModels "jellyfin-duplicate/configuration/models"
+	"sync"
+	"time"
+)
+
+// rateWindow is the period of the login rate limit
+const rateWindow = time.Minute
+
+// throttledClientsPruned is the number of tracked clients from which the inactive ones are forgotten
+const throttledClientsPruned = 1000
+
+// Throttle limits the login attempts of each client address, and locks a client out after repeated failures
+type Throttle struct {
+	mutex sync.Mutex
+	// rate is the number of attempts per minute
+	rate        int
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+	clients     map[string]*clientAttempts
+}
+
+// clientAttempts are the recent attempts and failures of a client
+type clientAttempts struct {
+	attempts    []time.Time
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+// NewThrottle limits the login attempts as configured, the configuration being validated when loaded
+func NewThrottle(config confModels.AuthConfig) *Throttle {
+	return &Throttle{
+		rate:        config.LoginRateLimit,
+		maxFailures: config.MaxFailedLogins,
+		window:      time.Duration(config.FailedLoginWindow) * time.Minute,
+		lockout:     time.Duration(config.LockoutDuration) * time.Minute,
+		clients:     make(map[string]*clientAttempts),
+	}
+}
+
+// Allow records a sign in attempt of a client. When the client is locked out or above the rate limit, it returns
+// false and how long the client has to wait.
+func (t *Throttle) Allow(client string) (time.Duration, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	attempts := t.client(client, now)
+	if now.Before(attempts.lockedUntil) {
+		return attempts.lockedUntil.Sub(now), false
+	}
+	if len(attempts.attempts) >= t.rate {
+		return attempts.attempts[0].Add(rateWindow).Sub(now), false
+	}
+	attempts.attempts = append(attempts.attempts, now)
+	return 0, true
+}
+
+// Locked checks if a client is locked out, without counting an attempt. It returns how long the client has to wait.
+func (t *Throttle) Locked(client string) (time.Duration, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	if attempts, found := t.clients[client]; found && now.Before(attempts.lockedUntil) {
+		return attempts.lockedUntil.Sub(now), true
+	}
+	return 0, false
+}
+
+// Fail records a failed attempt of a client. It returns true when the client gets locked out.
+func (t *Throttle) Fail(client string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	attempts := t.client(client, now)
+	attempts.failures = append(attempts.failures, now)
+	if len(attempts.failures) < t.maxFailures {
+		return false
+	}
+
+	attempts.lockedUntil = now.Add(t.lockout)
+	attempts.failures = nil
+	return true
+}
+
+// Succeed forgets the failures of a client once it signed in
+func (t *Throttle) Succeed(client string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if attempts, found := t.clients[client]; found {
+		attempts.failures = nil
+	}
+}
+
+// client returns the attempts of a client, without the ones too old to count
+func (t *Throttle) client(client string, now time.Time) *clientAttempts {
+	if len(t.clients) >= throttledClientsPruned {
+		for address, attempts := range t.clients {
+			if attempts.expire(now, t.window) {
+				delete(t.clients, address)
+			}
+		}
+	}
+
+	attempts, found := t.clients[client]
+	if !found {
+		attempts = &clientAttempts{}
+		t.clients[client] = attempts
+	}
+	attempts.expire(now, t.window)
+	return attempts
+}
+
+// expire drops the attempts older than the rate window and the failures older than the failure window.
+// It returns true when nothing is left to remember.
+func (a *clientAttempts) expire(now time.Time, window time.Duration) bool {
+	a.attempts = dropBefore(a.attempts, now.Add(-rateWindow))
+	a.failures = dropBefore(a.failures, now.Add(-window))
+	return len(a.attempts) == 0 && len(a.failures) == 0 && !now.Before(a.lockedUntil)
+}
+
+// dropBefore removes the times before limit from a chronological list
+func dropBefore(times []time.Time, limit time.Time) []time.Time {
+	for len(times) > 0 && times[0].Before(limit) {
+		times = times[1:]
+	}
+	return times
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"maps"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrCallBudgetExceeded is returned by the calls made over the budget of a scan, when it aborts the scan
+var ErrCallBudgetExceeded = errors.New("Jellyfin API call budget exceeded")
+
+// itemIDPattern matches the item and user IDs of the paths, replaced by {id} to group calls by endpoint
+var itemIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$|^[0-9a-fA-F]{8}(-[0-9a-fA-F]{4}){3}-[0-9a-fA-F]{12}$`)
+
+// CallBudget counts the Jellyfin calls of a scan by endpoint, and aborts or throttles the calls over its limit
+type CallBudget struct {
+	mutex sync.Mutex
+	// limit is the number of calls allowed, unlimited when 0
+	limit    int
+	action   constants.BudgetAction
+	interval time.Duration
+	calls    map[string]int
+	total    int
+	// nextCall is the time of the next throttled call
+	nextCall time.Time
+	// exceeded is set once a call went over the limit
+	exceeded bool
+	// onExceeded is called once, by the first call over the limit
+	onExceeded func(limit int)
+}
+
+// NewCallBudget creates a budget of limit calls, unlimited when 0, spacing the calls over it by interval with
+// the throttle action. onExceeded is called once when the limit is first exceeded, it may be nil.
+func NewCallBudget(limit int, action constants.BudgetAction, interval time.Duration, onExceeded func(limit int)) *CallBudget {
+	return &CallBudget{limit: limit, action: action, interval: interval, calls: map[string]int{}, onExceeded: onExceeded}
+}
+
+// budgetKey is the context key of the budget counting the calls of a request
+type budgetKey struct{}
+
+// WithCallBudget returns a context counting the calls of the clients using it in the budget, see Client.WithContext
+func WithCallBudget(ctx context.Context, budget *CallBudget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// Total returns the number of calls made
+func (b *CallBudget) Total() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.total
+}
+
+// ByEndpoint returns the number of calls made by endpoint, the IDs of the paths being replaced by {id}
+func (b *CallBudget) ByEndpoint() map[string]int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return maps.Clone(b.calls)
+}
+
+// take counts a call, failing with ErrCallBudgetExceeded or waiting for its turn when it is over the limit.
+// Rejected calls are not counted, as they are not made.
+func (b *CallBudget) take(ctx context.Context, method, endpoint string) error {
+	b.mutex.Lock()
+	over := b.limit > 0 && b.total >= b.limit
+	if over && !b.exceeded && b.onExceeded != nil {
+		// Called outside of the lock, as it may take a while
+		defer b.onExceeded(b.limit)
+	}
+	b.exceeded = b.exceeded || over
+	if over && b.action != constants.ThrottleBudgetAction {
+		b.mutex.Unlock()
+		return fmt.Errorf("%w: the scan reached scan.max_api_calls (%d)", ErrCallBudgetExceeded, b.limit)
+	}
+	b.calls[method+" "+endpoint]++
+	b.total++
+	if !over {
+		b.mutex.Unlock()
+		return nil
+	}
+
+	wait := time.Until(b.nextCall)
+	b.nextCall = time.Now().Add(max(wait, 0) + b.interval)
+	b.mutex.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// countCalls counts every call made with a budget in its context, see WithCallBudget
+func (c *Client) countCalls() {
+	c.client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		budget, found := req.Context().Value(budgetKey{}).(*CallBudget)
+		if !found {
+			return nil
+		}
+		return budget.take(req.Context(), req.Method, endpointPattern(strings.TrimPrefix(req.URL, strings.TrimSuffix(c.baseURL, "/"))))
+	})
+}
+
+// endpointPattern returns the path of a request URL relative to the server without query, its IDs replaced by {id}
+func endpointPattern(requestURL string) string {
+	path := requestURL
+	if parsed, err := url.Parse(requestURL); err == nil {
+		path = parsed.Path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if itemIDPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
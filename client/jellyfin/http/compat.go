@@ -0,0 +1,158 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// ServerVersion is the version of a Jellyfin server
+type ServerVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseServerVersion parses versions such as "10.9.11" or "10.10.0-rc1"
+func ParseServerVersion(version string) (ServerVersion, error) {
+	core, _, _ := strings.Cut(strings.TrimSpace(version), "-")
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 {
+		return ServerVersion{}, fmt.Errorf("invalid server version %q", version)
+	}
+
+	numbers := make([]int, 3)
+	for i := 0; i < len(parts) && i < 3; i++ {
+		number, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return ServerVersion{}, fmt.Errorf("invalid server version %q", version)
+		}
+		numbers[i] = number
+	}
+
+	return ServerVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// AtLeast checks if the version is greater than or equal to major.minor
+func (v ServerVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v ServerVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// compatibility adapts endpoints to the version of the Jellyfin server.
+// Until the version is detected, the endpoints supported by older servers are used.
+type compatibility struct {
+	version  ServerVersion
+	detected bool
+}
+
+// userQueryEndpoints checks if user scoped endpoints take the user as a query parameter
+// (/Items/{id}?userId=) rather than in the path (/Users/{userId}/Items/{id}), since Jellyfin 10.9
+func (c compatibility) userQueryEndpoints() bool {
+	return c.detected && c.version.AtLeast(10, 9)
+}
+
+// userViews returns the endpoint listing the libraries of a user
+func (c compatibility) userViews(userID string) (string, map[string]string) {
+	if c.userQueryEndpoints() {
+		return "/UserViews", map[string]string{"userId": userID}
+	}
+	return fmt.Sprintf("/Users/%s/Views", userID), nil
+}
+
+// userItem returns the endpoint of an item with the data of a user
+func (c compatibility) userItem(userID, itemID string) (string, map[string]string) {
+	if c.userQueryEndpoints() {
+		return fmt.Sprintf("/Items/%s", itemID), map[string]string{"userId": userID}
+	}
+	return fmt.Sprintf("/Users/%s/Items/%s", userID, itemID), nil
+}
+
+// playedItem returns the endpoint marking an item as played for a user
+func (c compatibility) playedItem(userID, itemID string) (string, map[string]string) {
+	if c.userQueryEndpoints() {
+		return fmt.Sprintf("/UserPlayedItems/%s", itemID), map[string]string{"userId": userID}
+	}
+	return fmt.Sprintf("/Users/%s/PlayedItems/%s", userID, itemID), nil
+}
+
+// favoriteItem returns the endpoint adding an item to the favorites of a user
+func (c compatibility) favoriteItem(userID, itemID string) (string, map[string]string) {
+	if c.userQueryEndpoints() {
+		return fmt.Sprintf("/UserFavoriteItems/%s", itemID), map[string]string{"userId": userID}
+	}
+	return fmt.Sprintf("/Users/%s/FavoriteItems/%s", userID, itemID), nil
+}
+
+// userItemData returns the endpoint updating the data of an item for a user
+func (c compatibility) userItemData(userID, itemID string) (string, map[string]string) {
+	if c.userQueryEndpoints() {
+		return fmt.Sprintf("/UserItems/%s/UserData", itemID), map[string]string{"userId": userID}
+	}
+	return fmt.Sprintf("/Users/%s/Items/%s/UserData", userID, itemID), nil
+}
+
+// request creates a request authenticated with the Authorization header and the device identity,
+// supported by every Jellyfin version unlike the deprecated X-MediaBrowser-Token header
+func (c *Client) request() *resty.Request {
+	request := c.client.R().SetHeader("Authorization", c.identity.header(c.apiKey))
+	if c.ctx != nil {
+		request.SetContext(c.ctx)
+	}
+	return request
+}
+
+// DetectServerVersion reads the version of the server and adapts the following requests to it.
+// The public system information is used, as it does not require any authentication.
+func (c *Client) DetectServerVersion() (ServerVersion, error) {
+	info, err := c.GetPublicSystemInfo()
+	if err != nil {
+		return ServerVersion{}, fmt.Errorf("failed to detect server version: %v", err)
+	}
+
+	version, err := ParseServerVersion(info.Version)
+	if err != nil {
+		return ServerVersion{}, err
+	}
+
+	c.compat = compatibility{version: version, detected: true}
+	logrus.Infof("Detected Jellyfin %s (user query endpoints: %t)", version, c.compat.userQueryEndpoints())
+	return version, nil
+}
+
+// quickConnectInitiateMethod returns the method initiating a Quick Connect request, POST since Jellyfin 10.9
+func (c compatibility) quickConnectInitiateMethod() string {
+	if c.detected && c.version.AtLeast(10, 9) {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// Compatibility describes how the requests are adapted to the version of the Jellyfin server
+type Compatibility struct {
+	// Detected is false until the server version is detected, the endpoints of older servers being used meanwhile
+	Detected           bool   `json:"detected"`
+	ServerVersion      string `json:"server_version,omitempty"`
+	UserQueryEndpoints bool   `json:"user_query_endpoints"`
+	QuickConnectMethod string `json:"quick_connect_method"`
+}
+
+// Compatibility reports how the requests are adapted to the version of the server
+func (c *Client) Compatibility() Compatibility {
+	compatibility := Compatibility{
+		Detected:           c.compat.detected,
+		UserQueryEndpoints: c.compat.userQueryEndpoints(),
+		QuickConnectMethod: c.compat.quickConnectInitiateMethod(),
+	}
+	if c.compat.detected {
+		compatibility.ServerVersion = c.compat.version.String()
+	}
+	return compatibility
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// failureLogSize is the number of failed calls kept, the oldest ones being replaced
+	failureLogSize = 50
+	// failureBodyLimit is the number of bytes of response body kept for each failed call
+	failureBodyLimit = 2048
+)
+
+// sensitiveQueryParams are removed from the recorded endpoints
+var sensitiveQueryParams = []string{"api_key", "ApiKey", "X-Emby-Token"}
+
+// APIFailure is a Jellyfin call which failed, with an HTTP error status or without any response
+type APIFailure struct {
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	// Status is 0 when no response was received
+	Status int `json:"status"`
+	// Body is the beginning of the response body
+	Body string `json:"body,omitempty"`
+	// Error is the network error when no response was received
+	Error string    `json:"error,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// failureLog is a ring buffer of the last failed calls
+type failureLog struct {
+	mutex   sync.Mutex
+	entries []APIFailure
+	next    int
+}
+
+func (l *failureLog) add(failure APIFailure) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.entries) < failureLogSize {
+		l.entries = append(l.entries, failure)
+		return
+	}
+	l.entries[l.next] = failure
+	l.next = (l.next + 1) % failureLogSize
+}
+
+// list returns the failed calls, most recent first
+func (l *failureLog) list() []APIFailure {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	failures := make([]APIFailure, 0, len(l.entries))
+	for i := range l.entries {
+		// The most recent entry is just before next, which is the oldest one once the buffer is full
+		index := (l.next - 1 - i + 2*len(l.entries)) % len(l.entries)
+		failures = append(failures, l.entries[index])
+	}
+	return failures
+}
+
+// recordFailures records every call of the client answered with an error status or without response
+func (c *Client) recordFailures() {
+	c.client.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		if resp.StatusCode() < 400 {
+			return nil
+		}
+		body := resp.Body()
+		truncated := len(body) > failureBodyLimit
+		if truncated {
+			body = body[:failureBodyLimit]
+		}
+		failure := APIFailure{
+			Method:   resp.Request.Method,
+			Endpoint: c.endpoint(resp.Request.URL),
+			Status:   resp.StatusCode(),
+			Body:     strings.ToValidUTF8(string(body), ""),
+			At:       time.Now(),
+		}
+		if truncated {
+			failure.Body += "…"
+		}
+		c.failures.add(failure)
+		return nil
+	})
+	c.client.OnError(func(req *resty.Request, err error) {
+		// Responses with an error status are already recorded
+		if responseErr, ok := err.(*resty.ResponseError); ok && responseErr.Response != nil && responseErr.Response.StatusCode() >= 400 {
+			return
+		}
+		// Requests aborted on purpose, such as by a cancelled scan, did not fail
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		c.failures.add(APIFailure{
+			Method:   req.Method,
+			Endpoint: c.endpoint(req.URL),
+			Error:    err.Error(),
+			At:       time.Now(),
+		})
+	})
+}
+
+// endpoint returns the path and query of a request URL relative to the server, without credentials
+func (c *Client) endpoint(requestURL string) string {
+	parsed, err := url.Parse(requestURL)
+	if err != nil {
+		return strings.TrimPrefix(requestURL, c.baseURL)
+	}
+	query := parsed.Query()
+	for _, param := range sensitiveQueryParams {
+		query.Del(param)
+	}
+	parsed.RawQuery = query.Encode()
+	return strings.TrimPrefix(parsed.String(), strings.TrimSuffix(c.baseURL, "/"))
+}
+
+// LastFailures returns the last failed calls to Jellyfin, most recent first
+func (c *Client) LastFailures() []APIFailure {
+	return c.failures.list()
+}
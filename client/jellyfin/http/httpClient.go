@@ -1,9 +1,13 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/pkg/cache"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -27,7 +31,12 @@ var httpStatusDescriptions = map[int]string{
 	504: "Gateway Timeout",
 }
 
-// checkHTTPResponse checks the HTTP response status code and returns an error if not successful
+// ErrNotFound is returned when Jellyfin does not know the requested item anymore,
+// typically because it was deleted outside of the application
+var ErrNotFound = errors.New("item not found on Jellyfin")
+
+// checkHTTPResponse checks the HTTP response status code and returns an error if not successful.
+// A 404 status is reported as ErrNotFound.
 func checkHTTPResponse(resp *resty.Response, expectedStatusCodes ...int) error {
 	statusCode := resp.StatusCode()
 	
@@ -48,26 +57,134 @@ func checkHTTPResponse(resp *resty.Response, expectedStatusCodes ...int) error {
 	logrus.Errorf("HTTP request failed with status %d (%s)", statusCode, description)
 	logrus.Debugf("Response body: %s", string(resp.Body()))
 	
+	if statusCode == 404 {
+		return fmt.Errorf("%w: HTTP request failed with status %d (%s)", ErrNotFound, statusCode, description)
+	}
 	return fmt.Errorf("HTTP request failed with status %d (%s)", statusCode, description)
 }
 
 type Client struct {
-	baseURL    string
-	apiKey     string
-	userID     string
-	client     *resty.Client
-	userCache  map[string]string // userID -> userName cache
-	cacheMutex sync.Mutex        // mutex to protect cache access
+	baseURL   string
+	apiKey    string
+	userID    string
+	client    *resty.Client
+	userCache *cache.Cache[string, string] // userID -> userName cache
+	compat    compatibility                // adapts requests to the server version
+	identity  Identity                     // device identity sent with every request
+	failures  *failureLog                  // last failed calls, for troubleshooting
+	libraries []string                     // names of the scanned libraries, all when empty
+	operators []string                     // users tried after userID, see SetOperators
+	// homeVideos also fetches the videos of the "Home Videos & Photos" libraries, see IncludeHomeVideos
+	homeVideos bool
+	// ctx aborts the requests when it is done, nil for a client without context, see WithContext
+	ctx context.Context
+	// progress follows the paged fetches, nil when not followed, see WithProgress
+	progress FetchProgress
+	// concurrency is the number of libraries or users fetched at the same time, see SetConcurrency
+	concurrency int
+}
+
+// FetchProgress follows the items fetched by paged requests: the TotalRecordCount of the first page of
+// each paged request is added to the total, then every page advances by its number of items
+type FetchProgress interface {
+	AddTotal(total int)
+	Advance(count int)
 }
 
-func NewClient(baseURL, apiKey string, userID string) *Client {
-	return &Client{
+func NewClient(baseURL, apiKey string, userID string, identity Identity) *Client {
+	client := &Client{
 		baseURL:   baseURL,
 		apiKey:    apiKey,
 		userID:    userID,
-		client:    resty.New(),
-		userCache: make(map[string]string),
+		client:    resty.New().SetHeader("User-Agent", identity.userAgent()),
+		userCache: cache.New[string, string](),
+		identity:  identity,
+		failures:  &failureLog{},
+		// Enough to speed up large servers without overwhelming them
+		concurrency: 5,
 	}
+	client.recordFailures()
+	client.countCalls()
+	return client
+}
+
+// WithContext returns a client sharing the connections, caches and settings of c whose requests are aborted once
+// the context is done, such as the requests of a cancelled scan
+func (c *Client) WithContext(ctx context.Context) *Client {
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
+// WithProgress returns a client sharing the connections, caches and settings of c whose paged fetches of
+// movies are reported to progress
+func (c *Client) WithProgress(progress FetchProgress) *Client {
+	client := *c
+	client.progress = progress
+	return &client
+}
+
+// reportPage reports a page of a paged fetch, the first one carrying the total number of items
+func (c *Client) reportPage(startIndex, count, totalRecordCount int) {
+	if c.progress == nil {
+		return
+	}
+	if startIndex == 0 {
+		c.progress.AddTotal(totalRecordCount)
+	}
+	c.progress.Advance(count)
+}
+
+// RestrictLibraries limits GetAllMovies to the libraries with these names, compared case-insensitively.
+// Every library is scanned when no name is given.
+func (c *Client) RestrictLibraries(names []string) {
+	c.libraries = names
+}
+
+// SetConcurrency sets the number of libraries or users whose movies are fetched at the same time, 5 by default
+func (c *Client) SetConcurrency(concurrency int) {
+	c.concurrency = concurrency
+}
+
+// IncludeHomeVideos also fetches the videos of the "Home Videos & Photos" libraries with the movies,
+// marked as home videos. Only movies are fetched by default.
+func (c *Client) IncludeHomeVideos(include bool) {
+	c.homeVideos = include
+}
+
+// itemTypes returns the item types fetched as movies
+func (c *Client) itemTypes() string {
+	if c.homeVideos {
+		return "Movie,Video"
+	}
+	return "Movie"
+}
+
+// filterLibraries keeps the libraries RestrictLibraries was given, warning about the names matching none
+func (c *Client) filterLibraries(libraries []models.Library) []models.Library {
+	if len(c.libraries) == 0 {
+		return libraries
+	}
+	for _, name := range c.libraries {
+		found := false
+		for _, library := range libraries {
+			found = found || strings.EqualFold(library.Name, name)
+		}
+		if !found {
+			logrus.Warnf("Library %q is not found on Jellyfin, it is not scanned", name)
+		}
+	}
+
+	var filtered []models.Library
+	for _, library := range libraries {
+		for _, name := range c.libraries {
+			if strings.EqualFold(library.Name, name) {
+				filtered = append(filtered, library)
+				break
+			}
+		}
+	}
+	return filtered
 }
 
 func (c *Client) GetAllMovies() ([]models.Movie, error) {
@@ -81,21 +198,28 @@ func (c *Client) GetAllMovies() ([]models.Movie, error) {
 		return nil, fmt.Errorf("failed to get libraries: %v", err)
 	}
 	logrus.Infof("Found %d libraries", len(libraries))
+	libraries = c.filterLibraries(libraries)
+	if len(c.libraries) > 0 {
+		logrus.Infof("Scanning %d of them", len(libraries))
+	}
+	if !c.homeVideos {
+		libraries = withoutHomeVideos(libraries)
+	}
 
-	// Use channels for parallel fetching
-	movieChannel := make(chan []models.Movie, len(libraries))
+	// Fetch the libraries in parallel, keeping the movies by library to return them in the order of the libraries
+	// whatever the order the fetches complete in
+	moviesByLibrary := make([][]models.Movie, len(libraries))
 	errorChannel := make(chan error, len(libraries))
 	var wg sync.WaitGroup
 
 	// Limit concurrent goroutines to avoid overwhelming the system
 	// This prevents too many simultaneous API calls
-	maxConcurrent := 5
-	semaphore := make(chan struct{}, maxConcurrent)
+	semaphore := make(chan struct{}, c.concurrency)
 
 	// For each library, get movies in parallel
-	for _, library := range libraries {
+	for index, library := range libraries {
 		wg.Add(1)
-		go func(lib models.Library) {
+		go func(index int, lib models.Library) {
 			defer wg.Done()
 
 			// Acquire semaphore slot
@@ -103,25 +227,29 @@ func (c *Client) GetAllMovies() ([]models.Movie, error) {
 			defer func() { <-semaphore }()
 
 			logrus.Debugf("Fetching movies from library: %s", lib.Name)
-			libraryMovies, err := c.getMoviesFromLibrary(lib.ID)
+			itemTypes := "Movie"
+			if lib.IsHomeVideos() {
+				itemTypes = "Video"
+			}
+			libraryMovies, err := c.getMoviesFromLibrary(lib.ID, itemTypes)
 			if err != nil {
 				errorChannel <- fmt.Errorf("failed to get movies from library %s: %v", lib.Name, err)
 				return
 			}
+			for i := range libraryMovies {
+				libraryMovies[i].LibraryID = lib.ID
+				libraryMovies[i].LibraryName = lib.Name
+				libraryMovies[i].HomeVideo = lib.IsHomeVideos()
+			}
 			logrus.Infof("Found %d movies in library: %s", len(libraryMovies), lib.Name)
-			movieChannel <- libraryMovies
-		}(library)
+			moviesByLibrary[index] = libraryMovies
+		}(index, library)
 	}
 
-	// Close channels when all goroutines are done
-	go func() {
-		wg.Wait()
-		close(movieChannel)
-		close(errorChannel)
-	}()
-
-	// Collect results from channels
-	for libraryMovies := range movieChannel {
+	// Wait for all goroutines before collecting the results
+	wg.Wait()
+	close(errorChannel)
+	for _, libraryMovies := range moviesByLibrary {
 		movies = append(movies, libraryMovies...)
 	}
 
@@ -138,14 +266,33 @@ func (c *Client) GetAllMovies() ([]models.Movie, error) {
 	return movies, nil
 }
 
-func (c *Client) GetLibraries() ([]models.Library, error) {
-	if c.userID == "" {
-		return nil, fmt.Errorf("user ID not set")
+// withoutHomeVideos leaves the "Home Videos & Photos" libraries out
+func withoutHomeVideos(libraries []models.Library) []models.Library {
+	var movieLibraries []models.Library
+	for _, library := range libraries {
+		if !library.IsHomeVideos() {
+			movieLibraries = append(movieLibraries, library)
+		}
 	}
+	return movieLibraries
+}
 
-	resp, err := c.client.R().
-		SetHeader("X-MediaBrowser-Token", c.apiKey).
-		Get(fmt.Sprintf("%s/Users/%s/Views", c.baseURL, c.userID))
+// GetLibraries lists the libraries seen by the first operator able to list them
+func (c *Client) GetLibraries() ([]models.Library, error) {
+	var libraries []models.Library
+	_, err := c.withOperator(func(userID string) error {
+		var err error
+		libraries, err = c.getLibraries(userID)
+		return err
+	})
+	return libraries, err
+}
+
+func (c *Client) getLibraries(userID string) ([]models.Library, error) {
+	endpoint, params := c.compat.userViews(userID)
+	resp, err := c.request().
+		SetQueryParams(params).
+		Get(c.baseURL + endpoint)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to call Jellyfin API for libraries: %v", err)
@@ -177,7 +324,8 @@ func (c *Client) GetLibraries() ([]models.Library, error) {
 	return result.Items, nil
 }
 
-func (c *Client) getMoviesFromLibrary(libraryID string) ([]models.Movie, error) {
+// getMoviesFromLibrary fetches the items of the given comma-separated types of a library
+func (c *Client) getMoviesFromLibrary(libraryID string, itemTypes string) ([]models.Movie, error) {
 	var allMovies []models.Movie
 
 	// Start with the first page
@@ -190,11 +338,10 @@ func (c *Client) getMoviesFromLibrary(libraryID string) ([]models.Movie, error)
 			TotalRecordCount int            `json:"TotalRecordCount"`
 		}
 
-		resp, err := c.client.R().
-			SetHeader("X-MediaBrowser-Token", c.apiKey).
+		resp, err := c.request().
 			SetQueryParam("Recursive", "true").
-			SetQueryParam("IncludeItemTypes", "Movie").
-			SetQueryParam("Fields", "ProviderIds,ProductionYear,Path,UserData").
+			SetQueryParam("IncludeItemTypes", itemTypes).
+			SetQueryParam("Fields", "ProviderIds,ProductionYear,Path,UserData,MediaSources,DateCreated").
 			SetQueryParam("ParentId", libraryID).
 			SetQueryParam("StartIndex", fmt.Sprintf("%d", startIndex)).
 			SetQueryParam("Limit", fmt.Sprintf("%d", limit)).
@@ -213,6 +360,7 @@ func (c *Client) getMoviesFromLibrary(libraryID string) ([]models.Movie, error)
 
 		// Add movies from this page to our collection
 		allMovies = append(allMovies, result.Items...)
+		c.reportPage(startIndex, len(result.Items), result.TotalRecordCount)
 
 		// Check if we've fetched all movies
 		if len(allMovies) >= result.TotalRecordCount {
@@ -232,8 +380,7 @@ func (c *Client) GetAllUsers() ([]models.User, error) {
 	logrus.Info("Fetching all users from Jellyfin...")
 	var users []models.User
 
-	resp, err := c.client.R().
-		SetHeader("X-MediaBrowser-Token", c.apiKey).
+	resp, err := c.request().
 		SetResult(&users).
 		Get(fmt.Sprintf("%s/Users", c.baseURL))
 
@@ -248,11 +395,9 @@ func (c *Client) GetAllUsers() ([]models.User, error) {
 	}
 
 	// Populate user cache with all fetched users
-	c.cacheMutex.Lock()
 	for _, user := range users {
-		c.userCache[user.ID] = user.Name
+		c.userCache.Set(user.ID, user.Name)
 	}
-	c.cacheMutex.Unlock()
 
 	logrus.Infof("Found %d users and populated user cache", len(users))
 	return users, nil
@@ -268,10 +413,11 @@ func (c *Client) GetUserPlayStatus(movieID string, userID string) (models.UserPl
 		} `json:"UserData"`
 	}
 
-	resp, err := c.client.R().
-		SetHeader("X-MediaBrowser-Token", c.apiKey).
+	endpoint, params := c.compat.userItem(userID, movieID)
+	resp, err := c.request().
 		SetResult(&result).
-		Get(fmt.Sprintf("%s/Users/%s/Items/%s", c.baseURL, userID, movieID))
+		SetQueryParams(params).
+		Get(c.baseURL + endpoint)
 
 	if err != nil {
 		return models.UserPlayStatus{}, fmt.Errorf("failed to call Jellyfin API for user play status: %v", err)
@@ -284,15 +430,26 @@ func (c *Client) GetUserPlayStatus(movieID string, userID string) (models.UserPl
 	}
 
 	return models.UserPlayStatus{
-		UserID:    c.userID,
-		UserName:  "Current User", // Would need to fetch user info separately
-		Played:    result.UserData.Played,
-		PlayCount: result.UserData.PlayCount,
+		UserID:         c.userID,
+		UserName:       "Current User", // Would need to fetch user info separately
+		Played:         result.UserData.Played,
+		PlayCount:      result.UserData.PlayCount,
+		LastPlayedDate: result.UserData.LastPlayedDate,
 	}, nil
 }
 
 // GetSeenMoviesForUser fetches all movies that a specific user has seen (played)
 func (c *Client) GetSeenMoviesForUser(userID string) ([]models.Movie, error) {
+	return c.getUserMovies(userID, "IsPlayed")
+}
+
+// GetUserMovies fetches all movies with the data of a user: play status, favorite and playback position
+func (c *Client) GetUserMovies(userID string) ([]models.Movie, error) {
+	return c.getUserMovies(userID, "")
+}
+
+// getUserMovies fetches the movies matching the filters, with the data of a user
+func (c *Client) getUserMovies(userID string, filters string) ([]models.Movie, error) {
 	var allMovies []models.Movie
 
 	// Start with the first page
@@ -305,12 +462,15 @@ func (c *Client) GetSeenMoviesForUser(userID string) ([]models.Movie, error) {
 			TotalRecordCount int            `json:"TotalRecordCount"`
 		}
 
-		resp, err := c.client.R().
-			SetHeader("X-MediaBrowser-Token", c.apiKey).
+		request := c.request()
+		if filters != "" {
+			request.SetQueryParam("Filters", filters)
+		}
+
+		resp, err := request.
 			SetQueryParam("Recursive", "true").
-			SetQueryParam("IncludeItemTypes", "Movie").
+			SetQueryParam("IncludeItemTypes", c.itemTypes()).
 			SetQueryParam("Fields", "ProviderIds,ProductionYear,Path,UserData").
-			SetQueryParam("Filters", "IsPlayed").
 			SetQueryParam("UserId", userID).
 			SetQueryParam("StartIndex", fmt.Sprintf("%d", startIndex)).
 			SetQueryParam("Limit", fmt.Sprintf("%d", limit)).
@@ -318,7 +478,7 @@ func (c *Client) GetSeenMoviesForUser(userID string) ([]models.Movie, error) {
 			Get(fmt.Sprintf("%s/Items", c.baseURL))
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch seen movies for user %s: %v", userID, err)
+			return nil, fmt.Errorf("failed to fetch movies for user %s: %v", userID, err)
 		}
 
 		// Debug: Log the raw response if there's an issue
@@ -328,6 +488,7 @@ func (c *Client) GetSeenMoviesForUser(userID string) ([]models.Movie, error) {
 
 		// Add movies from this page to our collection
 		allMovies = append(allMovies, result.Items...)
+		c.reportPage(startIndex, len(result.Items), result.TotalRecordCount)
 
 		// Check if we've fetched all movies
 		if len(allMovies) >= result.TotalRecordCount {
@@ -341,15 +502,28 @@ func (c *Client) GetSeenMoviesForUser(userID string) ([]models.Movie, error) {
 	return allMovies, nil
 }
 
-// GetSeenMoviesForAllUsers fetches seen movies for all users in parallel (max 5 concurrent)
+// GetSeenMoviesForAllUsers fetches seen movies for all users in parallel (5 concurrent by default)
 func (c *Client) GetSeenMoviesForAllUsers(users []models.User) (map[string][]models.Movie, error) {
-	logrus.Infof("Fetching seen movies for %d users in parallel...", len(users))
+	return c.getMoviesForAllUsers(users, "seen", c.GetSeenMoviesForUser)
+}
+
+// GetResumableMoviesForAllUsers fetches the movies each user stopped watching before the end, with their
+// playback position, in parallel like GetSeenMoviesForAllUsers
+func (c *Client) GetResumableMoviesForAllUsers(users []models.User) (map[string][]models.Movie, error) {
+	return c.getMoviesForAllUsers(users, "resumable", func(userID string) ([]models.Movie, error) {
+		return c.getUserMovies(userID, "IsResumable")
+	})
+}
+
+// getMoviesForAllUsers fetches the movies of a kind for all users in parallel, see SetConcurrency
+func (c *Client) getMoviesForAllUsers(users []models.User, kind string, fetch func(userID string) ([]models.Movie, error)) (map[string][]models.Movie, error) {
+	logrus.Infof("Fetching %s movies for %d users in parallel...", kind, len(users))
 	userSeenMovies := make(map[string][]models.Movie)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	// Limit concurrent goroutines to 5
-	semaphore := make(chan struct{}, 5)
+	// Limit concurrent goroutines, see SetConcurrency
+	semaphore := make(chan struct{}, c.concurrency)
 
 	var errors []error
 
@@ -362,11 +536,11 @@ func (c *Client) GetSeenMoviesForAllUsers(users []models.User) (map[string][]mod
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			logrus.Debugf("Fetching seen movies for user: %s", u.Name)
-			seenMovies, err := c.GetSeenMoviesForUser(u.ID)
+			logrus.Debugf("Fetching %s movies for user: %s", kind, u.Name)
+			seenMovies, err := fetch(u.ID)
 			if err != nil {
 				mu.Lock()
-				errors = append(errors, fmt.Errorf("failed to get seen movies for user %s: %v", u.Name, err))
+				errors = append(errors, fmt.Errorf("failed to get %s movies for user %s: %v", kind, u.Name, err))
 				mu.Unlock()
 				return
 			}
@@ -374,36 +548,43 @@ func (c *Client) GetSeenMoviesForAllUsers(users []models.User) (map[string][]mod
 			mu.Lock()
 			userSeenMovies[u.ID] = seenMovies
 			mu.Unlock()
-			logrus.Infof("Found %d seen movies for user: %s", len(seenMovies), u.Name)
+			logrus.Infof("Found %d %s movies for user: %s", len(seenMovies), kind, u.Name)
 		}(user)
 	}
 
 	wg.Wait()
 
 	if len(errors) > 0 {
-		return nil, fmt.Errorf("errors occurred while fetching seen movies: %v", errors)
+		return nil, fmt.Errorf("errors occurred while fetching %s movies: %v", kind, errors)
 	}
 
-	logrus.Infof("Successfully fetched seen movies for all %d users", len(users))
+	logrus.Infof("Successfully fetched %s movies for all %d users", kind, len(users))
 	return userSeenMovies, nil
 }
 
-// GetMovieName gets the name of a movie by its ID
+// GetMovieName gets the name of a movie by its ID, as seen by the first operator able to see it
 func (c *Client) GetMovieName(movieID string) (string, error) {
-	// Use the user-specific items endpoint which returns more complete data
-	if c.userID == "" {
-		return "", fmt.Errorf("user ID not set for movie name lookup")
-	}
+	var name string
+	_, err := c.withOperator(func(userID string) error {
+		var err error
+		name, err = c.getMovieName(movieID, userID)
+		return err
+	})
+	return name, err
+}
 
+func (c *Client) getMovieName(movieID string, userID string) (string, error) {
+	// Use the user-specific items endpoint which returns more complete data
 	var result struct {
 		Name string `json:"Name"`
 	}
 
-	resp, err := c.client.R().
-		SetHeader("X-MediaBrowser-Token", c.apiKey).
+	endpoint, params := c.compat.userItem(userID, movieID)
+	resp, err := c.request().
 		SetQueryParam("Fields", "ProviderIds,ProductionYear,Path,UserData").
 		SetResult(&result).
-		Get(fmt.Sprintf("%s/Users/%s/Items/%s", c.baseURL, c.userID, movieID))
+		SetQueryParams(params).
+		Get(c.baseURL + endpoint)
 
 	if err != nil {
 		return "", fmt.Errorf("failed to call Jellyfin API for movie name: %v", err)
@@ -420,8 +601,7 @@ func (c *Client) GetMovieName(movieID string) (string, error) {
 		var basicResult struct {
 			Name string `json:"Name"`
 		}
-		resp, err := c.client.R().
-			SetHeader("X-MediaBrowser-Token", c.apiKey).
+		resp, err := c.request().
 			SetResult(&basicResult).
 			Get(fmt.Sprintf("%s/Items/%s", c.baseURL, movieID))
 
@@ -444,20 +624,16 @@ func (c *Client) GetMovieName(movieID string) (string, error) {
 // GetUserName gets the name of a user by their ID with caching
 func (c *Client) GetUserName(userID string) (string, error) {
 	// Check cache first
-	c.cacheMutex.Lock()
-	if cachedName, exists := c.userCache[userID]; exists {
-		c.cacheMutex.Unlock()
+	if cachedName, exists := c.userCache.Get(userID); exists {
 		return cachedName, nil
 	}
-	c.cacheMutex.Unlock()
 
 	// Cache miss, fetch from API
 	var result struct {
 		Name string `json:"Name"`
 	}
 
-	resp, err := c.client.R().
-		SetHeader("X-MediaBrowser-Token", c.apiKey).
+	resp, err := c.request().
 		SetResult(&result).
 		Get(fmt.Sprintf("%s/Users/%s", c.baseURL, userID))
 
@@ -472,25 +648,34 @@ func (c *Client) GetUserName(userID string) (string, error) {
 	}
 
 	// Cache the result
-	c.cacheMutex.Lock()
-	c.userCache[userID] = result.Name
-	c.cacheMutex.Unlock()
+	c.userCache.Set(userID, result.Name)
 
 	return result.Name, nil
 }
 
+// UserNameCacheStats returns the usage of the user name cache
+func (c *Client) UserNameCacheStats() cache.Stats {
+	return c.userCache.Stats()
+}
+
+// FlushUserNameCache empties the user name cache and returns the number of names removed
+func (c *Client) FlushUserNameCache() int {
+	return c.userCache.Flush()
+}
+
 // MarkMovieAsPlayed marks a movie as played for a specific user using Jellyfin API
 func (c *Client) MarkMovieAsPlayed(movieID string, userID string, movieName string, userName string) error {
 	logrus.Infof("Marking movie %s (%s) as played for user %s (%s)", movieName, movieID, userName, userID)
 
 	// Jellyfin API endpoint to mark an item as played
 	// Alternative endpoint format that might work better
-	url := fmt.Sprintf("%s/Users/%s/PlayedItems/%s", c.baseURL, userID, movieID)
+	endpoint, params := c.compat.playedItem(userID, movieID)
+	url := c.baseURL + endpoint
 	logrus.Debugf("Using URL: %s", url)
 
-	resp, err := c.client.R().
-		SetHeader("X-MediaBrowser-Token", c.apiKey).
+	resp, err := c.request().
 		SetHeader("Content-Type", "application/json").
+		SetQueryParams(params).
 		Post(url)
 
 	if err != nil {
@@ -507,6 +692,11 @@ func (c *Client) MarkMovieAsPlayed(movieID string, userID string, movieName stri
 		logrus.Warnf("Response body: %s", string(resp.Body()))
 	}
 
+	if statusCode == 404 {
+		logrus.Warnf("Movie %s not found when marking it as played", movieID)
+		return fmt.Errorf("%w: movie %s", ErrNotFound, movieID)
+	}
+
 	// Jellyfin API returns 204 No Content on success for this endpoint
 	// Some versions might return 200 OK
 	if statusCode != 204 && statusCode != 200 {
@@ -518,6 +708,26 @@ func (c *Client) MarkMovieAsPlayed(movieID string, userID string, movieName stri
 	return nil
 }
 
+// MarkMovieAsUnplayed clears the played state of a movie for a user, its play count included
+func (c *Client) MarkMovieAsUnplayed(movieID string, userID string) error {
+	endpoint, params := c.compat.playedItem(userID, movieID)
+	resp, err := c.request().
+		SetQueryParams(params).
+		Delete(c.baseURL + endpoint)
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to mark movie as unplayed: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to mark movie %s as unplayed for user %s: %w", movieID, userID, err)
+	}
+
+	return nil
+}
+
 // DeleteMovie deletes a movie from Jellyfin using the API
 func (c *Client) DeleteMovie(movieID string) error {
 	logrus.Infof("Deleting movie %s from Jellyfin", movieID)
@@ -526,8 +736,7 @@ func (c *Client) DeleteMovie(movieID string) error {
 	url := fmt.Sprintf("%s/Items/%s", c.baseURL, movieID)
 	logrus.Debugf("Using delete URL: %s", url)
 
-	resp, err := c.client.R().
-		SetHeader("X-MediaBrowser-Token", c.apiKey).
+	resp, err := c.request().
 		Delete(url)
 
 	if err != nil {
@@ -544,6 +753,11 @@ func (c *Client) DeleteMovie(movieID string) error {
 		logrus.Warnf("Delete response body: %s", string(resp.Body()))
 	}
 
+	if statusCode == 404 {
+		logrus.Warnf("Movie %s not found when deleting it, it was already deleted", movieID)
+		return fmt.Errorf("%w: movie %s", ErrNotFound, movieID)
+	}
+
 	// Jellyfin API returns 204 No Content on successful deletion
 	// Some versions might return 200 OK
 	if statusCode != 204 && statusCode != 200 {
@@ -580,21 +794,22 @@ func (c *Client) ReconcilePlayStatusWithAllMovies(allMovies []models.Movie, user
 			continue
 		}
 
-		// Create a map of seen movie IDs for this user
-		seenMovieIDs := make(map[string]bool)
+		// Create a map of the play data of the seen movies for this user
+		seenMovieData := make(map[string]models.UserItemData)
 		for _, seenMovie := range seenMovies {
-			seenMovieIDs[seenMovie.ID] = true
+			seenMovieData[seenMovie.ID] = seenMovie.UserData
 		}
 
 		// Update play status for each movie
 		for movieID, movie := range movieMap {
-			if seenMovieIDs[movieID] {
+			if data, seen := seenMovieData[movieID]; seen {
 				// Movie is seen by this user, update play status
 				playStatus := models.UserPlayStatus{
-					UserID:   user.ID,
-					UserName: user.Name,
-					Played:   true,
-					// Note: PlayCount would need to be fetched separately if needed
+					UserID:         user.ID,
+					UserName:       user.Name,
+					Played:         true,
+					PlayCount:      data.PlayCount,
+					LastPlayedDate: data.LastPlayedDate,
 				}
 				movie.UserPlayStatuses = append(movie.UserPlayStatuses, playStatus)
 			} else {
@@ -610,11 +825,556 @@ func (c *Client) ReconcilePlayStatusWithAllMovies(allMovies []models.Movie, user
 		}
 	}
 
-	// Convert map back to slice
+	// Convert map back to slice, in the order of the movies so that scans return the same pairs in the same order
 	var moviesWithPlayStatus []models.Movie
-	for _, movie := range movieMap {
-		moviesWithPlayStatus = append(moviesWithPlayStatus, movie)
+	for _, movie := range allMovies {
+		if reconciled, ok := movieMap[movie.ID]; ok {
+			moviesWithPlayStatus = append(moviesWithPlayStatus, reconciled)
+			delete(movieMap, movie.ID)
+		}
 	}
 
 	return moviesWithPlayStatus, nil
 }
+
+// GetPublicSystemInfo fetches the unauthenticated server information, useful to check connectivity
+func (c *Client) GetPublicSystemInfo() (models.SystemInfo, error) {
+	var info models.SystemInfo
+
+	resp, err := c.client.R().
+		SetResult(&info).
+		Get(fmt.Sprintf("%s/System/Info/Public", c.baseURL))
+
+	if err != nil {
+		return models.SystemInfo{}, fmt.Errorf("failed to call Jellyfin API for public system info: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.SystemInfo{}, fmt.Errorf("failed to fetch public system info: %v", err)
+	}
+
+	return info, nil
+}
+
+// GetSystemInfo fetches the authenticated server information, which requires a valid API key
+func (c *Client) GetSystemInfo() (models.SystemInfo, error) {
+	var info models.SystemInfo
+
+	resp, err := c.request().
+		SetResult(&info).
+		Get(fmt.Sprintf("%s/System/Info", c.baseURL))
+
+	if err != nil {
+		return models.SystemInfo{}, fmt.Errorf("failed to call Jellyfin API for system info: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.SystemInfo{}, fmt.Errorf("failed to fetch system info: %v", err)
+	}
+
+	return info, nil
+}
+
+// GetUser fetches a single user by its ID
+func (c *Client) GetUser(userID string) (models.User, error) {
+	var user models.User
+
+	resp, err := c.request().
+		SetResult(&user).
+		Get(fmt.Sprintf("%s/Users/%s", c.baseURL, userID))
+
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to call Jellyfin API for user: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to fetch user %s: %v", userID, err)
+	}
+
+	return user, nil
+}
+
+// GetMovie fetches a single movie by its ID, including its path
+func (c *Client) GetMovie(movieID string) (models.Movie, error) {
+	movie, _, err := c.GetMovieAsOperator(movieID)
+	return movie, err
+}
+
+// GetMovieAsOperator fetches a single movie by its ID with the first operator able to see it,
+// and returns the ID of that operator
+func (c *Client) GetMovieAsOperator(movieID string) (models.Movie, string, error) {
+	var movie models.Movie
+	operator, err := c.withOperator(func(userID string) error {
+		var err error
+		movie, err = c.getMovie(movieID, userID)
+		return err
+	})
+	return movie, operator, err
+}
+
+func (c *Client) getMovie(movieID string, userID string) (models.Movie, error) {
+	var movie models.Movie
+
+	endpoint, params := c.compat.userItem(userID, movieID)
+	resp, err := c.request().
+		SetQueryParam("Fields", "ProviderIds,ProductionYear,Path,UserData,MediaSources").
+		SetResult(&movie).
+		SetQueryParams(params).
+		Get(c.baseURL + endpoint)
+
+	if err != nil {
+		return models.Movie{}, fmt.Errorf("failed to call Jellyfin API for movie: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.Movie{}, fmt.Errorf("failed to fetch movie %s: %w", movieID, err)
+	}
+
+	return movie, nil
+}
+
+// NotifyMediaUpdated reports a changed path to Jellyfin so that it rescans the affected folder
+func (c *Client) NotifyMediaUpdated(path string, updateType string) error {
+	logrus.Infof("Notifying Jellyfin that %s was %s", path, strings.ToLower(updateType))
+
+	body := map[string]any{
+		"Updates": []map[string]string{
+			{"Path": path, "UpdateType": updateType},
+		},
+	}
+
+	resp, err := c.request().
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Post(fmt.Sprintf("%s/Library/Media/Updated", c.baseURL))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API for media update: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to notify media update: %v", err)
+	}
+
+	return nil
+}
+
+// GetItemLibrary finds the library (collection folder) containing an item
+func (c *Client) GetItemLibrary(itemID string) (models.BaseItem, error) {
+	var ancestors []models.BaseItem
+
+	resp, err := c.request().
+		SetResult(&ancestors).
+		Get(fmt.Sprintf("%s/Items/%s/Ancestors", c.baseURL, itemID))
+
+	if err != nil {
+		return models.BaseItem{}, fmt.Errorf("failed to call Jellyfin API for item ancestors: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.BaseItem{}, fmt.Errorf("failed to fetch ancestors of item %s: %v", itemID, err)
+	}
+
+	for _, ancestor := range ancestors {
+		if ancestor.Type == "CollectionFolder" {
+			return ancestor, nil
+		}
+	}
+
+	return models.BaseItem{}, fmt.Errorf("no library found for item %s", itemID)
+}
+
+// RefreshItem triggers a recursive metadata refresh of an item, such as a library
+func (c *Client) RefreshItem(itemID string) error {
+	logrus.Infof("Triggering Jellyfin refresh of item %s", itemID)
+
+	resp, err := c.request().
+		SetQueryParam("Recursive", "true").
+		SetQueryParam("MetadataRefreshMode", "Default").
+		SetQueryParam("ImageRefreshMode", "Default").
+		Post(fmt.Sprintf("%s/Items/%s/Refresh", c.baseURL, itemID))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API for item refresh: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to refresh item %s: %v", itemID, err)
+	}
+
+	return nil
+}
+
+// MergeVersions groups movies as the versions of a single title, Jellyfin picking the primary one.
+// It requires an administrator API key.
+func (c *Client) MergeVersions(itemIDs ...string) error {
+	logrus.Infof("Merging Jellyfin items %s as versions", strings.Join(itemIDs, ", "))
+
+	resp, err := c.request().
+		SetQueryParam("ids", strings.Join(itemIDs, ",")).
+		Post(fmt.Sprintf("%s/Videos/MergeVersions", c.baseURL))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API for merging versions: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to merge versions: %v", err)
+	}
+
+	return nil
+}
+
+// RefreshMetadata fetches the metadata and images of an item again from the metadata providers. With replace,
+// the existing metadata and images are dropped instead of being completed, as the Identify dialog of Jellyfin
+// does for misidentified items.
+func (c *Client) RefreshMetadata(itemID string, replace bool) error {
+	logrus.Infof("Triggering Jellyfin metadata refresh of item %s (replace: %t)", itemID, replace)
+
+	resp, err := c.request().
+		SetQueryParam("MetadataRefreshMode", "FullRefresh").
+		SetQueryParam("ImageRefreshMode", "FullRefresh").
+		SetQueryParam("ReplaceAllMetadata", strconv.FormatBool(replace)).
+		SetQueryParam("ReplaceAllImages", strconv.FormatBool(replace)).
+		Post(fmt.Sprintf("%s/Items/%s/Refresh", c.baseURL, itemID))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API for metadata refresh: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to refresh metadata of item %s: %v", itemID, err)
+	}
+
+	return nil
+}
+
+// SearchMovieMetadata asks the metadata providers of Jellyfin for the movies matching a name and a year, 0 when
+// unknown, to identify a movie
+func (c *Client) SearchMovieMetadata(itemID string, name string, year int) ([]models.RemoteSearchResult, error) {
+	searchInfo := map[string]any{"Name": name}
+	if year > 0 {
+		searchInfo["Year"] = year
+	}
+	body := map[string]any{
+		"ItemId":     itemID,
+		"SearchInfo": searchInfo,
+	}
+
+	var results []models.RemoteSearchResult
+	resp, err := c.request().
+		SetBody(body).
+		SetResult(&results).
+		Post(fmt.Sprintf("%s/Items/RemoteSearch/Movie", c.baseURL))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Jellyfin API for metadata search: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search metadata of %q: %v", name, err)
+	}
+
+	logrus.Debugf("Found %d metadata candidates for %q", len(results), name)
+	return results, nil
+}
+
+// ApplySearchResult identifies an item as a result of SearchMovieMetadata, Jellyfin then refreshing its metadata
+// from the providers of the result. With replaceImages, the images of the item are fetched again too.
+func (c *Client) ApplySearchResult(itemID string, result models.RemoteSearchResult, replaceImages bool) error {
+	logrus.Infof("Identifying Jellyfin item %s as %s (%d)", itemID, result.Name, result.ProductionYear)
+
+	resp, err := c.request().
+		SetQueryParam("ReplaceAllImages", strconv.FormatBool(replaceImages)).
+		SetBody(result).
+		Post(fmt.Sprintf("%s/Items/RemoteSearch/Apply/%s", c.baseURL, itemID))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to apply search result: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to identify item %s: %v", itemID, err)
+	}
+
+	return nil
+}
+
+// GetPlaylists returns every playlist of the server, whatever its owner
+func (c *Client) GetPlaylists() ([]models.Playlist, error) {
+	var result struct {
+		Items []models.Playlist `json:"Items"`
+	}
+
+	resp, err := c.request().
+		SetQueryParam("IncludeItemTypes", "Playlist").
+		SetQueryParam("Recursive", "true").
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/Items", c.baseURL))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Jellyfin API for playlists: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlists: %v", err)
+	}
+
+	logrus.Debugf("Successfully fetched %d playlists from Jellyfin", len(result.Items))
+	return result.Items, nil
+}
+
+// GetPlaylistEntries returns the entries of a playlist, in playlist order
+func (c *Client) GetPlaylistEntries(playlistID string) ([]models.PlaylistEntry, error) {
+	var result struct {
+		Items []models.PlaylistEntry `json:"Items"`
+	}
+
+	resp, err := c.request().
+		SetQueryParam("UserId", c.userID).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/Playlists/%s/Items", c.baseURL, playlistID))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Jellyfin API for playlist items: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch items of playlist %s: %v", playlistID, err)
+	}
+
+	return result.Items, nil
+}
+
+// AddToPlaylist appends an item at the end of a playlist
+func (c *Client) AddToPlaylist(playlistID string, itemID string) error {
+	resp, err := c.request().
+		SetQueryParam("Ids", itemID).
+		SetQueryParam("UserId", c.userID).
+		Post(fmt.Sprintf("%s/Playlists/%s/Items", c.baseURL, playlistID))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to add playlist item: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to add item %s to playlist %s: %v", itemID, playlistID, err)
+	}
+
+	return nil
+}
+
+// MovePlaylistEntry moves a playlist entry to a new zero based position
+func (c *Client) MovePlaylistEntry(playlistID string, playlistItemID string, index int) error {
+	resp, err := c.request().
+		Post(fmt.Sprintf("%s/Playlists/%s/Items/%s/Move/%d", c.baseURL, playlistID, playlistItemID, index))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to move playlist item: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to move entry %s of playlist %s: %v", playlistItemID, playlistID, err)
+	}
+
+	return nil
+}
+
+// RemoveFromPlaylist removes an entry from a playlist
+func (c *Client) RemoveFromPlaylist(playlistID string, playlistItemID string) error {
+	resp, err := c.request().
+		SetQueryParam("EntryIds", playlistItemID).
+		Delete(fmt.Sprintf("%s/Playlists/%s/Items", c.baseURL, playlistID))
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to remove playlist item: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to remove entry %s from playlist %s: %v", playlistItemID, playlistID, err)
+	}
+
+	return nil
+}
+
+// MarkMovieAsFavorite adds a movie to the favorites of a user
+func (c *Client) MarkMovieAsFavorite(movieID string, userID string) error {
+	endpoint, params := c.compat.favoriteItem(userID, movieID)
+	resp, err := c.request().
+		SetQueryParams(params).
+		Post(c.baseURL + endpoint)
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to mark favorite: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to mark movie %s as favorite for user %s: %v", movieID, userID, err)
+	}
+
+	return nil
+}
+
+// SetPlaybackPosition sets the resume position of a movie for a user
+func (c *Client) SetPlaybackPosition(movieID string, userID string, positionTicks int64) error {
+	endpoint, params := c.compat.userItemData(userID, movieID)
+	resp, err := c.request().
+		SetQueryParams(params).
+		SetBody(map[string]any{"PlaybackPositionTicks": positionTicks}).
+		Post(c.baseURL + endpoint)
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to set playback position: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to set playback position of movie %s for user %s: %v", movieID, userID, err)
+	}
+
+	return nil
+}
+
+// GetBoxSets returns every collection of the server
+func (c *Client) GetBoxSets() ([]models.BoxSet, error) {
+	var result struct {
+		Items []models.BoxSet `json:"Items"`
+	}
+
+	resp, err := c.request().
+		SetQueryParam("IncludeItemTypes", "BoxSet").
+		SetQueryParam("Recursive", "true").
+		SetQueryParam("UserId", c.userID).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/Items", c.baseURL))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Jellyfin API for collections: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collections: %v", err)
+	}
+
+	logrus.Debugf("Successfully fetched %d collections from Jellyfin", len(result.Items))
+	return result.Items, nil
+}
+
+// GetBoxSetMovies returns the movies of a collection
+func (c *Client) GetBoxSetMovies(boxSetID string) ([]models.Movie, error) {
+	var result struct {
+		Items []models.Movie `json:"Items"`
+	}
+
+	resp, err := c.request().
+		SetQueryParam("ParentId", boxSetID).
+		SetQueryParam("IncludeItemTypes", "Movie").
+		SetQueryParam("Fields", "ProviderIds,ProductionYear").
+		SetQueryParam("UserId", c.userID).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/Items", c.baseURL))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Jellyfin API for collection items: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch items of collection %s: %v", boxSetID, err)
+	}
+
+	return result.Items, nil
+}
+
+// GetUserItemData returns the play status of a movie for a user
+func (c *Client) GetUserItemData(movieID string, userID string) (models.UserItemData, error) {
+	var result struct {
+		UserData models.UserItemData `json:"UserData"`
+	}
+
+	endpoint, params := c.compat.userItem(userID, movieID)
+	resp, err := c.request().
+		SetResult(&result).
+		SetQueryParams(params).
+		Get(c.baseURL + endpoint)
+
+	if err != nil {
+		return models.UserItemData{}, fmt.Errorf("failed to call Jellyfin API for user item data: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.UserItemData{}, fmt.Errorf("failed to fetch play status of movie %s for user %s: %v", movieID, userID, err)
+	}
+
+	return result.UserData, nil
+}
+
+// UpdateUserItemData overwrites the played state, playback position, play count and last played date of a movie for a user
+func (c *Client) UpdateUserItemData(movieID string, userID string, data models.UserItemData) error {
+	body := map[string]any{
+		"Played":                data.Played,
+		"PlaybackPositionTicks": data.PlaybackPositionTicks,
+		"PlayCount":             data.PlayCount,
+	}
+	if data.LastPlayedDate != "" {
+		body["LastPlayedDate"] = data.LastPlayedDate
+	}
+
+	endpoint, params := c.compat.userItemData(userID, movieID)
+	resp, err := c.request().
+		SetQueryParams(params).
+		SetBody(body).
+		Post(c.baseURL + endpoint)
+
+	if err != nil {
+		return fmt.Errorf("failed to call Jellyfin API to update user item data: %v", err)
+	}
+
+	// Check HTTP status code
+	err = checkHTTPResponse(resp, 200, 204)
+	if err != nil {
+		return fmt.Errorf("failed to update play status of movie %s for user %s: %v", movieID, userID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Identity is how the application presents itself to Jellyfin, shown in its devices and dashboard
+type Identity struct {
+	Client   string
+	Device   string
+	DeviceID string
+	Version  string
+}
+
+// headerValueReplacer removes the characters delimiting the values of the Authorization header
+var headerValueReplacer = strings.NewReplacer(`"`, "", ",", "")
+
+// header builds the value of the Authorization header
+func (i Identity) header(token string) string {
+	return fmt.Sprintf(`MediaBrowser Client="%s", Device="%s", DeviceId="%s", Version="%s", Token="%s"`,
+		headerValueReplacer.Replace(i.Client),
+		headerValueReplacer.Replace(i.Device),
+		headerValueReplacer.Replace(i.DeviceID),
+		headerValueReplacer.Replace(i.Version),
+		token)
+}
+
+// userAgent builds the value of the User-Agent header, such as "jellyfin-duplicate/1.2.0"
+func (i Identity) userAgent() string {
+	if i.Version == "" {
+		return i.Client
+	}
+	return i.Client + "/" + i.Version
+}
+
+// Identity returns the device identity the client presents to Jellyfin
+func (c *Client) Identity() Identity {
+	return c.identity
+}
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"fmt"
+	"jellyfin-duplicate/client/jellyfin/models"
+	"strconv"
+)
+
+// GetUserImage fetches the avatar of a user, scaled down by Jellyfin to fit in size pixels.
+// It returns ErrNotFound when the user has no avatar.
+func (c *Client) GetUserImage(userID string, size int) (models.Image, error) {
+	resp, err := c.request().
+		SetQueryParam("maxWidth", strconv.Itoa(size)).
+		SetQueryParam("maxHeight", strconv.Itoa(size)).
+		Get(fmt.Sprintf("%s/Users/%s/Images/Primary", c.baseURL, userID))
+
+	if err != nil {
+		return models.Image{}, fmt.Errorf("failed to call Jellyfin API for user image: %v", err)
+	}
+
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.Image{}, fmt.Errorf("failed to fetch user image: %w", err)
+	}
+
+	return models.Image{ContentType: resp.Header().Get("Content-Type"), Data: resp.Body()}, nil
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SetOperators sets the users tried, in order, after the admin user of the client when it cannot list the
+// libraries or see an item, e.g. once deleted or restricted to some libraries
+func (c *Client) SetOperators(userIDs []string) {
+	c.operators = userIDs
+}
+
+// operatorIDs lists the admin user of the client then the other operators, without duplicates
+func (c *Client) operatorIDs() []string {
+	ids := make([]string, 0, len(c.operators)+1)
+	seen := make(map[string]bool)
+	for _, id := range append([]string{c.userID}, c.operators...) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// withOperator runs a call on behalf of each operator until one succeeds, and returns the ID of that operator.
+// The error of the last operator is returned when none succeeds.
+func (c *Client) withOperator(call func(userID string) error) (string, error) {
+	ids := c.operatorIDs()
+	if len(ids) == 0 {
+		return "", fmt.Errorf("user ID not set")
+	}
+
+	var err error
+	for i, id := range ids {
+		if err = call(id); err == nil {
+			return id, nil
+		}
+		if i < len(ids)-1 {
+			logrus.Debugf("Call on behalf of user %s failed, trying the next operator: %v", id, err)
+		}
+	}
+	return "", err
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"fmt"
+	"jellyfin-duplicate/client/jellyfin/models"
+)
+
+// IsQuickConnectEnabled checks if the server accepts Quick Connect requests, which does not require any authentication
+func (c *Client) IsQuickConnectEnabled() (bool, error) {
+	var enabled bool
+
+	resp, err := c.request().
+		SetResult(&enabled).
+		Get(fmt.Sprintf("%s/QuickConnect/Enabled", c.baseURL))
+
+	if err != nil {
+		return false, fmt.Errorf("failed to call Jellyfin API for Quick Connect status: %v", err)
+	}
+
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch Quick Connect status: %v", err)
+	}
+
+	return enabled, nil
+}
+
+// InitiateQuickConnect starts a Quick Connect request for the device of the client. Its code is to be approved
+// by a signed in user, its secret identifies the request afterwards.
+func (c *Client) InitiateQuickConnect() (models.QuickConnectResult, error) {
+	var result models.QuickConnectResult
+
+	resp, err := c.request().
+		SetResult(&result).
+		Execute(c.compat.quickConnectInitiateMethod(), fmt.Sprintf("%s/QuickConnect/Initiate", c.baseURL))
+
+	if err != nil {
+		return models.QuickConnectResult{}, fmt.Errorf("failed to call Jellyfin API to initiate Quick Connect: %v", err)
+	}
+
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.QuickConnectResult{}, fmt.Errorf("failed to initiate Quick Connect: %v", err)
+	}
+
+	return result, nil
+}
+
+// GetQuickConnectState fetches the state of a Quick Connect request, to know when its code was approved
+func (c *Client) GetQuickConnectState(secret string) (models.QuickConnectResult, error) {
+	var result models.QuickConnectResult
+
+	resp, err := c.request().
+		SetQueryParam("secret", secret).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/QuickConnect/Connect", c.baseURL))
+
+	if err != nil {
+		return models.QuickConnectResult{}, fmt.Errorf("failed to call Jellyfin API for Quick Connect state: %v", err)
+	}
+
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.QuickConnectResult{}, fmt.Errorf("failed to fetch Quick Connect state: %v", err)
+	}
+
+	return result, nil
+}
+
+// AuthenticateWithQuickConnect exchanges the secret of an approved Quick Connect request for an access token
+// of the user who approved it
+func (c *Client) AuthenticateWithQuickConnect(secret string) (models.AuthenticationResult, error) {
+	var result models.AuthenticationResult
+
+	resp, err := c.request().
+		SetBody(map[string]string{"Secret": secret}).
+		SetResult(&result).
+		Post(fmt.Sprintf("%s/Users/AuthenticateWithQuickConnect", c.baseURL))
+
+	if err != nil {
+		return models.AuthenticationResult{}, fmt.Errorf("failed to call Jellyfin API to authenticate with Quick Connect: %v", err)
+	}
+
+	err = checkHTTPResponse(resp, 200)
+	if err != nil {
+		return models.AuthenticationResult{}, fmt.Errorf("failed to authenticate with Quick Connect: %v", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,7 @@
+package models
+
+// BoxSet is a Jellyfin collection of movies
+type BoxSet struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// Image is an image served by Jellyfin, such as a user avatar
+type Image struct {
+	ContentType string
+	Data        []byte
+}
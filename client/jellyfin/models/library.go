@@ -1,6 +1,24 @@
 package models
 
+// HomeVideosCollectionType is the collection type of the "Home Videos & Photos" libraries
+const HomeVideosCollectionType = "homevideos"
+
 type Library struct {
 	ID   string `json:"Id"`
 	Name string `json:"Name"`
+	// CollectionType is the content of the library, such as "movies" or HomeVideosCollectionType
+	CollectionType string `json:"CollectionType"`
+}
+
+// IsHomeVideos checks if the library is a "Home Videos & Photos" library
+func (l Library) IsHomeVideos() bool {
+	return l.CollectionType == HomeVideosCollectionType
+}
+
+// BaseItem represents the minimal information shared by every Jellyfin item
+type BaseItem struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+	Type string `json:"Type"`
+	Path string `json:"Path"`
 }
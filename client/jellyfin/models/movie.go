@@ -1,22 +1,267 @@
 package models
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/humanize"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Movie struct {
 	ID             string         `json:"Id"`
 	Name           string         `json:"Name"`
 	Path           string         `json:"Path"`
 	ProductionYear int            `json:"ProductionYear"`
 	PlayStatus     UserPlayStatus `json:"PlayStatus"`
-	UserData       struct {
-		Played                bool   `json:"Played"`
-		PlaybackPositionTicks int64  `json:"PlaybackPositionTicks"`
-		PlayCount             int    `json:"PlayCount"`
-		LastPlayedDate        string `json:"LastPlayedDate"`
-	} `json:"UserData"`
-	ProviderIds struct {
+	UserData       UserItemData   `json:"UserData"`
+	ProviderIds    struct {
 		Tmdb string `json:"Tmdb"`
 		Imdb string `json:"Imdb"`
 	} `json:"ProviderIds"`
 	UserPlayStatuses []UserPlayStatus `json:"UserPlayStatuses"`
+	MediaSources     []MediaSource    `json:"MediaSources"`
+	// DateCreated is when the movie was added to the library
+	DateCreated string `json:"DateCreated"`
+	// RunTimeTicks is the duration of the movie, in ticks of 100 nanoseconds
+	RunTimeTicks int64 `json:"RunTimeTicks"`
+	// ImageTags are the tags of the images of the movie by type, such as Primary for the poster
+	ImageTags map[string]string `json:"ImageTags,omitempty"`
+	// Library the movie was found in, set while fetching movies library by library
+	LibraryID   string `json:"LibraryId"`
+	LibraryName string `json:"LibraryName"`
+	// Playlists referencing the movie, set while scanning for duplicates
+	Playlists []Playlist `json:"Playlists,omitempty"`
+	// Part is the part number of a multi-part movie file (CD1, part 2...), 0 for single files
+	Part int `json:"Part,omitempty"`
+	// FileModified is the RFC 3339 modification date of the file, read from disk while scanning, empty when
+	// the media folders are not accessible
+	FileModified string `json:"FileModified,omitempty"`
+	// HomeVideo is set for the videos of "Home Videos & Photos" libraries, fetched with scan.home_videos
+	HomeVideo bool `json:"HomeVideo,omitempty"`
+	// ContentFingerprint identifies the recording of a home video whatever its name, computed while scanning
+	// when the media folders are accessible, or the group of identical files of an imported filesystem report,
+	// empty otherwise
+	ContentFingerprint string `json:"ContentFingerprint,omitempty"`
+	// Inspection holds the stream details read by ffprobe for the copies of duplicates, with scan.inspect_media,
+	// nil when not inspected
+	Inspection *MediaInspection `json:"Inspection,omitempty"`
+}
+
+// TickDuration is the unit of Jellyfin durations and playback positions
+const TickDuration = 100 * time.Nanosecond
+
+// UserItemData is the play status of an item for a user
+type UserItemData struct {
+	Played                bool   `json:"Played"`
+	PlaybackPositionTicks int64  `json:"PlaybackPositionTicks"`
+	PlayCount             int    `json:"PlayCount"`
+	LastPlayedDate        string `json:"LastPlayedDate,omitempty"`
+	IsFavorite            bool   `json:"IsFavorite"`
+}
+
+// MediaSource describes a file backing a movie
+type MediaSource struct {
+	ID           string        `json:"Id"`
+	Path         string        `json:"Path"`
+	Container    string        `json:"Container"`
+	Size         int64         `json:"Size"`
+	Bitrate      int64         `json:"Bitrate"`
+	MediaStreams []MediaStream `json:"MediaStreams"`
+}
+
+// MediaStream describes a video, audio or subtitle track of a media source
+type MediaStream struct {
+	Type         constants.MediaStreamType `json:"Type"`
+	Language     string                    `json:"Language"`
+	Codec        string                    `json:"Codec"`
+	DisplayTitle string                    `json:"DisplayTitle"`
+	IsExternal   bool                      `json:"IsExternal"`
+	// Width, Height and VideoRange (SDR, HDR) are only set for video tracks
+	Width      int    `json:"Width"`
+	Height     int    `json:"Height"`
+	VideoRange string `json:"VideoRange"`
+}
+
+// MediaInspection holds the stream details of a file read by ffprobe, more accurate than the ones reported by Jellyfin
+type MediaInspection struct {
+	// VideoBitrate is the bitrate of the video stream in bits per second
+	VideoBitrate  int64  `json:"VideoBitrate"`
+	Interlaced    bool   `json:"Interlaced"`
+	AudioChannels int    `json:"AudioChannels"`
+	AudioLayout   string `json:"AudioLayout,omitempty"`
+}
+
+// Size returns the file size in bytes of the movie's first media source, or 0 when unknown
+func (m Movie) Size() int64 {
+	if len(m.MediaSources) == 0 {
+		return 0
+	}
+	return m.MediaSources[0].Size
+}
+
+// Bitrate returns the overall bitrate in bits per second of the movie's first media source, or 0 when unknown
+func (m Movie) Bitrate() int64 {
+	if len(m.MediaSources) == 0 {
+		return 0
+	}
+	return m.MediaSources[0].Bitrate
+}
+
+// Duration returns the duration of the movie, or 0 when unknown
+func (m Movie) Duration() time.Duration {
+	return time.Duration(m.RunTimeTicks) * TickDuration
+}
+
+// MarshalJSON adds the size, bitrate and duration of the movie to its Jellyfin fields, both raw
+// and human-readable with a "_h" suffix. Unknown values are 0, without human-readable value.
+func (m Movie) MarshalJSON() ([]byte, error) {
+	// movieFields has the fields of Movie without its methods, so that encoding it does not recurse
+	type movieFields Movie
+	fields := struct {
+		movieFields
+		Size      int64  `json:"size"`
+		SizeH     string `json:"size_h,omitempty"`
+		Bitrate   int64  `json:"bitrate"`
+		BitrateH  string `json:"bitrate_h,omitempty"`
+		Duration  int64  `json:"duration"`
+		DurationH string `json:"duration_h,omitempty"`
+	}{
+		movieFields: movieFields(m),
+		Size:        m.Size(),
+		Bitrate:     m.Bitrate(),
+		Duration:    int64(m.Duration() / time.Second),
+	}
+	if fields.Size > 0 {
+		fields.SizeH = humanize.Bytes(fields.Size)
+	}
+	if fields.Bitrate > 0 {
+		fields.BitrateH = humanize.Bitrate(fields.Bitrate)
+	}
+	if fields.Duration > 0 {
+		fields.DurationH = humanize.Duration(m.Duration())
+	}
+	return json.Marshal(fields)
+}
+
+// Fingerprint identifies the content of a movie independently of its item ID, which changes when Jellyfin
+// removes and adds the file again: it is derived from the provider IDs, the normalized path and the size
+func (m Movie) Fingerprint() string {
+	normalizedPath := strings.ToLower(strings.ReplaceAll(m.Path, `\`, "/"))
+	content := strings.Join([]string{m.ProviderIds.Tmdb, m.ProviderIds.Imdb, normalizedPath, strconv.FormatInt(m.Size(), 10)}, "|")
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:16])
+}
+
+// AddedAt returns when the movie was added to the library, false when unknown
+func (m Movie) AddedAt() (time.Time, bool) {
+	addedAt, err := time.Parse(time.RFC3339, m.DateCreated)
+	return addedAt, err == nil
+}
+
+// ModifiedAt returns when the file was last modified, false when unknown
+func (m Movie) ModifiedAt() (time.Time, bool) {
+	modifiedAt, err := time.Parse(time.RFC3339, m.FileModified)
+	return modifiedAt, err == nil
+}
+
+// FileDate returns when the file was last modified, or when it was added to the library when its modification
+// date is unknown. It is zero when both are unknown.
+func (m Movie) FileDate() time.Time {
+	if modifiedAt, ok := m.ModifiedAt(); ok {
+		return modifiedAt
+	}
+	addedAt, _ := m.AddedAt()
+	return addedAt
+}
+
+// HasStreams checks if the tracks of the movie's first media source are known
+func (m Movie) HasStreams() bool {
+	return len(m.MediaSources) > 0 && len(m.MediaSources[0].MediaStreams) > 0
+}
+
+// VideoStream returns the first video track of the movie's first media source
+func (m Movie) VideoStream() (MediaStream, bool) {
+	if len(m.MediaSources) == 0 {
+		return MediaStream{}, false
+	}
+	for _, stream := range m.MediaSources[0].MediaStreams {
+		if stream.Type == constants.VideoStream {
+			return stream, true
+		}
+	}
+	return MediaStream{}, false
+}
+
+// Resolution classifies the video track by its width, so that cropped widescreen
+// videos (e.g. 1920x800) keep their nominal resolution. It is unknown without video track.
+func (m Movie) Resolution() constants.Resolution {
+	video, found := m.VideoStream()
+	if !found || (video.Width == 0 && video.Height == 0) {
+		return constants.UnknownResolution
+	}
+
+	switch {
+	case video.Width >= 3200 || video.Height >= 2000:
+		return constants.UHD4K
+	case video.Width >= 1800 || video.Height >= 1000:
+		return constants.HD1080
+	case video.Width >= 1200 || video.Height >= 700:
+		return constants.HD720
+	default:
+		return constants.SDResolution
+	}
+}
+
+// VideoCodec returns the lowercase codec of the video track, empty when unknown
+func (m Movie) VideoCodec() string {
+	video, _ := m.VideoStream()
+	return strings.ToLower(video.Codec)
+}
+
+// IsHDR checks if the video track has a high dynamic range
+func (m Movie) IsHDR() bool {
+	video, _ := m.VideoStream()
+	return strings.EqualFold(video.VideoRange, "HDR")
+}
+
+// AudioLanguages returns the sorted languages of the audio tracks of the movie's first media source
+func (m Movie) AudioLanguages() []string {
+	return m.streamLanguages(constants.AudioStream)
+}
+
+// SubtitleLanguages returns the sorted languages of the subtitle tracks, embedded or external
+func (m Movie) SubtitleLanguages() []string {
+	return m.streamLanguages(constants.SubtitleStream)
+}
+
+// streamLanguages lists the distinct languages of the tracks of a type, "und" standing for unknown
+func (m Movie) streamLanguages(streamType constants.MediaStreamType) []string {
+	if len(m.MediaSources) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	languages := []string{}
+	for _, stream := range m.MediaSources[0].MediaStreams {
+		if stream.Type != streamType {
+			continue
+		}
+		language := strings.ToLower(stream.Language)
+		if language == "" {
+			language = UndefinedLanguage
+		}
+		if !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+	return languages
 }
 
 type UserPlayStatus struct {
@@ -24,6 +269,10 @@ type UserPlayStatus struct {
 	UserName  string `json:"UserName"`
 	Played    bool   `json:"Played"`
 	PlayCount int    `json:"PlayCount"`
+	// LastPlayedDate is the RFC 3339 date the user last played the movie, empty when unknown
+	LastPlayedDate string `json:"LastPlayedDate,omitempty"`
+	// PlaybackPositionTicks is where the user stopped watching the movie before the end, 0 otherwise
+	PlaybackPositionTicks int64 `json:"PlaybackPositionTicks,omitempty"`
 }
 
 // User model for multi-user support
@@ -33,6 +282,10 @@ type User struct {
 	HasPassword      bool   `json:"HasPassword"`
 	LastLoginDate    string `json:"LastLoginDate,omitempty"`
 	LastActivityDate string `json:"LastActivityDate,omitempty"`
+	Policy           struct {
+		IsAdministrator bool `json:"IsAdministrator"`
+		IsDisabled      bool `json:"IsDisabled"`
+	} `json:"Policy"`
 }
 
 // Extended Movie model with play status
@@ -48,9 +301,15 @@ type PlayStatusDiscrepancy struct {
 	UserName      string `json:"user_name"`
 	MovieToUpdate string `json:"movie_to_update"`
 	MovieName     string `json:"movie_name"`
+	// PlayCount and LastPlayedDate are the plays of the user on the copy seen, and PlayCountDelta the difference
+	// with the plays on the copy to update, to tell which record is authoritative
+	PlayCount      int    `json:"play_count"`
+	PlayCountDelta int    `json:"play_count_delta"`
+	LastPlayedDate string `json:"last_played_date,omitempty"`
 }
 
 type DuplicateResult struct {
+	ID                       string                  `json:"id"`
 	Movie1                   Movie                   `json:"movie1"`
 	Movie2                   Movie                   `json:"movie2"`
 	IsDuplicate              bool                    `json:"is_duplicate"`
@@ -58,4 +317,70 @@ type DuplicateResult struct {
 	HasPlayStatusDiscrepancy bool                    `json:"has_play_status_discrepancy"`
 	HasIdenticalPlayStatus   bool                    `json:"has_identical_play_status"`
 	PlayStatusDiscrepancies  []PlayStatusDiscrepancy `json:"play_status_discrepancies,omitempty"`
+	// RecommendedDeleteID is the ID of the lower quality copy, empty when both copies are equivalent
+	RecommendedDeleteID string `json:"recommended_delete_id,omitempty"`
+	// Tracks compares the languages of both copies, nil when the tracks of a copy are unknown
+	Tracks *TrackComparison `json:"tracks,omitempty"`
+	// TraktDiscrepancies lists the copies Trakt reports as watched while Jellyfin does not
+	TraktDiscrepancies []PlayStatusDiscrepancy `json:"trakt_discrepancies,omitempty"`
+	// Link is set when both copies are the same file on disk, with scan.detect_links
+	Link *FileLink `json:"link,omitempty"`
+	// ReclaimableSize is the space freed by deleting a copy in bytes: the recommended one when set,
+	// otherwise the smallest one, 0 for linked copies or when sizes are unknown
+	ReclaimableSize int64 `json:"reclaimable_size"`
+	// BelowMinReclaimableSize is set when ReclaimableSize is under deletion.min_reclaimable_size,
+	// no copy is recommended for deletion then
+	BelowMinReclaimableSize bool `json:"below_min_reclaimable_size,omitempty"`
+	// SameStem is set when both files are in the same folder with the same name, only their extension differing:
+	// they are duplicates whatever their metadata
+	SameStem bool `json:"same_stem,omitempty"`
+	// SameContent is set when both copies have the same content fingerprint: the same recording of home videos,
+	// or identical files according to an imported filesystem report, whatever their names and paths
+	SameContent bool `json:"same_content,omitempty"`
+	// Severity classifies the pair with scan.severity_rules, from exact duplicates to mismatches
+	Severity constants.Severity `json:"severity"`
+	// UsersAffected is the number of users with a play status or Trakt discrepancy on the pair
+	UsersAffected int `json:"users_affected"`
+	// PositionConflicts lists the users who stopped watching both copies at different positions: synchronizing
+	// the pair requires choosing the copy whose position is kept
+	PositionConflicts   []PositionConflict `json:"position_conflicts,omitempty"`
+	HasPositionConflict bool               `json:"has_position_conflict"`
+}
+
+// PositionConflict is a user who stopped watching both copies of a pair at different positions
+type PositionConflict struct {
+	UserID              string `json:"user_id"`
+	UserName            string `json:"user_name"`
+	Movie1PositionTicks int64  `json:"movie1_position_ticks"`
+	Movie2PositionTicks int64  `json:"movie2_position_ticks"`
+}
+
+// FileLink reports the two copies of a pair as the same file on disk, taking no extra space
+type FileLink struct {
+	Type constants.LinkType `json:"type"`
+	// SymlinkID is the copy going through a symbolic link, empty for hard links
+	SymlinkID string `json:"symlink_id,omitempty"`
+}
+
+// UndefinedLanguage is used for tracks without language
+const UndefinedLanguage = "und"
+
+// TrackInventory lists the audio and subtitle languages of a copy
+type TrackInventory struct {
+	AudioLanguages    []string `json:"audio_languages"`
+	SubtitleLanguages []string `json:"subtitle_languages"`
+}
+
+// TrackComparison compares the audio and subtitle languages of both copies of a pair
+type TrackComparison struct {
+	Movie1 TrackInventory `json:"movie1"`
+	Movie2 TrackInventory `json:"movie2"`
+	// Languages only available in the copy recommended for deletion
+	LostAudioLanguages    []string `json:"lost_audio_languages,omitempty"`
+	LostSubtitleLanguages []string `json:"lost_subtitle_languages,omitempty"`
+}
+
+// LosesLanguages checks if deleting the recommended copy would lose an audio or subtitle language
+func (c *TrackComparison) LosesLanguages() bool {
+	return c != nil && (len(c.LostAudioLanguages) > 0 || len(c.LostSubtitleLanguages) > 0)
 }
@@ -0,0 +1,14 @@
+package models
+
+type Playlist struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+}
+
+// PlaylistEntry is an item of a playlist. PlaylistItemID identifies the entry itself,
+// as the same item may appear several times in a playlist.
+type PlaylistEntry struct {
+	ID             string `json:"Id"`
+	Name           string `json:"Name"`
+	PlaylistItemID string `json:"PlaylistItemId"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// QuickConnectResult is the state of a Quick Connect request, authenticated once a user approved its code
+type QuickConnectResult struct {
+	Authenticated bool   `json:"Authenticated"`
+	Secret        string `json:"Secret"`
+	Code          string `json:"Code"`
+	DeviceID      string `json:"DeviceId"`
+}
+
+// AuthenticationResult is the session issued to a user, with its access token
+type AuthenticationResult struct {
+	User        User   `json:"User"`
+	AccessToken string `json:"AccessToken"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// RemoteSearchResult is a candidate found by the metadata providers of Jellyfin when identifying an item, sent
+// back as is to apply it
+type RemoteSearchResult struct {
+	Name               string            `json:"Name"`
+	ProductionYear     int               `json:"ProductionYear,omitempty"`
+	PremiereDate       string            `json:"PremiereDate,omitempty"`
+	ProviderIds        map[string]string `json:"ProviderIds"`
+	ImageURL           string            `json:"ImageUrl,omitempty"`
+	Overview           string            `json:"Overview,omitempty"`
+	SearchProviderName string            `json:"SearchProviderName,omitempty"`
+}
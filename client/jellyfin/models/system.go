@@ -0,0 +1,10 @@
+package models
+
+// SystemInfo represents the server information returned by /System/Info
+type SystemInfo struct {
+	ID              string `json:"Id"`
+	ServerName      string `json:"ServerName"`
+	Version         string `json:"Version"`
+	ProductName     string `json:"ProductName"`
+	OperatingSystem string `json:"OperatingSystem"`
+}
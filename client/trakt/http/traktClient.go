@@ -0,0 +1,53 @@
+package http
+
+import (
+	"fmt"
+	"jellyfin-duplicate/client/trakt/models"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const traktAPIURL = "https://api.trakt.tv"
+
+type Client struct {
+	clientID    string
+	accessToken string
+	client      *resty.Client
+}
+
+func NewClient(clientID, accessToken string) *Client {
+	return &Client{
+		clientID:    clientID,
+		accessToken: accessToken,
+		client: resty.New().
+			SetBaseURL(traktAPIURL).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("trakt-api-version", "2"),
+	}
+}
+
+// GetWatchedMovies returns every movie watched by the Trakt account of the access token
+func (c *Client) GetWatchedMovies() ([]models.WatchedMovie, error) {
+	logrus.Info("Fetching watched movies from Trakt...")
+
+	var watched []models.WatchedMovie
+
+	resp, err := c.client.R().
+		SetHeader("trakt-api-key", c.clientID).
+		SetAuthToken(c.accessToken).
+		SetResult(&watched).
+		Get("/sync/watched/movies")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Trakt API for watched movies: %v", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		logrus.Debugf("Response body: %s", string(resp.Body()))
+		return nil, fmt.Errorf("failed to fetch watched movies: HTTP request failed with status %d", resp.StatusCode())
+	}
+
+	logrus.Infof("Found %d watched movies on Trakt", len(watched))
+	return watched, nil
+}
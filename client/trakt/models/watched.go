@@ -0,0 +1,19 @@
+package models
+
+// WatchedMovie is a movie of the watched history of a Trakt account
+type WatchedMovie struct {
+	Plays         int    `json:"plays"`
+	LastWatchedAt string `json:"last_watched_at"`
+	Movie         Movie  `json:"movie"`
+}
+
+type Movie struct {
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+	IDs   struct {
+		Trakt int    `json:"trakt"`
+		Slug  string `json:"slug"`
+		Imdb  string `json:"imdb"`
+		Tmdb  int    `json:"tmdb"`
+	} `json:"ids"`
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"context"
+	"jellyfin-duplicate/pkg/cache"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// remoteCache is a cache whose entries are Redis keys sharing a prefix
+type remoteCache struct {
+	cluster *Cluster
+	name    string
+	ttl     time.Duration
+}
+
+// Cache returns a cache shared by the replicas, whose entries expire after ttl, or are kept until flushed when
+// ttl is 0
+func (c *Cluster) Cache(name string, ttl time.Duration) cache.Remote {
+	return &remoteCache{cluster: c, name: name, ttl: ttl}
+}
+
+// entry returns the name of the value of a key
+func (r *remoteCache) entry(key string) string {
+	return "cache:" + r.name + ":" + key
+}
+
+func (r *remoteCache) Get(key string) ([]byte, bool) {
+	value, found, err := r.cluster.Get(r.entry(key))
+	if err != nil {
+		logrus.Warnf("Failed to read cache %s from Redis: %v", r.name, err)
+	}
+	return value, found
+}
+
+func (r *remoteCache) Set(key string, value []byte) {
+	if err := r.cluster.Set(r.entry(key), value, r.ttl); err != nil {
+		logrus.Warnf("Failed to write cache %s to Redis: %v", r.name, err)
+	}
+}
+
+func (r *remoteCache) Flush() int {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	keys, err := r.keys(ctx)
+	if err != nil || len(keys) == 0 {
+		return 0
+	}
+	flushed, err := r.cluster.client.Del(ctx, keys...).Result()
+	if err != nil {
+		logrus.Warnf("Failed to flush cache %s from Redis: %v", r.name, err)
+	}
+	return int(flushed)
+}
+
+func (r *remoteCache) Usage() (int, int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	keys, err := r.keys(ctx)
+	if err != nil || len(keys) == 0 {
+		return 0, 0
+	}
+	pipeline := r.cluster.client.Pipeline()
+	for _, key := range keys {
+		pipeline.StrLen(ctx, key)
+	}
+	results, err := pipeline.Exec(ctx)
+	if err != nil {
+		logrus.Warnf("Failed to measure cache %s in Redis: %v", r.name, err)
+	}
+	var bytes int64
+	for _, result := range results {
+		if length, ok := result.(*redis.IntCmd); ok {
+			bytes += length.Val()
+		}
+	}
+	return len(keys), bytes
+}
+
+// keys lists the Redis keys of the entries
+func (r *remoteCache) keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	iterator := r.cluster.client.Scan(ctx, 0, r.cluster.key(r.entry("*")), 100).Iterator()
+	for iterator.Next(ctx) {
+		keys = append(keys, iterator.Val())
+	}
+	if err := iterator.Err(); err != nil {
+		logrus.Warnf("Failed to list cache %s in Redis: %v", r.name, err)
+		return nil, err
+	}
+	return keys, nil
+}
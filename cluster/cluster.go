@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// requestTimeout bounds each Redis request, so that an unreachable server slows the application down
+// without blocking it
+const requestTimeout = 5 * time.Second
+
+// Cluster coordinates the replicas of the application sharing a Redis server: it holds their shared caches,
+// serializes their scans with locks, and carries messages between them. Every key starts with the prefix.
+type Cluster struct {
+	client *redis.Client
+	prefix string
+	// id identifies this replica in the locks it holds
+	id string
+
+	mutex         sync.Mutex
+	subscriptions []*redis.PubSub
+}
+
+// Connect connects to the Redis server of a URL, such as redis://:password@redis:6379/0
+func Connect(url, prefix string) (*Cluster, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %v", err)
+	}
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to reach Redis at %s: %v", options.Addr, err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to generate replica ID: %v", err)
+	}
+	return &Cluster{client: client, prefix: prefix, id: hex.EncodeToString(id)}, nil
+}
+
+// String describes the Redis server, without credentials
+func (c *Cluster) String() string {
+	return fmt.Sprintf("Redis %s", c.client.Options().Addr)
+}
+
+// Close stops the subscriptions and closes the connections to Redis
+func (c *Cluster) Close() error {
+	c.mutex.Lock()
+	for _, subscription := range c.subscriptions {
+		subscription.Close()
+	}
+	c.subscriptions = nil
+	c.mutex.Unlock()
+	return c.client.Close()
+}
+
+// key returns the Redis key of a name
+func (c *Cluster) key(name string) string {
+	return c.prefix + name
+}
+
+// Get returns a value, found being false when it does not exist
+func (c *Cluster) Get(name string) (value []byte, found bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	value, err = c.client.Get(ctx, c.key(name)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set replaces a value, kept for ttl or until deleted when ttl is 0
+func (c *Cluster) Set(name string, value []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.client.Set(ctx, c.key(name), value, ttl).Err()
+}
+
+// Delete removes a value and returns whether it existed
+func (c *Cluster) Delete(name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	deleted, err := c.client.Del(ctx, c.key(name)).Result()
+	return deleted > 0, err
+}
+
+// Increment adds 1 to a counter, 0 when it does not exist, and returns its new value
+func (c *Cluster) Increment(name string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.client.Incr(ctx, c.key(name)).Result()
+}
+
+// Publish sends a message to the replicas subscribed to a channel, including this one
+func (c *Cluster) Publish(channel, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.client.Publish(ctx, c.key(channel), message).Err()
+}
+
+// Subscribe calls handle with every message published to a channel, until Close
+func (c *Cluster) Subscribe(channel string, handle func(message string)) {
+	subscription := c.client.Subscribe(context.Background(), c.key(channel))
+	c.mutex.Lock()
+	c.subscriptions = append(c.subscriptions, subscription)
+	c.mutex.Unlock()
+
+	go func() {
+		// The channel is closed by Close, and reconnects are handled by the client
+		for message := range subscription.Channel() {
+			handle(message.Payload)
+		}
+		logrus.Debugf("Subscription to %s closed", channel)
+	}()
+}
@@ -0,0 +1,23 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireScript takes a lease nobody holds, or extends it for its holder
+var acquireScript = redis.NewScript(`local holder = redis.call("GET", KEYS[1])
+if holder == false then return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2]) and 1 end
+if holder == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end
+return 0`)
+
+// AcquireLease takes the lease of a name for ttl, or extends it when this replica holds it, and reports whether
+// this replica holds it
+func (c *Cluster) AcquireLease(name string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	held, err := acquireScript.Run(ctx, c.client, []string{c.key("lease:" + name)}, c.id, ttl.Milliseconds()).Int()
+	return held == 1, err
+}
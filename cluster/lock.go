@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// lockTTL is how long the lock of a replica which stopped without releasing it is held
+	lockTTL = 30 * time.Second
+	// lockPollInterval is how often a replica waiting for a lock tries to take it
+	lockPollInterval = 500 * time.Millisecond
+)
+
+var (
+	// renewScript extends a lock while it is held by the token
+	renewScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+	// unlockScript releases a lock while it is held by the token, leaving a lock taken since it expired
+	unlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+)
+
+// Lock waits until the lock of a name is taken, or until ctx is done. The lock is held until unlock is called,
+// and released lockTTL after the replica stopped otherwise.
+func (c *Cluster) Lock(ctx context.Context, name string) (unlock func(), err error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate lock token: %v", err)
+	}
+	key, token := c.key("lock:"+name), c.id+"-"+hex.EncodeToString(random)
+
+	for {
+		taken, err := c.client.SetNX(ctx, key, token, lockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to take lock %s: %v", name, err)
+		}
+		if taken {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	stop := make(chan struct{})
+	go c.renew(key, token, stop)
+	return func() {
+		close(stop)
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		if err := unlockScript.Run(ctx, c.client, []string{key}, token).Err(); err != nil {
+			logrus.Warnf("Failed to release lock %s, it expires in %s: %v", name, lockTTL, err)
+		}
+	}, nil
+}
+
+// renew extends a lock until stop is closed
+func (c *Cluster) renew(key, token string, stop chan struct{}) {
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		renewed, err := renewScript.Run(ctx, c.client, []string{key}, token, lockTTL.Milliseconds()).Int()
+		cancel()
+		if err != nil || renewed == 0 {
+			logrus.Warnf("Failed to renew lock %s, another replica may take it: %v", key, err)
+		}
+	}
+}
+
+// Locked checks if a replica holds the lock of a name
+func (c *Cluster) Locked(name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	exists, err := c.client.Exists(ctx, c.key("lock:"+name)).Result()
+	return exists > 0, err
+}
@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/dedupe"
+	"jellyfin-duplicate/pkg/dedupe/simulation"
+	"jellyfin-duplicate/pkg/humanize"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// BenchReport is the result of the bench command, for each generated library size
+type BenchReport struct {
+	Libraries []BenchLibrary `json:"libraries"`
+}
+
+// BenchLibrary is a generated library and the measurements of every configuration on it
+type BenchLibrary struct {
+	Movies       int                      `json:"movies"`
+	Items        int                      `json:"items"`
+	Duplicates   int                      `json:"duplicates"`
+	Mismatches   int                      `json:"mismatches"`
+	Measurements []simulation.Measurement `json:"measurements"`
+}
+
+// RunBench generates synthetic libraries whose duplicates are known and measures the runtime, memory,
+// precision and recall of the detection with every combination of the given thresholds, year tolerances
+// and groupings, to guide their tuning. It returns the process exit code.
+func RunBench(args []string) int {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	moviesFlag := flags.String("movies", "1000,10000", "comma-separated numbers of movies of the generated libraries")
+	duplicateRate := flags.Float64("duplicates", 0.2, "share of movies with a second copy, between 0 and 1")
+	mismatchRate := flags.Float64("mismatches", 0.05, "share of movies with another movie sharing their name, between 0 and 1")
+	seed := flags.Uint64("seed", 1, "seed of the generated libraries, the same seed generating the same libraries")
+	thresholdsFlag := flags.String("thresholds", "85,90,95,98", "comma-separated duplicate thresholds, in percent")
+	toleranceFlag := flags.String("year-tolerance", "0,1", "comma-separated year tolerances")
+	groupingFlag := flags.String("grouping", "name,folder", "comma-separated groupings, name or folder")
+	runs := flags.Int("runs", 3, "runs of each configuration, the median duration being reported")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	movieCounts, err := parseIntList(*moviesFlag, 1, 10_000_000)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --movies: %v\n", err)
+		return 2
+	}
+	thresholds, err := parseIntList(*thresholdsFlag, 1, 100)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --thresholds: %v\n", err)
+		return 2
+	}
+	tolerances, err := parseIntList(*toleranceFlag, 0, 10)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --year-tolerance: %v\n", err)
+		return 2
+	}
+	var groupings []constants.Grouping
+	for _, value := range strings.Split(*groupingFlag, ",") {
+		grouping := constants.Grouping(strings.TrimSpace(value))
+		if !constants.IsValidGrouping(grouping) {
+			fmt.Fprintf(os.Stderr, "Invalid --grouping: %s, must be %s or %s\n", grouping, constants.NameGrouping, constants.FolderGrouping)
+			return 2
+		}
+		groupings = append(groupings, grouping)
+	}
+	if *duplicateRate < 0 || *duplicateRate > 1 || *mismatchRate < 0 || *mismatchRate > 1 {
+		fmt.Fprintln(os.Stderr, "Invalid rates: --duplicates and --mismatches must be between 0 and 1")
+		return 2
+	}
+
+	configurations := benchConfigurations(thresholds, tolerances, groupings)
+	var report BenchReport
+	for _, movies := range movieCounts {
+		library := simulation.Generate(simulation.LibraryOptions{
+			Movies:        movies,
+			DuplicateRate: *duplicateRate,
+			MismatchRate:  *mismatchRate,
+			Seed:          *seed,
+		})
+		result := BenchLibrary{
+			Movies:     movies,
+			Items:      len(library.Items),
+			Duplicates: len(library.Duplicates),
+			Mismatches: library.Mismatches,
+		}
+		for _, configuration := range configurations {
+			result.Measurements = append(result.Measurements, simulation.Measure(library, configuration, *runs))
+		}
+		report.Libraries = append(report.Libraries, result)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	printBenchReport(report)
+	return 0
+}
+
+// benchConfigurations combines every threshold, year tolerance and grouping
+func benchConfigurations(thresholds, tolerances []int, groupings []constants.Grouping) []simulation.Configuration {
+	var configurations []simulation.Configuration
+	for _, grouping := range groupings {
+		groupKey := dedupe.Item.GroupKey
+		if grouping == constants.FolderGrouping {
+			groupKey = dedupe.FolderGroupKey
+		}
+		for _, tolerance := range tolerances {
+			for _, threshold := range thresholds {
+				configurations = append(configurations, simulation.Configuration{
+					Name: fmt.Sprintf("grouping=%s year_tolerance=%d threshold=%d", grouping, tolerance, threshold),
+					Options: dedupe.Options{
+						DuplicateThreshold: threshold,
+						YearTolerance:      tolerance,
+						GroupKey:           groupKey,
+					},
+				})
+			}
+		}
+	}
+	return configurations
+}
+
+// parseIntList parses comma-separated integers between low and high
+func parseIntList(value string, low, high int) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(value, ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || number < low || number > high {
+			return nil, fmt.Errorf("%q must be a number between %d and %d", field, low, high)
+		}
+		values = append(values, number)
+	}
+	return values, nil
+}
+
+func printBenchReport(report BenchReport) {
+	fmt.Println("Detection benchmark")
+	fmt.Println("===================")
+	for _, library := range report.Libraries {
+		fmt.Printf("\n%d movies: %d items, %d duplicates, %d movies sharing a name\n",
+			library.Movies, library.Items, library.Duplicates, library.Mismatches)
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "  CONFIGURATION\tDURATION\tALLOCATED\tPAIRS\tPRECISION\tRECALL\tFALSE +\tFALSE -")
+		for _, measurement := range library.Measurements {
+			fmt.Fprintf(writer, "  %s\t%s\t%s\t%d\t%.1f%%\t%.1f%%\t%d\t%d\n", measurement.Configuration,
+				measurement.Duration.Round(10_000), humanize.Bytes(int64(measurement.Allocated)), measurement.Pairs,
+				measurement.Precision*100, measurement.Recall*100, measurement.FalsePositives, measurement.FalseNegatives)
+		}
+		writer.Flush()
+	}
+}
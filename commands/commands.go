@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"fmt"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"os"
+	"runtime"
+)
+
+const usage = `Usage: jellyfin-duplicate [command]
+
+Without command, the web server is started. With --demo, it serves a generated library
+instead of the Jellyfin server, to try the application.
+
+Commands:
+  version                   Print the version, commit and build date of the application
+  config validate           Check the configuration against the Jellyfin server
+  jellyfin connect          Authorize the application with a Jellyfin Quick Connect code,
+                            instead of an API key
+  servers compare [--json]  Compare the movies and play status of the Jellyfin server
+                            with the secondary server, to follow a migration
+  scan convert <file>       Print a scan result saved by an older version, or a page
+                            of /api/duplicates, in the current scan result schema
+  bench [options]           Measure the runtime, memory, precision and recall of the
+                            detection on generated libraries, for several thresholds
+                            (bench --help lists the options)
+  storage migrate [--force] Copy the JSON files of the data directory to the SQLite or
+                            Postgres database of storage.backend
+`
+
+// Run dispatches command line arguments to the matching command and returns the process exit code
+func Run(args []string) int {
+	switch {
+	case len(args) >= 1 && (args[0] == "version" || args[0] == "--version"):
+		return RunVersion()
+	case len(args) >= 2 && args[0] == "config" && args[1] == "validate":
+		return RunConfigValidate()
+	case len(args) >= 2 && args[0] == "jellyfin" && args[1] == "connect":
+		return RunJellyfinConnect()
+	case len(args) >= 2 && args[0] == "servers" && args[1] == "compare":
+		return RunServersCompare(args[2:])
+	case len(args) >= 2 && args[0] == "scan" && args[1] == "convert":
+		return RunScanConvert(args[2:])
+	case len(args) >= 1 && args[0] == "bench":
+		return RunBench(args[1:])
+	case len(args) >= 2 && args[0] == "storage" && args[1] == "migrate":
+		return RunStorageMigrate(args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+// RunVersion prints the version of the application and the platform it was built for
+func RunVersion() int {
+	fmt.Printf("jellyfin-duplicate %s %s %s/%s\n", constants.VersionString(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return 0
+}
+
+// newJellyfinClient creates a client of a Jellyfin server, presenting the configured device identity
+func newJellyfinClient(config *confModels.Config, server confModels.JellyfinConfig) *jellyfinClients.Client {
+	return jellyfinClients.NewClient(server.URL, server.APIKey, server.UserID, jellyfinClients.Identity{
+		Client:   config.Device.Client,
+		Device:   config.Device.Name,
+		DeviceID: config.Device.ID,
+		Version:  constants.Version,
+	})
+}
@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	traktClients "jellyfin-duplicate/client/trakt/http"
+	confModels "jellyfin-duplicate/configuration/models"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/filesystem"
+	"jellyfin-duplicate/notifications"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// ValidationCheck is the result of a single configuration check
+type ValidationCheck struct {
+	Name    string
+	Passed  bool
+	Message string
+}
+
+// ValidationReport groups every check run by the validate command
+type ValidationReport struct {
+	Checks []ValidationCheck
+}
+
+func (r *ValidationReport) add(name string, passed bool, format string, args ...any) {
+	r.Checks = append(r.Checks, ValidationCheck{
+		Name:    name,
+		Passed:  passed,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Failed returns the number of failed checks
+func (r *ValidationReport) Failed() int {
+	failed := 0
+	for _, check := range r.Checks {
+		if !check.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Print writes the report to stdout
+func (r *ValidationReport) Print() {
+	fmt.Println("Configuration validation report")
+	fmt.Println("===============================")
+	for _, check := range r.Checks {
+		status := "PASS"
+		if !check.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("[%s] %-22s %s\n", status, check.Name, check.Message)
+	}
+	fmt.Printf("\n%d checks, %d passed, %d failed\n", len(r.Checks), len(r.Checks)-r.Failed(), r.Failed())
+}
+
+// RunConfigValidate loads the configuration, checks it against the Jellyfin server
+// and returns the process exit code (0 when every check passed, 1 otherwise)
+func RunConfigValidate() int {
+	// Keep the report readable, only warnings and errors are logged
+	logrus.SetLevel(logrus.WarnLevel)
+
+	report := &ValidationReport{}
+
+	config, err := confServices.LoadConfig()
+	if err != nil {
+		report.add("Configuration", false, "failed to load config: %v", err)
+		report.Print()
+		return 1
+	}
+	report.add("Configuration", true, "loaded for %s environment", config.Environment)
+
+	validateJellyfin(report, config)
+	validateDeletion(report, config)
+	validateTrakt(report, config)
+	validateSecondaryJellyfin(report, config)
+	validateNotifications(report, config.Notifications)
+
+	report.Print()
+	if report.Failed() > 0 {
+		return 1
+	}
+	return 0
+}
+
+func validateJellyfin(report *ValidationReport, config *confModels.Config) {
+	if err := confServices.CheckJellyfinCredentials(config); err != nil {
+		report.add("Jellyfin credentials", false, "%v", err)
+		return
+	}
+
+	client := newJellyfinClient(config, config.Jellyfin)
+
+	// Connectivity does not require any authentication
+	publicInfo, err := client.GetPublicSystemInfo()
+	if err != nil {
+		report.add("Jellyfin connectivity", false, "%s is not reachable: %v", config.Jellyfin.URL, err)
+		// Every other check depends on the server being reachable
+		return
+	}
+	report.add("Jellyfin connectivity", true, "%s reachable (%s %s)", config.Jellyfin.URL, publicInfo.ServerName, publicInfo.Version)
+
+	// Following requests depend on the server version
+	if version, err := client.DetectServerVersion(); err != nil {
+		report.add("Server version", false, "%v", err)
+	} else {
+		report.add("Server version", true, "Jellyfin %s", version)
+	}
+
+	// An invalid API key is rejected by the authenticated system endpoint
+	if _, err := client.GetSystemInfo(); err != nil {
+		report.add("API key", false, "API key rejected: %v", err)
+		return
+	}
+	report.add("API key", true, "API key accepted")
+
+	// Listing all users requires an administrator scoped key
+	users, err := client.GetAllUsers()
+	if err != nil {
+		report.add("API key scope", false, "API key cannot list users, an administrator key is required: %v", err)
+	} else {
+		report.add("API key scope", true, "API key can list %d users", len(users))
+	}
+
+	// The configured user must exist and be an administrator to delete items
+	user, err := client.GetUser(config.Jellyfin.UserID)
+	if err != nil {
+		report.add("Admin user ID", false, "user %s not found: %v", config.Jellyfin.UserID, err)
+		return
+	}
+	if !user.Policy.IsAdministrator {
+		report.add("Admin user ID", false, "user %s (%s) is not an administrator", user.Name, user.ID)
+	} else {
+		report.add("Admin user ID", true, "user %s (%s) is an administrator", user.Name, user.ID)
+	}
+
+	// The other operators are only used when the admin user fails, they are checked the same way
+	for _, operatorID := range config.Jellyfin.OperatorIDs {
+		operator, err := client.GetUser(operatorID)
+		if err != nil {
+			report.add("Operator user ID", false, "user %s not found: %v", operatorID, err)
+		} else if !operator.Policy.IsAdministrator {
+			report.add("Operator user ID", false, "user %s (%s) is not an administrator", operator.Name, operator.ID)
+		} else {
+			report.add("Operator user ID", true, "user %s (%s) is an administrator", operator.Name, operator.ID)
+		}
+	}
+
+	// The admin user must be able to see at least one library
+	libraries, err := client.GetLibraries()
+	if err != nil {
+		report.add("Libraries", false, "failed to list libraries: %v", err)
+	} else if len(libraries) == 0 {
+		report.add("Libraries", false, "no library is visible to user %s", user.Name)
+	} else {
+		report.add("Libraries", true, "%d libraries visible to user %s", len(libraries), user.Name)
+		validateLibraryFilter(report, config.Scan.Libraries, libraries)
+	}
+}
+
+// validateLibraryFilter reports the names of scan.libraries matching no library, which are not scanned
+func validateLibraryFilter(report *ValidationReport, names []string, libraries []jellyfinModels.Library) {
+	if len(names) == 0 {
+		return
+	}
+
+	var unknown []string
+	for _, name := range names {
+		found := lo.ContainsBy(libraries, func(library jellyfinModels.Library) bool {
+			return strings.EqualFold(library.Name, name)
+		})
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		available := lo.Map(libraries, func(library jellyfinModels.Library, _ int) string { return library.Name })
+		report.add("Libraries", false, "scan.libraries lists unknown libraries %s, available: %s",
+			strings.Join(unknown, ", "), strings.Join(available, ", "))
+	} else {
+		report.add("Libraries", true, "scan.libraries: %s", strings.Join(names, ", "))
+	}
+}
+
+func validateDeletion(report *ValidationReport, config *confModels.Config) {
+	if config.Deletion.Backend != constants.FilesystemDeletion {
+		report.add("Deletion backend", true, "items are deleted through the Jellyfin API")
+	} else if err := filesystem.NewTrash(config.Deletion.TrashDir).CheckWritable(); err != nil {
+		report.add("Trash directory", false, "%v", err)
+	} else {
+		report.add("Trash directory", true, "%s is writable", config.Deletion.TrashDir)
+	}
+
+	// Path mappings are only used when this application accesses the media files
+	if config.Deletion.Backend != constants.FilesystemDeletion && !config.Deletion.TransferSidecars {
+		return
+	}
+
+	// Every mapped local root must be mounted in the container
+	for _, mapping := range config.Deletion.PathMappings {
+		if _, err := os.Stat(mapping.Local); err != nil {
+			report.add("Path mapping", false, "%s -> %s: local path not accessible: %v", mapping.Jellyfin, mapping.Local, err)
+		} else {
+			report.add("Path mapping", true, "%s -> %s", mapping.Jellyfin, mapping.Local)
+		}
+	}
+}
+
+func validateTrakt(report *ValidationReport, config *confModels.Config) {
+	if !config.Trakt.Enabled() {
+		return
+	}
+
+	client := traktClients.NewClient(config.Trakt.ClientID, config.Trakt.AccessToken)
+	watched, err := client.GetWatchedMovies()
+	if err != nil {
+		report.add("Trakt", false, "failed to read watched history: %v", err)
+	} else {
+		report.add("Trakt", true, "%d watched movies, compared with Jellyfin user %s", len(watched), config.Trakt.JellyfinUserID)
+	}
+}
+
+func validateSecondaryJellyfin(report *ValidationReport, config *confModels.Config) {
+	if !config.SecondaryJellyfin.Configured() {
+		return
+	}
+
+	client := newJellyfinClient(config, config.SecondaryJellyfin)
+	if _, err := client.GetSystemInfo(); err != nil {
+		report.add("Secondary server", false, "%s: %v", config.SecondaryJellyfin.URL, err)
+	} else {
+		report.add("Secondary server", true, "%s reachable, API key accepted", config.SecondaryJellyfin.URL)
+	}
+}
+
+// validateNotifications checks that every webhook URL is valid and that its server answers. Empty severity
+// webhooks mute their events, they are not checked.
+func validateNotifications(report *ValidationReport, config confModels.NotificationsConfig) {
+	webhooks := map[string]string{}
+	if config.WebhookURL != "" {
+		webhooks["webhook_url"] = config.WebhookURL
+	}
+	for severity, webhookURL := range config.SeverityWebhooks {
+		if webhookURL != "" {
+			webhooks[fmt.Sprintf("severity_webhooks.%s", severity)] = webhookURL
+		}
+	}
+	for user, webhookURL := range config.UserWebhooks {
+		webhooks["user_webhooks."+user] = webhookURL
+	}
+
+	// Sorted, so that the report is the same on every run
+	names := lo.Keys(webhooks)
+	sort.Strings(names)
+	for _, name := range names {
+		if err := notifications.CheckWebhook(webhooks[name]); err != nil {
+			report.add("Notifications", false, "%s: %v", name, err)
+		} else {
+			report.add("Notifications", true, "%s reachable", name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateLibraryFilter(t *testing.T) {
+	libraries := []jellyfinModels.Library{{ID: "1", Name: "Movies"}, {ID: "2", Name: "Kids"}}
+
+	report := &ValidationReport{}
+	validateLibraryFilter(report, nil, libraries)
+	if len(report.Checks) != 0 {
+		t.Errorf("Expected no check without scan.libraries, got %v", report.Checks)
+	}
+
+	report = &ValidationReport{}
+	validateLibraryFilter(report, []string{"movies", "Kids"}, libraries)
+	if len(report.Checks) != 1 || !report.Checks[0].Passed {
+		t.Errorf("Expected the known libraries to pass, compared case-insensitively, got %v", report.Checks)
+	}
+
+	report = &ValidationReport{}
+	validateLibraryFilter(report, []string{"Movies", "Documentaries"}, libraries)
+	if len(report.Checks) != 1 || report.Checks[0].Passed || !strings.Contains(report.Checks[0].Message, "Documentaries") {
+		t.Errorf("Expected the unknown library to be reported, got %v", report.Checks)
+	}
+}
+
+func TestValidateNotifications(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Webhooks usually only accept the POST requests of the events
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer webhook.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	report := &ValidationReport{}
+	validateNotifications(report, confModels.NotificationsConfig{
+		WebhookURL: webhook.URL + "/hooks/secret-token",
+		SeverityWebhooks: map[constants.Severity]string{
+			constants.ExactSeverity:    "ftp://example.com/hook",
+			constants.ProbableSeverity: "",
+		},
+		UserWebhooks: map[string]string{"alice": closed.URL + "/hooks/secret-token"},
+	})
+
+	passed := map[string]bool{}
+	for _, check := range report.Checks {
+		if strings.Contains(check.Message, "secret-token") {
+			t.Errorf("The webhook token is reported: %s", check.Message)
+		}
+		passed[strings.SplitN(check.Message, ":", 2)[0]] = check.Passed
+	}
+	expected := map[string]bool{
+		"webhook_url reachable":                                true,
+		"severity_webhooks." + string(constants.ExactSeverity): false,
+		"user_webhooks.alice":                                  false,
+	}
+	if len(passed) != len(expected) {
+		t.Fatalf("Expected %d checks, got %v", len(expected), report.Checks)
+	}
+	for name, want := range expected {
+		if got, found := passed[name]; !found || got != want {
+			t.Errorf("Expected %s to pass: %t, got %v", name, want, report.Checks)
+		}
+	}
+}
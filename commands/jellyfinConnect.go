@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	confModels "jellyfin-duplicate/configuration/models"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// quickConnectPollInterval is how often the approval of the Quick Connect code is checked
+	quickConnectPollInterval = 5 * time.Second
+	// quickConnectTimeout is how long the code can be approved, Jellyfin forgets it after 10 minutes
+	quickConnectTimeout = 10 * time.Minute
+)
+
+// RunJellyfinConnect authorizes the application through Quick Connect: a code is shown, and once an administrator
+// approves it from the Jellyfin interface, the issued access token is stored in the data directory.
+// It returns the process exit code.
+func RunJellyfinConnect() int {
+	// Keep the instructions readable, only warnings and errors are logged
+	logrus.SetLevel(logrus.WarnLevel)
+
+	config, err := confServices.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+
+	if config.Jellyfin.URL == "" {
+		fmt.Fprintf(os.Stderr, "%s is not set\n", constants.EnvJellyfinURL)
+		return 1
+	}
+
+	// The requests are not authenticated, the token is issued for the device identity of the application
+	client := newJellyfinClient(config, confModels.JellyfinConfig{URL: config.Jellyfin.URL})
+	if _, err := client.DetectServerVersion(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach %s: %v\n", config.Jellyfin.URL, err)
+		return 1
+	}
+
+	enabled, err := client.IsQuickConnectEnabled()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to check Quick Connect: %v\n", err)
+		return 1
+	}
+	if !enabled {
+		fmt.Fprintln(os.Stderr, "Quick Connect is disabled: enable it in the Jellyfin dashboard (General > Quick Connect), or set "+
+			constants.EnvJellyfinAPIKey)
+		return 1
+	}
+
+	request, err := client.InitiateQuickConnect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initiate Quick Connect: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Quick Connect code: %s\n\n", request.Code)
+	fmt.Printf("Sign in to %s as an administrator, open Quick Connect from the user menu and enter the code.\n", config.Jellyfin.URL)
+	fmt.Printf("Waiting for approval (%s at most)...\n", quickConnectTimeout)
+
+	deadline := time.Now().Add(quickConnectTimeout)
+	for !request.Authenticated {
+		if time.Now().After(deadline) {
+			fmt.Fprintln(os.Stderr, "The code was not approved in time, run the command again for a new one")
+			return 1
+		}
+		time.Sleep(quickConnectPollInterval)
+
+		request, err = client.GetQuickConnectState(request.Secret)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to check the approval of the code: %v\n", err)
+			return 1
+		}
+	}
+
+	authentication, err := client.AuthenticateWithQuickConnect(request.Secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get an access token: %v\n", err)
+		return 1
+	}
+	// Listing users and deleting items require an administrator
+	if !authentication.User.Policy.IsAdministrator {
+		fmt.Fprintf(os.Stderr, "%s is not an administrator, approve the code with an administrator account\n", authentication.User.Name)
+		return 1
+	}
+
+	err = confServices.SaveJellyfinCredentials(config.DataDir, confModels.JellyfinCredentials{
+		Method:       constants.QuickConnectAuth,
+		ServerURL:    config.Jellyfin.URL,
+		AccessToken:  authentication.AccessToken,
+		UserID:       authentication.User.ID,
+		UserName:     authentication.User.Name,
+		AuthorizedAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to store the access token: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\nAuthorized by %s, the access token is stored in %s.\n", authentication.User.Name, confServices.CredentialsPath(config.DataDir))
+	fmt.Printf("%s and %s can be left unset from now on.\n", constants.EnvJellyfinAPIKey, constants.EnvJellyfinAdminUserID)
+	return 0
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"jellyfin-duplicate/schema"
+	"os"
+)
+
+// RunScanConvert reads a scan result saved by any version, or a page of /api/duplicates, and prints it
+// in the current schema. It returns the process exit code.
+func RunScanConvert(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: jellyfin-duplicate scan convert <file>")
+		return 2
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", args[0], err)
+		return 1
+	}
+
+	result, err := schema.Decode(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to convert %s: %v\n", args[0], err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write scan result: %v\n", err)
+		return 1
+	}
+	return 0
+}
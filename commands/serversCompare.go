@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/server"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunServersCompare compares the movies of the Jellyfin server with the secondary server and prints
+// the report, as text or as JSON with --json. It returns the process exit code.
+func RunServersCompare(args []string) int {
+	asJSON := len(args) > 0 && args[0] == "--json"
+
+	// Keep the report readable, only warnings and errors are logged
+	logrus.SetLevel(logrus.WarnLevel)
+
+	config, err := confServices.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if err := confServices.CheckJellyfinCredentials(config); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid Jellyfin credentials: %v\n", err)
+		return 1
+	}
+	if !config.SecondaryJellyfin.Configured() {
+		fmt.Fprintf(os.Stderr, "The secondary server is not configured: set %s and %s\n",
+			constants.EnvSecondaryJellyfinURL, constants.EnvSecondaryJellyfinAPIKey)
+		return 1
+	}
+
+	primary := newJellyfinClient(config, config.Jellyfin)
+	secondary := newJellyfinClient(config, config.SecondaryJellyfin)
+	if _, err := primary.DetectServerVersion(); err != nil {
+		logrus.Warnf("Failed to detect primary server version: %v", err)
+	}
+	if _, err := secondary.DetectServerVersion(); err != nil {
+		logrus.Warnf("Failed to detect secondary server version: %v", err)
+	}
+
+	comparison, err := server.CompareServers(primary, secondary)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to compare servers: %v\n", err)
+		return 1
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		if err := encoder.Encode(comparison); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	printServerComparison(comparison, config.Jellyfin.URL, config.SecondaryJellyfin.URL)
+	return 0
+}
+
+func printServerComparison(comparison server.ServerComparison, primaryURL, secondaryURL string) {
+	fmt.Println("Server comparison report")
+	fmt.Println("========================")
+	fmt.Printf("Primary:   %s\nSecondary: %s\n", primaryURL, secondaryURL)
+
+	fmt.Printf("\nOnly on the primary server (%d)\n", len(comparison.OnlyPrimary))
+	for _, movie := range comparison.OnlyPrimary {
+		fmt.Printf("  %s (%d)  %s\n", movie.Name, movie.ProductionYear, movie.Path)
+	}
+
+	fmt.Printf("\nOnly on the secondary server (%d)\n", len(comparison.OnlySecondary))
+	for _, movie := range comparison.OnlySecondary {
+		fmt.Printf("  %s (%d)  %s\n", movie.Name, movie.ProductionYear, movie.Path)
+	}
+
+	differences := 0
+	fmt.Println("\nPlay status differences")
+	for _, match := range comparison.Both {
+		for _, status := range match.PlayStatusDifferences {
+			differences++
+			fmt.Printf("  %s (%d): %s has %s\n", match.Primary.Name, match.Primary.ProductionYear, status.UserName,
+				playedOn(status))
+		}
+	}
+
+	fmt.Printf("\n%d movies on both servers, %d only on the primary, %d only on the secondary, %d play status differences\n",
+		len(comparison.Both), len(comparison.OnlyPrimary), len(comparison.OnlySecondary), differences)
+}
+
+func playedOn(status server.CrossServerPlayStatus) string {
+	if status.PlayedOnPrimary {
+		return "seen it on the primary server only"
+	}
+	return "seen it on the secondary server only"
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/storage"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunStorageMigrate copies the JSON files of the data directory to the configured storage backend, to move an
+// existing installation to SQLite or Postgres. It returns the process exit code.
+func RunStorageMigrate(args []string) int {
+	flags := flag.NewFlagSet("storage migrate", flag.ContinueOnError)
+	force := flags.Bool("force", false, "replace the documents already stored by the backend")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Keep the output readable, only warnings and errors are logged
+	logrus.SetLevel(logrus.WarnLevel)
+
+	config, err := confServices.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if config.Storage.Backend == constants.FileStorage {
+		fmt.Fprintf(os.Stderr, "storage.backend is %s, set it to %s or %s to migrate\n", constants.FileStorage,
+			constants.SQLiteStorage, constants.PostgresStorage)
+		return 1
+	}
+
+	from, err := storage.OpenBackend(constants.FileStorage, "", config.DataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open %s: %v\n", config.DataDir, err)
+		return 1
+	}
+	defer from.Close()
+	to, err := storage.OpenBackend(config.Storage.Backend, config.Storage.DSN, config.DataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open storage: %v\n", err)
+		return 1
+	}
+	defer to.Close()
+
+	copied, err := storage.Migrate(from, to, *force)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		return 1
+	}
+	if len(copied) == 0 {
+		fmt.Printf("Nothing to copy from %s to %s, the documents are missing or already stored (--force replaces them)\n", from, to)
+		return 0
+	}
+	fmt.Printf("Copied %s from %s to %s\n", strings.Join(copied, ", "), from, to)
+	return 0
+}
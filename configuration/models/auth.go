@@ -0,0 +1,88 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+
+	"github.com/samber/lo"
+)
+
+type AuthConfig struct {
+	// DiscoveryURL is the OpenID Connect discovery document of the provider, such as
+	// https://auth.example.com/.well-known/openid-configuration. Login is disabled when empty.
+	DiscoveryURL string `json:"discovery_url"`
+	ClientID     string `json:"client_id"`
+	// ClientSecret is read from the environment
+	ClientSecret string `json:"-"`
+	// RedirectURL is the callback registered with the provider, ending with /auth/callback
+	RedirectURL string `json:"redirect_url"`
+	// Scopes are requested besides openid, "profile", "email" and "groups" by default
+	Scopes []string `json:"scopes"`
+	// GroupsClaim is the ID token claim listing the groups of the user, "groups" by default
+	GroupsClaim string `json:"groups_claim"`
+	// AdminGroups may run every action, ViewerGroups may only browse the results.
+	// Every signed in user is a viewer when ViewerGroups is empty.
+	AdminGroups  []string `json:"admin_groups"`
+	ViewerGroups []string `json:"viewer_groups"`
+	// SessionDuration is the number of minutes a login lasts, 12 hours by default
+	SessionDuration int `json:"session_duration"`
+	// SessionSecret signs the session cookies, read from the environment.
+	// A random one is generated when empty, signing users out on restart.
+	SessionSecret string `json:"-"`
+	// LoginRateLimit is the number of sign in attempts per minute of a client address, 30 by default
+	LoginRateLimit int `json:"login_rate_limit"`
+	// MaxFailedLogins failed attempts of a client address within FailedLoginWindow minutes lock it out for
+	// LockoutDuration minutes, 5 failures in 15 minutes locking out for 15 minutes by default. Failures are
+	// rejected sign ins, and calls with a wrong bearer token or setup token.
+	MaxFailedLogins   int `json:"max_failed_logins"`
+	FailedLoginWindow int `json:"failed_login_window"`
+	LockoutDuration   int `json:"lockout_duration"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies whose X-Forwarded-For header gives
+	// the client address, none by default
+	TrustedProxies []string `json:"trusted_proxies"`
+	// TrustedHeader is the header in which an authenticating reverse proxy, such as Authelia or authentik, gives
+	// the name of the signed in user, e.g. "Remote-User" or "X-Forwarded-User". Login is delegated to the proxy
+	// when set, and the header is only accepted from the TrustedProxies.
+	TrustedHeader string `json:"trusted_header"`
+	// GroupsHeader is the header listing the comma separated groups of the user, e.g. "Remote-Groups", mapped
+	// to a role with AdminGroups and ViewerGroups. Optional.
+	GroupsHeader string `json:"groups_header"`
+	// AdminUsers may run every action, ViewerUsers may only browse the results, when signed in by the proxy.
+	// Every user is a viewer when both ViewerUsers and ViewerGroups are empty.
+	AdminUsers  []string `json:"admin_users"`
+	ViewerUsers []string `json:"viewer_users"`
+	// LogoutURL is the page signing the user out of the proxy, the log out link is hidden when empty
+	LogoutURL string `json:"logout_url"`
+}
+
+// Enabled checks if login through an OpenID Connect provider is required
+func (c AuthConfig) Enabled() bool {
+	return c.DiscoveryURL != ""
+}
+
+// HeaderEnabled checks if users are signed in by an authenticating reverse proxy
+func (c AuthConfig) HeaderEnabled() bool {
+	return c.TrustedHeader != ""
+}
+
+// RoleForUser returns the role of a user signed in by the proxy from its name and groups, false when neither
+// is allowed
+func (c AuthConfig) RoleForUser(name string, groups []string) (constants.Role, bool) {
+	if lo.Contains(c.AdminUsers, name) || lo.Some(groups, c.AdminGroups) {
+		return constants.AdminRole, true
+	}
+	if (len(c.ViewerUsers) == 0 && len(c.ViewerGroups) == 0) || lo.Contains(c.ViewerUsers, name) || lo.Some(groups, c.ViewerGroups) {
+		return constants.ViewerRole, true
+	}
+	return "", false
+}
+
+// RoleForGroups returns the role of a user from its groups, false when none of them is allowed
+func (c AuthConfig) RoleForGroups(groups []string) (constants.Role, bool) {
+	if lo.Some(groups, c.AdminGroups) {
+		return constants.AdminRole, true
+	}
+	if len(c.ViewerGroups) == 0 || lo.Some(groups, c.ViewerGroups) {
+		return constants.ViewerRole, true
+	}
+	return "", false
+}
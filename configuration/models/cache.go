@@ -0,0 +1,16 @@
+package models
+
+import "jellyfin-duplicate/constants"
+
+// CacheConfig selects where the avatars and the latest scan result are cached, and how replicas sharing a
+// storage backend coordinate their scans and jobs
+type CacheConfig struct {
+	// Backend is memory or redis, memory when empty
+	Backend constants.CacheBackend `json:"backend"`
+	// URL is the connection URL of Redis, such as redis://:password@redis:6379/0. It is read from the
+	// environment, as it may hold a password.
+	URL string `json:"-"`
+	// KeyPrefix starts the Redis keys of the application, so that several applications can share a server,
+	// jellyfin-duplicate: when empty
+	KeyPrefix string `json:"key_prefix"`
+}
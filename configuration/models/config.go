@@ -7,6 +7,29 @@ import (
 type Config struct {
 	Environment constants.Environment `json:"environment"`
 	ServerPort  string                `json:"server_port"`
+	BasePath    string                `json:"base_path"`
+	DataDir     string                `json:"data_dir"`
 	Logrus      LogrusConfig          `json:"logrus"`
 	Jellyfin    JellyfinConfig        `json:"jellyfin"`
+	// SecondaryJellyfin is the server compared with the main one when migrating, read from the environment
+	SecondaryJellyfin JellyfinConfig      `json:"-"`
+	Deletion          DeletionConfig      `json:"deletion"`
+	Trakt             TraktConfig         `json:"trakt"`
+	Notifications     NotificationsConfig `json:"notifications"`
+	Device            DeviceConfig        `json:"device"`
+	Scan              ScanConfig          `json:"scan"`
+	Debug             DebugConfig         `json:"debug"`
+	EarlyWarning      EarlyWarningConfig  `json:"early_warning"`
+	Auth              AuthConfig          `json:"auth"`
+	// Listen are the addresses served, such as "127.0.0.1:8080" or "[::1]:8080", every interface on ServerPort when empty
+	Listen []string   `json:"listen"`
+	TLS    TLSConfig  `json:"tls"`
+	CORS   CORSConfig `json:"cors"`
+	// Storage selects where the state is persisted, JSON files in DataDir by default
+	Storage StorageConfig `json:"storage"`
+	// Cache selects where the avatars and the latest scan result are cached, in memory by default
+	Cache CacheConfig `json:"cache"`
+	// WidgetToken lets dashboard widgets read /api/widget with a bearer token instead of signing in, read from
+	// the environment
+	WidgetToken string `json:"-"`
 }
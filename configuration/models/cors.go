@@ -0,0 +1,22 @@
+package models
+
+// CORSConfig lets pages of other origins, such as dashboards or single page applications, call the JSON API from
+// the browser
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed, such as "https://dash.example.com", or "*" for every origin.
+	// Cross-origin requests are not allowed when empty.
+	AllowedOrigins []string `json:"allowed_origins"`
+	// AllowedMethods and AllowedHeaders are answered to preflight requests, GET, POST, PUT, PATCH and DELETE
+	// and the Authorization and Content-Type headers when empty
+	AllowedMethods []string `json:"allowed_methods"`
+	AllowedHeaders []string `json:"allowed_headers"`
+	// AllowCredentials lets the allowed origins send the session cookie, it cannot be combined with "*"
+	AllowCredentials bool `json:"allow_credentials"`
+	// MaxAge is the number of seconds browsers cache the answer to a preflight request, 600 by default
+	MaxAge int `json:"max_age"`
+}
+
+// Enabled checks if cross-origin requests are allowed
+func (c CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
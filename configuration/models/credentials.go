@@ -0,0 +1,19 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+	"time"
+)
+
+// JellyfinCredentials is an access token issued by Jellyfin through Quick Connect, or an API key entered in the
+// setup wizard, stored in the data directory and used when no API key is configured
+type JellyfinCredentials struct {
+	Method constants.JellyfinAuthMethod `json:"method"`
+	// ServerURL is the server which issued the token, the credentials are ignored for another server
+	ServerURL   string `json:"server_url"`
+	AccessToken string `json:"access_token"`
+	// UserID and UserName are the admin user, who approved the Quick Connect code when the token acts on its behalf
+	UserID       string    `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	AuthorizedAt time.Time `json:"authorized_at"`
+}
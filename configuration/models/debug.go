@@ -0,0 +1,10 @@
+package models
+
+type DebugConfig struct {
+	// Pprof exposes the Go profiling endpoints under /debug/pprof, protected by the admin token
+	Pprof bool `json:"pprof"`
+	// AdminToken is read from the environment
+	AdminToken string `json:"-"`
+	// MemoryLogInterval is the number of seconds between memory usage log lines during scans, 0 disables them
+	MemoryLogInterval int `json:"memory_log_interval"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+)
+
+type DeletionConfig struct {
+	Backend          constants.DeletionBackend `json:"backend"`
+	TrashDir         string                    `json:"trash_dir"`
+	PathMappings     []PathMapping             `json:"path_mappings"`
+	Refresh          constants.RefreshMode     `json:"refresh"`
+	TransferSidecars bool                      `json:"transfer_sidecars"`
+	RepointPlaylists bool                      `json:"repoint_playlists"`
+	// MinReclaimableSize in megabytes leaves pairs freeing less space without recommended deletion, 0 to disable
+	MinReclaimableSize int64 `json:"min_reclaimable_size"`
+	// MaintenanceWindow is the time of day scheduled deletions run at, instead of right away
+	MaintenanceWindow MaintenanceWindowConfig `json:"maintenance_window"`
+}
+
+// MaintenanceWindowConfig is a daily window of low usage, such as the night, for scheduled deletions
+type MaintenanceWindowConfig struct {
+	// Start is the time of day (HH:MM) the window opens, scheduling deletions is disabled when empty
+	Start string `json:"start"`
+	// End is the time of day (HH:MM) the window closes, possibly the next day. Scheduled deletions which could
+	// not start before it fail instead of running during the day. The window has no end when empty.
+	End string `json:"end"`
+	// Timezone is the IANA name of the timezone of Start and End, the system one when empty
+	Timezone string `json:"timezone"`
+}
+
+// Enabled checks if deletions can be scheduled
+func (c MaintenanceWindowConfig) Enabled() bool {
+	return c.Start != ""
+}
+
+// PathMapping translates a path as seen by Jellyfin into a path as seen by this application
+type PathMapping struct {
+	Jellyfin string `json:"jellyfin"`
+	Local    string `json:"local"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// DeviceConfig is the identity this application presents to Jellyfin, shown in its devices and dashboard
+type DeviceConfig struct {
+	Client string `json:"client"`
+	Name   string `json:"name"`
+	// ID is derived from the client and device names when empty
+	ID string `json:"id"`
+}
@@ -0,0 +1,14 @@
+package models
+
+type EarlyWarningConfig struct {
+	// Interval is the number of minutes between two checks of recently added movies, 0 disables them
+	Interval int `json:"interval"`
+	// Timezone is the IANA name of the timezone of StartAt and of the reported run times, the system one when empty
+	Timezone string `json:"timezone"`
+	// StartAt aligns the checks on a time of day (HH:MM): they start every day at that time, then run every Interval
+	StartAt string `json:"start_at"`
+	// Days is the age under which a movie is considered recently added, 7 by default
+	Days int `json:"days"`
+	// WebhookToken enables the Jellyfin webhook endpoint, read from the environment
+	WebhookToken string `json:"-"`
+}
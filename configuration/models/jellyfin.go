@@ -4,4 +4,11 @@ type JellyfinConfig struct {
 	URL    string
 	APIKey string
 	UserID string
+	// OperatorIDs are other admin users, tried in order when UserID cannot list the libraries or see an item
+	OperatorIDs []string
+}
+
+// Configured checks if the server URL and API key are set
+func (c JellyfinConfig) Configured() bool {
+	return c.URL != "" && c.APIKey != ""
 }
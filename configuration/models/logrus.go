@@ -5,4 +5,24 @@ type LogrusConfig struct {
 	Format        string `json:"format"`
 	DisableColors bool   `json:"disable_colors"`
 	ReportCaller  bool   `json:"report_caller"`
+	// DisableConsole stops writing logs to the standard error, e.g. when they are only written to a file
+	DisableConsole bool `json:"disable_console"`
+	// File writes the logs to a rotated file too, whatever the console format
+	File LogFileConfig `json:"file"`
+}
+
+// LogFileConfig describes the log file, disabled when Path is empty
+type LogFileConfig struct {
+	Path string `json:"path"`
+	// Format is "json" by default, one JSON object per line for Loki or ELK, or "text"
+	Format string `json:"format"`
+	// MaxSize is the size in megabytes from which the file is rotated, 100 by default
+	MaxSize int `json:"max_size"`
+	// MaxBackups is the number of rotated files kept, 5 by default, none when negative
+	MaxBackups int `json:"max_backups"`
+}
+
+// Enabled checks if logs are written to a file
+func (c LogFileConfig) Enabled() bool {
+	return c.Path != ""
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+)
+
+type NotificationsConfig struct {
+	// WebhookURL receives notification events as JSON, notifications are only logged when empty
+	WebhookURL string `json:"webhook_url"`
+	// SeverityWebhooks routes the events about a duplicate of a severity to another webhook than WebhookURL,
+	// such as a channel for exact duplicates only. An empty URL mutes the events of the severity.
+	SeverityWebhooks map[constants.Severity]string `json:"severity_webhooks"`
+	// Muted only logs the notifications, without posting them to the webhooks
+	Muted bool `json:"muted"`
+	// NotifyUsers sends a watched_state_changed event about each user whose play status was changed by resolving
+	// a duplicate, describing what changed, so that the titles suddenly watched are not a surprise
+	NotifyUsers bool `json:"notify_users"`
+	// UserWebhooks routes the events about a user to their own webhook, keyed by Jellyfin user name or ID.
+	// They are posted to WebhookURL otherwise, with the user, for relays routing them.
+	UserWebhooks map[string]string `json:"user_webhooks"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+)
+
+type ScanConfig struct {
+	// MinGroupSize is the number of movies sharing a name and year for them to be compared, 2 by default
+	MinGroupSize int `json:"min_group_size"`
+	// MaxPairsPerGroup caps the pairs compared within a group, so that a large group of movies
+	// sharing a generic name does not produce thousands of pairs
+	MaxPairsPerGroup int `json:"max_pairs_per_group"`
+	// AutoTuneThreshold applies the duplicate thresholds suggested per library by the pairs marked as not duplicates
+	AutoTuneThreshold bool `json:"auto_tune_threshold"`
+	// MinFeedbackLabels is the number of pairs of a library marked as not duplicates before a threshold is suggested, 3 by default
+	MinFeedbackLabels int `json:"min_feedback_labels"`
+	// DetectLinks checks if the copies of a pair are the same file through a hard or symbolic link,
+	// which requires the media folders to be accessible, through deletion.path_mappings when needed
+	DetectLinks bool `json:"detect_links"`
+	// Libraries restricts scans to the libraries with these names, every library is scanned when empty
+	Libraries []string `json:"libraries"`
+	// LibraryPolicies is the policy of each library by name, libraries left out are manual. A policy applies
+	// to a pair when both copies are in libraries with that policy, other pairs are manual.
+	LibraryPolicies map[string]constants.LibraryPolicy `json:"library_policies"`
+	// KeepRule chooses the copy to keep of a duplicate: the highest quality one by default, or the newest or
+	// oldest file, dated by its modification date when the media folders are accessible, otherwise when it was added
+	KeepRule constants.KeepRule `json:"keep_rule"`
+	// YearTolerance also compares the movies sharing a name whose production years differ by up to this many
+	// years, such as region releases. Such pairs are only reported when they share a TMDb or IMDb ID, or when
+	// their paths are similar enough to be duplicates. Disabled when 0.
+	YearTolerance int `json:"year_tolerance"`
+	// Grouping groups the movies compared with each other by metadata name and year by default, or by the title
+	// and year of their folder name
+	Grouping constants.Grouping `json:"grouping"`
+	// HomeVideos also scans the videos of "Home Videos & Photos" libraries, whose titles are often meaningless:
+	// copies of the same recording are found by content fingerprint, which requires the media folders to be
+	// accessible, through deletion.path_mappings when needed
+	HomeVideos bool `json:"home_videos"`
+	// FFprobePath is the ffprobe executable fingerprinting home videos and inspecting media, "ffprobe" by default.
+	// When it is not found, home videos are fingerprinted by duration and partial checksum, only matching identical
+	// copies, and media are not inspected.
+	FFprobePath string `json:"ffprobe_path"`
+	// InspectMedia runs ffprobe on the copies of duplicates to read their true video bitrate, interlacing and audio
+	// layout, used to recommend the copy to delete. It requires the media folders to be accessible.
+	InspectMedia bool `json:"inspect_media"`
+	// SeverityRules classify each pair: the first rule whose conditions all hold gives its severity, pairs matching
+	// no rule are mismatches. DefaultSeverityRules are used when empty.
+	SeverityRules []SeverityRule `json:"severity_rules"`
+	// Concurrency is the number of libraries or users whose movies are fetched from Jellyfin at the same time, 5 by default
+	Concurrency int `json:"concurrency"`
+	// MaxAPICalls is the number of Jellyfin calls a scan may make, unlimited when 0. BudgetAction tells what
+	// happens over it: the scan is aborted by default, or its calls are throttled to one every ThrottleInterval
+	// milliseconds, 1000 by default.
+	MaxAPICalls      int                    `json:"max_api_calls"`
+	BudgetAction     constants.BudgetAction `json:"budget_action"`
+	ThrottleInterval int                    `json:"throttle_interval"`
+	// APICallGrowth warns when a scan makes this percentage more Jellyfin calls than the average of the previous
+	// completed scans, 100 by default
+	APICallGrowth int `json:"api_call_growth"`
+}
+
+// SeverityRule gives a severity to the pairs matching all its conditions, conditions left out are ignored.
+// Conditions on sizes, durations or resolutions never match when they are unknown for a copy.
+type SeverityRule struct {
+	Severity constants.Severity `json:"severity"`
+	// IsDuplicate matches the pairs whose paths are similar enough to be duplicates, or the potential mismatches
+	IsDuplicate *bool `json:"is_duplicate,omitempty"`
+	// MinSimilarity matches the pairs whose paths are at least this similar, in percent
+	MinSimilarity int `json:"min_similarity,omitempty"`
+	// SameSize matches the copies of the same file size
+	SameSize *bool `json:"same_size,omitempty"`
+	// SameDuration matches the copies whose durations differ by less than 2 minutes
+	SameDuration *bool `json:"same_duration,omitempty"`
+	// SameResolution matches the copies of the same resolution
+	SameResolution *bool `json:"same_resolution,omitempty"`
+	// SameStem matches the files of the same name in the same folder, only their extension differing
+	SameStem *bool `json:"same_stem,omitempty"`
+	// SameContent matches the home videos of the same content fingerprint
+	SameContent *bool `json:"same_content,omitempty"`
+	// Linked matches the copies which are the same file on disk, with scan.detect_links
+	Linked *bool `json:"linked,omitempty"`
+}
+
+// DefaultSeverityRules classify identical recordings and files of the same size as exact duplicates, duplicates
+// whose durations or resolutions differ as versions, and other duplicates as probable
+var DefaultSeverityRules = []SeverityRule{
+	{Severity: constants.ExactSeverity, SameContent: &yes},
+	{Severity: constants.ExactSeverity, IsDuplicate: &yes, SameSize: &yes},
+	{Severity: constants.VersionSeverity, IsDuplicate: &yes, SameDuration: &no},
+	{Severity: constants.VersionSeverity, IsDuplicate: &yes, SameResolution: &no},
+	{Severity: constants.ProbableSeverity, IsDuplicate: &yes},
+}
+
+var yes, no = true, false
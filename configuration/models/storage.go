@@ -0,0 +1,16 @@
+package models
+
+import "jellyfin-duplicate/constants"
+
+// StorageConfig selects where the state, the audit log, the latest scan result and the settings changed through
+// the admin API are persisted. The Jellyfin credentials and the certificates stay in the data directory.
+type StorageConfig struct {
+	// Backend is file, sqlite or postgres, file when empty
+	Backend constants.StorageBackend `json:"backend"`
+	// DSN is the path of the SQLite database, data_dir/state.db when empty, or the connection URL of Postgres.
+	// It is read from the environment, as it may hold a password.
+	DSN string `json:"-"`
+	// RefreshInterval is how often the state written by other instances sharing the database is read again,
+	// in seconds, 10 when 0
+	RefreshInterval int `json:"refresh_interval"`
+}
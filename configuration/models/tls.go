@@ -0,0 +1,35 @@
+package models
+
+// TLSConfig serves the application over HTTPS, without a reverse proxy
+type TLSConfig struct {
+	// CertFile and KeyFile are the paths of the PEM encoded certificate chain and private key
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ACME provisions the certificate automatically instead, from Let's Encrypt by default
+	ACME ACMEConfig `json:"acme"`
+}
+
+// ACMEConfig provisions and renews certificates through the ACME protocol, answering HTTP-01 challenges
+type ACMEConfig struct {
+	// Domains are the domain names certificates are requested for, ACME is disabled when empty
+	Domains []string `json:"domains"`
+	// Email is given to the certificate authority for expiry and account notices, optional
+	Email string `json:"email"`
+	// CacheDir keeps the account key and the certificates across restarts, "certs" inside the data directory when empty
+	CacheDir string `json:"cache_dir"`
+	// ChallengeAddress serves the HTTP-01 challenges and redirects other requests to HTTPS, ":80" by default.
+	// The certificate authority always connects on port 80, which has to reach this address.
+	ChallengeAddress string `json:"challenge_address"`
+	// DirectoryURL is the ACME directory of the certificate authority, Let's Encrypt when empty
+	DirectoryURL string `json:"directory_url"`
+}
+
+// Enabled checks if certificates are provisioned through ACME
+func (c ACMEConfig) Enabled() bool {
+	return len(c.Domains) > 0
+}
+
+// Enabled checks if the application is served over HTTPS
+func (c TLSConfig) Enabled() bool {
+	return (c.CertFile != "" && c.KeyFile != "") || c.ACME.Enabled()
+}
@@ -0,0 +1,14 @@
+package models
+
+type TraktConfig struct {
+	// Credentials are read from the environment
+	ClientID    string `json:"-"`
+	AccessToken string `json:"-"`
+	// JellyfinUserID is the Jellyfin user owning the Trakt account, the admin user when empty
+	JellyfinUserID string `json:"jellyfin_user_id"`
+}
+
+// Enabled checks if a Trakt account is connected
+func (c TraktConfig) Enabled() bool {
+	return c.ClientID != "" && c.AccessToken != ""
+}
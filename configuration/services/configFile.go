@@ -0,0 +1,73 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	conf_models "jellyfin-duplicate/configuration/models"
+	"os"
+)
+
+// updateConfigFile changes settings of the configuration file of the environment through update, and returns the
+// configuration loaded again. The file is restored when the new configuration is invalid.
+// Keys are written in alphabetical order, the other settings are kept as they are.
+func updateConfigFile(config *conf_models.Config, update func(settings map[string]any)) (*conf_models.Config, error) {
+	path := getConfigPath(config.Environment)
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %s: %v", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %s: %v", path, err)
+	}
+
+	// Numbers are kept as written, a generic decoding would turn them into floats
+	var settings map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(previous))
+	decoder.UseNumber()
+	if err := decoder.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %v", path, err)
+	}
+
+	update(settings)
+
+	data, err := json.MarshalIndent(settings, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize configuration: %v", err)
+	}
+	if err := replaceFile(path, append(data, '\n'), info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+
+	reloaded, err := LoadConfig()
+	if err != nil {
+		if restoreErr := replaceFile(path, previous, info.Mode().Perm()); restoreErr != nil {
+			return nil, fmt.Errorf("invalid configuration: %v, and failed to restore the previous one: %v", err, restoreErr)
+		}
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	return reloaded, nil
+}
+
+// section returns the object of a top-level key of the configuration, created when missing
+func section(settings map[string]any, key string) map[string]any {
+	if object, ok := settings[key].(map[string]any); ok {
+		return object
+	}
+	object := map[string]any{}
+	settings[key] = object
+	return object
+}
+
+// replaceFile writes a file through a temporary one, so that it is never left half written
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	return nil
+}
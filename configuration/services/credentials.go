@@ -0,0 +1,101 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	conf_models "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// credentialsFile holds the Jellyfin access token issued through Quick Connect, or the API key entered in the setup
+// wizard, inside the data directory
+const credentialsFile = "jellyfin-credentials.json"
+
+// CredentialsPath returns the path of the stored Jellyfin credentials
+func CredentialsPath(dataDir string) string {
+	return filepath.Join(dataDir, credentialsFile)
+}
+
+// SaveJellyfinCredentials stores the Jellyfin credentials, readable by the owner only
+func SaveJellyfinCredentials(dataDir string, credentials conf_models.JellyfinCredentials) error {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory %s: %v", dataDir, err)
+	}
+
+	data, err := json.MarshalIndent(credentials, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize Jellyfin credentials: %v", err)
+	}
+
+	path := CredentialsPath(dataDir)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write Jellyfin credentials to %s: %v", path, err)
+	}
+	return nil
+}
+
+// applyStoredCredentials uses the stored credentials when no API key is configured, and their server when no URL is.
+// The admin user defaults to the stored one, the user who approved the code for Quick Connect.
+func applyStoredCredentials(config *conf_models.Config) error {
+	if config.Jellyfin.APIKey != "" && config.Jellyfin.URL != "" {
+		return nil
+	}
+
+	path := CredentialsPath(config.DataDir)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read Jellyfin credentials %s: %v", path, err)
+	}
+
+	var credentials conf_models.JellyfinCredentials
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return fmt.Errorf("failed to parse Jellyfin credentials %s: %v", path, err)
+	}
+
+	if config.Jellyfin.URL == "" {
+		config.Jellyfin.URL = credentials.ServerURL
+	}
+	if strings.TrimSuffix(credentials.ServerURL, "/") != strings.TrimSuffix(config.Jellyfin.URL, "/") {
+		logrus.Warnf("Ignoring Jellyfin credentials of %s, the configured server is %s", credentials.ServerURL, config.Jellyfin.URL)
+		return nil
+	}
+	if config.Jellyfin.APIKey != "" {
+		return nil
+	}
+
+	config.Jellyfin.APIKey = credentials.AccessToken
+	if config.Jellyfin.UserID == "" {
+		config.Jellyfin.UserID = credentials.UserID
+	}
+	if credentials.Method == constants.QuickConnectAuth {
+		logrus.Infof("Using the Jellyfin access token authorized by %s through Quick Connect", credentials.UserName)
+	} else {
+		logrus.Infof("Using the Jellyfin API key stored by the setup wizard, on behalf of %s", credentials.UserName)
+	}
+	return nil
+}
+
+// CheckJellyfinCredentials verifies that the Jellyfin server can be called, with an API key or a token issued
+// through Quick Connect, on behalf of an admin user
+func CheckJellyfinCredentials(config *conf_models.Config) error {
+	if config.Jellyfin.URL == "" {
+		return fmt.Errorf("%s is not set: set it, or complete the setup wizard", constants.EnvJellyfinURL)
+	}
+	if config.Jellyfin.APIKey == "" {
+		return fmt.Errorf("%s is not set: set it, complete the setup wizard or authorize the application with the 'jellyfin connect' command",
+			constants.EnvJellyfinAPIKey)
+	}
+	if config.Jellyfin.UserID == "" {
+		return fmt.Errorf("%s is not set", constants.EnvJellyfinAdminUserID)
+	}
+	return nil
+}
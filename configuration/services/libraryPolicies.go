@@ -0,0 +1,18 @@
+package services
+
+import (
+	conf_models "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+)
+
+// WriteLibraryPolicies replaces scan.library_policies in the configuration file of the environment and returns the
+// configuration loaded again, see updateConfigFile
+func WriteLibraryPolicies(config *conf_models.Config, policies map[string]constants.LibraryPolicy) (*conf_models.Config, error) {
+	if policies == nil {
+		policies = map[string]constants.LibraryPolicy{}
+	}
+
+	return updateConfigFile(config, func(settings map[string]any) {
+		section(settings, "scan")["library_policies"] = policies
+	})
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	conf_models "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/storage"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidSettings is returned when changed settings cannot be applied, the stored settings being left unchanged
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// TunableSetting is a setting which can be changed through the admin API, by its dotted key in the configuration
+type TunableSetting struct {
+	Key string `json:"key"`
+	// Restart is set for the settings only read when the application starts
+	Restart bool `json:"restart"`
+}
+
+// TunableSettings lists the settings which can be changed through the admin API
+var TunableSettings = []TunableSetting{
+	{Key: "scan.min_group_size"},
+	{Key: "scan.max_pairs_per_group"},
+	{Key: "scan.auto_tune_threshold"},
+	{Key: "scan.min_feedback_labels"},
+	{Key: "scan.detect_links"},
+	{Key: "scan.keep_rule"},
+	{Key: "scan.year_tolerance"},
+	{Key: "scan.grouping"},
+	{Key: "scan.inspect_media", Restart: true},
+	{Key: "scan.concurrency", Restart: true},
+	{Key: "scan.max_api_calls"},
+	{Key: "scan.budget_action"},
+	{Key: "scan.throttle_interval"},
+	{Key: "scan.api_call_growth"},
+	{Key: "deletion.min_reclaimable_size"},
+	{Key: "notifications.muted"},
+	{Key: "early_warning.interval", Restart: true},
+	{Key: "early_warning.start_at", Restart: true},
+	{Key: "early_warning.timezone", Restart: true},
+	{Key: "early_warning.days"},
+}
+
+// StoredSettings returns the settings changed through the admin API by key, empty when none was changed. They
+// are kept by the storage backend, in settings.json inside the data directory by default, and survive restarts
+// and container updates.
+func StoredSettings(config *conf_models.Config) (map[string]json.RawMessage, error) {
+	backend, err := storage.OpenBackend(config.Storage.Backend, config.Storage.DSN, config.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer backend.Close()
+
+	settings, _, err := loadSettings(backend)
+	return settings, err
+}
+
+// loadSettings reads the stored settings and their revision from a backend
+func loadSettings(backend storage.Backend) (map[string]json.RawMessage, int64, error) {
+	settings := map[string]json.RawMessage{}
+	data, revision, err := backend.Load(storage.SettingsDocument)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read settings: %v", err)
+	}
+	if data == nil {
+		return settings, 0, nil
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse settings from %s: %v", backend, err)
+	}
+	return settings, revision, nil
+}
+
+// applyStoredSettings applies the settings changed through the admin API over the configuration file.
+// Settings which cannot be changed anymore are ignored.
+func applyStoredSettings(config *conf_models.Config) error {
+	settings, err := StoredSettings(config)
+	if err != nil {
+		return err
+	}
+	for key, value := range settings {
+		if !IsTunableSetting(key) {
+			logrus.Warnf("Ignoring stored setting %s, it cannot be changed through the admin API", key)
+			continue
+		}
+		if err := ApplySetting(config, key, value); err != nil {
+			return err
+		}
+	}
+	if len(settings) > 0 {
+		logrus.Infof("Applied %d settings changed through the admin API", len(settings))
+	}
+	return nil
+}
+
+// IsTunableSetting checks if the setting can be changed through the admin API
+func IsTunableSetting(key string) bool {
+	return lo.ContainsBy(TunableSettings, func(setting TunableSetting) bool { return setting.Key == key })
+}
+
+// ApplySetting sets a setting of the configuration from its JSON value, leaving the other settings unchanged
+func ApplySetting(config *conf_models.Config, key string, value json.RawMessage) error {
+	section, name, found := strings.Cut(key, ".")
+	if !found {
+		return fmt.Errorf("invalid setting %s", key)
+	}
+	// Decoding into the configuration only changes the fields present in the JSON document
+	document := fmt.Sprintf(`{%q: {%q: %s}}`, section, name, value)
+	decoder := json.NewDecoder(strings.NewReader(document))
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("invalid value %s for %s: %v", value, key, err)
+	}
+	return nil
+}
+
+// SettingValue returns the JSON value of a setting of the configuration
+func SettingValue(config *conf_models.Config, key string) (json.RawMessage, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize configuration: %v", err)
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("failed to read configuration: %v", err)
+	}
+
+	section, name, _ := strings.Cut(key, ".")
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(sections[section], &values); err != nil {
+		return nil, fmt.Errorf("unknown setting %s", key)
+	}
+	value, found := values[name]
+	if !found {
+		return nil, fmt.Errorf("unknown setting %s", key)
+	}
+	return value, nil
+}
+
+// SaveSettings stores changed settings and returns the configuration loaded again with them. A null value
+// removes the setting, the value of the configuration file applying again. The stored settings are restored
+// when the new configuration is invalid.
+func SaveSettings(config *conf_models.Config, changes map[string]json.RawMessage) (*conf_models.Config, error) {
+	for key := range changes {
+		if !IsTunableSetting(key) {
+			return nil, fmt.Errorf("%w: %s cannot be changed through the admin API", ErrInvalidSettings, key)
+		}
+	}
+
+	backend, err := storage.OpenBackend(config.Storage.Backend, config.Storage.DSN, config.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer backend.Close()
+
+	settings, revision, err := loadSettings(backend)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := json.MarshalIndent(settings, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize settings: %v", err)
+	}
+	for key, value := range changes {
+		if string(bytes.TrimSpace(value)) == "null" {
+			delete(settings, key)
+			continue
+		}
+		settings[key] = value
+	}
+
+	data, err := json.MarshalIndent(settings, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize settings: %v", err)
+	}
+	// Settings changed by another instance since they were read are not overwritten
+	if _, err := backend.Save(storage.SettingsDocument, append(data, '\n'), revision); err != nil {
+		return nil, err
+	}
+
+	reloaded, err := LoadConfig()
+	if err != nil {
+		if _, restoreErr := backend.Save(storage.SettingsDocument, append(previous, '\n'), storage.AnyRevision); restoreErr != nil {
+			return nil, fmt.Errorf("%w: %v, and failed to restore the previous ones: %v", ErrInvalidSettings, err, restoreErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+	return reloaded, nil
+}
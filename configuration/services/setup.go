@@ -0,0 +1,32 @@
+package services
+
+import (
+	conf_models "jellyfin-duplicate/configuration/models"
+)
+
+// SetupChoices are the settings chosen in the setup wizard, written to the configuration file
+type SetupChoices struct {
+	// Libraries are the names of the scanned libraries, all of them when empty
+	Libraries          []string `json:"libraries"`
+	MinReclaimableSize int64    `json:"min_reclaimable_size"`
+	RepointPlaylists   bool     `json:"repoint_playlists"`
+	AutoTuneThreshold  bool     `json:"auto_tune_threshold"`
+}
+
+// WriteSetupChoices writes the choices of the setup wizard to the configuration file of the environment and
+// returns the configuration loaded again, see updateConfigFile
+func WriteSetupChoices(config *conf_models.Config, choices SetupChoices) (*conf_models.Config, error) {
+	libraries := choices.Libraries
+	if libraries == nil {
+		libraries = []string{}
+	}
+
+	return updateConfigFile(config, func(settings map[string]any) {
+		scan := section(settings, "scan")
+		scan["libraries"] = libraries
+		scan["auto_tune_threshold"] = choices.AutoTuneThreshold
+		deletion := section(settings, "deletion")
+		deletion["min_reclaimable_size"] = choices.MinReclaimableSize
+		deletion["repoint_playlists"] = choices.RepointPlaylists
+	})
+}
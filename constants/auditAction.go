@@ -0,0 +1,31 @@
+package constants
+
+// AuditAction is a change made to Jellyfin or to the media files, or a rejected access, recorded in the audit log
+type AuditAction string
+
+const (
+	// DeleteMovieAudit is a movie deleted through the Jellyfin API
+	DeleteMovieAudit AuditAction = "delete_movie"
+	// TrashMovieAudit is a movie file moved to the trash directory
+	TrashMovieAudit AuditAction = "trash_movie"
+	// MarkPlayedAudit is a movie marked as played for a user
+	MarkPlayedAudit AuditAction = "mark_played"
+	// SetPositionAudit is the playback position of a movie set for a user, from the other copy of a pair
+	SetPositionAudit AuditAction = "set_position"
+	// RestorePlayStatusAudit is the play status of a user restored by the rollback of a bulk action
+	RestorePlayStatusAudit AuditAction = "restore_play_status"
+	// ClearOrphanAudit is the played state of a user cleared on an item whose file is missing
+	ClearOrphanAudit AuditAction = "clear_orphan"
+	// MergeVersionsAudit is a pair of movies merged as the versions of a single title
+	MergeVersionsAudit AuditAction = "merge_versions"
+	// RefreshMetadataAudit is the metadata of a movie fetched again from the metadata providers of Jellyfin
+	RefreshMetadataAudit AuditAction = "refresh_metadata"
+	// IdentifyMovieAudit is a movie identified as a search result of the metadata providers of Jellyfin
+	IdentifyMovieAudit AuditAction = "identify_movie"
+	// RepointPlaylistAudit is a playlist entry moved from a deleted copy to the kept one
+	RepointPlaylistAudit AuditAction = "repoint_playlist"
+	// LoginFailedAudit is a rejected sign in, or a call with a wrong bearer token
+	LoginFailedAudit AuditAction = "login_failed"
+	// LoginLockoutAudit is a client address locked out after repeated failures
+	LoginLockoutAudit AuditAction = "login_lockout"
+)
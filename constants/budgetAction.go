@@ -0,0 +1,21 @@
+package constants
+
+// BudgetAction is what happens to a scan making more Jellyfin calls than scan.max_api_calls
+type BudgetAction string
+
+const (
+	// AbortBudgetAction fails the scan at the first call over the budget, the action by default
+	AbortBudgetAction BudgetAction = "abort"
+	// ThrottleBudgetAction lets the scan finish, spacing its calls over the budget by scan.throttle_interval
+	ThrottleBudgetAction BudgetAction = "throttle"
+)
+
+// IsValidBudgetAction checks if the budget action is supported
+func IsValidBudgetAction(action BudgetAction) bool {
+	switch action {
+	case AbortBudgetAction, ThrottleBudgetAction:
+		return true
+	default:
+		return false
+	}
+}
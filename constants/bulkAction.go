@@ -0,0 +1,26 @@
+package constants
+
+type BulkAction string
+
+const (
+	// SyncPlayStatusAction marks each copy as seen for the users who have seen the other copy
+	SyncPlayStatusAction BulkAction = "sync_play_status"
+	// IgnoreAction hides the duplicate pair from future results
+	IgnoreAction BulkAction = "ignore"
+	// NotDuplicateAction records a potential duplicate as a false positive, it is shown as a mismatch from then on
+	NotDuplicateAction BulkAction = "not_duplicate"
+	// DeleteLowerQualityAction deletes the recommended lower quality copy
+	DeleteLowerQualityAction BulkAction = "delete_lower_quality"
+	// KeepFirstAction keeps the first copy and deletes the second one
+	KeepFirstAction BulkAction = "keep_first"
+	// KeepSecondAction keeps the second copy and deletes the first one
+	KeepSecondAction BulkAction = "keep_second"
+	// SyncThenDeleteAction synchronizes the play status of the recommended copy to the kept one, checks it,
+	// then deletes the recommended copy
+	SyncThenDeleteAction BulkAction = "sync_then_delete"
+	// ResolvedManuallyAction records a group cleaned up outside of the application, without any Jellyfin operation
+	ResolvedManuallyAction BulkAction = "resolved_manually"
+	// IntentionalVersionsAction keeps both copies as the versions of a single title, such as 4K and HD, optionally
+	// merging them in Jellyfin. The pair is left out of the duplicates, its disk usage is still tracked.
+	IntentionalVersionsAction BulkAction = "intentional_versions"
+)
@@ -0,0 +1,22 @@
+package constants
+
+// CacheBackend is where the avatars, the latest scan result and the coordination of scans and jobs are kept
+type CacheBackend string
+
+const (
+	// MemoryCache keeps them in the memory of the application, the default
+	MemoryCache CacheBackend = "memory"
+	// RedisCache shares them through the Redis server of REDIS_URL, so that several replicas of the application
+	// can run behind a load balancer
+	RedisCache CacheBackend = "redis"
+)
+
+// IsValidCacheBackend checks if the cache backend is supported
+func IsValidCacheBackend(backend CacheBackend) bool {
+	switch backend {
+	case MemoryCache, RedisCache:
+		return true
+	default:
+		return false
+	}
+}
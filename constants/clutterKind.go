@@ -0,0 +1,13 @@
+package constants
+
+// ClutterKind tells why a file of a movie folder is redundant
+type ClutterKind string
+
+const (
+	// DuplicateSubtitle is a subtitle file with the same content as another subtitle of the folder
+	DuplicateSubtitle ClutterKind = "duplicate_subtitle"
+	// SampleFile is a sample video left by the release, which Jellyfin does not show
+	SampleFile ClutterKind = "sample"
+	// PartialDownload is a leftover of an interrupted download
+	PartialDownload ClutterKind = "partial_download"
+)
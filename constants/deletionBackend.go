@@ -0,0 +1,10 @@
+package constants
+
+type DeletionBackend string
+
+const (
+	// JellyfinDeletion deletes items through the Jellyfin API
+	JellyfinDeletion DeletionBackend = "jellyfin"
+	// FilesystemDeletion moves files to a trash directory on disk
+	FilesystemDeletion DeletionBackend = "filesystem"
+)
@@ -1,8 +1,20 @@
 package constants
 
 const (
-	EnvJellyfinURL         = "JELLYFIN_URL"
-	EnvJellyfinAPIKey      = "JELLYFIN_API_KEY"
-	EnvJellyfinAdminUserID = "JELLYFIN_ADMIN_USER_ID"
-	EnvEnvironment         = "ENVIRONMENT"
+	EnvJellyfinURL                  = "JELLYFIN_URL"
+	EnvJellyfinAPIKey               = "JELLYFIN_API_KEY"
+	EnvJellyfinAdminUserID          = "JELLYFIN_ADMIN_USER_ID"
+	EnvEnvironment                  = "ENVIRONMENT"
+	EnvSecondaryJellyfinURL         = "SECONDARY_JELLYFIN_URL"
+	EnvSecondaryJellyfinAPIKey      = "SECONDARY_JELLYFIN_API_KEY"
+	EnvSecondaryJellyfinAdminUserID = "SECONDARY_JELLYFIN_ADMIN_USER_ID"
+	EnvTraktClientID                = "TRAKT_CLIENT_ID"
+	EnvTraktAccessToken             = "TRAKT_ACCESS_TOKEN"
+	EnvDebugAdminToken              = "DEBUG_ADMIN_TOKEN"
+	EnvWebhookToken                 = "JELLYFIN_WEBHOOK_TOKEN"
+	EnvOIDCClientSecret             = "OIDC_CLIENT_SECRET"
+	EnvSessionSecret                = "SESSION_SECRET"
+	EnvWidgetToken                  = "WIDGET_TOKEN"
+	EnvStorageDSN                   = "STORAGE_DSN"
+	EnvRedisURL                     = "REDIS_URL"
 )
@@ -0,0 +1,23 @@
+package constants
+
+// ExportDateFormat is how the dates of CSV exports are written
+type ExportDateFormat string
+
+const (
+	// ISODateFormat writes RFC 3339 times, read by scripts, the default without locale
+	ISODateFormat ExportDateFormat = "iso"
+	// ShortDateFormat writes the numeric date and time of the locale, read by spreadsheets, the default with a locale
+	ShortDateFormat ExportDateFormat = "short"
+	// MediumDateFormat writes the date with the abbreviated month name of the locale and the time
+	MediumDateFormat ExportDateFormat = "medium"
+)
+
+// IsValidExportDateFormat checks if the date format of exports is supported
+func IsValidExportDateFormat(format ExportDateFormat) bool {
+	switch format {
+	case ISODateFormat, ShortDateFormat, MediumDateFormat:
+		return true
+	default:
+		return false
+	}
+}
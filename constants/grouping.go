@@ -0,0 +1,22 @@
+package constants
+
+// Grouping is how the movies compared with each other are grouped
+type Grouping string
+
+const (
+	// NameGrouping groups the movies sharing a name and production year in their metadata, the default
+	NameGrouping Grouping = "name"
+	// FolderGrouping groups the movies by the title and year of their folder name, "Name (Year)", for
+	// libraries whose metadata titles are inconsistent
+	FolderGrouping Grouping = "folder"
+)
+
+// IsValidGrouping checks if the grouping is supported
+func IsValidGrouping(grouping Grouping) bool {
+	switch grouping {
+	case NameGrouping, FolderGrouping:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,11 @@
+package constants
+
+// JellyfinAuthMethod is how the stored Jellyfin credentials were obtained
+type JellyfinAuthMethod string
+
+const (
+	// APIKeyAuth is an API key created in the Jellyfin dashboard, entered in the setup wizard
+	APIKeyAuth JellyfinAuthMethod = "api_key"
+	// QuickConnectAuth is an access token issued once an administrator approved a Quick Connect code
+	QuickConnectAuth JellyfinAuthMethod = "quick_connect"
+)
@@ -0,0 +1,20 @@
+package constants
+
+type JobType string
+
+const (
+	// BulkActionJob applies a bulk action to several duplicate groups
+	BulkActionJob JobType = "bulk_action"
+	// PlayStatusMigrationJob copies play status, favorites and playback positions from one server to the other
+	PlayStatusMigrationJob JobType = "play_status_migration"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
@@ -0,0 +1,23 @@
+package constants
+
+// KeepRule is how the copy to keep of a duplicate pair is chosen, the other one being recommended for deletion
+type KeepRule string
+
+const (
+	// QualityKeepRule keeps the copy with the highest bitrate, then the largest one, the rule by default
+	QualityKeepRule KeepRule = "quality"
+	// NewestKeepRule keeps the most recent file, assuming it is an upgraded release
+	NewestKeepRule KeepRule = "newest"
+	// OldestKeepRule keeps the oldest file
+	OldestKeepRule KeepRule = "oldest"
+)
+
+// IsValidKeepRule checks if the keep rule is supported
+func IsValidKeepRule(rule KeepRule) bool {
+	switch rule {
+	case QualityKeepRule, NewestKeepRule, OldestKeepRule:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,23 @@
+package constants
+
+// LibraryPolicy is how the duplicates found in a library are resolved
+type LibraryPolicy string
+
+const (
+	// ManualPolicy leaves every decision to an administrator, the policy of libraries without one
+	ManualPolicy LibraryPolicy = "manual"
+	// AutoCleanPolicy synchronizes play status and deletes the lower quality copy after each scan, when it is safe
+	AutoCleanPolicy LibraryPolicy = "auto_clean"
+	// IgnorePolicy leaves the duplicates of the library out of the results
+	IgnorePolicy LibraryPolicy = "ignore"
+)
+
+// IsValidLibraryPolicy checks if the policy is supported
+func IsValidLibraryPolicy(policy LibraryPolicy) bool {
+	switch policy {
+	case ManualPolicy, AutoCleanPolicy, IgnorePolicy:
+		return true
+	default:
+		return false
+	}
+}
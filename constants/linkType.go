@@ -0,0 +1,11 @@
+package constants
+
+// LinkType is how two copies of a pair turn out to be the same file on disk
+type LinkType string
+
+const (
+	// HardLink copies are two paths of the same inode, deleting one frees no space
+	HardLink LinkType = "hardlink"
+	// SymbolicLink copies are a symbolic link and its target, deleting the target breaks the link
+	SymbolicLink LinkType = "symlink"
+)
@@ -0,0 +1,4 @@
+package constants
+
+// LocaleCookie is the name of the cookie storing the locale preference, the browser languages being used without it
+const LocaleCookie = "locale"
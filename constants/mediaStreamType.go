@@ -0,0 +1,9 @@
+package constants
+
+type MediaStreamType string
+
+const (
+	VideoStream    MediaStreamType = "Video"
+	AudioStream    MediaStreamType = "Audio"
+	SubtitleStream MediaStreamType = "Subtitle"
+)
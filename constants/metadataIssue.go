@@ -0,0 +1,16 @@
+package constants
+
+// MetadataIssue is a check of the metadata score of a movie it failed
+type MetadataIssue string
+
+const (
+	// MissingProviderIDs is a movie with neither a TMDb nor an IMDb ID, which was never identified
+	MissingProviderIDs MetadataIssue = "missing_provider_ids"
+	// MissingYear is a movie without production year
+	MissingYear MetadataIssue = "missing_year"
+	// MissingPoster is a movie without primary image
+	MissingPoster MetadataIssue = "missing_poster"
+	// PathMismatch is a movie whose file and folder names do not contain its title, often identified as
+	// another movie
+	PathMismatch MetadataIssue = "path_mismatch"
+)
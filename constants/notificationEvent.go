@@ -0,0 +1,17 @@
+package constants
+
+type NotificationEvent string
+
+const (
+	// JobFinishedEvent is sent when a job succeeded, failed or was cancelled
+	JobFinishedEvent NotificationEvent = "job_finished"
+	// RecentDuplicateEvent is sent when a recently added movie duplicates one already in the library
+	RecentDuplicateEvent NotificationEvent = "recent_duplicate"
+	// ScanCompletedEvent is sent with the result of every duplicate scan
+	ScanCompletedEvent NotificationEvent = "scan_completed"
+	// APICallsEvent is sent when a scan exceeds its Jellyfin call budget, or makes unexpectedly more calls than
+	// the previous scans
+	APICallsEvent NotificationEvent = "api_calls"
+	// WatchedStateChangedEvent is sent about a user whose play status was changed by resolving a duplicate
+	WatchedStateChangedEvent NotificationEvent = "watched_state_changed"
+)
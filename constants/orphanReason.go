@@ -0,0 +1,12 @@
+package constants
+
+// OrphanReason tells why a watched entry of a user points at a missing item
+type OrphanReason string
+
+const (
+	// OrphanMissingItem is a watched movie missing from the scanned libraries, deleted from Jellyfin
+	// while the scan was fetching the movies
+	OrphanMissingItem OrphanReason = "missing_item"
+	// OrphanMissingFile is a watched movie whose file was deleted outside of Jellyfin, which still lists it
+	OrphanMissingFile OrphanReason = "missing_file"
+)
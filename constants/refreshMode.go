@@ -0,0 +1,12 @@
+package constants
+
+type RefreshMode string
+
+const (
+	// NoRefresh does not ask Jellyfin to rescan anything after a deletion
+	NoRefresh RefreshMode = "none"
+	// FolderRefresh reports the folder of the deleted item as modified
+	FolderRefresh RefreshMode = "folder"
+	// LibraryRefresh triggers a recursive refresh of the library containing the deleted item
+	LibraryRefresh RefreshMode = "library"
+)
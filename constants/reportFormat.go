@@ -0,0 +1,21 @@
+package constants
+
+// ReportFormat is the format of a report of a filesystem duplicate finder
+type ReportFormat string
+
+const (
+	// CzkawkaReport is the JSON report of duplicate files saved by czkawka
+	CzkawkaReport ReportFormat = "czkawka"
+	// RdfindReport is the results.txt file written by rdfind
+	RdfindReport ReportFormat = "rdfind"
+)
+
+// IsValidReportFormat checks if the report format is supported
+func IsValidReportFormat(format ReportFormat) bool {
+	switch format {
+	case CzkawkaReport, RdfindReport:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,15 @@
+package constants
+
+type Resolution string
+
+const (
+	// SDResolution is any video narrower than 720p
+	SDResolution      Resolution = "sd"
+	HD720             Resolution = "720p"
+	HD1080            Resolution = "1080p"
+	UHD4K             Resolution = "4k"
+	UnknownResolution Resolution = ""
+)
+
+// Resolutions lists the resolutions accepted by the duplicate filters
+var Resolutions = []Resolution{SDResolution, HD720, HD1080, UHD4K}
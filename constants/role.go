@@ -0,0 +1,18 @@
+package constants
+
+// Role is what a user signed in through OpenID Connect may do
+type Role string
+
+const (
+	// AdminRole may run every action
+	AdminRole Role = "admin"
+	// ViewerRole may only browse the results
+	ViewerRole Role = "viewer"
+)
+
+const (
+	// SessionCookie is the name of the cookie storing the signed in user
+	SessionCookie = "session"
+	// LoginCookie is the name of the cookie storing the state of a login in progress
+	LoginCookie = "login"
+)
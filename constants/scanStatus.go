@@ -0,0 +1,24 @@
+package constants
+
+// ScanStatus is the state of a duplicate scan in the scan history
+type ScanStatus string
+
+const (
+	ScanRunning   ScanStatus = "running"
+	ScanCompleted ScanStatus = "completed"
+	ScanFailed    ScanStatus = "failed"
+	// ScanCancelled is a scan stopped through the cancel endpoint, its Jellyfin requests being aborted
+	ScanCancelled ScanStatus = "cancelled"
+)
+
+// ScanPhase is the step of the running scan reported by the scan progress
+type ScanPhase string
+
+const (
+	// ScanFetching fetches the movies and the play status of the users from Jellyfin
+	ScanFetching ScanPhase = "fetching"
+	// ScanAnalyzing reads the files of the movies, for their modification date and fingerprint
+	ScanAnalyzing ScanPhase = "analyzing"
+	// ScanComparing compares the pairs found, inspecting the copies of duplicates
+	ScanComparing ScanPhase = "comparing"
+)
@@ -0,0 +1,29 @@
+package constants
+
+// Severity classifies a pair of movies, from the most to the least certain duplicate
+type Severity string
+
+const (
+	// ExactSeverity is a pair of identical copies, such as the same recording or the same file size
+	ExactSeverity Severity = "exact"
+	// ProbableSeverity is a pair of copies of the same movie, in different qualities or containers
+	ProbableSeverity Severity = "probable"
+	// VersionSeverity is a pair of versions or editions of a movie, such as a director's cut, whose
+	// durations or resolutions differ: both copies may be worth keeping
+	VersionSeverity Severity = "version"
+	// MismatchSeverity is a pair of movies sharing a name whose paths differ, possibly different movies
+	MismatchSeverity Severity = "mismatch"
+)
+
+// Severities lists the severities from the most to the least severe
+var Severities = []Severity{ExactSeverity, ProbableSeverity, VersionSeverity, MismatchSeverity}
+
+// IsValidSeverity checks if the severity is supported
+func IsValidSeverity(severity Severity) bool {
+	switch severity {
+	case ExactSeverity, ProbableSeverity, VersionSeverity, MismatchSeverity:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,25 @@
+package constants
+
+// StorageBackend is where the state, the audit log, the latest scan result and the settings changed through
+// the admin API are persisted
+type StorageBackend string
+
+const (
+	// FileStorage writes JSON files in the data directory, the default
+	FileStorage StorageBackend = "file"
+	// SQLiteStorage writes a SQLite database, data_dir/state.db unless STORAGE_DSN is set
+	SQLiteStorage StorageBackend = "sqlite"
+	// PostgresStorage writes a Postgres database, whose connection URL is STORAGE_DSN. Several instances may
+	// share it.
+	PostgresStorage StorageBackend = "postgres"
+)
+
+// IsValidStorageBackend checks if the storage backend is supported
+func IsValidStorageBackend(backend StorageBackend) bool {
+	switch backend {
+	case FileStorage, SQLiteStorage, PostgresStorage:
+		return true
+	default:
+		return false
+	}
+}
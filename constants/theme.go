@@ -0,0 +1,13 @@
+package constants
+
+type Theme string
+
+const (
+	DarkTheme  Theme = "dark"
+	LightTheme Theme = "light"
+	// AutoTheme follows the operating system preference
+	AutoTheme Theme = "auto"
+)
+
+// ThemeCookie is the name of the cookie storing the theme preference
+const ThemeCookie = "theme"
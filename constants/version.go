@@ -0,0 +1,70 @@
+package constants
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// Version of the application, reported to Jellyfin
+var Version = "dev"
+
+// Commit is the revision the application was built from, set with
+// -ldflags "-X jellyfin-duplicate/constants.Commit=<revision>". The revision recorded by the Go toolchain
+// is used when empty.
+var Commit = ""
+
+// BuildDate is when the application was built, set with -ldflags "-X jellyfin-duplicate/constants.BuildDate=<date>",
+// empty when unknown
+var BuildDate = ""
+
+// BuildCommit returns the revision the application was built from, with a "-dirty" suffix when the toolchain
+// recorded uncommitted changes. It is empty when unknown.
+func BuildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	var revision string
+	modified := false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// VersionString describes the version with its short commit and build date when known,
+// such as "1.2.0 (3f2c1ab, built 2026-10-18)"
+func VersionString() string {
+	var details []string
+	if commit := BuildCommit(); commit != "" {
+		revision, dirty := strings.CutSuffix(commit, "-dirty")
+		if len(revision) > 7 {
+			revision = revision[:7]
+		}
+		if dirty {
+			revision += "-dirty"
+		}
+		details = append(details, revision)
+	}
+	if BuildDate != "" {
+		details = append(details, "built "+BuildDate)
+	}
+
+	if len(details) == 0 {
+		return Version
+	}
+	return fmt.Sprintf("%s (%s)", Version, strings.Join(details, ", "))
+}
@@ -0,0 +1,28 @@
+package demo
+
+import (
+	conf_models "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+)
+
+// Configure points the configuration to the demo server, and leaves out what would reach beyond it: the
+// secondary server, Trakt, notifications and the media files, which do not exist. The state is kept in the
+// temporary data directory of the server, never in the configured database nor Redis, so that the demo starts
+// from the generated library on every run.
+func (s *Server) Configure(config *conf_models.Config) {
+	config.DataDir = s.dataDir
+	config.Storage.Backend, config.Storage.DSN = constants.FileStorage, ""
+	config.Cache.Backend, config.Cache.URL = constants.MemoryCache, ""
+	config.Jellyfin = conf_models.JellyfinConfig{URL: s.URL(), APIKey: APIKey, UserID: s.library.AdminUserID()}
+	config.SecondaryJellyfin = conf_models.JellyfinConfig{}
+	config.Trakt.ClientID, config.Trakt.AccessToken = "", ""
+	config.Notifications.Muted = true
+
+	config.Scan.Libraries = nil
+	config.Scan.HomeVideos = false
+	config.Scan.InspectMedia = false
+	config.Scan.DetectLinks = false
+	config.Deletion.Backend = constants.JellyfinDeletion
+	config.Deletion.PathMappings = nil
+	config.Deletion.TrashDir = ""
+}
@@ -0,0 +1,279 @@
+package demo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// generatedAt is the reference date of the generated library, fixed so that every run serves the same data
+var generatedAt = time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC)
+
+// seed makes the generated play statuses, sizes and dates the same on every run
+const seed = 20240601
+
+// title is a movie of the generated library
+type title struct {
+	name string
+	year int
+	// minutes is the duration of the movie
+	minutes int
+}
+
+// titles are public domain movies, so that the demo shows real names
+var titles = []title{
+	{"Nosferatu", 1922, 94},
+	{"Metropolis", 1927, 153},
+	{"The General", 1926, 78},
+	{"Sherlock Jr.", 1924, 45},
+	{"The Kid", 1921, 68},
+	{"Safety Last!", 1923, 74},
+	{"The Gold Rush", 1925, 95},
+	{"Night of the Living Dead", 1968, 96},
+	{"His Girl Friday", 1940, 92},
+	{"Charade", 1963, 113},
+	{"The Cabinet of Dr. Caligari", 1920, 76},
+	{"A Trip to the Moon", 1902, 13},
+	{"The Phantom of the Opera", 1925, 93},
+	{"Plan 9 from Outer Space", 1957, 79},
+	{"Detour", 1945, 68},
+	{"The Little Shop of Horrors", 1960, 72},
+	{"Carnival of Souls", 1962, 78},
+	{"The Great Train Robbery", 1903, 12},
+	{"It's a Wonderful Life", 1946, 130},
+	{"The Last Man on Earth", 1964, 86},
+	{"Battleship Potemkin", 1925, 75},
+	{"The Hitch-Hiker", 1953, 71},
+	{"D.O.A.", 1949, 83},
+	{"Meet John Doe", 1941, 122},
+	{"Reefer Madness", 1936, 66},
+	{"The Man with the Golden Arm", 1955, 119},
+	{"House on Haunted Hill", 1959, 75},
+	{"Steamboat Bill, Jr.", 1928, 70},
+	{"The Lost World", 1925, 106},
+	{"Santa Claus Conquers the Martians", 1964, 81},
+}
+
+// boxSetTitles are the movies of the generated box set
+var boxSetTitles = []string{"The General", "Sherlock Jr.", "Steamboat Bill, Jr.", "Safety Last!", "The Kid", "The Gold Rush"}
+
+// userNames are the users of the generated library, the first one being the administrator
+var userNames = []string{"Demo Admin", "Alice", "Bob", "Charlie", "Dana"}
+
+// Library is the state of the generated Jellyfin server, changed by the calls of the application
+type Library struct {
+	mutex     sync.RWMutex
+	libraries []models.Library
+	users     []models.User
+	// movies are in the order they are listed, with the library each one belongs to
+	movies    []models.Movie
+	libraryOf map[string]string
+	// userData holds the play status of the movies by user ID then movie ID
+	userData  map[string]map[string]models.UserItemData
+	boxSet    models.BoxSet
+	boxSetIDs []string
+	playlists []playlist
+}
+
+// playlist is a playlist of the generated library with its entries
+type playlist struct {
+	models.Playlist
+	entries []models.PlaylistEntry
+}
+
+// demoID returns a Jellyfin-like item ID, the same for the same kind and key on every run
+func demoID(kind string, key string) string {
+	hash := sha256.Sum256([]byte(kind + "/" + key))
+	return hex.EncodeToString(hash[:16])
+}
+
+// Generate creates the library served in demo mode: two movie libraries whose copies include upscaled and
+// re-encoded duplicates of the same movies, a movie sharing its name with another one, users with diverging
+// play statuses, a box set and a playlist. The same library is generated on every run.
+func Generate() *Library {
+	random := rand.New(rand.NewPCG(seed, seed))
+	library := &Library{
+		libraries: []models.Library{
+			{ID: demoID("library", "movies"), Name: "Movies", CollectionType: "movies"},
+			{ID: demoID("library", "4k"), Name: "4K Movies", CollectionType: "movies"},
+		},
+		libraryOf: map[string]string{},
+		userData:  map[string]map[string]models.UserItemData{},
+	}
+
+	for i, name := range userNames {
+		user := models.User{
+			ID:               demoID("user", name),
+			Name:             name,
+			HasPassword:      true,
+			LastLoginDate:    generatedAt.AddDate(0, 0, -i).Format(time.RFC3339),
+			LastActivityDate: generatedAt.AddDate(0, 0, -i).Format(time.RFC3339),
+		}
+		user.Policy.IsAdministrator = i == 0
+		library.users = append(library.users, user)
+		library.userData[user.ID] = map[string]models.UserItemData{}
+	}
+
+	moviesID, uhdID := library.libraries[0].ID, library.libraries[1].ID
+	for i, title := range titles {
+		folder := fmt.Sprintf("%s (%d)", title.name, title.year)
+		tmdb, imdb := titleProviderIDs(i)
+		addedAt := generatedAt.AddDate(0, 0, -30-random.IntN(700))
+
+		movie := newMovie("movie", folder, title, tmdb, imdb, addedAt)
+		movie.Path = fmt.Sprintf("/media/movies/%s/%s.mkv", folder, folder)
+		movie.MediaSources = []models.MediaSource{mediaSource(movie.ID, movie.Path, "mkv", title.minutes, 8_000_000+random.Int64N(4_000_000),
+			"h264", 1920, 1080, "SDR", []string{"eng", "fre"}, []string{"eng"})}
+		library.add(movie, moviesID)
+
+		switch {
+		case i%4 == 0:
+			// A 4K release of the same movie, the 1080p copy being the one to delete, either as another version in
+			// the folder of the movie or in the 4K library
+			duplicate := newMovie("movie-4k", folder, title, tmdb, imdb, addedAt.AddDate(0, 0, random.IntN(30)))
+			duplicate.Path = fmt.Sprintf("/media/movies/%s/%s - 2160p.mkv", folder, folder)
+			libraryID := moviesID
+			if i%8 == 4 {
+				duplicate.Path = fmt.Sprintf("/media/movies-4k/%s/%s - 2160p.mkv", folder, folder)
+				libraryID = uhdID
+			}
+			duplicate.MediaSources = []models.MediaSource{mediaSource(duplicate.ID, duplicate.Path, "mkv", title.minutes, 40_000_000+random.Int64N(20_000_000),
+				"hevc", 3840, 2160, "HDR", []string{"eng"}, []string{"eng", "spa"})}
+			library.add(duplicate, libraryID)
+		case i%8 == 1:
+			// An old re-encode left next to the file, only its extension differing
+			duplicate := newMovie("movie-avi", folder, title, tmdb, imdb, addedAt.AddDate(0, 0, -random.IntN(300)))
+			duplicate.Path = fmt.Sprintf("/media/movies/%s/%s.avi", folder, folder)
+			// Jellyfin found no poster for the old rip
+			duplicate.ImageTags = nil
+			duplicate.MediaSources = []models.MediaSource{mediaSource(duplicate.ID, duplicate.Path, "avi", title.minutes, 1_500_000+random.Int64N(500_000),
+				"mpeg4", 720, 480, "SDR", []string{"eng"}, nil)}
+			library.add(duplicate, moviesID)
+		case i%8 == 6:
+			// Another movie sharing the name and year, filed elsewhere: a mismatch rather than a duplicate
+			other := newMovie("movie-other", folder, title, documentaryTmdbID(i), "", addedAt.AddDate(0, 0, random.IntN(60)))
+			other.Path = fmt.Sprintf("/media/movies/Documentaries/%s - Behind the Scenes/%s.mp4", title.name, title.name)
+			other.RunTimeTicks = int64(25 * time.Minute / models.TickDuration)
+			other.MediaSources = []models.MediaSource{mediaSource(other.ID, other.Path, "mp4", 25, 3_000_000+random.Int64N(1_000_000),
+				"h264", 1280, 720, "SDR", []string{"eng"}, nil)}
+			library.add(other, moviesID)
+		}
+	}
+
+	// Users watched some copies and not the others, which the analysis reports as discrepancies
+	for _, user := range library.users {
+		for _, movie := range library.movies {
+			library.userData[user.ID][movie.ID] = randomUserData(random, movie)
+		}
+	}
+
+	// Alice started both copies of Metropolis, stopping at different times, which the analysis reports as a
+	// position conflict
+	alice := library.users[1].ID
+	for _, kind := range []string{"movie", "movie-avi"} {
+		id := demoID(kind, "Metropolis (1927)")
+		library.userData[alice][id] = models.UserItemData{
+			PlaybackPositionTicks: int64(time.Duration(40+random.IntN(80)) * time.Minute / models.TickDuration),
+			LastPlayedDate:        generatedAt.Add(-time.Duration(1+random.IntN(30*24)) * time.Hour).Format(time.RFC3339),
+		}
+	}
+
+	library.boxSet = models.BoxSet{ID: demoID("boxset", "silent-comedy"), Name: "Silent Comedy"}
+	for _, movie := range library.movies {
+		for _, name := range boxSetTitles {
+			if movie.Name == name && library.libraryOf[movie.ID] == moviesID {
+				library.boxSetIDs = append(library.boxSetIDs, movie.ID)
+			}
+		}
+	}
+
+	movieNight := playlist{Playlist: models.Playlist{ID: demoID("playlist", "movie-night"), Name: "Movie Night"}}
+	for i := 0; i < len(library.movies); i += 5 {
+		movie := library.movies[i]
+		movieNight.entries = append(movieNight.entries, models.PlaylistEntry{
+			ID: movie.ID, Name: movie.Name, PlaylistItemID: demoID("entry", fmt.Sprintf("%s/%d", movieNight.ID, i)),
+		})
+	}
+	library.playlists = []playlist{movieNight}
+
+	return library
+}
+
+// titleProviderIDs returns the TMDb and IMDb IDs of the title at index i of titles
+func titleProviderIDs(i int) (string, string) {
+	return fmt.Sprintf("%d", 10000+i*37), fmt.Sprintf("tt%07d", 10000+i*131)
+}
+
+// documentaryTmdbID returns the TMDb ID of the behind the scenes documentary of the title at index i of titles
+func documentaryTmdbID(i int) string {
+	return fmt.Sprintf("%d", 90000+i)
+}
+
+// newMovie creates a movie of the generated library, without file
+func newMovie(kind string, folder string, title title, tmdb string, imdb string, addedAt time.Time) models.Movie {
+	movie := models.Movie{
+		ID:             demoID(kind, folder),
+		Name:           title.name,
+		ProductionYear: title.year,
+		DateCreated:    addedAt.Format(time.RFC3339),
+		RunTimeTicks:   int64(time.Duration(title.minutes) * time.Minute / models.TickDuration),
+		ImageTags:      map[string]string{"Primary": demoID("poster", kind+"/"+folder)},
+	}
+	movie.ProviderIds.Tmdb = tmdb
+	movie.ProviderIds.Imdb = imdb
+	return movie
+}
+
+// mediaSource describes the file of a movie from its bitrate and tracks, its size following from its duration
+func mediaSource(id string, path string, container string, minutes int, bitrate int64, codec string, width int, height int,
+	videoRange string, audioLanguages []string, subtitleLanguages []string) models.MediaSource {
+	streams := []models.MediaStream{{Type: constants.VideoStream, Codec: codec, Width: width, Height: height, VideoRange: videoRange,
+		DisplayTitle: fmt.Sprintf("%dp %s %s", height, codec, videoRange)}}
+	for _, language := range audioLanguages {
+		streams = append(streams, models.MediaStream{Type: constants.AudioStream, Language: language, Codec: "aac", DisplayTitle: language})
+	}
+	for _, language := range subtitleLanguages {
+		streams = append(streams, models.MediaStream{Type: constants.SubtitleStream, Language: language, Codec: "srt", DisplayTitle: language})
+	}
+
+	return models.MediaSource{
+		ID:           id,
+		Path:         path,
+		Container:    container,
+		Size:         bitrate / 8 * int64(minutes) * 60,
+		Bitrate:      bitrate,
+		MediaStreams: streams,
+	}
+}
+
+// randomUserData draws the play status of a movie for a user
+func randomUserData(random *rand.Rand, movie models.Movie) models.UserItemData {
+	var data models.UserItemData
+	switch draw := random.Float64(); {
+	case draw < 0.35:
+		data.Played = true
+		data.PlayCount = 1 + random.IntN(3)
+		data.LastPlayedDate = generatedAt.Add(-time.Duration(1+random.IntN(400*24)) * time.Hour).Format(time.RFC3339)
+	case draw < 0.45:
+		data.PlaybackPositionTicks = movie.RunTimeTicks * int64(10+random.IntN(80)) / 100
+		data.LastPlayedDate = generatedAt.Add(-time.Duration(1+random.IntN(60*24)) * time.Hour).Format(time.RFC3339)
+	}
+	data.IsFavorite = random.Float64() < 0.08
+	return data
+}
+
+// add adds a movie to a library
+func (l *Library) add(movie models.Movie, libraryID string) {
+	l.movies = append(l.movies, movie)
+	l.libraryOf[movie.ID] = libraryID
+}
+
+// AdminUserID returns the ID of the administrator of the generated library
+func (l *Library) AdminUserID() string {
+	return l.users[0].ID
+}
@@ -0,0 +1,458 @@
+package demo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jellyfin-duplicate/client/jellyfin/models"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// APIKey is the API key the demo server expects, any other key being rejected like by Jellyfin
+const APIKey = "demo"
+
+// serverVersion is the Jellyfin version the demo server reports, so that the current API style is used
+const serverVersion = "10.10.0"
+
+// Server serves a generated library through the subset of the Jellyfin API used by the application,
+// on a loopback port, so that the application runs without Jellyfin server
+type Server struct {
+	library  *Library
+	listener net.Listener
+	server   *http.Server
+	// dataDir is a temporary data directory for the application, see Configure
+	dataDir string
+}
+
+// Start serves the library on a free loopback port
+func Start(library *Library) (*Server, error) {
+	dataDir, err := os.MkdirTemp("", "jellyfin-duplicate-demo-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create demo data directory: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the demo server: %v", err)
+	}
+
+	s := &Server{library: library, listener: listener, dataDir: dataDir}
+	s.server = &http.Server{Handler: s.routes(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Demo server stopped: %v", err)
+		}
+	}()
+	return s, nil
+}
+
+// URL returns the base URL of the demo server, to be used as the Jellyfin URL
+func (s *Server) URL() string {
+	return "http://" + s.listener.Addr().String()
+}
+
+// Close stops the server and removes its temporary data directory
+func (s *Server) Close() error {
+	if err := s.server.Close(); err != nil {
+		return fmt.Errorf("failed to stop the demo server: %v", err)
+	}
+	if err := os.RemoveAll(s.dataDir); err != nil {
+		return fmt.Errorf("failed to remove the demo data directory: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /System/Info/Public", s.getSystemInfo)
+	mux.HandleFunc("GET /System/Info", s.getSystemInfo)
+	mux.HandleFunc("GET /Users", s.getUsers)
+	mux.HandleFunc("GET /Users/{id}", s.getUser)
+	mux.HandleFunc("GET /Users/{id}/Images/Primary", http.NotFound)
+	mux.HandleFunc("GET /UserViews", s.getLibraries)
+	mux.HandleFunc("GET /Items", s.getItems)
+	mux.HandleFunc("GET /Items/{id}", s.getItem)
+	mux.HandleFunc("DELETE /Items/{id}", s.deleteItem)
+	mux.HandleFunc("GET /Items/{id}/Ancestors", s.getAncestors)
+	mux.HandleFunc("POST /Items/{id}/Refresh", noContent)
+	mux.HandleFunc("POST /Items/RemoteSearch/Movie", s.searchMetadata)
+	mux.HandleFunc("POST /Items/RemoteSearch/Apply/{id}", s.applySearchResult)
+	mux.HandleFunc("POST /Library/Media/Updated", noContent)
+	mux.HandleFunc("POST /Videos/MergeVersions", noContent)
+	mux.HandleFunc("POST /UserPlayedItems/{id}", s.setPlayed(true))
+	mux.HandleFunc("DELETE /UserPlayedItems/{id}", s.setPlayed(false))
+	mux.HandleFunc("POST /UserFavoriteItems/{id}", s.setFavorite(true))
+	mux.HandleFunc("DELETE /UserFavoriteItems/{id}", s.setFavorite(false))
+	mux.HandleFunc("GET /UserItems/{id}/UserData", s.getUserData)
+	mux.HandleFunc("POST /UserItems/{id}/UserData", s.updateUserData)
+	mux.HandleFunc("GET /Playlists/{id}/Items", s.getPlaylistEntries)
+	mux.HandleFunc("POST /Playlists/{id}/Items", s.addPlaylistEntries)
+	mux.HandleFunc("DELETE /Playlists/{id}/Items", s.removePlaylistEntries)
+	mux.HandleFunc("POST /Playlists/{id}/Items/{entry}/Move/{index}", s.movePlaylistEntry)
+	return requireAPIKey(mux)
+}
+
+// requireAPIKey rejects the requests without the demo API key in their Authorization header
+func requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/System/Info/Public" && !strings.Contains(r.Header.Get("Authorization"), fmt.Sprintf(`Token="%s"`, APIKey)) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logrus.Errorf("Demo server failed to write response: %v", err)
+	}
+}
+
+func noContent(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// page returns the page of items requested with StartIndex and Limit, all of them without Limit
+func page[T any](items []T, query url.Values) []T {
+	start, _ := strconv.Atoi(query.Get("StartIndex"))
+	start = min(max(start, 0), len(items))
+	end := len(items)
+	if limit, err := strconv.Atoi(query.Get("Limit")); err == nil && limit >= 0 {
+		end = min(start+limit, end)
+	}
+	return items[start:end]
+}
+
+func (s *Server) getSystemInfo(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, models.SystemInfo{
+		ID:              demoID("server", "demo"),
+		ServerName:      "Demo",
+		Version:         serverVersion,
+		ProductName:     "Jellyfin Server",
+		OperatingSystem: "Linux",
+	})
+}
+
+func (s *Server) getUsers(w http.ResponseWriter, _ *http.Request) {
+	s.library.mutex.RLock()
+	defer s.library.mutex.RUnlock()
+	writeJSON(w, s.library.users)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.library.mutex.RLock()
+	defer s.library.mutex.RUnlock()
+	for _, user := range s.library.users {
+		if user.ID == r.PathValue("id") {
+			writeJSON(w, user)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (s *Server) getLibraries(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{"Items": s.library.libraries})
+}
+
+// getItems lists the playlists, the box sets, the movies of a box set or of a library, or the movies of a user
+// with the IsPlayed or IsResumable filter, like the /Items endpoint does for the parameters the application sends
+func (s *Server) getItems(w http.ResponseWriter, r *http.Request) {
+	s.library.mutex.RLock()
+	defer s.library.mutex.RUnlock()
+	query := r.URL.Query()
+
+	switch {
+	case query.Get("IncludeItemTypes") == "Playlist":
+		playlists := make([]models.Playlist, 0, len(s.library.playlists))
+		for _, playlist := range s.library.playlists {
+			playlists = append(playlists, playlist.Playlist)
+		}
+		writeJSON(w, map[string]any{"Items": playlists, "TotalRecordCount": len(playlists)})
+		return
+	case query.Get("IncludeItemTypes") == "BoxSet":
+		writeJSON(w, map[string]any{"Items": []models.BoxSet{s.library.boxSet}, "TotalRecordCount": 1})
+		return
+	}
+
+	userID := query.Get("UserId")
+	if userID == "" {
+		userID = s.library.AdminUserID()
+	}
+	parentID := query.Get("ParentId")
+	played := strings.Contains(query.Get("Filters"), "IsPlayed")
+	resumable := strings.Contains(query.Get("Filters"), "IsResumable")
+
+	movies := []models.Movie{}
+	for _, movie := range s.library.movies {
+		switch {
+		case parentID == s.library.boxSet.ID && !slices.Contains(s.library.boxSetIDs, movie.ID):
+			continue
+		case parentID != "" && parentID != s.library.boxSet.ID && s.library.libraryOf[movie.ID] != parentID:
+			continue
+		case played && !s.library.userData[userID][movie.ID].Played:
+			continue
+		case resumable && s.library.userData[userID][movie.ID].PlaybackPositionTicks == 0:
+			continue
+		}
+		movies = append(movies, s.library.withUserData(movie, userID))
+	}
+	writeJSON(w, map[string]any{"Items": page(movies, query), "TotalRecordCount": len(movies)})
+}
+
+func (s *Server) getItem(w http.ResponseWriter, r *http.Request) {
+	s.library.mutex.RLock()
+	defer s.library.mutex.RUnlock()
+	movie, found := s.library.movie(r.PathValue("id"))
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		userID = s.library.AdminUserID()
+	}
+	writeJSON(w, s.library.withUserData(movie, userID))
+}
+
+// searchMetadata answers the identify search with the titles whose name contains the searched one, each with its
+// behind the scenes documentary, as a metadata provider would
+func (s *Server) searchMetadata(w http.ResponseWriter, r *http.Request) {
+	var query struct {
+		SearchInfo struct {
+			Name string
+			Year int
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := strings.ToLower(query.SearchInfo.Name)
+	results := []models.RemoteSearchResult{}
+	for i, title := range titles {
+		if !strings.Contains(strings.ToLower(title.name), name) && !strings.Contains(name, strings.ToLower(title.name)) {
+			continue
+		}
+		if query.SearchInfo.Year > 0 && query.SearchInfo.Year != title.year {
+			continue
+		}
+		tmdb, imdb := titleProviderIDs(i)
+		results = append(results,
+			models.RemoteSearchResult{Name: title.name, ProductionYear: title.year, SearchProviderName: "TheMovieDb",
+				ProviderIds: map[string]string{"Tmdb": tmdb, "Imdb": imdb}},
+			models.RemoteSearchResult{Name: title.name + " - Behind the Scenes", ProductionYear: title.year, SearchProviderName: "TheMovieDb",
+				ProviderIds: map[string]string{"Tmdb": documentaryTmdbID(i)}})
+	}
+	writeJSON(w, results)
+}
+
+// applySearchResult identifies a movie as a search result, replacing its name, year and provider IDs
+func (s *Server) applySearchResult(w http.ResponseWriter, r *http.Request) {
+	var result models.RemoteSearchResult
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.library.mutex.Lock()
+	defer s.library.mutex.Unlock()
+	index := slices.IndexFunc(s.library.movies, func(movie models.Movie) bool { return movie.ID == r.PathValue("id") })
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	movie := &s.library.movies[index]
+	logrus.Infof("Demo server identified %s as %s (%d)", movie.Path, result.Name, result.ProductionYear)
+	movie.Name, movie.ProductionYear = result.Name, result.ProductionYear
+	movie.ProviderIds.Tmdb, movie.ProviderIds.Imdb = result.ProviderIds["Tmdb"], result.ProviderIds["Imdb"]
+	if r.URL.Query().Get("ReplaceAllImages") == "true" {
+		movie.ImageTags = map[string]string{"Primary": demoID("poster", movie.ID+"/"+result.ProviderIds["Tmdb"])}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteItem(w http.ResponseWriter, r *http.Request) {
+	s.library.mutex.Lock()
+	defer s.library.mutex.Unlock()
+	id := r.PathValue("id")
+	index := slices.IndexFunc(s.library.movies, func(movie models.Movie) bool { return movie.ID == id })
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	logrus.Infof("Demo server deleted %s", s.library.movies[index].Path)
+	s.library.movies = slices.Delete(s.library.movies, index, index+1)
+	s.library.boxSetIDs = slices.DeleteFunc(s.library.boxSetIDs, func(movieID string) bool { return movieID == id })
+	for i := range s.library.playlists {
+		s.library.playlists[i].entries = slices.DeleteFunc(s.library.playlists[i].entries, func(entry models.PlaylistEntry) bool {
+			return entry.ID == id
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getAncestors(w http.ResponseWriter, r *http.Request) {
+	s.library.mutex.RLock()
+	defer s.library.mutex.RUnlock()
+	libraryID, found := s.library.libraryOf[r.PathValue("id")]
+	if _, exists := s.library.movie(r.PathValue("id")); !found || !exists {
+		http.NotFound(w, r)
+		return
+	}
+	for _, library := range s.library.libraries {
+		if library.ID == libraryID {
+			writeJSON(w, []models.BaseItem{
+				{ID: library.ID, Name: library.Name, Type: "CollectionFolder"},
+				{ID: demoID("folder", "root"), Name: "root", Type: "UserRootFolder"},
+			})
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// changeUserData applies change to the play status of the requested item for the user of the userId parameter
+func (s *Server) changeUserData(w http.ResponseWriter, r *http.Request, change func(data *models.UserItemData) error) {
+	s.library.mutex.Lock()
+	defer s.library.mutex.Unlock()
+	id, userID := r.PathValue("id"), r.URL.Query().Get("userId")
+	userData, found := s.library.userData[userID]
+	if _, exists := s.library.movie(id); !found || !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := userData[id]
+	if err := change(&data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userData[id] = data
+	writeJSON(w, data)
+}
+
+func (s *Server) setPlayed(played bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.changeUserData(w, r, func(data *models.UserItemData) error {
+			data.Played = played
+			data.PlaybackPositionTicks = 0
+			if played {
+				data.PlayCount++
+				data.LastPlayedDate = time.Now().UTC().Format(time.RFC3339)
+			}
+			return nil
+		})
+	}
+}
+
+func (s *Server) setFavorite(favorite bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.changeUserData(w, r, func(data *models.UserItemData) error {
+			data.IsFavorite = favorite
+			return nil
+		})
+	}
+}
+
+func (s *Server) getUserData(w http.ResponseWriter, r *http.Request) {
+	s.changeUserData(w, r, func(*models.UserItemData) error { return nil })
+}
+
+// updateUserData changes the fields of the play status present in the request body, the other ones being kept
+func (s *Server) updateUserData(w http.ResponseWriter, r *http.Request) {
+	s.changeUserData(w, r, func(data *models.UserItemData) error {
+		return json.NewDecoder(r.Body).Decode(data)
+	})
+}
+
+// changePlaylist applies change to the entries of the requested playlist
+func (s *Server) changePlaylist(w http.ResponseWriter, r *http.Request, change func(playlist *playlist) error) {
+	s.library.mutex.Lock()
+	defer s.library.mutex.Unlock()
+	for i := range s.library.playlists {
+		if s.library.playlists[i].ID == r.PathValue("id") {
+			if err := change(&s.library.playlists[i]); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (s *Server) getPlaylistEntries(w http.ResponseWriter, r *http.Request) {
+	s.changePlaylist(w, r, func(playlist *playlist) error {
+		writeJSON(w, map[string]any{"Items": playlist.entries, "TotalRecordCount": len(playlist.entries)})
+		return nil
+	})
+}
+
+func (s *Server) addPlaylistEntries(w http.ResponseWriter, r *http.Request) {
+	s.changePlaylist(w, r, func(playlist *playlist) error {
+		for _, id := range strings.Split(r.URL.Query().Get("Ids"), ",") {
+			movie, found := s.library.movie(id)
+			if !found {
+				return fmt.Errorf("unknown item %s", id)
+			}
+			playlist.entries = append(playlist.entries, models.PlaylistEntry{
+				ID: movie.ID, Name: movie.Name, PlaylistItemID: demoID("entry", fmt.Sprintf("%s/%s/%d", playlist.ID, id, time.Now().UnixNano())),
+			})
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+func (s *Server) removePlaylistEntries(w http.ResponseWriter, r *http.Request) {
+	s.changePlaylist(w, r, func(playlist *playlist) error {
+		entryIDs := strings.Split(r.URL.Query().Get("EntryIds"), ",")
+		playlist.entries = slices.DeleteFunc(playlist.entries, func(entry models.PlaylistEntry) bool {
+			return slices.Contains(entryIDs, entry.PlaylistItemID)
+		})
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+func (s *Server) movePlaylistEntry(w http.ResponseWriter, r *http.Request) {
+	s.changePlaylist(w, r, func(playlist *playlist) error {
+		index, err := strconv.Atoi(r.PathValue("index"))
+		from := slices.IndexFunc(playlist.entries, func(entry models.PlaylistEntry) bool {
+			return entry.PlaylistItemID == r.PathValue("entry")
+		})
+		if err != nil || from < 0 || index < 0 || index >= len(playlist.entries) {
+			return fmt.Errorf("invalid move of entry %s to %s", r.PathValue("entry"), r.PathValue("index"))
+		}
+		entry := playlist.entries[from]
+		playlist.entries = slices.Insert(slices.Delete(playlist.entries, from, from+1), index, entry)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
+
+// movie returns a movie of the library by ID
+func (l *Library) movie(id string) (models.Movie, bool) {
+	index := slices.IndexFunc(l.movies, func(movie models.Movie) bool { return movie.ID == id })
+	if index < 0 {
+		return models.Movie{}, false
+	}
+	return l.movies[index], true
+}
+
+// withUserData returns the movie with the play status of a user
+func (l *Library) withUserData(movie models.Movie, userID string) models.Movie {
+	movie.UserData = l.userData[userID][movie.ID]
+	return movie
+}
@@ -0,0 +1,154 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"jellyfin-duplicate/constants"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// subtitleExtensions lists the extensions of the text and image subtitles compared by content
+var subtitleExtensions = map[string]bool{
+	".srt": true, ".ass": true, ".ssa": true, ".sub": true, ".vtt": true, ".sup": true,
+}
+
+// videoExtensions lists the extensions of the videos checked for samples
+var videoExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".m4v": true, ".avi": true, ".mov": true, ".wmv": true, ".ts": true,
+	".m2ts": true, ".webm": true, ".mpg": true, ".mpeg": true,
+}
+
+// partialExtensions lists the extensions of the files left by interrupted downloads
+var partialExtensions = map[string]bool{
+	".part": true, ".partial": true, ".crdownload": true, ".!qb": true, ".!ut": true, ".aria2": true,
+}
+
+// sampleFolders are the subfolders holding the sample videos of releases
+var sampleFolders = map[string]bool{"sample": true, "samples": true}
+
+// samplePattern matches the names of sample videos, such as "movie-sample.mkv" or "Sample.mkv"
+var samplePattern = regexp.MustCompile(`(?i)(^|[^a-z0-9])sample([^a-z0-9]|$)`)
+
+// Clutter is a redundant file of a movie folder
+type Clutter struct {
+	Path string
+	Kind constants.ClutterKind
+	Size int64
+	// DuplicateOf is the subtitle with the same content which is kept, for duplicate subtitles
+	DuplicateOf string
+}
+
+// FindClutter lists the redundant files of a movie folder: subtitles with the same content as another one,
+// sample videos, in the folder or its sample subfolder, and leftovers of interrupted downloads. The videos
+// of the library are never reported, whatever their name.
+func FindClutter(dir string, videos map[string]bool) ([]Clutter, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folder %s: %v", dir, err)
+	}
+
+	var clutter []Clutter
+	subtitlesBySize := map[int64][]string{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() && sampleFolders[strings.ToLower(entry.Name())] {
+			clutter = append(clutter, findSampleFolder(path, videos)...)
+			continue
+		}
+		if !entry.Type().IsRegular() || videos[path] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		extension := strings.ToLower(filepath.Ext(entry.Name()))
+		switch {
+		case partialExtensions[extension]:
+			clutter = append(clutter, Clutter{Path: path, Kind: constants.PartialDownload, Size: info.Size()})
+		case videoExtensions[extension] && samplePattern.MatchString(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))):
+			clutter = append(clutter, Clutter{Path: path, Kind: constants.SampleFile, Size: info.Size()})
+		case subtitleExtensions[extension] && info.Size() > 0:
+			subtitlesBySize[info.Size()] = append(subtitlesBySize[info.Size()], path)
+		}
+	}
+
+	for size, paths := range subtitlesBySize {
+		if len(paths) > 1 {
+			clutter = append(clutter, duplicateSubtitles(paths, size)...)
+		}
+	}
+
+	sort.Slice(clutter, func(i, j int) bool {
+		return clutter[i].Path < clutter[j].Path
+	})
+	return clutter, nil
+}
+
+// findSampleFolder lists the videos of the sample subfolder of a release, all of them being samples
+func findSampleFolder(dir string, videos map[string]bool) []Clutter {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var samples []Clutter
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.Type().IsRegular() || videos[path] || !videoExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			samples = append(samples, Clutter{Path: path, Kind: constants.SampleFile, Size: info.Size()})
+		}
+	}
+	return samples
+}
+
+// duplicateSubtitles compares the content of subtitles of the same size. The subtitle with the longest name,
+// usually telling its language, is kept and the others are reported as its duplicates.
+func duplicateSubtitles(paths []string, size int64) []Clutter {
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) > len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+
+	var duplicates []Clutter
+	kept := map[string]string{}
+	for _, path := range paths {
+		checksum, err := fileChecksum(path)
+		if err != nil {
+			continue
+		}
+		if original, found := kept[checksum]; found {
+			duplicates = append(duplicates, Clutter{Path: path, Kind: constants.DuplicateSubtitle, Size: size, DuplicateOf: original})
+			continue
+		}
+		kept[checksum] = path
+	}
+	return duplicates
+}
+
+// fileChecksum returns the SHA-256 checksum of the content of a file
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
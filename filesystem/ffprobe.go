@@ -0,0 +1,136 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// ffprobeTimeout bounds the time ffprobe takes to read a file
+const ffprobeTimeout = 2 * time.Minute
+
+// FFprobe runs the ffprobe executable to read the streams of local media files
+type FFprobe struct {
+	path string
+}
+
+// NewFFprobe looks ffprobe up, by path or by name in PATH
+func NewFFprobe(path string) (*FFprobe, error) {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe is not available: %v", err)
+	}
+	return &FFprobe{path: resolved}, nil
+}
+
+// Inspection holds the stream details of a media file read by ffprobe
+type Inspection struct {
+	// VideoBitrate is the bitrate of the video stream in bits per second, the overall bitrate of the file
+	// when the container does not record it
+	VideoBitrate int64
+	// Interlaced is set when the video stream is interlaced
+	Interlaced bool
+	// AudioChannels and AudioLayout describe the audio stream with the most channels, such as 6 and "5.1(side)"
+	AudioChannels int
+	AudioLayout   string
+}
+
+// probeOutput is the JSON output of ffprobe, numbers being reported as strings
+type probeOutput struct {
+	Streams []struct {
+		CodecType     string            `json:"codec_type"`
+		BitRate       string            `json:"bit_rate"`
+		FieldOrder    string            `json:"field_order"`
+		Channels      int               `json:"channels"`
+		ChannelLayout string            `json:"channel_layout"`
+		FrameRate     string            `json:"r_frame_rate"`
+		Packets       string            `json:"nb_read_packets"`
+		Tags          map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		BitRate  string `json:"bit_rate"`
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// run runs ffprobe on a file with the given arguments, parsing its JSON output
+func (f *FFprobe) run(path string, args ...string) (probeOutput, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ffprobeTimeout)
+	defer cancel()
+
+	args = append(append([]string{"-v", "error"}, args...), "-of", "json", path)
+	output, err := exec.CommandContext(ctx, f.path, args...).Output()
+	if err != nil {
+		return probeOutput{}, fmt.Errorf("failed to run ffprobe on %s: %v", path, err)
+	}
+
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return probeOutput{}, fmt.Errorf("failed to parse ffprobe output for %s: %v", path, err)
+	}
+	return probe, nil
+}
+
+// Inspect reads the true bitrate and interlacing of the video stream of a file, and the layout of its audio
+func (f *FFprobe) Inspect(path string) (Inspection, error) {
+	probe, err := f.run(path, "-show_entries",
+		"stream=codec_type,bit_rate,field_order,channels,channel_layout:stream_tags=BPS:format=bit_rate")
+	if err != nil {
+		return Inspection{}, err
+	}
+
+	var inspection Inspection
+	foundVideo := false
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			if foundVideo {
+				continue
+			}
+			foundVideo = true
+			// Matroska records the bitrate of its streams in tags
+			bitrate := stream.BitRate
+			if bitrate == "" || bitrate == "N/A" {
+				bitrate = stream.Tags["BPS"]
+			}
+			inspection.VideoBitrate, _ = strconv.ParseInt(bitrate, 10, 64)
+			inspection.Interlaced = stream.FieldOrder != "" && stream.FieldOrder != "progressive" && stream.FieldOrder != "unknown"
+		case "audio":
+			if stream.Channels > inspection.AudioChannels {
+				inspection.AudioChannels = stream.Channels
+				inspection.AudioLayout = stream.ChannelLayout
+			}
+		}
+	}
+	if !foundVideo {
+		return Inspection{}, fmt.Errorf("no video stream found in %s", path)
+	}
+	if inspection.VideoBitrate == 0 {
+		inspection.VideoBitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	}
+	return inspection, nil
+}
+
+// signature builds the signature of the first video stream of a file, from its frame count and frame rate,
+// and the duration of the file
+func (f *FFprobe) signature(path string) (string, error) {
+	probe, err := f.run(path, "-select_streams", "v:0", "-count_packets",
+		"-show_entries", "stream=nb_read_packets,r_frame_rate:format=duration")
+	if err != nil {
+		return "", err
+	}
+	if len(probe.Streams) == 0 || probe.Streams[0].Packets == "" {
+		return "", fmt.Errorf("no video stream found in %s", path)
+	}
+	seconds, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid duration %q of %s: %v", probe.Format.Duration, path, err)
+	}
+
+	stream := probe.Streams[0]
+	return fmt.Sprintf("ffprobe:%s@%s:%ds", stream.Packets, stream.FrameRate, int64(math.Round(seconds))), nil
+}
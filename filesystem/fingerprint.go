@@ -0,0 +1,70 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// checksumSampleSize is the size of each of the samples read at the start, middle and end of a file
+const checksumSampleSize = 64 * 1024
+
+// Fingerprinter identifies the recording of a video file whatever its name and path: by its frame count,
+// frame rate and duration read with ffprobe when it is available, which also matches the same recording
+// remuxed in another container, otherwise by its duration and a partial checksum, matching identical copies
+type Fingerprinter struct {
+	// ffprobe is nil when it is not available
+	ffprobe *FFprobe
+}
+
+// NewFingerprinter creates a fingerprinter reading videos with ffprobe, or by partial checksums when it is nil
+func NewFingerprinter(ffprobe *FFprobe) *Fingerprinter {
+	return &Fingerprinter{ffprobe: ffprobe}
+}
+
+// Fingerprint identifies the recording of a local video file. duration is the duration known for the video,
+// 0 when unknown. Files ffprobe fails to read are fingerprinted by partial checksum.
+func (f *Fingerprinter) Fingerprint(path string, duration time.Duration) (string, error) {
+	if f.ffprobe != nil {
+		fingerprint, err := f.ffprobe.signature(path)
+		if err == nil {
+			return fingerprint, nil
+		}
+		logrus.Debugf("Failed to probe %s, using a partial checksum: %v", path, err)
+	}
+	return PartialChecksum(path, duration)
+}
+
+// PartialChecksum identifies a file by its duration, its size and a checksum of samples read at its start,
+// middle and end, so that large videos are not read entirely. duration is 0 when unknown.
+func PartialChecksum(path string, duration time.Duration) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	hash := sha256.New()
+	binary.Write(hash, binary.BigEndian, info.Size())
+	sample := make([]byte, checksumSampleSize)
+	for _, offset := range []int64{0, info.Size()/2 - checksumSampleSize/2, info.Size() - checksumSampleSize} {
+		n, err := file.ReadAt(sample, max(offset, 0))
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		hash.Write(sample[:n])
+	}
+
+	return fmt.Sprintf("checksum:%ds:%s", int64(duration.Round(time.Second).Seconds()), hex.EncodeToString(hash.Sum(nil)[:16])), nil
+}
@@ -0,0 +1,58 @@
+package filesystem
+
+import (
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"os"
+	"path/filepath"
+)
+
+// Link describes two paths of the same file
+type Link struct {
+	Type constants.LinkType
+	// Symlink is the path going through a symbolic link, empty for hard links or when both paths do
+	Symlink string
+}
+
+// DetectLink checks if two local paths are the same file, through a hard link or a symbolic link
+// to the file or to one of its folders. It returns false for different files.
+func DetectLink(path1, path2 string) (Link, bool, error) {
+	if filepath.Clean(path1) == filepath.Clean(path2) {
+		return Link{}, false, nil
+	}
+
+	info1, err := os.Stat(path1)
+	if err != nil {
+		return Link{}, false, fmt.Errorf("failed to read %s: %v", path1, err)
+	}
+	info2, err := os.Stat(path2)
+	if err != nil {
+		return Link{}, false, fmt.Errorf("failed to read %s: %v", path2, err)
+	}
+	if !os.SameFile(info1, info2) {
+		return Link{}, false, nil
+	}
+
+	// Paths resolving to the same real path go through a symbolic link, otherwise they are hard links
+	real1, err := filepath.EvalSymlinks(path1)
+	if err != nil {
+		return Link{}, false, fmt.Errorf("failed to resolve %s: %v", path1, err)
+	}
+	real2, err := filepath.EvalSymlinks(path2)
+	if err != nil {
+		return Link{}, false, fmt.Errorf("failed to resolve %s: %v", path2, err)
+	}
+	if real1 != real2 {
+		return Link{Type: constants.HardLink}, true, nil
+	}
+
+	// The link is unknown when both paths go through symbolic links, such as a linked mount point
+	link := Link{Type: constants.SymbolicLink}
+	linked1, linked2 := real1 != filepath.Clean(path1), real2 != filepath.Clean(path2)
+	if linked1 && !linked2 {
+		link.Symlink = path1
+	} else if linked2 && !linked1 {
+		link.Symlink = path2
+	}
+	return link, true, nil
+}
@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	confModels "jellyfin-duplicate/configuration/models"
+	"path/filepath"
+	"strings"
+)
+
+// PathMapper translates paths reported by Jellyfin into local paths
+type PathMapper struct {
+	mappings []confModels.PathMapping
+}
+
+func NewPathMapper(mappings []confModels.PathMapping) *PathMapper {
+	return &PathMapper{mappings: mappings}
+}
+
+// ToLocal maps a Jellyfin path to a local path using the longest matching prefix.
+// Jellyfin may run on Windows, both separators are handled in its paths.
+// The path is returned unchanged when no mapping matches.
+func (m *PathMapper) ToLocal(jellyfinPath string) string {
+	bestMatch := -1
+	for i, mapping := range m.mappings {
+		if !hasPathPrefix(jellyfinPath, mapping.Jellyfin) {
+			continue
+		}
+		if bestMatch == -1 || len(mapping.Jellyfin) > len(m.mappings[bestMatch].Jellyfin) {
+			bestMatch = i
+		}
+	}
+
+	if bestMatch == -1 {
+		return jellyfinPath
+	}
+
+	mapping := m.mappings[bestMatch]
+	relative := strings.TrimPrefix(jellyfinPath, trimSeparators(mapping.Jellyfin))
+	return filepath.Join(mapping.Local, filepath.FromSlash(strings.ReplaceAll(relative, `\`, "/")))
+}
+
+// ToJellyfin maps a local path back to a Jellyfin path using the longest matching local prefix, with the
+// separator of the Jellyfin path of the mapping. The path is returned unchanged when no mapping matches.
+func (m *PathMapper) ToJellyfin(localPath string) string {
+	localPath = filepath.ToSlash(localPath)
+	bestMatch := -1
+	for i, mapping := range m.mappings {
+		if !hasPathPrefix(localPath, filepath.ToSlash(mapping.Local)) {
+			continue
+		}
+		if bestMatch == -1 || len(mapping.Local) > len(m.mappings[bestMatch].Local) {
+			bestMatch = i
+		}
+	}
+
+	if bestMatch == -1 {
+		return localPath
+	}
+
+	mapping := m.mappings[bestMatch]
+	relative := strings.TrimPrefix(localPath, strings.TrimSuffix(filepath.ToSlash(mapping.Local), "/"))
+	if strings.Contains(mapping.Jellyfin, `\`) && !strings.Contains(mapping.Jellyfin, "/") {
+		relative = strings.ReplaceAll(relative, "/", `\`)
+	}
+	return trimSeparators(mapping.Jellyfin) + relative
+}
+
+// hasPathPrefix checks if path starts with prefix on a path component boundary, either separator ending a
+// component like in the paths of Jellyfin on Windows
+func hasPathPrefix(path, prefix string) bool {
+	prefix = trimSeparators(prefix)
+	if prefix == "" || !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/' || path[len(prefix)] == '\\'
+}
+
+// trimSeparators removes the trailing separators of a path, of either kind
+func trimSeparators(path string) string {
+	return strings.TrimRight(path, `/\`)
+}
+
+// JellyfinDir returns the folder of a Jellyfin path, split on either separator like in the paths of Jellyfin on
+// Windows, "." when the path has none
+func JellyfinDir(jellyfinPath string) string {
+	index := strings.LastIndexAny(jellyfinPath, `/\`)
+	switch {
+	case index < 0:
+		return "."
+	// The root keeps its separator, such as / or D:\
+	case index == 0 || (index == 2 && jellyfinPath[1] == ':'):
+		return jellyfinPath[:index+1]
+	}
+	return jellyfinPath[:index]
+}
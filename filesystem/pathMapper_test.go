@@ -0,0 +1,19 @@
+package filesystem
+
+import "testing"
+
+func TestJellyfinDir(t *testing.T) {
+	cases := map[string]string{
+		"/movies/Heat (1995)/Heat.mkv":      "/movies/Heat (1995)",
+		`D:\Movies\Heat (1995)\Heat.mkv`:    `D:\Movies\Heat (1995)`,
+		`\\nas\movies\Heat (1995)\Heat.mkv`: `\\nas\movies\Heat (1995)`,
+		`D:\Heat.mkv`:                       `D:\`,
+		"/Heat.mkv":                         "/",
+		"Heat.mkv":                          ".",
+	}
+	for jellyfinPath, expected := range cases {
+		if actual := JellyfinDir(jellyfinPath); actual != expected {
+			t.Errorf("JellyfinDir(%q) = %q, expected %q", jellyfinPath, actual, expected)
+		}
+	}
+}
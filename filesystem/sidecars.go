@@ -0,0 +1,83 @@
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sidecarExtensions lists the extensions of the metadata, artwork and subtitle files stored next to videos
+var sidecarExtensions = map[string]bool{
+	".nfo": true, ".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".tbn": true,
+	".srt": true, ".ass": true, ".ssa": true, ".sub": true, ".idx": true, ".vtt": true, ".sup": true,
+}
+
+// folderSidecarNames lists the sidecar names which apply to the whole folder rather than to one video
+var folderSidecarNames = map[string]bool{
+	"movie": true, "poster": true, "folder": true, "cover": true, "fanart": true, "backdrop": true,
+	"banner": true, "logo": true, "clearlogo": true, "clearart": true, "disc": true, "discart": true,
+	"landscape": true, "thumb": true,
+}
+
+// TransferSidecars copies the sidecar files (NFO, artwork, subtitles) of deletedFile next to keptFile,
+// when the kept copy does not have them yet. Files named after the deleted video are renamed after the
+// kept one, folder wide files keep their name. It returns the paths of the copied files.
+func TransferSidecars(deletedFile, keptFile string) ([]string, error) {
+	deletedDir, keptDir := filepath.Dir(deletedFile), filepath.Dir(keptFile)
+	deletedBase := strings.TrimSuffix(filepath.Base(deletedFile), filepath.Ext(deletedFile))
+	keptBase := strings.TrimSuffix(filepath.Base(keptFile), filepath.Ext(keptFile))
+
+	if _, err := os.Stat(keptDir); err != nil {
+		return nil, fmt.Errorf("folder of kept copy %s is not accessible: %v", keptDir, err)
+	}
+
+	entries, err := os.ReadDir(deletedDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folder %s: %v", deletedDir, err)
+	}
+
+	var copied []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !sidecarExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+
+		// Names of both videos may share a prefix, files of the kept video are never sidecars of the deleted one
+		if keptBase != deletedBase && (strings.HasPrefix(name, keptBase+".") || strings.HasPrefix(name, keptBase+"-")) {
+			continue
+		}
+
+		var targetName string
+		switch {
+		case strings.HasPrefix(name, deletedBase+".") || strings.HasPrefix(name, deletedBase+"-"):
+			targetName = keptBase + strings.TrimPrefix(name, deletedBase)
+		case folderSidecarNames[strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))]:
+			targetName = name
+		default:
+			// Belongs to another video of the same folder
+			continue
+		}
+
+		source := filepath.Join(deletedDir, name)
+		target := filepath.Join(keptDir, targetName)
+		if source == target {
+			continue
+		}
+		if _, err := os.Stat(target); err == nil {
+			// The kept copy already has its own version
+			continue
+		}
+
+		if err := copyFile(source, target); err != nil {
+			return copied, fmt.Errorf("failed to copy %s to %s: %v", source, target, err)
+		}
+		logrus.Infof("Copied sidecar %s to %s", source, target)
+		copied = append(copied, target)
+	}
+
+	return copied, nil
+}
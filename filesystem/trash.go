@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Trash moves files into a trash directory instead of deleting them
+type Trash struct {
+	dir string
+}
+
+func NewTrash(dir string) *Trash {
+	return &Trash{dir: dir}
+}
+
+// Move moves the file at localPath into the trash directory and returns its new location.
+// Files are stored in a timestamped sub directory so that identical names never collide.
+func (t *Trash) Move(localPath string) (string, error) {
+	if t.dir == "" {
+		return "", fmt.Errorf("trash directory not configured")
+	}
+
+	if _, err := os.Stat(localPath); err != nil {
+		return "", fmt.Errorf("file %s is not accessible: %v", localPath, err)
+	}
+
+	destinationDir := filepath.Join(t.dir, time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(destinationDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create trash directory %s: %v", destinationDir, err)
+	}
+
+	destination := filepath.Join(destinationDir, filepath.Base(localPath))
+	logrus.Debugf("Moving %s to %s", localPath, destination)
+
+	err := os.Rename(localPath, destination)
+	if errors.Is(err, syscall.EXDEV) {
+		// The trash lives on another device, fallback to copy and remove
+		err = moveAcrossDevices(localPath, destination)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to move %s to trash: %v", localPath, err)
+	}
+
+	logrus.Infof("Moved %s to trash at %s", localPath, destination)
+	return destination, nil
+}
+
+// CheckWritable verifies that the trash directory exists (or can be created) and is writable
+func (t *Trash) CheckWritable() error {
+	if t.dir == "" {
+		return fmt.Errorf("trash directory not configured")
+	}
+
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create trash directory %s: %v", t.dir, err)
+	}
+
+	probe, err := os.CreateTemp(t.dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("trash directory %s is not writable: %v", t.dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func moveAcrossDevices(source, destination string) error {
+	if err := copyFile(source, destination); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
+
+// copyFile copies source to destination, which must not exist yet
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(destination)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(destination)
+		return err
+	}
+
+	return nil
+}
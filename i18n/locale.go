@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/ar"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/he"
+	"github.com/go-playground/locales/it"
+	"github.com/go-playground/locales/pt"
+	"github.com/samber/lo"
+	"golang.org/x/text/language"
+)
+
+// Locale formats numbers and dates following the conventions of a language, and tells the direction of its text
+type Locale struct {
+	// Tag is the BCP 47 language tag, e.g. "fr"
+	Tag string `json:"tag"`
+	// Name is the name of the language in the language itself
+	Name       string `json:"name"`
+	RTL        bool   `json:"rtl"`
+	translator locales.Translator
+}
+
+// supportedLocales lists the available locales, the first one being the default
+var supportedLocales = []Locale{
+	{Tag: "en", Name: "English", translator: en.New()},
+	{Tag: "fr", Name: "Français", translator: fr.New()},
+	{Tag: "de", Name: "Deutsch", translator: de.New()},
+	{Tag: "es", Name: "Español", translator: es.New()},
+	{Tag: "it", Name: "Italiano", translator: it.New()},
+	{Tag: "pt", Name: "Português", translator: pt.New()},
+	{Tag: "ar", Name: "العربية", RTL: true, translator: ar.New()},
+	{Tag: "he", Name: "עברית", RTL: true, translator: he.New()},
+}
+
+// matcher finds the closest supported locale to the languages preferred by a browser
+var matcher = language.NewMatcher(lo.Map(supportedLocales, func(locale Locale, _ int) language.Tag {
+	return language.MustParse(locale.Tag)
+}))
+
+// Default returns the locale used when no preference matches a supported locale
+func Default() Locale {
+	return supportedLocales[0]
+}
+
+// Supported returns the available locales
+func Supported() []Locale {
+	return supportedLocales
+}
+
+// Lookup finds a supported locale by tag
+func Lookup(tag string) (Locale, bool) {
+	return lo.Find(supportedLocales, func(locale Locale) bool {
+		return locale.Tag == tag
+	})
+}
+
+// Match returns the supported locale closest to an Accept-Language header, the default locale without match
+func Match(acceptLanguage string) Locale {
+	_, index := language.MatchStrings(matcher, acceptLanguage)
+	return supportedLocales[index]
+}
+
+// Dir returns the direction of the text of the locale, "rtl" or "ltr", as used by the HTML dir attribute
+func (l Locale) Dir() string {
+	return lo.Ternary(l.RTL, "rtl", "ltr")
+}
+
+// FormatNumber formats a number with the given number of decimals, e.g. "1 234,5" in French
+func (l Locale) FormatNumber(value float64, decimals int) string {
+	return l.translator.FmtNumber(value, uint64(max(decimals, 0)))
+}
+
+// FormatDate formats the day of a time, e.g. "Mar 5, 2026" in English
+func (l Locale) FormatDate(t time.Time) string {
+	return l.translator.FmtDateMedium(t)
+}
+
+// FormatDateTime formats the day and the time of day of a time, e.g. "5 mars 2026 14:07" in French
+func (l Locale) FormatDateTime(t time.Time) string {
+	return l.translator.FmtDateMedium(t) + " " + l.translator.FmtTimeShort(t)
+}
+
+// FormatShortDateTime formats the numeric date and the time of day of a time, e.g. "05/03/2026 14:07" in French
+func (l Locale) FormatShortDateTime(t time.Time) string {
+	return l.translator.FmtDateShort(t) + " " + l.translator.FmtTimeShort(t)
+}
+
+// DecimalSeparator returns the separator of the decimals of the locale, e.g. "," in French
+func (l Locale) DecimalSeparator() string {
+	return strings.TrimFunc(l.translator.FmtNumber(0.5, 1), unicode.IsDigit)
+}
+
+// FormatDecimal formats a number with the given number of decimals and the decimal separator of the locale, but
+// without grouping separators, so that spreadsheets read it back as a number, e.g. "1234,5" in French
+func (l Locale) FormatDecimal(value float64, decimals int) string {
+	return strings.Replace(strconv.FormatFloat(value, 'f', max(decimals, 0), 64), ".", l.DecimalSeparator(), 1)
+}
@@ -0,0 +1,387 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jellyfin-duplicate/cluster"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/notifications"
+	"jellyfin-duplicate/storage"
+	"jellyfin-duplicate/storage/models"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// finishedJobsKept is the number of finished jobs kept in the history
+const finishedJobsKept = 100
+
+// Channels through which replicas sharing Redis wake their workers and cancel the jobs they run
+const (
+	wakeChannel   = "jobs:wake"
+	cancelChannel = "jobs:cancel"
+)
+
+// sharedPollInterval is how often idle replicas sharing Redis check the queue for the jobs queued by the others
+const sharedPollInterval = 5 * time.Second
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobFinished    = errors.New("job already finished")
+	ErrUnknownJobType = errors.New("unknown job type")
+	errInterrupted    = errors.New("interrupted by an application restart")
+	errMissedWindow   = errors.New("could not start before the end of its window")
+)
+
+// Progress reports the number of processed items out of the total
+type Progress func(done, total int)
+
+// Runner executes a job and returns its result. It must stop when ctx is cancelled.
+type Runner func(ctx context.Context, params json.RawMessage, progress Progress) (any, error)
+
+// Queue executes jobs one after the other in the background. Jobs are persisted in the store,
+// queued jobs survive restarts while running ones are marked as failed. Replicas sharing the store and Redis
+// share the queue, each job being run by one of them.
+type Queue struct {
+	store    *storage.Store
+	notifier *notifications.Notifier
+	runners  map[constants.JobType]Runner
+	wake     chan struct{}
+	// cluster is nil unless replicas share Redis
+	cluster *cluster.Cluster
+
+	// mutex guards the cancel function of the running job
+	mutex     sync.Mutex
+	runningID string
+	cancel    context.CancelFunc
+}
+
+// NewQueue creates a queue, shared with the other replicas through a cluster when not nil
+func NewQueue(store *storage.Store, notifier *notifications.Notifier, shared *cluster.Cluster) *Queue {
+	return &Queue{
+		store:    store,
+		notifier: notifier,
+		runners:  make(map[constants.JobType]Runner),
+		wake:     make(chan struct{}, 1),
+		cluster:  shared,
+	}
+}
+
+// Register sets the runner of a job type, before the queue is started
+func (q *Queue) Register(jobType constants.JobType, runner Runner) {
+	q.runners[jobType] = runner
+}
+
+// Start recovers the persisted jobs and starts the worker. The jobs run by other replicas are left running.
+func (q *Queue) Start() {
+	for _, job := range q.store.Jobs() {
+		if job.Status == constants.JobRunning && !q.runningElsewhere(job.ID) {
+			logrus.Warnf("Job %s (%s) was interrupted by a restart", job.ID, job.Type)
+			q.finish(job, nil, errInterrupted)
+		}
+	}
+
+	if q.cluster != nil {
+		q.cluster.Subscribe(wakeChannel, func(string) {
+			q.signal()
+		})
+		q.cluster.Subscribe(cancelChannel, func(id string) {
+			q.mutex.Lock()
+			defer q.mutex.Unlock()
+			if q.runningID == id {
+				logrus.Infof("Cancelling running job %s, as asked through another replica", id)
+				q.cancel()
+			}
+		})
+	}
+	go q.work()
+}
+
+// runningElsewhere checks if another replica holds the lock of a job
+func (q *Queue) runningElsewhere(id string) bool {
+	if q.cluster == nil {
+		return false
+	}
+	locked, err := q.cluster.Locked(jobLock(id))
+	if err != nil {
+		logrus.Warnf("Failed to check if job %s runs on another replica, considering it does: %v", id, err)
+		return true
+	}
+	return locked
+}
+
+// jobLock returns the name of the lock held by the replica running a job
+func jobLock(id string) string {
+	return "job:" + id
+}
+
+// Submit queues a new job
+func (q *Queue) Submit(jobType constants.JobType, params any) (models.Job, error) {
+	return q.submit(jobType, params, nil, nil)
+}
+
+// Schedule queues a new job started at runAt, failed instead when it cannot start before runBefore, if any
+func (q *Queue) Schedule(jobType constants.JobType, params any, runAt time.Time, runBefore *time.Time) (models.Job, error) {
+	return q.submit(jobType, params, &runAt, runBefore)
+}
+
+func (q *Queue) submit(jobType constants.JobType, params any, runAt, runBefore *time.Time) (models.Job, error) {
+	if _, ok := q.runners[jobType]; !ok {
+		return models.Job{}, fmt.Errorf("%w: %s", ErrUnknownJobType, jobType)
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return models.Job{}, fmt.Errorf("failed to serialize job parameters: %v", err)
+	}
+
+	job := models.Job{
+		ID:        newJobID(),
+		Type:      jobType,
+		Status:    constants.JobQueued,
+		Params:    data,
+		CreatedAt: time.Now(),
+		RunAt:     runAt,
+		RunBefore: runBefore,
+	}
+	if err := q.store.SaveJob(job); err != nil {
+		return models.Job{}, fmt.Errorf("failed to save job: %v", err)
+	}
+
+	if runAt != nil {
+		logrus.Infof("Job %s (%s) scheduled at %s", job.ID, job.Type, runAt.Format(time.RFC3339))
+	} else {
+		logrus.Infof("Job %s (%s) queued", job.ID, job.Type)
+	}
+	q.signal()
+	if q.cluster != nil {
+		// The worker of the first replica available starts it
+		if err := q.cluster.Publish(wakeChannel, job.ID); err != nil {
+			logrus.Warnf("Failed to wake the workers of the other replicas: %v", err)
+		}
+	}
+	return job, nil
+}
+
+// Get returns a job by ID
+func (q *Queue) Get(id string) (models.Job, bool) {
+	return q.store.Job(id)
+}
+
+// List returns all jobs, most recent first
+func (q *Queue) List() []models.Job {
+	jobs := q.store.Jobs()
+	for i, j := 0, len(jobs)-1; i < j; i, j = i+1, j-1 {
+		jobs[i], jobs[j] = jobs[j], jobs[i]
+	}
+	return jobs
+}
+
+// Cancel cancels a queued job, or asks a running job to stop
+func (q *Queue) Cancel(id string) (models.Job, error) {
+	job, ok := q.store.Job(id)
+	if !ok {
+		return models.Job{}, ErrJobNotFound
+	}
+	if job.IsFinished() {
+		return job, ErrJobFinished
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.runningID == id {
+		// The worker records the cancellation once the runner stopped
+		logrus.Infof("Cancelling running job %s", id)
+		q.cancel()
+		return job, nil
+	}
+
+	// Re-read the job, the worker may have started it in the meantime
+	job, _ = q.store.Job(id)
+	if job.Status == constants.JobRunning && q.cluster != nil {
+		// The replica running it records the cancellation
+		logrus.Infof("Asking the other replicas to cancel running job %s", id)
+		if err := q.cluster.Publish(cancelChannel, id); err != nil {
+			return job, fmt.Errorf("failed to cancel job %s on another replica: %v", id, err)
+		}
+		return job, nil
+	}
+	if job.Status != constants.JobQueued {
+		return job, ErrJobFinished
+	}
+	return q.finish(job, nil, context.Canceled), nil
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work runs queued jobs in creation order, waiting for new jobs or for the next scheduled one when none is due
+func (q *Queue) work() {
+	for {
+		job, ok, wakeAt := q.next()
+		if ok {
+			q.run(job)
+			continue
+		}
+		// Woken replicas may not have read the jobs queued by the others yet, so they check the queue again
+		if q.cluster != nil {
+			if poll := time.Now().Add(sharedPollInterval); wakeAt == nil || poll.Before(*wakeAt) {
+				wakeAt = &poll
+			}
+		}
+		if wakeAt == nil {
+			<-q.wake
+			continue
+		}
+
+		timer := time.NewTimer(time.Until(*wakeAt))
+		select {
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// next picks the oldest queued job which is due and marks it as running. Otherwise, it returns the time of
+// the next scheduled job, nil when none is scheduled. Scheduled jobs whose window ended are failed.
+func (q *Queue) next() (runningJob, bool, *time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var wakeAt *time.Time
+	for _, job := range q.store.Jobs() {
+		if job.Status != constants.JobQueued {
+			continue
+		}
+
+		now := time.Now()
+		if job.RunBefore != nil && now.After(*job.RunBefore) {
+			logrus.Warnf("Job %s (%s) missed its window, ending at %s", job.ID, job.Type, job.RunBefore.Format(time.RFC3339))
+			q.finish(job, nil, errMissedWindow)
+			continue
+		}
+		if job.RunAt != nil && job.RunAt.After(now) {
+			if wakeAt == nil || job.RunAt.Before(*wakeAt) {
+				wakeAt = job.RunAt
+			}
+			continue
+		}
+
+		job.Status = constants.JobRunning
+		job.StartedAt = &now
+		err := q.store.StartJob(job)
+		if errors.Is(err, storage.ErrJobTaken) {
+			logrus.Debugf("Job %s (%s) was started by another replica", job.ID, job.Type)
+			continue
+		}
+		if err != nil {
+			logrus.Errorf("Failed to save job %s: %v", job.ID, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		q.runningID = job.ID
+		q.cancel = cancel
+		return runningJob{Job: job, ctx: ctx}, true, nil
+	}
+	return runningJob{}, false, wakeAt
+}
+
+func (q *Queue) run(job runningJob) {
+	logrus.Infof("Job %s (%s) started", job.ID, job.Type)
+	if q.cluster != nil {
+		// Replicas starting meanwhile leave the job running
+		unlock, err := q.cluster.Lock(job.ctx, jobLock(job.ID))
+		if err != nil {
+			logrus.Warnf("Failed to lock job %s, a replica starting meanwhile would fail it: %v", job.ID, err)
+		} else {
+			defer unlock()
+		}
+	}
+
+	progress := func(done, total int) {
+		job.Progress, job.Total = done, total
+		if err := q.store.SaveJob(job.Job); err != nil {
+			logrus.Errorf("Failed to save progress of job %s: %v", job.ID, err)
+		}
+	}
+
+	result, err := q.runners[job.Type](job.ctx, job.Params, progress)
+
+	q.mutex.Lock()
+	if job.ctx.Err() != nil {
+		err = context.Canceled
+	}
+	q.cancel()
+	q.runningID, q.cancel = "", nil
+	q.mutex.Unlock()
+
+	q.finish(job.Job, result, err)
+}
+
+// finish records the final status of a job and sends the completion notification
+func (q *Queue) finish(job models.Job, result any, err error) models.Job {
+	now := time.Now()
+	job.FinishedAt = &now
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.Status = constants.JobCancelled
+		job.Error = "cancelled"
+	case err != nil:
+		job.Status = constants.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = constants.JobSucceeded
+	}
+
+	if result != nil {
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			logrus.Errorf("Failed to serialize result of job %s: %v", job.ID, marshalErr)
+		}
+		job.Result = data
+	}
+
+	if saveErr := q.store.SaveJob(job); saveErr != nil {
+		logrus.Errorf("Failed to save job %s: %v", job.ID, saveErr)
+	}
+	if pruneErr := q.store.PruneJobs(finishedJobsKept); pruneErr != nil {
+		logrus.Warnf("Failed to prune finished jobs: %v", pruneErr)
+	}
+
+	logrus.Infof("Job %s (%s) %s", job.ID, job.Type, job.Status)
+	q.notifier.Notify(notifications.Event{
+		Type:    constants.JobFinishedEvent,
+		Title:   fmt.Sprintf("Job %s %s", job.Type, job.Status),
+		Message: lo.Ternary(job.Error != "", job.Error, fmt.Sprintf("%d/%d items processed", job.Progress, job.Total)),
+		Data:    job,
+	})
+	return job
+}
+
+// runningJob is a job with the context cancelled to stop it
+type runningJob struct {
+	models.Job
+	ctx context.Context
+}
+
+func newJobID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(bytes)
+}
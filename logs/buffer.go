@@ -0,0 +1,118 @@
+package logs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// bufferSize is the number of log entries kept, the oldest ones being replaced
+	bufferSize = 1000
+	// subscriberBacklog is the number of entries waiting for a slow subscriber before the next ones are dropped
+	subscriberBacklog = 100
+)
+
+// Entry is a log entry as shown in the web interface
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+
+	level logrus.Level
+}
+
+// AtLeast checks if the entry is at the given level or more severe
+func (e Entry) AtLeast(level logrus.Level) bool {
+	return e.level <= level
+}
+
+// Buffer is a logrus hook keeping the last log entries in memory and forwarding new ones
+// to subscribers, so that logs can be read without access to the container
+type Buffer struct {
+	mutex       sync.Mutex
+	entries     []Entry
+	next        int
+	subscribers map[chan Entry]struct{}
+}
+
+func NewBuffer() *Buffer {
+	return &Buffer{
+		entries:     make([]Entry, 0, bufferSize),
+		subscribers: make(map[chan Entry]struct{}),
+	}
+}
+
+// Levels implements logrus.Hook, every level is kept and filtered when read
+func (b *Buffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (b *Buffer) Fire(logEntry *logrus.Entry) error {
+	entry := Entry{
+		Time:    logEntry.Time,
+		Level:   logEntry.Level.String(),
+		Message: logEntry.Message,
+		level:   logEntry.Level,
+	}
+	if len(logEntry.Data) > 0 {
+		entry.Fields = make(map[string]string, len(logEntry.Data))
+		for key, value := range logEntry.Data {
+			entry.Fields[key] = fmt.Sprint(value)
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.entries) < bufferSize {
+		b.entries = append(b.entries, entry)
+	} else {
+		b.entries[b.next] = entry
+		b.next = (b.next + 1) % bufferSize
+	}
+
+	// Logging must never wait for a subscriber, a slow one misses entries
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- entry:
+		default:
+		}
+	}
+	return nil
+}
+
+// Entries returns the kept entries at the given level or more severe, oldest first like in the log output
+func (b *Buffer) Entries(level logrus.Level) []Entry {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entries := make([]Entry, 0, len(b.entries))
+	for i := range b.entries {
+		// The oldest entry is at next once the buffer is full
+		entry := b.entries[(b.next+i)%len(b.entries)]
+		if entry.AtLeast(level) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Subscribe returns a channel receiving the new entries, until unsubscribe is called
+func (b *Buffer) Subscribe() (entries <-chan Entry, unsubscribe func()) {
+	subscriber := make(chan Entry, subscriberBacklog)
+
+	b.mutex.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mutex.Unlock()
+
+	return subscriber, func() {
+		b.mutex.Lock()
+		delete(b.subscribers, subscriber)
+		b.mutex.Unlock()
+	}
+}
@@ -0,0 +1,32 @@
+package logs
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FileHook is a logrus hook writing the entries to a file in its own format, independently of the console output
+type FileHook struct {
+	writer    io.Writer
+	formatter logrus.Formatter
+}
+
+func NewFileHook(writer io.Writer, formatter logrus.Formatter) *FileHook {
+	return &FileHook{writer: writer, formatter: formatter}
+}
+
+// Levels implements logrus.Hook, entries below the logger level are never fired
+func (h *FileHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (h *FileHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(line)
+	return err
+}
@@ -0,0 +1,98 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file rotated once it reaches its maximum size: the file is renamed with a ".1" suffix,
+// previous backups being shifted to ".2", ".3"... and the oldest ones above the maximum removed
+type RotatingFile struct {
+	mutex      sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// OpenRotatingFile opens the log file, creating it and its folder when needed. maxSize is in bytes.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the folder of %s: %v", path, err)
+	}
+	rotating := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := rotating.open(); err != nil {
+		return nil, err
+	}
+	return rotating, nil
+}
+
+// open appends to the log file, keeping track of its size
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %v", r.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read log file %s: %v", r.path, err)
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// Write implements io.Writer, rotating the file first when the entry would exceed its maximum size
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate closes the log file, shifts the backups and starts a new file
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file %s: %v", r.path, err)
+	}
+
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil {
+			return fmt.Errorf("failed to remove log file %s: %v", r.path, err)
+		}
+		return r.open()
+	}
+
+	os.Remove(r.backupPath(r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backupPath(i), r.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file %s: %v", r.backupPath(i), err)
+		}
+	}
+	if err := os.Rename(r.path, r.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate log file %s: %v", r.path, err)
+	}
+	return r.open()
+}
+
+// backupPath returns the path of the backup with the given number, 1 being the most recent
+func (r *RotatingFile) backupPath(number int) string {
+	return fmt.Sprintf("%s.%d", r.path, number)
+}
+
+// Close closes the log file
+func (r *RotatingFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
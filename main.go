@@ -2,21 +2,51 @@ package main
 
 import (
 	jellyfinClient "jellyfin-duplicate/client/jellyfin/http"
+	"jellyfin-duplicate/cluster"
+	"jellyfin-duplicate/commands"
 	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/demo"
+	"jellyfin-duplicate/jobs"
+	"jellyfin-duplicate/logs"
+	"jellyfin-duplicate/notifications"
 	server "jellyfin-duplicate/server"
+	"jellyfin-duplicate/storage"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	// Run a command instead of the web server when one is given, --demo serving a generated library instead of
+	// the Jellyfin server
+	demoMode := len(os.Args) == 2 && os.Args[1] == "--demo"
+	if len(os.Args) > 1 && !demoMode {
+		os.Exit(commands.Run(os.Args[1:]))
+	}
+
 	// Initialize with default logrus settings first
 	logrus.SetLevel(logrus.InfoLevel)
 	logrus.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp: true,
 	})
+	// Recent log entries are kept from the start, to be shown in the web interface
+	logBuffer := logs.NewBuffer()
+	logrus.AddHook(logBuffer)
 
-	logrus.Info("Starting jellyfin-duplicate application...")
+	logrus.Infof("Starting jellyfin-duplicate %s...", constants.VersionString())
+
+	if demoMode {
+		demoServer, err := demo.Start(demo.Generate())
+		if err != nil {
+			logrus.Fatalf("Failed to start demo mode: %v", err)
+		}
+		confServices.Override(demoServer.Configure)
+		logrus.Warnf("Demo mode: serving a generated library from %s instead of the Jellyfin server", demoServer.URL())
+	}
 
 	// Load configuration
 	logrus.Info("Loading configuration...")
@@ -26,45 +56,117 @@ func main() {
 	}
 
 	// Configure logrus based on config
-	confServices.ConfigureLogrus(&config.Logrus)
-
-	logrus.Infof("Configuration loaded successfully. Jellyfin URL: %s", config.Jellyfin.URL)
+	if err := confServices.ConfigureLogrus(&config.Logrus); err != nil {
+		logrus.Fatalf("Failed to configure logs: %v", err)
+	}
 
 	// Configure GIN mode
 	confServices.ConfigureGINMode(config.Environment)
 
+	// Without Jellyfin credentials, the setup wizard is served until it writes them
+	if err := confServices.CheckJellyfinCredentials(config); err != nil {
+		logrus.Warnf("Jellyfin is not configured: %v", err)
+		config, err = server.RunSetup(config)
+		if err != nil {
+			logrus.Fatalf("Setup failed: %v", err)
+		}
+		if err := confServices.ConfigureLogrus(&config.Logrus); err != nil {
+			logrus.Fatalf("Failed to configure logs: %v", err)
+		}
+	}
+
+	logrus.Infof("Configuration loaded successfully. Jellyfin URL: %s", config.Jellyfin.URL)
+
 	// Initialize Jellyfin client
 	logrus.Info("Initializing Jellyfin client...")
-	jellyfinClient := jellyfinClient.NewClient(config.Jellyfin.URL, config.Jellyfin.APIKey, config.Jellyfin.UserID)
+	jellyfinClient := jellyfinClient.NewClient(config.Jellyfin.URL, config.Jellyfin.APIKey, config.Jellyfin.UserID, jellyfinClient.Identity{
+		Client:   config.Device.Client,
+		Device:   config.Device.Name,
+		DeviceID: config.Device.ID,
+		Version:  constants.Version,
+	})
+
+	jellyfinClient.RestrictLibraries(config.Scan.Libraries)
+	jellyfinClient.IncludeHomeVideos(config.Scan.HomeVideos)
+	jellyfinClient.SetConcurrency(config.Scan.Concurrency)
+	jellyfinClient.SetOperators(config.Jellyfin.OperatorIDs)
+
+	// Adapt API calls to the server version, the oldest supported style is used when unknown
+	if _, err := jellyfinClient.DetectServerVersion(); err != nil {
+		logrus.Warnf("Failed to detect Jellyfin version, using legacy API style: %v", err)
+	}
 
 	logrus.Info("Jellyfin client initialized successfully")
 
+	// Load persisted state
+	backend, err := storage.OpenBackend(config.Storage.Backend, config.Storage.DSN, config.DataDir)
+	if err != nil {
+		logrus.Fatalf("Failed to open storage: %v", err)
+	}
+	logrus.Infof("Loading state from %s...", backend)
+	store, err := storage.NewStore(backend, time.Duration(config.Storage.RefreshInterval)*time.Second)
+	if err != nil {
+		logrus.Fatalf("Failed to load state: %v", err)
+	}
+	defer store.Close()
+
+	// Replicas share their caches, scans and jobs through Redis
+	var shared *cluster.Cluster
+	if config.Cache.Backend == constants.RedisCache {
+		shared, err = cluster.Connect(config.Cache.URL, config.Cache.KeyPrefix)
+		if err != nil {
+			logrus.Fatalf("Failed to connect to the cache: %v", err)
+		}
+		defer shared.Close()
+		logrus.Infof("Sharing caches, scans and jobs with the other replicas through %s", shared)
+	}
+
+	// Long operations run in the background job queue
+	notifier := notifications.NewNotifier(config.Notifications)
+	queue := jobs.NewQueue(store, notifier, shared)
+
 	// Create Gin router
 	logrus.Info("Setting up web server...")
 	r := gin.Default()
+	if err := r.SetTrustedProxies(config.Auth.TrustedProxies); err != nil {
+		logrus.Fatalf("Invalid auth.trusted_proxies: %v", err)
+	}
+	if config.CORS.Enabled() {
+		// Registered on the router rather than the routes, to answer the preflight requests of every path
+		r.Use(server.CORS(config.CORS))
+		logrus.Infof("Cross-origin requests allowed from %v", config.CORS.AllowedOrigins)
+	}
 
 	// Load HTML templates
 	logrus.Info("Loading HTML templates...")
-	r.LoadHTMLGlob("server/templates/*")
+	// Templates are parsed again on every render in debug mode, to see changes without restarting
+	templates, err := server.LoadTemplates("server/templates", gin.IsDebugging())
+	if err != nil {
+		logrus.Fatalf("Failed to load templates: %v", err)
+	}
+	r.HTMLRender = templates
 
 	// Set up handlers
 	logrus.Info("Initializing handlers...")
-	handler := server.NewHandler(jellyfinClient)
+	handler := server.NewHandler(jellyfinClient, config, store, queue, notifier, shared)
+	queue.Start()
+	defer handler.StartEarlyWarning()()
 
 	// Routes
 	logrus.Info("Configuring routes...")
-	r.GET("/", handler.GetHomePage)
-	r.GET("/analysis", handler.GetDuplicatesPage)
-	r.GET("/api/duplicates", handler.GetDuplicatesJSON)
-	r.GET("/api/mark-as-seen", handler.MarkMovieAsSeen)
-	r.GET("/api/delete-movie", handler.DeleteMovie)
+	if err := server.RegisterRoutes(r, handler, config, logBuffer); err != nil {
+		logrus.Fatalf("Failed to configure routes: %v", err)
+	}
 	logrus.Info("Routes configured successfully")
 
 	// Start server
-	port := ":" + config.ServerPort
-	logrus.Infof("Starting server on %s", port)
-	logrus.Infof("Application ready. Access the web interface at http://localhost%s", port)
-	if err := r.Run(port); err != nil {
+	logrus.Infof("Starting server on %s", strings.Join(config.Listen, ", "))
+	servers, err := server.Listen(config, r)
+	if err != nil {
 		logrus.Fatalf("Failed to start server: %v", err)
 	}
+	for _, url := range server.URLs(config) {
+		logrus.Infof("Application ready. Access the web interface at %s/", url)
+	}
+	logrus.Fatalf("Server stopped: %v", <-servers.Errors())
 }
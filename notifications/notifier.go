@@ -0,0 +1,129 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// Event is a notification sent to the configured webhook
+type Event struct {
+	Type    constants.NotificationEvent `json:"type"`
+	Title   string                      `json:"title"`
+	Message string                      `json:"message"`
+	Data    any                         `json:"data,omitempty"`
+	Time    time.Time                   `json:"time"`
+	// Severity is the severity of the duplicate the event is about, routing it to the webhook of the severity
+	Severity constants.Severity `json:"severity,omitempty"`
+	// UserID and UserName are the Jellyfin user the event is about, routing it to the webhook of the user
+	UserID   string `json:"user_id,omitempty"`
+	UserName string `json:"user_name,omitempty"`
+}
+
+// Notifier sends events to a webhook, chosen by the user or the severity of the event
+type Notifier struct {
+	webhookURL       string
+	severityWebhooks map[constants.Severity]string
+	userWebhooks     map[string]string
+	client           *resty.Client
+	// muted only logs the events, see SetMuted
+	muted atomic.Bool
+}
+
+func NewNotifier(config confModels.NotificationsConfig) *Notifier {
+	notifier := &Notifier{
+		webhookURL:       config.WebhookURL,
+		severityWebhooks: config.SeverityWebhooks,
+		userWebhooks:     config.UserWebhooks,
+		client:           resty.New().SetTimeout(10 * time.Second),
+	}
+	notifier.muted.Store(config.Muted)
+	return notifier
+}
+
+// SetMuted stops or resumes posting the events to the webhooks, they are still logged
+func (n *Notifier) SetMuted(muted bool) {
+	n.muted.Store(muted)
+}
+
+// Notify logs the event and posts it to the webhook. Failures are logged, a notification never
+// makes the notified operation fail.
+func (n *Notifier) Notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	logrus.Infof("Notification %s: %s - %s", event.Type, event.Title, event.Message)
+
+	webhookURL := n.webhookURL
+	if url, found := n.severityWebhooks[event.Severity]; found && event.Severity != "" {
+		webhookURL = url
+	}
+	if url, found := n.userWebhook(event); found {
+		webhookURL = url
+	}
+	if webhookURL == "" || n.muted.Load() {
+		return
+	}
+
+	if err := n.post(webhookURL, event); err != nil {
+		logrus.Warnf("Failed to send notification %s to webhook: %v", event.Type, err)
+	}
+}
+
+// userWebhook returns the webhook of the user an event is about, configured by user ID or name
+func (n *Notifier) userWebhook(event Event) (string, bool) {
+	if event.UserID != "" {
+		if url, found := n.userWebhooks[event.UserID]; found {
+			return url, true
+		}
+	}
+	if event.UserName != "" {
+		if url, found := n.userWebhooks[event.UserName]; found {
+			return url, true
+		}
+	}
+	return "", false
+}
+
+func (n *Notifier) post(webhookURL string, event Event) error {
+	resp, err := n.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(event).
+		Post(webhookURL)
+
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %v", err)
+	}
+	if resp.IsError() {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode())
+	}
+	return nil
+}
+
+// CheckWebhook verifies that a webhook URL is valid and that its server answers, without posting an event. Any
+// status is accepted, as webhooks usually only accept the POST requests of the events.
+func CheckWebhook(webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid webhook URL, an http or https URL is expected")
+	}
+	if _, err := resty.New().SetTimeout(10 * time.Second).R().Head(webhookURL); err != nil {
+		// The URL is left out of the error, as webhooks usually carry their token in it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s is not reachable: %v", parsed.Host, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats describes the usage of a cache since it was created
+type Stats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	// HitRate is the share of lookups served by the cache, between 0 and 1, 0 before any lookup
+	HitRate float64 `json:"hit_rate"`
+	// EstimatedBytes is an estimate of the memory held by the entries, see SizeOf
+	EstimatedBytes int64 `json:"estimated_bytes"`
+}
+
+// Counter counts the hits and misses of a cache. The zero value is ready to use.
+type Counter struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Hit records a lookup served by the cache
+func (c *Counter) Hit() {
+	c.hits.Add(1)
+}
+
+// Miss records a lookup the cache could not serve
+func (c *Counter) Miss() {
+	c.misses.Add(1)
+}
+
+// Stats returns the counted hits and misses for the entries and estimated memory of the cache
+func (c *Counter) Stats(entries int, estimatedBytes int64) Stats {
+	hits, misses := c.hits.Load(), c.misses.Load()
+	stats := Stats{Entries: entries, Hits: hits, Misses: misses, EstimatedBytes: estimatedBytes}
+	if hits+misses > 0 {
+		stats.HitRate = float64(hits) / float64(hits+misses)
+	}
+	return stats
+}
+
+// Cache is a map safe for concurrent use which counts its hits and misses.
+// Entries are kept until flushed, or until they expire from the remote cache holding them.
+type Cache[K comparable, V any] struct {
+	mutex   sync.RWMutex
+	entries map[K]V
+	counter Counter
+	// remote holds the entries instead of entries when set, encoded as JSON
+	remote Remote
+}
+
+func New[K comparable, V any]() *Cache[K, V] {
+	return &Cache[K, V]{entries: make(map[K]V)}
+}
+
+// NewRemote creates a cache whose entries are held by a remote cache, shared with other processes. Keys are
+// formatted with fmt.Sprint and values encoded as JSON, so only their exported fields are kept. A nil remote
+// creates an in-memory cache.
+func NewRemote[K comparable, V any](remote Remote) *Cache[K, V] {
+	if remote == nil {
+		return New[K, V]()
+	}
+	return &Cache[K, V]{remote: remote}
+}
+
+// Get returns the value of a key, counting a hit when found and a miss otherwise
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	var value V
+	found := false
+	if c.remote != nil {
+		var data []byte
+		data, found = c.remote.Get(fmt.Sprint(key))
+		found = found && json.Unmarshal(data, &value) == nil
+	} else {
+		c.mutex.RLock()
+		value, found = c.entries[key]
+		c.mutex.RUnlock()
+	}
+
+	if found {
+		c.counter.Hit()
+	} else {
+		c.counter.Miss()
+	}
+	return value, found
+}
+
+// Set adds or replaces the value of a key
+func (c *Cache[K, V]) Set(key K, value V) {
+	if c.remote != nil {
+		if data, err := json.Marshal(value); err == nil {
+			c.remote.Set(fmt.Sprint(key), data)
+		}
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = value
+}
+
+// Len returns the number of entries
+func (c *Cache[K, V]) Len() int {
+	if c.remote != nil {
+		entries, _ := c.remote.Usage()
+		return entries
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.entries)
+}
+
+// Flush removes every entry and returns how many were removed. Hits and misses are kept.
+func (c *Cache[K, V]) Flush() int {
+	if c.remote != nil {
+		return c.remote.Flush()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	flushed := len(c.entries)
+	c.entries = make(map[K]V)
+	return flushed
+}
+
+// Stats returns the usage of the cache. The hits and misses of a remote cache are the ones of this process.
+func (c *Cache[K, V]) Stats() Stats {
+	if c.remote != nil {
+		entries, bytes := c.remote.Usage()
+		return c.counter.Stats(entries, bytes)
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.counter.Stats(len(c.entries), SizeOf(c.entries))
+}
@@ -0,0 +1,15 @@
+// Package cache keeps in-memory caches which report their usage, so that they can be inspected
+// and flushed at runtime:
+//
+//	names := cache.New[string, string]()
+//	names.Set("user-id", "Alice")
+//	name, found := names.Get("user-id")
+//	stats := names.Stats() // entries, hits, misses, hit rate and estimated memory
+//	names.Flush()
+//
+// NewRemote creates a cache whose entries are held by a Remote, such as a Redis server, shared by
+// several processes.
+//
+// Caches holding a single value, such as the latest scan result, count their hits with a Counter
+// and estimate their memory with SizeOf.
+package cache
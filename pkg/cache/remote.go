@@ -0,0 +1,12 @@
+package cache
+
+// Remote is a cache shared by several processes, such as a Redis server. Its errors are handled by the
+// implementation: a value which cannot be read is missing, and a value which cannot be written is not cached.
+type Remote interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	// Flush removes every entry and returns how many were removed
+	Flush() int
+	// Usage returns the number of entries and the bytes of their values
+	Usage() (int, int64)
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"reflect"
+)
+
+// mapEntryOverhead approximates the bookkeeping memory of a map entry besides its key and value
+const mapEntryOverhead = 16
+
+// SizeOf estimates the memory held by a value, following pointers, slices, maps and strings.
+// The estimate ignores allocator rounding and memory shared with other values, it is meant to
+// compare caches and watch them grow rather than to account for every byte.
+func SizeOf(value any) int64 {
+	if value == nil {
+		return 0
+	}
+	v := reflect.ValueOf(value)
+	return int64(v.Type().Size()) + indirectSize(v, make(map[uintptr]bool))
+}
+
+// indirectSize returns the memory referenced by a value, beyond the value itself.
+// Pointers already seen are counted once.
+func indirectSize(v reflect.Value, seen map[uintptr]bool) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		if v.Kind() == reflect.Pointer {
+			if seen[v.Pointer()] {
+				return 0
+			}
+			seen[v.Pointer()] = true
+		}
+		elem := v.Elem()
+		return int64(elem.Type().Size()) + indirectSize(elem, seen)
+	case reflect.Slice:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += indirectSize(v.Index(i), seen)
+		}
+		return size
+	case reflect.Array:
+		var size int64
+		for i := 0; i < v.Len(); i++ {
+			size += indirectSize(v.Index(i), seen)
+		}
+		return size
+	case reflect.Map:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+		entrySize := int64(v.Type().Key().Size()+v.Type().Elem().Size()) + mapEntryOverhead
+		size := int64(v.Len()) * entrySize
+		iter := v.MapRange()
+		for iter.Next() {
+			size += indirectSize(iter.Key(), seen) + indirectSize(iter.Value(), seen)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += indirectSize(v.Field(i), seen)
+		}
+		return size
+	default:
+		return 0
+	}
+}
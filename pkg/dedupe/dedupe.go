@@ -0,0 +1,324 @@
+package dedupe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultDuplicateThreshold is the path similarity percentage from which a pair is a duplicate
+const DefaultDuplicateThreshold = 95
+
+// Item is a media item as seen by the detection engine
+type Item struct {
+	ID   string
+	Name string
+	Year int
+	Path string
+	// Size in bytes and Bitrate in bits per second of the file, 0 when unknown
+	Size    int64
+	Bitrate int64
+	// Interlaced and AudioChannels describe the streams of the file when they were inspected, 0 channels when unknown
+	Interlaced    bool
+	AudioChannels int
+	// Date is when the file was last modified or added, zero when unknown
+	Date time.Time
+	// ProviderIDs identify the item in external databases, such as "tmdb:603", used to confirm the pairs
+	// of items whose years differ
+	ProviderIDs []string
+	// ContentFingerprint identifies the content of the file, such as a checksum: items sharing one are duplicates
+	// whatever their names and paths. Empty when unknown.
+	ContentFingerprint string
+}
+
+// GroupKey returns the key grouping the items compared with each other: items sharing a name
+// and a year, so that remakes with the same name are never compared
+func (i Item) GroupKey() string {
+	return fmt.Sprintf("%s-%d", i.Name, i.Year)
+}
+
+// Options tunes the detection. The zero value compares every pair of every group of 2 items or more.
+type Options struct {
+	// MinGroupSize is the number of items sharing a group key for them to be compared, 2 when 0
+	MinGroupSize int
+	// MaxPairsPerGroup caps the pairs compared within a group, unlimited when 0
+	MaxPairsPerGroup int
+	// DuplicateThreshold is the path similarity percentage of duplicates, DefaultDuplicateThreshold when 0
+	DuplicateThreshold int
+	// PairThreshold overrides DuplicateThreshold for a pair when it returns more than 0, e.g. with a
+	// threshold tuned per library. It receives the positions of the items in the slice given to Find.
+	PairThreshold func(index1, index2 int) int
+	// Skip leaves a pair out of the results, e.g. a pair the user ignored. It receives the positions
+	// of the items in the slice given to Find.
+	Skip func(index1, index2 int) bool
+	// Recommend picks the copy to delete of a duplicate, such as RecommendOlder. Recommend is used when nil.
+	Recommend func(item1, item2 Item) (Item, bool)
+	// YearTolerance also compares the items sharing a name whose years differ by up to this many years, such
+	// as region releases dated 1999 and 2000. Such pairs are only kept when they share a provider ID or their
+	// paths are similar enough to be duplicates. Disabled when 0.
+	YearTolerance int
+	// GroupKey returns the key grouping the items compared with each other, such as FolderGroupKey.
+	// Item.GroupKey is used when nil.
+	GroupKey func(item Item) string
+}
+
+// Pair is two items of the same group. Pairs below the duplicate threshold are likely different
+// items sharing a name, or misnamed files.
+type Pair struct {
+	ID     string
+	Item1  Item
+	Item2  Item
+	Index1 int
+	Index2 int
+	// Similarity is the similarity percentage of the paths, extensions excluded
+	Similarity  int
+	IsDuplicate bool
+	// SameStem is set for files in the same folder with the same name and different extensions, such as
+	// "Movie.mkv" and "Movie.mp4": they are duplicates whatever their metadata and path similarity
+	SameStem bool
+	// SameContent is set for items with the same content fingerprint, duplicates whatever their metadata and paths
+	SameContent bool
+	// RecommendedDeleteID is the lower quality copy of a duplicate, empty when none is recommended
+	RecommendedDeleteID string
+}
+
+// Warning reports a group whose pairs were not all compared
+type Warning struct {
+	Group         string `json:"group"`
+	Items         int    `json:"items"`
+	PossiblePairs int    `json:"possible_pairs"`
+	ComparedPairs int    `json:"compared_pairs"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%q has %d items: only %d of %d pairs were compared", w.Group, w.Items, w.ComparedPairs, w.PossiblePairs)
+}
+
+// Result holds the pairs found by Find, ordered by group key, then by name for the years within the tolerance,
+// then by same stem, then by content fingerprint, and the groups above the pair cap
+type Result struct {
+	Groups   int
+	Pairs    []Pair
+	Warnings []Warning
+}
+
+// Find groups the items by their group key and compares the items of each group. Parts of the same
+// multi-part movie (CD1, CD2...) complete each other and are never paired. With a year tolerance,
+// the duplicates sharing a name with years close to each other are then added. Items with the
+// same stem, then items with the same content fingerprint, are finally paired as duplicates,
+// including across groups and beyond the pair cap.
+func Find(items []Item, options Options) Result {
+	minGroupSize := options.MinGroupSize
+	if minGroupSize == 0 {
+		minGroupSize = 2
+	}
+	threshold := options.DuplicateThreshold
+	if threshold == 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+	recommend := options.Recommend
+	if recommend == nil {
+		recommend = Recommend
+	}
+
+	groupKey := options.GroupKey
+	if groupKey == nil {
+		groupKey = Item.GroupKey
+	}
+
+	groups := make(map[string][]int)
+	for index, item := range items {
+		key := groupKey(item)
+		groups[key] = append(groups[key], index)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := Result{Groups: len(groups)}
+	// compared holds the positions of the pairs compared within a group, so that the same stem pass skips them
+	compared := make(map[[2]int]bool)
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < minGroupSize {
+			continue
+		}
+
+		possiblePairs := len(group) * (len(group) - 1) / 2
+		comparedPairs := 0
+	pairs:
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				if comparedPairs == options.MaxPairsPerGroup && options.MaxPairsPerGroup > 0 {
+					break pairs
+				}
+				comparedPairs++
+
+				if options.Skip != nil && options.Skip(group[i], group[j]) {
+					continue
+				}
+
+				compared[[2]int{group[i], group[j]}] = true
+				if IsSameMultiPartMovie(items[group[i]].Path, items[group[j]].Path) {
+					continue
+				}
+
+				pairThreshold := threshold
+				if options.PairThreshold != nil {
+					if override := options.PairThreshold(group[i], group[j]); override > 0 {
+						pairThreshold = override
+					}
+				}
+				result.Pairs = append(result.Pairs, newPair(items, group[i], group[j], pairThreshold, recommend))
+			}
+		}
+
+		if comparedPairs < possiblePairs {
+			result.Warnings = append(result.Warnings, Warning{
+				Group:         key,
+				Items:         len(group),
+				PossiblePairs: possiblePairs,
+				ComparedPairs: comparedPairs,
+			})
+		}
+	}
+
+	if options.YearTolerance > 0 {
+		result.Pairs = append(result.Pairs, findNearYears(items, options, threshold, recommend, compared)...)
+	}
+
+	// Files side by side with the same name and different extensions are the same movie, even when their
+	// metadata differs and puts them in different groups
+	stemGroups := sameStemGroups(items)
+	stems := make([]string, 0, len(stemGroups))
+	for stem := range stemGroups {
+		stems = append(stems, stem)
+	}
+	sort.Strings(stems)
+
+	for _, stem := range stems {
+		group := stemGroups[stem]
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				if compared[[2]int{group[i], group[j]}] || !IsSameStem(items[group[i]].Path, items[group[j]].Path) {
+					continue
+				}
+				if options.Skip != nil && options.Skip(group[i], group[j]) {
+					continue
+				}
+				compared[[2]int{group[i], group[j]}] = true
+				result.Pairs = append(result.Pairs, newPair(items, group[i], group[j], threshold, recommend))
+			}
+		}
+	}
+
+	// Copies of the same recording are duplicates, even when they are named and filed differently
+	result.Pairs = append(result.Pairs, findSameContent(items, options, threshold, recommend, compared)...)
+	return result
+}
+
+// newPair compares two items, same stem files and same content items being duplicates whatever the
+// similarity of their paths
+func newPair(items []Item, index1, index2 int, threshold int, recommend func(item1, item2 Item) (Item, bool)) Pair {
+	item1, item2 := items[index1], items[index2]
+	pair := Pair{
+		ID:          PairID(item1.ID, item2.ID),
+		Item1:       item1,
+		Item2:       item2,
+		Index1:      index1,
+		Index2:      index2,
+		Similarity:  CalculatePathSimilarity(item1.Path, item2.Path),
+		SameStem:    IsSameStem(item1.Path, item2.Path),
+		SameContent: item1.ContentFingerprint != "" && item1.ContentFingerprint == item2.ContentFingerprint,
+	}
+	pair.IsDuplicate = pair.SameStem || pair.SameContent || pair.Similarity >= threshold
+	if pair.IsDuplicate {
+		if item, ok := recommend(item1, item2); ok {
+			pair.RecommendedDeleteID = item.ID
+		}
+	}
+	return pair
+}
+
+// PairID builds a stable identifier for a pair of items, independent of their order
+func PairID(id1, id2 string) string {
+	if id1 > id2 {
+		id1, id2 = id2, id1
+	}
+	return strings.Join([]string{id1, id2}, "_")
+}
+
+// Recommend picks the lower quality copy of a duplicate pair: an interlaced copy of a progressive one,
+// otherwise comparing bitrate, then audio channels, then file size. It returns false when both copies
+// are equivalent, or when one of them is a part of a multi-part movie: a part is only a fraction of
+// its copy, the copy to keep has to be chosen explicitly.
+func Recommend(item1, item2 Item) (Item, bool) {
+	if isMultiPart(item1) || isMultiPart(item2) {
+		return Item{}, false
+	}
+
+	if item1.Interlaced != item2.Interlaced {
+		if item1.Interlaced {
+			return item1, true
+		}
+		return item2, true
+	}
+
+	if item1.Bitrate != item2.Bitrate {
+		if item1.Bitrate < item2.Bitrate {
+			return item1, true
+		}
+		return item2, true
+	}
+
+	if item1.AudioChannels != item2.AudioChannels {
+		if item1.AudioChannels < item2.AudioChannels {
+			return item1, true
+		}
+		return item2, true
+	}
+
+	if item1.Size != item2.Size {
+		if item1.Size < item2.Size {
+			return item1, true
+		}
+		return item2, true
+	}
+
+	return Item{}, false
+}
+
+// RecommendOlder picks the older copy of a duplicate pair by date, keeping the newest file as an upgraded
+// release. It returns false when a date is unknown, when both dates are equal or for multi-part movies.
+func RecommendOlder(item1, item2 Item) (Item, bool) {
+	if isMultiPart(item1) || isMultiPart(item2) || item1.Date.IsZero() || item2.Date.IsZero() || item1.Date.Equal(item2.Date) {
+		return Item{}, false
+	}
+	if item1.Date.Before(item2.Date) {
+		return item1, true
+	}
+	return item2, true
+}
+
+// RecommendNewer picks the newer copy of a duplicate pair by date, keeping the oldest file. It returns
+// false in the same cases as RecommendOlder.
+func RecommendNewer(item1, item2 Item) (Item, bool) {
+	older, ok := RecommendOlder(item1, item2)
+	if !ok {
+		return Item{}, false
+	}
+	if older.ID == item1.ID {
+		return item2, true
+	}
+	return item1, true
+}
+
+// isMultiPart checks if an item is a part of a multi-part movie
+func isMultiPart(item Item) bool {
+	_, _, ok := ParseMultiPart(item.Path)
+	return ok
+}
@@ -0,0 +1,13 @@
+// Package dedupe is the duplicate detection engine: it groups media items by name and year,
+// compares the paths of the items of a group and recommends the copy to delete.
+//
+// It has no dependency on Jellyfin or on the web application, items are described with the
+// few fields detection needs:
+//
+//	result := dedupe.Find(items, dedupe.Options{MaxPairsPerGroup: 500})
+//	for _, pair := range result.Pairs {
+//		if pair.IsDuplicate {
+//			fmt.Println(pair.Item1.Path, pair.Item2.Path, pair.RecommendedDeleteID)
+//		}
+//	}
+package dedupe
@@ -0,0 +1,55 @@
+package dedupe
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// folderTagPattern matches the provider IDs and labels Jellyfin allows in folder names, such as "[tmdbid-603]"
+// or "{imdb-tt0133093}"
+var folderTagPattern = regexp.MustCompile(`\[[^\]]*\]|\{[^}]*\}`)
+
+// folderYearPattern matches a folder name ending with a year, "Name (1999)" as Jellyfin names movie folders,
+// or "Name.1999" as release folders often are, capturing the title and the year
+var folderYearPattern = regexp.MustCompile(`^(.+?)[ ._]*[(\[]?((?:19|20)[0-9]{2})[)\]]?$`)
+
+// titleSeparatorPattern matches the punctuation and separators ignored when comparing titles
+var titleSeparatorPattern = regexp.MustCompile(`[^\pL\pN]+`)
+
+// ParseFolderName derives the title and year of a movie from the name of the folder of its file, following
+// the Jellyfin naming conventions, "Name (Year) [tmdbid-603]". The title is lowercased, its punctuation and
+// separators collapsed to single spaces. It returns false when the folder name does not end with a year.
+func ParseFolderName(filePath string) (string, int, bool) {
+	// Jellyfin may run on Windows, both separators are handled
+	folderPath := filePath[:max(strings.LastIndexAny(filePath, `/\`), 0)]
+	folderName := folderPath[strings.LastIndexAny(folderPath, `/\`)+1:]
+
+	folderName = strings.TrimSpace(folderTagPattern.ReplaceAllString(folderName, ""))
+	matches := folderYearPattern.FindStringSubmatch(folderName)
+	if matches == nil {
+		return "", 0, false
+	}
+	year, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return "", 0, false
+	}
+
+	title := strings.TrimSpace(titleSeparatorPattern.ReplaceAllString(strings.ToLower(matches[1]), " "))
+	if title == "" {
+		return "", 0, false
+	}
+	return title, year, true
+}
+
+// FolderGroupKey groups the items by the title and year of their folder name rather than by their metadata,
+// for libraries whose titles are inconsistent. Items whose folder name does not end with a year, such as files
+// at the root of a library, are grouped by GroupKey.
+func FolderGroupKey(item Item) string {
+	title, year, ok := ParseFolderName(item.Path)
+	if !ok {
+		return item.GroupKey()
+	}
+	return fmt.Sprintf("folder:%s-%d", title, year)
+}
@@ -0,0 +1,39 @@
+package dedupe
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// multiPartPattern matches the part marker of multi-part movie files such as "Movie CD1", "Movie - part 2"
+// or "Movie.disc3", capturing the name before the marker, the part number and the rest of the name
+var multiPartPattern = regexp.MustCompile(`(?i)^(.*?)(?:^|[ _.\-\[(])(?:cd|dvd|part|pt|disc|disk)[ _.-]*([0-9]{1,2})(?:$|[ _.\-\])])(.*)$`)
+
+// ParseMultiPart detects if a file is a part of a multi-part movie. It returns a key shared by every
+// part of the same movie (same folder, same name apart from the part marker) and the part number.
+func ParseMultiPart(filePath string) (string, int, bool) {
+	// Jellyfin may run on Windows, both separators are handled
+	separator := strings.LastIndexAny(filePath, `/\`)
+	folder, name := filePath[:separator+1], filePath[separator+1:]
+	name = removeFileExtension(name)
+
+	matches := multiPartPattern.FindStringSubmatch(name)
+	if matches == nil {
+		return "", 0, false
+	}
+
+	part, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return strings.ToLower(folder + matches[1] + "|" + matches[3]), part, true
+}
+
+// IsSameMultiPartMovie checks if two files are different parts of the same multi-part movie
+func IsSameMultiPartMovie(path1, path2 string) bool {
+	key1, part1, ok1 := ParseMultiPart(path1)
+	key2, part2, ok2 := ParseMultiPart(path2)
+	return ok1 && ok2 && key1 == key2 && part1 != part2
+}
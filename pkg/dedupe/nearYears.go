@@ -0,0 +1,89 @@
+package dedupe
+
+import (
+	"sort"
+)
+
+// findNearYears pairs the items sharing a name whose years differ by at most the year tolerance, keeping only
+// the duplicates confirmed by a shared provider ID or by the similarity of their paths. The compared pairs are
+// added to compared, at most MaxPairsPerGroup per name.
+func findNearYears(items []Item, options Options, threshold int, recommend func(item1, item2 Item) (Item, bool), compared map[[2]int]bool) []Pair {
+	names := make(map[string][]int)
+	for index, item := range items {
+		names[item.Name] = append(names[item.Name], index)
+	}
+
+	keys := make([]string, 0, len(names))
+	for name, group := range names {
+		if len(group) >= 2 {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	var pairs []Pair
+	for _, name := range keys {
+		group := names[name]
+		comparedPairs := 0
+	pairs:
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				item1, item2 := items[group[i]], items[group[j]]
+				difference := abs(item1.Year - item2.Year)
+				if difference == 0 || difference > options.YearTolerance || compared[[2]int{group[i], group[j]}] {
+					continue
+				}
+				if comparedPairs == options.MaxPairsPerGroup && options.MaxPairsPerGroup > 0 {
+					break pairs
+				}
+				comparedPairs++
+
+				if options.Skip != nil && options.Skip(group[i], group[j]) {
+					continue
+				}
+				compared[[2]int{group[i], group[j]}] = true
+				if IsSameMultiPartMovie(item1.Path, item2.Path) {
+					continue
+				}
+
+				pairThreshold := threshold
+				if options.PairThreshold != nil {
+					if override := options.PairThreshold(group[i], group[j]); override > 0 {
+						pairThreshold = override
+					}
+				}
+				pair := newPair(items, group[i], group[j], pairThreshold, recommend)
+				if !pair.IsDuplicate && SharesProviderID(item1, item2) {
+					pair.IsDuplicate = true
+					if item, ok := recommend(item1, item2); ok {
+						pair.RecommendedDeleteID = item.ID
+					}
+				}
+				// Other pairs are likely different movies, such as a remake released the next year
+				if pair.IsDuplicate {
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// SharesProviderID checks if two items have a provider ID in common
+func SharesProviderID(item1, item2 Item) bool {
+	for _, id1 := range item1.ProviderIDs {
+		for _, id2 := range item2.ProviderIDs {
+			if id1 == id2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,41 @@
+package dedupe
+
+import (
+	"sort"
+)
+
+// findSameContent pairs the items sharing a content fingerprint, leaving out the pairs already compared,
+// the skipped ones and the parts of the same multi-part movie
+func findSameContent(items []Item, options Options, threshold int, recommend func(item1, item2 Item) (Item, bool), compared map[[2]int]bool) []Pair {
+	groups := make(map[string][]int)
+	for index, item := range items {
+		if item.ContentFingerprint != "" {
+			groups[item.ContentFingerprint] = append(groups[item.ContentFingerprint], index)
+		}
+	}
+
+	fingerprints := make([]string, 0, len(groups))
+	for fingerprint, group := range groups {
+		if len(group) > 1 {
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	sort.Strings(fingerprints)
+
+	var pairs []Pair
+	for _, fingerprint := range fingerprints {
+		group := groups[fingerprint]
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				if compared[[2]int{group[i], group[j]}] || IsSameMultiPartMovie(items[group[i]].Path, items[group[j]].Path) {
+					continue
+				}
+				if options.Skip != nil && options.Skip(group[i], group[j]) {
+					continue
+				}
+				pairs = append(pairs, newPair(items, group[i], group[j], threshold, recommend))
+			}
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,43 @@
+package dedupe
+
+import (
+	"strings"
+)
+
+// splitStem splits a file path into the folder and name without extension, lowercased, and the extension.
+// ok is false when the file has no extension.
+func splitStem(filePath string) (stem string, extension string, ok bool) {
+	// Jellyfin may run on Windows, both separators are handled
+	separator := strings.LastIndexAny(filePath, `/\`)
+	folder, name := filePath[:separator+1], filePath[separator+1:]
+	withoutExtension := removeFileExtension(name)
+	if withoutExtension == name {
+		return "", "", false
+	}
+	return strings.ToLower(folder + withoutExtension), strings.ToLower(name[len(withoutExtension):]), true
+}
+
+// IsSameStem checks if two files sit side by side in the same folder with the same name, only their
+// extension differing, such as "Movie.mkv" and "Movie.mp4": the same movie in two containers
+func IsSameStem(path1, path2 string) bool {
+	stem1, extension1, ok1 := splitStem(path1)
+	stem2, extension2, ok2 := splitStem(path2)
+	return ok1 && ok2 && stem1 == stem2 && extension1 != extension2
+}
+
+// sameStemGroups indexes the items by folder and name without extension, keeping only the stems
+// shared by items with different extensions
+func sameStemGroups(items []Item) map[string][]int {
+	groups := make(map[string][]int)
+	for index, item := range items {
+		if stem, _, ok := splitStem(item.Path); ok {
+			groups[stem] = append(groups[stem], index)
+		}
+	}
+	for stem, group := range groups {
+		if len(group) < 2 {
+			delete(groups, stem)
+		}
+	}
+	return groups
+}
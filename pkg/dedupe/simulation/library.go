@@ -0,0 +1,143 @@
+// Package simulation generates synthetic libraries whose duplicates are known, and measures the runtime,
+// memory and accuracy of the detection engine on them, to compare detection settings before tuning
+// thresholds on a real library.
+package simulation
+
+import (
+	"fmt"
+	"jellyfin-duplicate/pkg/dedupe"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// titleWords are combined into the titles of the generated movies
+var titleWords = []string{
+	"Night", "City", "River", "Last", "Silent", "Golden", "Lost", "Shadow", "Summer", "Winter", "Iron", "Glass",
+	"House", "Road", "Star", "Storm", "Dark", "Long", "Red", "Blue", "Wild", "Secret", "Empty", "Broken",
+	"Garden", "Ocean", "Fire", "Stone", "Paper", "Moon", "Kingdom", "Harbor", "Station", "Letter", "Dream",
+	"Machine", "Island", "Mountain", "Mirror", "Thief", "Queen", "Soldier", "Detective", "Stranger", "Train",
+}
+
+// releaseTags are appended to the names of downloaded releases
+var releaseTags = []string{"1080p.BluRay.x264", "2160p.WEB-DL.HEVC", "720p.HDTV.x264", "1080p.WEBRip.AAC"}
+
+// LibraryOptions describes the synthetic library to generate
+type LibraryOptions struct {
+	// Movies is the number of distinct movies, before their copies are added
+	Movies int
+	// DuplicateRate is the share of movies with a second copy, between 0 and 1
+	DuplicateRate float64
+	// MismatchRate is the share of movies with another movie sharing their name, between 0 and 1
+	MismatchRate float64
+	// Seed makes the same options generate the same library
+	Seed uint64
+}
+
+// Library is a generated library, with the pairs of items which are copies of the same movie
+type Library struct {
+	Items []dedupe.Item
+	// Duplicates holds the IDs of the copies of the same movie, as returned by dedupe.PairID
+	Duplicates map[string]bool
+	// Mismatches counts the pairs of different movies sharing a name
+	Mismatches int
+}
+
+// Generate creates a library of movies filed as "Name (Year)/Name (Year).mkv". Their copies vary the way
+// real ones do: a 4K release next to the movie or in another library, an old rip with another extension, a
+// download named after its release and a regional release dated a year later. Movies sharing a name are a
+// documentary, a short or a remake dated a year later.
+func Generate(options LibraryOptions) Library {
+	random := rand.New(rand.NewPCG(options.Seed, options.Seed))
+	library := Library{Duplicates: map[string]bool{}}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Titles are unique, so that the only movies sharing a name are the ones generated as such
+	used := make(map[string]bool)
+
+	for i := 0; i < options.Movies; i++ {
+		name := movieTitle(random, used)
+		year := 1920 + random.IntN(105)
+		folder := fmt.Sprintf("%s (%d)", name, year)
+		tmdb := fmt.Sprintf("tmdb:%d", 1000+i)
+		movie := dedupe.Item{
+			ID:          fmt.Sprintf("movie-%d", i),
+			Name:        name,
+			Year:        year,
+			Path:        fmt.Sprintf("/media/movies/%s/%s.mkv", folder, folder),
+			Size:        4_000_000_000 + random.Int64N(4_000_000_000),
+			Bitrate:     8_000_000 + random.Int64N(4_000_000),
+			Date:        base.AddDate(0, 0, -random.IntN(2000)),
+			ProviderIDs: []string{tmdb},
+		}
+		library.Items = append(library.Items, movie)
+
+		if random.Float64() < options.DuplicateRate {
+			duplicate := movie
+			duplicate.ID = fmt.Sprintf("copy-%d", i)
+			duplicate.Date = movie.Date.AddDate(0, 0, random.IntN(300))
+			switch random.IntN(5) {
+			case 0:
+				duplicate.Path = fmt.Sprintf("/media/movies/%s/%s - 2160p.mkv", folder, folder)
+				duplicate.Size, duplicate.Bitrate = movie.Size*4, movie.Bitrate*4
+			case 1:
+				duplicate.Path = fmt.Sprintf("/media/movies-4k/%s/%s.mkv", folder, folder)
+				duplicate.Size, duplicate.Bitrate = movie.Size*4, movie.Bitrate*4
+			case 2:
+				duplicate.Path = fmt.Sprintf("/media/movies/%s/%s.avi", folder, folder)
+				duplicate.Size, duplicate.Bitrate = movie.Size/5, movie.Bitrate/5
+			case 3:
+				release := fmt.Sprintf("%s.%d.%s-GRP", strings.ReplaceAll(name, " ", "."), year, releaseTags[random.IntN(len(releaseTags))])
+				duplicate.Path = fmt.Sprintf("/media/downloads/%s/%s.mkv", release, release)
+			default:
+				duplicate.Year = year + 1
+				duplicate.Path = fmt.Sprintf("/media/movies/%s (%d)/%s (%d).mkv", name, year+1, name, year+1)
+			}
+			library.Items = append(library.Items, duplicate)
+			library.Duplicates[dedupe.PairID(movie.ID, duplicate.ID)] = true
+		}
+
+		if random.Float64() < options.MismatchRate {
+			other := dedupe.Item{
+				ID:          fmt.Sprintf("other-%d", i),
+				Name:        name,
+				Year:        year,
+				Size:        500_000_000 + random.Int64N(1_000_000_000),
+				Bitrate:     3_000_000 + random.Int64N(1_000_000),
+				Date:        base.AddDate(0, 0, -random.IntN(2000)),
+				ProviderIDs: []string{fmt.Sprintf("tmdb:%d", 900000+i)},
+			}
+			switch random.IntN(3) {
+			case 0:
+				other.Path = fmt.Sprintf("/media/movies/Documentaries/%s - Behind the Scenes/%s.mp4", name, name)
+			case 1:
+				other.Path = fmt.Sprintf("/media/movies/Shorts/%s (%d) [Short]/%s.mkv", name, year, name)
+			default:
+				other.Year = year + 1
+				other.Path = fmt.Sprintf("/media/movies/%s (%d)/%s (%d).mkv", name, year+1, name, year+1)
+			}
+			library.Items = append(library.Items, other)
+			library.Mismatches++
+		}
+	}
+	return library
+}
+
+// movieTitle combines words into a title not used yet, numbered once the combinations run out
+func movieTitle(random *rand.Rand, used map[string]bool) string {
+	var title string
+	for attempt := 0; attempt < 10; attempt++ {
+		words := make([]string, 2+random.IntN(2))
+		for i := range words {
+			words[i] = titleWords[random.IntN(len(titleWords))]
+		}
+		title = "The " + strings.Join(words, " ")
+		if !used[title] {
+			break
+		}
+	}
+	for number := 2; used[title]; number++ {
+		title = fmt.Sprintf("%s %d", strings.TrimRight(title, " 0123456789"), number)
+	}
+	used[title] = true
+	return title
+}
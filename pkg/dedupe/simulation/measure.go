@@ -0,0 +1,83 @@
+package simulation
+
+import (
+	"jellyfin-duplicate/pkg/dedupe"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Configuration is a named set of detection options to measure
+type Configuration struct {
+	Name    string
+	Options dedupe.Options
+}
+
+// Measurement is the performance and the accuracy of a configuration on a generated library
+type Measurement struct {
+	Configuration string `json:"configuration"`
+	Items         int    `json:"items"`
+	// Duration is the median duration of the runs, Allocated the bytes allocated by a run
+	Duration  time.Duration `json:"duration_ns"`
+	Allocated uint64        `json:"allocated_bytes"`
+	// Pairs counts the compared pairs, duplicates or not
+	Pairs int `json:"pairs"`
+	// TruePositives are the copies of the same movie found as duplicates, FalsePositives the pairs of different
+	// movies found as duplicates and FalseNegatives the copies missed
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+}
+
+// Measure runs the detection on the library runs times with a configuration, at least once, and compares the
+// duplicates found with the known ones
+func Measure(library Library, configuration Configuration, runs int) Measurement {
+	runs = max(runs, 1)
+	durations := make([]time.Duration, runs)
+	var allocated uint64
+	var result dedupe.Result
+	for run := range runs {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		result = dedupe.Find(library.Items, configuration.Options)
+		durations[run] = time.Since(start)
+		runtime.ReadMemStats(&after)
+		allocated = after.TotalAlloc - before.TotalAlloc
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	measurement := Measurement{
+		Configuration: configuration.Name,
+		Items:         len(library.Items),
+		Duration:      durations[runs/2],
+		Allocated:     allocated,
+		Pairs:         len(result.Pairs),
+	}
+	found := make(map[string]bool)
+	for _, pair := range result.Pairs {
+		if !pair.IsDuplicate || found[pair.ID] {
+			continue
+		}
+		found[pair.ID] = true
+		if library.Duplicates[pair.ID] {
+			measurement.TruePositives++
+		} else {
+			measurement.FalsePositives++
+		}
+	}
+	measurement.FalseNegatives = len(library.Duplicates) - measurement.TruePositives
+
+	// A configuration finding nothing is precise, and one with nothing to find has found everything
+	measurement.Precision, measurement.Recall = 1, 1
+	if detected := measurement.TruePositives + measurement.FalsePositives; detected > 0 {
+		measurement.Precision = float64(measurement.TruePositives) / float64(detected)
+	}
+	if len(library.Duplicates) > 0 {
+		measurement.Recall = float64(measurement.TruePositives) / float64(len(library.Duplicates))
+	}
+	return measurement
+}
@@ -0,0 +1,10 @@
+// Package fsreport reads the reports of filesystem duplicate finders, which compare the content of the files
+// rather than their names, into groups of paths of identical files:
+//
+//	groups, err := fsreport.ParseRdfind(file)
+//	for _, group := range groups {
+//		fmt.Println(strings.Join(group, " = "))
+//	}
+//
+// Groups of a single file are left out.
+package fsreport
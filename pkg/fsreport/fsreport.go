@@ -0,0 +1,106 @@
+package fsreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rdfindFields is the number of fields of a line of rdfind results: duptype id depth size device inode
+// priority name, the name possibly containing spaces
+const rdfindFields = 8
+
+// ParseCzkawka reads the JSON report of duplicate files saved by czkawka. Its groups of entries with a path are
+// found wherever they are nested, as the layout differs between the search modes and the versions of czkawka,
+// e.g. groups keyed by file size or a plain list of groups.
+func ParseCzkawka(reader io.Reader) ([][]string, error) {
+	var report any
+	if err := json.NewDecoder(reader).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid czkawka report: %v", err)
+	}
+
+	var groups [][]string
+	collectCzkawkaGroups(report, &groups)
+	return groups, nil
+}
+
+// collectCzkawkaGroups adds the arrays of entries with a path found in value to groups
+func collectCzkawkaGroups(value any, groups *[][]string) {
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectCzkawkaGroups(value[key], groups)
+		}
+	case []any:
+		var paths []string
+		for _, element := range value {
+			if entry, ok := element.(map[string]any); ok {
+				if path, ok := entry["path"].(string); ok && path != "" {
+					paths = append(paths, path)
+					continue
+				}
+			}
+			collectCzkawkaGroups(element, groups)
+		}
+		if len(paths) > 1 {
+			*groups = append(*groups, paths)
+		}
+	}
+}
+
+// ParseRdfind reads the results.txt file written by rdfind. The duplicates of a file share the absolute value
+// of its ID, negated for them.
+func ParseRdfind(reader io.Reader) ([][]string, error) {
+	indexes := make(map[int]int)
+	var groups [][]string
+
+	scanner := bufio.NewScanner(reader)
+	// Paths may be long, lines are not limited to the default 64 KiB
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", rdfindFields)
+		if len(fields) != rdfindFields || !strings.HasPrefix(fields[0], "DUPTYPE_") {
+			return nil, fmt.Errorf("invalid rdfind results line %d: expected duptype id depth size device inode priority name", number)
+		}
+		id, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rdfind results line %d: invalid id %s", number, fields[1])
+		}
+		if id < 0 {
+			id = -id
+		}
+
+		index, found := indexes[id]
+		if !found {
+			index = len(groups)
+			indexes[id] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], fields[rdfindFields-1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rdfind results: %v", err)
+	}
+
+	result := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		if len(group) > 1 {
+			result = append(result, group)
+		}
+	}
+	return result, nil
+}
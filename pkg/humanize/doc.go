@@ -0,0 +1,7 @@
+// Package humanize formats sizes, bitrates, durations and percentages for people to read.
+// The API exposes these next to the raw values, with a "_h" suffix, and templates render them:
+//
+//	humanize.Bytes(4831838208)            // "4.5 GiB"
+//	humanize.Bitrate(8500000)             // "8.5 Mbps"
+//	humanize.Duration(5535 * time.Second) // "1h 32m"
+package humanize
@@ -0,0 +1,68 @@
+package humanize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bytes formats a size in bytes using binary units
+// Example: 4831838208 → "4.5 GiB"
+func Bytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// Bitrate formats a bitrate in bits per second using decimal units, as media players do
+// Example: 8500000 → "8.5 Mbps"
+func Bitrate(bitsPerSecond int64) string {
+	const unit = 1000
+	if bitsPerSecond < unit {
+		return fmt.Sprintf("%d bps", bitsPerSecond)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bitsPerSecond / unit; n >= unit && exp < 2; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cbps", float64(bitsPerSecond)/float64(div), "kMG"[exp])
+}
+
+// Duration formats a duration with its two largest units, rounded to the second
+// Example: 5535s → "1h 32m", 95s → "1m 35s"
+func Duration(d time.Duration) string {
+	if d < 0 {
+		return "-" + Duration(-d)
+	}
+
+	d = d.Round(time.Second)
+	hours, minutes, seconds := int64(d/time.Hour), int64(d/time.Minute)%60, int64(d/time.Second)%60
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%dh %02dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %02ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+// Percent formats a percentage, with one decimal when it is not a whole number
+// Example: 95 → "95%", 66.666 → "66.7%"
+func Percent(value float64) string {
+	rounded := strconv.FormatFloat(value, 'f', 1, 64)
+	return strings.TrimSuffix(rounded, ".0") + "%"
+}
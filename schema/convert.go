@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+)
+
+// ErrUnsupportedVersion is returned for scan results written by a newer version of the application
+var ErrUnsupportedVersion = errors.New("unsupported scan result schema version")
+
+// legacyVersion is the version of documents without schema_version: the pages of /api/duplicates,
+// or a bare list of their items, saved before the schema existed
+const legacyVersion = 0
+
+// converters read a document of an older version into the current schema, keyed by version
+var converters = map[int]func(data []byte) (ScanResult, error){
+	legacyVersion: fromLegacyPage,
+}
+
+// Decode reads a scan result of any version, converting older ones to the current schema
+func Decode(data []byte) (ScanResult, error) {
+	var header struct {
+		SchemaVersion *int `json:"schema_version"`
+	}
+	// A bare list of duplicates has no header
+	if err := json.Unmarshal(data, &header); err != nil && !isArray(data) {
+		return ScanResult{}, fmt.Errorf("failed to parse scan result: %v", err)
+	}
+
+	version := legacyVersion
+	if header.SchemaVersion != nil {
+		version = *header.SchemaVersion
+	}
+
+	if version == CurrentVersion {
+		var result ScanResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return ScanResult{}, fmt.Errorf("failed to parse scan result: %v", err)
+		}
+		return result, nil
+	}
+
+	convert, found := converters[version]
+	if !found {
+		return ScanResult{}, fmt.Errorf("%w %d, this version reads up to %d", ErrUnsupportedVersion, version, CurrentVersion)
+	}
+	result, err := convert(data)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("failed to convert scan result from version %d: %v", version, err)
+	}
+	result.SchemaVersion = CurrentVersion
+	return result, nil
+}
+
+// fromLegacyPage converts a page of /api/duplicates or a list of its items. The generation time and the server
+// are unknown, they are left empty.
+func fromLegacyPage(data []byte) (ScanResult, error) {
+	var page struct {
+		Items       []jellyfinModels.DuplicateResult `json:"items"`
+		ScanVersion int64                            `json:"scan_version"`
+		Warnings    []Warning                        `json:"warnings"`
+	}
+	if isArray(data) {
+		if err := json.Unmarshal(data, &page.Items); err != nil {
+			return ScanResult{}, err
+		}
+	} else if err := json.Unmarshal(data, &page); err != nil {
+		return ScanResult{}, err
+	}
+
+	groups, actions := FromDuplicates(page.Items)
+	return ScanResult{
+		ScanVersion: page.ScanVersion,
+		Groups:      groups,
+		Actions:     actions,
+		Warnings:    append([]Warning{}, page.Warnings...),
+	}, nil
+}
+
+// isArray checks if a JSON document is an array
+func isArray(data []byte) bool {
+	for _, c := range data {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true
+		}
+		return false
+	}
+	return false
+}
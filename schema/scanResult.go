@@ -0,0 +1,156 @@
+package schema
+
+import (
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/dedupe"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// CurrentVersion is the version of the scan result schema written by this version of the application.
+// It is increased on every incompatible change, with a converter from the previous version in converters.
+const CurrentVersion = 1
+
+// ScanResult is the machine-readable outcome of a duplicate scan, as returned by the API, sent to the webhook
+// and persisted in the data directory
+type ScanResult struct {
+	SchemaVersion int       `json:"schema_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	// ApplicationVersion is the version of the application which ran the scan
+	ApplicationVersion string `json:"application_version"`
+	// ScanVersion increases with every scan, across restarts, it is sent back with actions
+	ScanVersion int64   `json:"scan_version"`
+	Server      Server  `json:"server"`
+	Groups      []Group `json:"groups"`
+	// Actions are the recommended actions on the groups, which can be run as bulk actions
+	Actions  []Action  `json:"actions"`
+	Warnings []Warning `json:"warnings"`
+}
+
+// Server identifies the scanned Jellyfin server, its fields are empty when it could not be reached
+type Server struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Group is a pair of copies of the same movie, or of movies sharing a name and year for potential mismatches
+type Group struct {
+	ID string `json:"id"`
+	// Fingerprint identifies the content of the pair, it stays the same when Jellyfin changes the item IDs
+	Fingerprint string `json:"fingerprint"`
+	// IsDuplicate is false for potential mismatches, whose paths differ
+	IsDuplicate bool   `json:"is_duplicate"`
+	Similarity  int    `json:"similarity"`
+	Items       []Item `json:"items"`
+	// RecommendedDeleteID is the lower quality copy, empty when no copy is recommended for deletion
+	RecommendedDeleteID     string                                 `json:"recommended_delete_id,omitempty"`
+	ReclaimableSize         int64                                  `json:"reclaimable_size"`
+	Link                    *jellyfinModels.FileLink               `json:"link,omitempty"`
+	PlayStatusDiscrepancies []jellyfinModels.PlayStatusDiscrepancy `json:"play_status_discrepancies,omitempty"`
+	// SameStem is set when both files are in the same folder with the same name, only their extension differing
+	SameStem bool `json:"same_stem,omitempty"`
+	// Severity classifies the pair, from exact duplicates to mismatches, empty in results saved before severities
+	Severity constants.Severity `json:"severity,omitempty"`
+	// UsersAffected is the number of users with a play status or Trakt discrepancy on the pair
+	UsersAffected int `json:"users_affected"`
+}
+
+// Item is a copy of a movie
+type Item struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Year    int    `json:"year"`
+	Path    string `json:"path"`
+	Library string `json:"library"`
+	// Size is in bytes, Bitrate in bits per second and Duration in seconds, 0 when unknown
+	Size       int64  `json:"size"`
+	Bitrate    int64  `json:"bitrate"`
+	Duration   int64  `json:"duration"`
+	Resolution string `json:"resolution,omitempty"`
+	VideoCodec string `json:"video_codec,omitempty"`
+	HDR        bool   `json:"hdr"`
+	// SeenBy lists the names of the users who have seen the copy
+	SeenBy []string `json:"seen_by"`
+}
+
+// Action is a recommended action on a group, ItemID being the copy to delete for deletions
+type Action struct {
+	GroupID string               `json:"group_id"`
+	Type    constants.BulkAction `json:"type"`
+	ItemID  string               `json:"item_id,omitempty"`
+	Reason  string               `json:"reason"`
+}
+
+// Warning reports a group of movies whose pairs were not all compared
+type Warning = dedupe.Warning
+
+// FromDuplicates converts duplicate results into groups, with the actions recommended on them
+func FromDuplicates(duplicates []jellyfinModels.DuplicateResult) ([]Group, []Action) {
+	groups := make([]Group, 0, len(duplicates))
+	actions := make([]Action, 0)
+	for _, dup := range duplicates {
+		groups = append(groups, Group{
+			ID:                      dup.ID,
+			Fingerprint:             dedupe.PairID(dup.Movie1.Fingerprint(), dup.Movie2.Fingerprint()),
+			IsDuplicate:             dup.IsDuplicate,
+			Similarity:              dup.Similarity,
+			Items:                   []Item{NewItem(dup.Movie1), NewItem(dup.Movie2)},
+			RecommendedDeleteID:     dup.RecommendedDeleteID,
+			ReclaimableSize:         dup.ReclaimableSize,
+			Link:                    dup.Link,
+			PlayStatusDiscrepancies: dup.PlayStatusDiscrepancies,
+			SameStem:                dup.SameStem,
+			Severity:                dup.Severity,
+			UsersAffected:           dup.UsersAffected,
+		})
+		actions = append(actions, recommendedActions(dup)...)
+	}
+	return groups, actions
+}
+
+// recommendedActions follows the safety checks of the bulk actions: play status is synchronized first,
+// and the lower quality copy is deleted only once it is safe to do so
+func recommendedActions(dup jellyfinModels.DuplicateResult) []Action {
+	if !dup.IsDuplicate {
+		return nil
+	}
+	if dup.HasPlayStatusDiscrepancy {
+		return []Action{{
+			GroupID: dup.ID,
+			Type:    constants.SyncPlayStatusAction,
+			Reason:  "play status differs between copies",
+		}}
+	}
+	if dup.RecommendedDeleteID != "" && dup.HasIdenticalPlayStatus && !dup.Tracks.LosesLanguages() {
+		return []Action{{
+			GroupID: dup.ID,
+			Type:    constants.DeleteLowerQualityAction,
+			ItemID:  dup.RecommendedDeleteID,
+			Reason:  "lower quality copy with identical play status",
+		}}
+	}
+	return nil
+}
+
+// NewItem converts a movie into a copy of the schema
+func NewItem(movie jellyfinModels.Movie) Item {
+	return Item{
+		ID:         movie.ID,
+		Name:       movie.Name,
+		Year:       movie.ProductionYear,
+		Path:       movie.Path,
+		Library:    movie.LibraryName,
+		Size:       movie.Size(),
+		Bitrate:    movie.Bitrate(),
+		Duration:   int64(movie.Duration() / time.Second),
+		Resolution: string(movie.Resolution()),
+		VideoCodec: movie.VideoCodec(),
+		HDR:        movie.IsHDR(),
+		SeenBy: lo.FilterMap(movie.UserPlayStatuses, func(status jellyfinModels.UserPlayStatus, _ int) (string, bool) {
+			return status.UserName, status.Played
+		}),
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdmin exposes the troubleshooting endpoints under /api/admin, protected by the admin token
+func RegisterAdmin(routes *gin.RouterGroup, handler *Handler, adminToken string) {
+	admin := routes.Group("/api/admin", handler.requireBearerToken(adminToken))
+	admin.GET("/cache", handler.GetCaches)
+	admin.DELETE("/cache", handler.FlushCache)
+	admin.DELETE("/cache/:name", handler.FlushCache)
+	admin.GET("/last-errors", handler.GetLastErrors)
+}
+
+// LastJellyfinErrors returns the last failed calls to each Jellyfin server, most recent first
+func (s *ServerService) LastJellyfinErrors() map[string][]jellyfinClients.APIFailure {
+	failures := map[string][]jellyfinClients.APIFailure{
+		"jellyfin": s.jellyfinClient.LastFailures(),
+	}
+	if s.secondaryClient != nil {
+		failures["secondary_jellyfin"] = s.secondaryClient.LastFailures()
+	}
+	return failures
+}
+
+// GET /api/admin/last-errors
+// GetLastErrors returns the last failed Jellyfin calls with their status and response body,
+// to troubleshoot user reports without access to the logs
+func (h *Handler) GetLastErrors(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"errors": h.serverService.LastJellyfinErrors(),
+	})
+}
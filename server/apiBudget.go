@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/notifications"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// callGrowthScans is the number of previous completed scans whose Jellyfin calls are averaged to detect growth
+const callGrowthScans = 5
+
+// newCallBudget creates the budget of Jellyfin calls of a scan from scan.max_api_calls, notifying when it is
+// exceeded
+func (s *ServerService) newCallBudget() *jellyfinClients.CallBudget {
+	config := s.liveConfig().Scan
+	return jellyfinClients.NewCallBudget(config.MaxAPICalls, config.BudgetAction, time.Duration(config.ThrottleInterval)*time.Millisecond,
+		func(limit int) {
+			consequence := "the scan is aborted"
+			if config.BudgetAction == constants.ThrottleBudgetAction {
+				consequence = fmt.Sprintf("its next calls are throttled to one every %d ms", config.ThrottleInterval)
+			}
+			logrus.Warnf("The scan exceeded its budget of %d Jellyfin calls, %s", limit, consequence)
+			s.notifier.Notify(notifications.Event{
+				Type:    constants.APICallsEvent,
+				Title:   "Jellyfin call budget exceeded",
+				Message: fmt.Sprintf("The scan made more than %d Jellyfin calls (scan.max_api_calls), %s", limit, consequence),
+			})
+		})
+}
+
+// checkAPICallGrowth warns when a scan made scan.api_call_growth percent more Jellyfin calls than the average
+// of the previous completed scans, such as after a library grew by mistake or a user was added with a huge history
+func (s *ServerService) checkAPICallGrowth(calls int) {
+	total, count := 0, 0
+	for _, record := range s.ScanHistory() {
+		if record.Status != constants.ScanCompleted || record.APICalls == 0 {
+			continue
+		}
+		total += record.APICalls
+		if count++; count == callGrowthScans {
+			break
+		}
+	}
+	if count == 0 {
+		return
+	}
+
+	average := total / count
+	if calls*100 <= average*(100+s.liveConfig().Scan.APICallGrowth) {
+		return
+	}
+	logrus.Warnf("The scan made %d Jellyfin calls, against %d on average for the previous scans", calls, average)
+	s.notifier.Notify(notifications.Event{
+		Type:  constants.APICallsEvent,
+		Title: "Unexpected growth of Jellyfin calls",
+		Message: fmt.Sprintf("The scan made %d Jellyfin calls, %d%% more than the %d calls of the previous scans on average",
+			calls, (calls-average)*100/max(average, 1), average),
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"jellyfin-duplicate/constants"
+	storageModels "jellyfin-duplicate/storage/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// auditEntriesKept is the number of entries of the audit log, the oldest ones are dropped
+const auditEntriesKept = 1000
+
+// recordAudit adds a change to the audit log, with the operator whose access resolved the item.
+// A failure is only logged, as the change is already made.
+func (s *ServerService) recordAudit(action constants.AuditAction, itemID, itemName, operatorID, details string) {
+	entry := storageModels.AuditEntry{
+		Action:     action,
+		ItemID:     itemID,
+		ItemName:   itemName,
+		OperatorID: operatorID,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	}
+	if operatorID != "" {
+		if name, err := s.jellyfinClient.GetUserName(operatorID); err == nil {
+			entry.OperatorName = name
+		}
+	}
+
+	if err := s.store.RecordAudit(entry, auditEntriesKept); err != nil {
+		logrus.Warnf("Failed to record %s of %s (%s) in the audit log: %v", action, itemName, itemID, err)
+	}
+}
+
+// recordLoginFailure adds a failed sign in to the audit log, name being who the client tried to sign in as,
+// and the lockout of the client when it follows
+func (s *ServerService) recordLoginFailure(client, name, reason string, locked bool) {
+	entries := []storageModels.AuditEntry{{
+		Action:       constants.LoginFailedAudit,
+		OperatorName: name,
+		Details:      fmt.Sprintf("from %s: %s", client, reason),
+		CreatedAt:    time.Now(),
+	}}
+	if locked {
+		entries = append(entries, storageModels.AuditEntry{
+			Action:    constants.LoginLockoutAudit,
+			Details:   fmt.Sprintf("%s locked out for %d minutes", client, s.config.Auth.LockoutDuration),
+			CreatedAt: time.Now(),
+		})
+	}
+
+	for _, entry := range entries {
+		if err := s.store.RecordAudit(entry, auditEntriesKept); err != nil {
+			logrus.Warnf("Failed to record %s from %s in the audit log: %v", entry.Action, client, err)
+		}
+	}
+}
+
+// AuditLog returns the changes made to Jellyfin or to the media files, most recent first
+func (s *ServerService) AuditLog() []storageModels.AuditEntry {
+	return s.store.AuditLog()
+}
+
+// GET /api/audit
+// GetAuditLog lists the changes made to Jellyfin or to the media files, with the identity they were made as
+func (h *Handler) GetAuditLog(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"entries": h.serverService.AuditLog(),
+	})
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"errors"
+	"jellyfin-duplicate/auth"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// loginDuration is how long the user has to sign in on the provider page
+const loginDuration = 10 * time.Minute
+
+// sessionContextKey is the gin context key of the signed in user
+const sessionContextKey = "session"
+
+// login signs users in through an OpenID Connect provider
+type login struct {
+	provider *auth.Provider
+	signer   *auth.Signer
+	// secure restricts the cookies to HTTPS, when the application is served over it
+	secure bool
+}
+
+// RegisterLogin requires users to sign in through the provider, and exposes the login endpoints under /auth.
+// Routes registered with RequireSession and RequireAdmin are protected from then on.
+func RegisterLogin(routes *gin.RouterGroup, handler *Handler, provider *auth.Provider) error {
+	signer, err := auth.NewSigner(handler.config.Auth.SessionSecret)
+	if err != nil {
+		return err
+	}
+	if handler.config.Auth.SessionSecret == "" {
+		logrus.Warnf("%s is not set, users will have to sign in again after a restart", constants.EnvSessionSecret)
+	}
+
+	handler.login = &login{
+		provider: provider,
+		signer:   signer,
+		secure:   strings.HasPrefix(handler.config.Auth.RedirectURL, "https://"),
+	}
+	routes.GET("/auth/login", handler.ThrottleLogin, handler.Login)
+	routes.GET("/auth/callback", handler.ThrottleLogin, handler.LoginCallback)
+	routes.GET("/auth/logout", handler.Logout)
+	return nil
+}
+
+// RequireSession rejects requests of users who are not signed in, redirecting pages to the login.
+// Every request is accepted when login is disabled.
+func (h *Handler) RequireSession(ctx *gin.Context) {
+	if h.trustedHeader != nil {
+		h.requireTrustedHeader(ctx)
+		return
+	}
+	if h.login == nil {
+		ctx.Next()
+		return
+	}
+
+	var session auth.Session
+	cookie, err := ctx.Cookie(constants.SessionCookie)
+	if err == nil {
+		err = h.login.signer.Decode(cookie, &session)
+	}
+	if err != nil {
+		if ctx.Request.Method == http.MethodGet && !strings.HasPrefix(ctx.Request.URL.Path, h.config.BasePath+"/api/") {
+			ctx.Redirect(http.StatusFound, h.config.BasePath+"/auth/login?return_to="+url.QueryEscape(ctx.Request.URL.RequestURI()))
+			ctx.Abort()
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+
+	ctx.Set(sessionContextKey, session)
+	ctx.Next()
+}
+
+// RequireAdmin rejects requests of users who may only browse the results.
+// Every request is accepted when login is disabled.
+func (h *Handler) RequireAdmin(ctx *gin.Context) {
+	if !h.loginRequired() {
+		ctx.Next()
+		return
+	}
+
+	if !h.isAdmin(ctx) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This action requires the admin role"})
+		return
+	}
+	ctx.Next()
+}
+
+// isAdmin checks if the signed in user may run every action, which everyone may when login is disabled
+func (h *Handler) isAdmin(ctx *gin.Context) bool {
+	session, found := currentSession(ctx)
+	return !h.loginRequired() || (found && session.IsAdmin())
+}
+
+// loginRequired checks if users sign in, through an OpenID Connect provider or a reverse proxy
+func (h *Handler) loginRequired() bool {
+	return h.login != nil || h.trustedHeader != nil
+}
+
+// currentSession returns the signed in user, false when login is disabled
+func currentSession(ctx *gin.Context) (auth.Session, bool) {
+	value, found := ctx.Get(sessionContextKey)
+	if !found {
+		return auth.Session{}, false
+	}
+	session, ok := value.(auth.Session)
+	return session, ok
+}
+
+// GET /auth/login
+// Login redirects to the provider page where the user signs in
+func (h *Handler) Login(ctx *gin.Context) {
+	returnTo := ctx.Query("return_to")
+	// Only pages of the application are accepted, not other sites
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		returnTo = h.config.BasePath + "/"
+	}
+
+	request, err := auth.NewLoginRequest(returnTo)
+	if err != nil {
+		h.renderLoginError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	cookie, err := h.login.signer.Encode(request, time.Now().Add(loginDuration))
+	if err != nil {
+		h.renderLoginError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.setAuthCookie(ctx, constants.LoginCookie, cookie, loginDuration)
+	ctx.Redirect(http.StatusFound, h.login.provider.AuthCodeURL(request))
+}
+
+// GET /auth/callback
+// LoginCallback signs the user in once the provider redirects back, mapping its groups to a role
+func (h *Handler) LoginCallback(ctx *gin.Context) {
+	if providerError := ctx.Query("error"); providerError != "" {
+		h.loginFailed(ctx, "", providerError)
+		h.renderLoginError(ctx, http.StatusUnauthorized, errors.New(providerError+": "+ctx.Query("error_description")))
+		return
+	}
+
+	var request auth.LoginRequest
+	cookie, err := ctx.Cookie(constants.LoginCookie)
+	if err == nil {
+		err = h.login.signer.Decode(cookie, &request)
+	}
+	if err != nil || request.State != ctx.Query("state") {
+		h.renderLoginError(ctx, http.StatusBadRequest, errors.New("login expired or started in another browser, try again"))
+		return
+	}
+	h.setAuthCookie(ctx, constants.LoginCookie, "", -1)
+
+	identity, err := h.login.provider.Exchange(ctx.Query("code"), request)
+	if err != nil {
+		h.loginFailed(ctx, "", err.Error())
+		h.renderLoginError(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	role, allowed := h.config.Auth.RoleForGroups(identity.Groups)
+	if !allowed {
+		logrus.Warnf("Rejecting login of %s, member of none of the allowed groups: %v", identity.Name, identity.Groups)
+		h.loginFailed(ctx, identity.Name, "member of none of the allowed groups")
+		h.renderLoginError(ctx, http.StatusForbidden, errors.New(identity.Name+" is not allowed to use this application"))
+		return
+	}
+
+	duration := time.Duration(h.config.Auth.SessionDuration) * time.Minute
+	session, err := h.login.signer.Encode(auth.Session{
+		Subject: identity.Subject,
+		Name:    identity.Name,
+		Email:   identity.Email,
+		Role:    role,
+	}, time.Now().Add(duration))
+	if err != nil {
+		h.renderLoginError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.throttle.Succeed(ctx.ClientIP())
+	logrus.Infof("User %s signed in as %s", identity.Name, role)
+	h.setAuthCookie(ctx, constants.SessionCookie, session, duration)
+	ctx.Redirect(http.StatusFound, request.ReturnTo)
+}
+
+// GET /auth/logout
+// Logout signs the user out of the application, and of the provider when it supports it
+func (h *Handler) Logout(ctx *gin.Context) {
+	h.setAuthCookie(ctx, constants.SessionCookie, "", -1)
+
+	if logoutURL := h.login.provider.LogoutURL(); logoutURL != "" {
+		ctx.Redirect(http.StatusFound, logoutURL)
+		return
+	}
+	ctx.Redirect(http.StatusFound, h.config.BasePath+"/")
+}
+
+// setAuthCookie stores a signed value in an HTTP only cookie, removing it with a negative duration
+func (h *Handler) setAuthCookie(ctx *gin.Context, name, value string, duration time.Duration) {
+	maxAge := int(duration.Seconds())
+	if duration < 0 {
+		maxAge = -1
+	}
+	// Lax cookies are sent back by the redirection of the provider
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(name, value, maxAge, h.config.BasePath+"/", "", h.login.secure, true)
+}
+
+func (h *Handler) renderLoginError(ctx *gin.Context, status int, err error) {
+	logrus.Errorf("Login failed: %v", err)
+	ctx.HTML(status, "error.html", h.templateData(ctx, gin.H{
+		"error": "Login failed: " + err.Error(),
+	}))
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// avatarSize is the width and height in pixels Jellyfin scales the avatars down to, twice their displayed size
+// for high density screens
+const avatarSize = 64
+
+// avatarMaxBytes is the size above which an avatar is not served nor cached
+const avatarMaxBytes = 256 * 1024
+
+// avatarTTL is how long an avatar, or its absence, is cached
+const avatarTTL = time.Hour
+
+// ErrNoAvatar is returned for users without avatar
+var ErrNoAvatar = errors.New("user has no avatar")
+
+// avatar is a cached avatar, with an empty image for users without one. Its fields are exported so that it can be
+// shared by replicas through Redis.
+type avatar struct {
+	Image     jellyfinModels.Image `json:"image"`
+	FetchedAt time.Time            `json:"fetched_at"`
+}
+
+// UserAvatar returns the avatar of a Jellyfin user, cached for avatarTTL. Only the users of the users page are
+// served, so that the cache holds one entry per user at most.
+func (s *ServerService) UserAvatar(userID string) (jellyfinModels.Image, error) {
+	if cached, found := s.avatars.Get(userID); found && time.Since(cached.FetchedAt) < avatarTTL {
+		if len(cached.Image.Data) == 0 {
+			return jellyfinModels.Image{}, ErrNoAvatar
+		}
+		return cached.Image, nil
+	}
+
+	users, err := s.ListUsers()
+	if err != nil {
+		return jellyfinModels.Image{}, err
+	}
+	if !lo.ContainsBy(users, func(user UserSummary) bool { return user.ID == userID }) {
+		return jellyfinModels.Image{}, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+
+	image, err := s.jellyfinClient.GetUserImage(userID, avatarSize)
+	switch {
+	case errors.Is(err, jellyfinClients.ErrNotFound):
+		image = jellyfinModels.Image{}
+	case err != nil:
+		return jellyfinModels.Image{}, err
+	case !strings.HasPrefix(image.ContentType, "image/"):
+		logrus.Warnf("Ignoring avatar of user %s served as %s", userID, image.ContentType)
+		image = jellyfinModels.Image{}
+	case len(image.Data) > avatarMaxBytes:
+		logrus.Warnf("Ignoring avatar of user %s of %d bytes, above the %d bytes limit", userID, len(image.Data), avatarMaxBytes)
+		image = jellyfinModels.Image{}
+	}
+
+	s.avatars.Set(userID, avatar{Image: image, FetchedAt: time.Now()})
+	if len(image.Data) == 0 {
+		return jellyfinModels.Image{}, ErrNoAvatar
+	}
+	return image, nil
+}
+
+// GET /api/users/:id/avatar
+// GetUserAvatar serves the avatar of a Jellyfin user through the avatar cache, so that browsers never
+// need a Jellyfin token
+func (h *Handler) GetUserAvatar(ctx *gin.Context) {
+	image, err := h.serverService.UserAvatar(ctx.Param("id"))
+	if errors.Is(err, ErrNoAvatar) || errors.Is(err, ErrUserNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error fetching avatar of user %s: %v", ctx.Param("id"), err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(avatarTTL.Seconds())))
+	ctx.Data(http.StatusOK, image.ContentType, image.Data)
+}
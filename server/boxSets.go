@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"sort"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMinBoxSetOverlap is the overlap percentage above which two collections are reported
+const DefaultMinBoxSetOverlap = 50
+
+// BoxSetMovie is a movie of a collection
+type BoxSetMovie struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Year int    `json:"year"`
+}
+
+// BoxSetSummary is a collection with its movies
+type BoxSetSummary struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Movies []BoxSetMovie `json:"movies"`
+}
+
+// BoxSetMerge suggests to add the missing movies to the kept collection, then to remove the other one
+type BoxSetMerge struct {
+	KeepID      string        `json:"keep_id"`
+	KeepName    string        `json:"keep_name"`
+	RemoveID    string        `json:"remove_id"`
+	RemoveName  string        `json:"remove_name"`
+	MoviesToAdd []BoxSetMovie `json:"movies_to_add"`
+}
+
+// BoxSetOverlap is a pair of collections sharing most of their movies
+type BoxSetOverlap struct {
+	First  BoxSetSummary `json:"first"`
+	Second BoxSetSummary `json:"second"`
+	Shared []BoxSetMovie `json:"shared"`
+	// Overlap is the percentage of the smallest collection found in the other one
+	Overlap    float64     `json:"overlap"`
+	Suggestion BoxSetMerge `json:"suggestion"`
+}
+
+// FindBoxSetOverlaps reports the pairs of collections whose overlap is at least minOverlap percent, highest overlap first
+func (s *ServerService) FindBoxSetOverlaps(minOverlap float64) ([]BoxSetOverlap, error) {
+	boxSets, err := s.jellyfinClient.GetBoxSets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collections: %v", err)
+	}
+
+	// Copies of the same movie are different items, so movies are compared by provider ID when available
+	keys := make(map[string]string)
+	summaries := make([]BoxSetSummary, 0, len(boxSets))
+	for _, boxSet := range boxSets {
+		movies, err := s.jellyfinClient.GetBoxSetMovies(boxSet.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get movies of collection %s: %v", boxSet.Name, err)
+		}
+		for _, movie := range movies {
+			keys[movie.ID] = boxSetMovieKey(movie)
+		}
+		summaries = append(summaries, BoxSetSummary{
+			ID:   boxSet.ID,
+			Name: boxSet.Name,
+			Movies: lo.Map(movies, func(movie jellyfinModels.Movie, _ int) BoxSetMovie {
+				return BoxSetMovie{ID: movie.ID, Name: movie.Name, Year: movie.ProductionYear}
+			}),
+		})
+	}
+
+	overlaps := compareBoxSets(summaries, keys, minOverlap)
+	logrus.Infof("Found %d overlapping collections among %d", len(overlaps), len(boxSets))
+	return overlaps, nil
+}
+
+// boxSetMovieKey identifies a movie across its copies
+func boxSetMovieKey(movie jellyfinModels.Movie) string {
+	if keys := providerKeys(movie); len(keys) > 0 {
+		return keys[0]
+	}
+	return "id:" + movie.ID
+}
+
+// compareBoxSets compares every pair of collections, keys mapping movie IDs to their matching key
+func compareBoxSets(boxSets []BoxSetSummary, keys map[string]string, minOverlap float64) []BoxSetOverlap {
+	var overlaps []BoxSetOverlap
+	for i := 0; i < len(boxSets); i++ {
+		for j := i + 1; j < len(boxSets); j++ {
+			first, second := boxSets[i], boxSets[j]
+			if len(first.Movies) == 0 || len(second.Movies) == 0 {
+				continue
+			}
+
+			secondKeys := lo.SliceToMap(second.Movies, func(movie BoxSetMovie) (string, bool) {
+				return keys[movie.ID], true
+			})
+			shared := lo.Filter(first.Movies, func(movie BoxSetMovie, _ int) bool {
+				return secondKeys[keys[movie.ID]]
+			})
+
+			overlap := float64(len(shared)) * 100 / float64(min(len(first.Movies), len(second.Movies)))
+			if len(shared) == 0 || overlap < minOverlap {
+				continue
+			}
+
+			overlaps = append(overlaps, BoxSetOverlap{
+				First:      first,
+				Second:     second,
+				Shared:     shared,
+				Overlap:    overlap,
+				Suggestion: suggestBoxSetMerge(first, second, keys),
+			})
+		}
+	}
+
+	sort.SliceStable(overlaps, func(i, j int) bool {
+		return overlaps[i].Overlap > overlaps[j].Overlap
+	})
+	return overlaps
+}
+
+// suggestBoxSetMerge keeps the largest collection and lists the movies of the other one it lacks
+func suggestBoxSetMerge(first, second BoxSetSummary, keys map[string]string) BoxSetMerge {
+	keep, remove := first, second
+	if len(second.Movies) > len(first.Movies) {
+		keep, remove = second, first
+	}
+
+	kept := lo.SliceToMap(keep.Movies, func(movie BoxSetMovie) (string, bool) {
+		return keys[movie.ID], true
+	})
+	return BoxSetMerge{
+		KeepID:     keep.ID,
+		KeepName:   keep.Name,
+		RemoveID:   remove.ID,
+		RemoveName: remove.Name,
+		MoviesToAdd: lo.Filter(remove.Movies, func(movie BoxSetMovie, _ int) bool {
+			return !kept[keys[movie.ID]]
+		}),
+	}
+}
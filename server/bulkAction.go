@@ -0,0 +1,344 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/jobs"
+	"jellyfin-duplicate/pkg/humanize"
+	storageModels "jellyfin-duplicate/storage/models"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// BulkActionRequest is the body of a bulk action request
+type BulkActionRequest struct {
+	Action   constants.BulkAction `json:"action" binding:"required"`
+	GroupIDs []string             `json:"group_ids" binding:"required,min=1"`
+	// ScanVersion is the version of the scan the groups were selected from, 0 to skip the check
+	ScanVersion int64 `json:"scan_version"`
+	// Note is the reason recorded with the action, required to resolve groups manually
+	Note string `json:"note"`
+	// Strict restores the play status synchronized by sync_then_delete when the deletion fails
+	Strict bool `json:"strict"`
+	// Positions are the IDs of the copies whose playback positions are kept by group ID, required to synchronize
+	// the groups with position conflicts
+	Positions map[string]string `json:"positions"`
+	// Merge merges the copies labeled as intentional versions in Jellyfin, which then shows a single title
+	Merge bool `json:"merge"`
+}
+
+// Validate checks the action and its note
+func (r BulkActionRequest) Validate() error {
+	if !IsValidBulkAction(r.Action) {
+		return fmt.Errorf("invalid action %s", r.Action)
+	}
+	if r.Action == constants.ResolvedManuallyAction && strings.TrimSpace(r.Note) == "" {
+		return fmt.Errorf("a note explaining how the groups were resolved is required")
+	}
+	return nil
+}
+
+// BulkActionResult is the outcome of a bulk action for a single duplicate group
+type BulkActionResult struct {
+	GroupID string `json:"group_id"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// IsValidBulkAction checks if the action is supported
+func IsValidBulkAction(action constants.BulkAction) bool {
+	switch action {
+	case constants.SyncPlayStatusAction, constants.IgnoreAction, constants.NotDuplicateAction,
+		constants.DeleteLowerQualityAction, constants.KeepFirstAction, constants.KeepSecondAction,
+		constants.ResolvedManuallyAction, constants.SyncThenDeleteAction, constants.IntentionalVersionsAction:
+		return true
+	default:
+		return false
+	}
+}
+
+// BulkActionReport summarizes the results of a bulk action
+type BulkActionReport struct {
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Results   []BulkActionResult `json:"results"`
+	// ActionID identifies the rollback journal of play status changes, empty when nothing was changed
+	ActionID string `json:"action_id,omitempty"`
+}
+
+// NewBulkActionReport counts the succeeded and failed groups of a bulk action
+func NewBulkActionReport(results []BulkActionResult) BulkActionReport {
+	succeeded := lo.CountBy(results, func(result BulkActionResult) bool {
+		return result.Success
+	})
+	return BulkActionReport{Succeeded: succeeded, Failed: len(results) - succeeded, Results: results}
+}
+
+// RunBulkActionJob is the job runner of bulk actions
+func (s *ServerService) RunBulkActionJob(ctx context.Context, params json.RawMessage, progress jobs.Progress) (any, error) {
+	var request BulkActionRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, fmt.Errorf("invalid bulk action parameters: %v", err)
+	}
+	// The scan of a job queued before a restart is gone: like for the jobs of the maintenance window, a fresh
+	// scan checks the groups are still duplicates instead
+	if s.scans.FromPreviousRun(request.ScanVersion) {
+		logrus.Infof("Bulk action issued against scan version %d of a previous run, checking the groups with a new scan", request.ScanVersion)
+		request.ScanVersion = 0
+	}
+
+	report, err := s.RunBulkAction(ctx, request, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Failed > 0 {
+		return report, fmt.Errorf("%d of %d groups failed", report.Failed, len(report.Results))
+	}
+	return report, nil
+}
+
+// RunBulkAction applies an action to several duplicate groups, one after the other.
+// A failure on one group does not stop the others, cancelling ctx stops before the next group.
+// Play status changes are journaled so that they can be rolled back. progress is optional.
+func (s *ServerService) RunBulkAction(ctx context.Context, request BulkActionRequest, progress jobs.Progress) (BulkActionReport, error) {
+	action, groupIDs := request.Action, request.GroupIDs
+	logrus.Infof("Running bulk action %s on %d duplicate groups", action, len(groupIDs))
+
+	scan, err := s.scanForAction(request.ScanVersion)
+	if err != nil {
+		return BulkActionReport{}, err
+	}
+
+	journal := storageModels.ActionJournal{ID: newActionID(), Action: action, CreatedAt: time.Now()}
+	// The journal is saved even when the action is cancelled, the changes made so far can be rolled back
+	defer func() {
+		if len(journal.Entries) == 0 {
+			return
+		}
+		if err := s.store.SaveAction(journal, actionsKept); err != nil {
+			logrus.Errorf("Failed to save rollback journal of bulk action %s: %v", action, err)
+		}
+		s.notifyWatchedChanges(journal.Entries)
+	}()
+
+	duplicatesByID := make(map[string]jellyfinModels.DuplicateResult, len(scan.Duplicates))
+	for _, dup := range scan.Duplicates {
+		duplicatesByID[dup.ID] = dup
+	}
+
+	results := make([]BulkActionResult, 0, len(groupIDs))
+	for index, groupID := range groupIDs {
+		if err := ctx.Err(); err != nil {
+			logrus.Infof("Bulk action %s cancelled after %d of %d groups", action, index, len(groupIDs))
+			return newJournaledReport(results, journal), err
+		}
+		if progress != nil {
+			progress(index, len(groupIDs))
+		}
+
+		dup, ok := duplicatesByID[groupID]
+		if !ok {
+			results = append(results, BulkActionResult{GroupID: groupID, Error: "duplicate group not found"})
+			continue
+		}
+
+		var message string
+		switch action {
+		case constants.SyncPlayStatusAction:
+			message, err = s.syncPlayStatus(dup, request.Positions[groupID], &journal)
+		case constants.IgnoreAction:
+			message, err = s.ignoreDuplicate(dup)
+		case constants.NotDuplicateAction:
+			message, err = s.markNotDuplicate(dup)
+		case constants.ResolvedManuallyAction:
+			message, err = s.resolveManually(dup, request.Note)
+		case constants.IntentionalVersionsAction:
+			message, err = s.keepVersions(dup, request.Merge)
+		case constants.DeleteLowerQualityAction:
+			message, err = s.deleteLowerQuality(dup)
+		case constants.KeepFirstAction:
+			message, err = s.deleteCopy(dup, dup.Movie2)
+		case constants.KeepSecondAction:
+			message, err = s.deleteCopy(dup, dup.Movie1)
+		case constants.SyncThenDeleteAction:
+			message, err = s.syncThenDelete(dup, request.Strict, request.Positions[groupID], &journal)
+		default:
+			err = fmt.Errorf("unsupported action %s", action)
+		}
+
+		if err != nil {
+			logrus.Warnf("Bulk action %s failed for group %s: %v", action, groupID, err)
+			results = append(results, BulkActionResult{GroupID: groupID, Error: err.Error()})
+			continue
+		}
+		results = append(results, BulkActionResult{GroupID: groupID, Success: true, Message: message})
+	}
+
+	if progress != nil {
+		progress(len(groupIDs), len(groupIDs))
+	}
+	return newJournaledReport(results, journal), nil
+}
+
+// newJournaledReport builds the report of a bulk action, referencing its journal when play status changed
+func newJournaledReport(results []BulkActionResult, journal storageModels.ActionJournal) BulkActionReport {
+	report := NewBulkActionReport(results)
+	if len(journal.Entries) > 0 {
+		report.ActionID = journal.ID
+	}
+	return report
+}
+
+// syncPlayStatus marks each copy as seen for every user who has only seen the other copy, and sets the playback
+// position of the copy keepPositionOf to the other copy for the users who stopped watching both at different
+// positions. The play status is recorded in the journal before being changed.
+func (s *ServerService) syncPlayStatus(dup jellyfinModels.DuplicateResult, keepPositionOf string, journal *storageModels.ActionJournal) (string, error) {
+	if err := checkPositionChoice(dup, keepPositionOf); err != nil {
+		return "", err
+	}
+	discrepancies := s.GetPlayStatusDiscrepancies(dup.Movie1, dup.Movie2)
+	if len(discrepancies) == 0 && !dup.HasPositionConflict {
+		return "play status already identical", nil
+	}
+
+	if err := s.syncDiscrepancies(discrepancies, journal); err != nil {
+		return "", err
+	}
+	if dup.HasPositionConflict {
+		if err := s.syncPositions(dup, keepPositionOf, journal); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("play status synchronized for %d users", usersAffected(discrepancies)+len(dup.PositionConflicts)), nil
+}
+
+// syncDiscrepancies marks the movie to update of each discrepancy as seen, recording the previous play status
+// in the journal before changing it
+func (s *ServerService) syncDiscrepancies(discrepancies []jellyfinModels.PlayStatusDiscrepancy, journal *storageModels.ActionJournal) error {
+	for _, discrepancy := range discrepancies {
+		previous, err := s.jellyfinClient.GetUserItemData(discrepancy.MovieToUpdate, discrepancy.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to record play status for user %s: %v", discrepancy.UserName, err)
+		}
+		journal.Entries = append(journal.Entries, storageModels.PlayStateEntry{
+			MovieID:               discrepancy.MovieToUpdate,
+			MovieName:             discrepancy.MovieName,
+			UserID:                discrepancy.UserID,
+			UserName:              discrepancy.UserName,
+			Played:                previous.Played,
+			PlaybackPositionTicks: previous.PlaybackPositionTicks,
+			PlayCount:             previous.PlayCount,
+			LastPlayedDate:        previous.LastPlayedDate,
+			Change:                constants.MarkPlayedAudit,
+		})
+
+		if err := s.MarkMovieAsSeen(discrepancy.MovieToUpdate, discrepancy.UserID); err != nil {
+			return fmt.Errorf("failed to sync play status for user %s: %v", discrepancy.UserName, err)
+		}
+	}
+
+	return nil
+}
+
+// ignoreDuplicate hides the duplicate pair from future results
+func (s *ServerService) ignoreDuplicate(dup jellyfinModels.DuplicateResult) (string, error) {
+	err := s.store.IgnorePair(storageModels.IgnoredPair{
+		ID:          dup.ID,
+		Fingerprint: PairFingerprint(dup.Movie1, dup.Movie2),
+		Movie1ID:    dup.Movie1.ID,
+		Movie2ID:    dup.Movie2.ID,
+		MovieName:   dup.Movie1.Name,
+		IgnoredAt:   time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to ignore duplicate: %v", err)
+	}
+
+	return "duplicate ignored", nil
+}
+
+// deleteLowerQuality deletes the recommended copy, only when it is safe to do so
+func (s *ServerService) deleteLowerQuality(dup jellyfinModels.DuplicateResult) (string, error) {
+	movie, err := recommendedCopy(dup)
+	if err != nil {
+		return "", err
+	}
+	return s.deleteCopy(dup, movie)
+}
+
+// recommendedCopy returns the lower quality copy of a pair, when it can be deleted without an explicit choice
+func recommendedCopy(dup jellyfinModels.DuplicateResult) (jellyfinModels.Movie, error) {
+	if dup.Link != nil {
+		return jellyfinModels.Movie{}, fmt.Errorf("both copies are the same file (%s), choose the copy to keep", dup.Link.Type)
+	}
+	if dup.BelowMinReclaimableSize {
+		return jellyfinModels.Movie{}, fmt.Errorf("deleting a copy frees only %s, below deletion.min_reclaimable_size", humanize.Bytes(dup.ReclaimableSize))
+	}
+	if dup.RecommendedDeleteID == "" {
+		return jellyfinModels.Movie{}, fmt.Errorf("both copies have the same quality, no copy is recommended for deletion")
+	}
+
+	// Languages are only lost on an explicit choice of the copy to keep
+	if dup.Tracks.LosesLanguages() {
+		lost := append(append([]string{}, dup.Tracks.LostAudioLanguages...), dup.Tracks.LostSubtitleLanguages...)
+		return jellyfinModels.Movie{}, fmt.Errorf("the lower quality copy is the only one with tracks in %s, choose the copy to keep",
+			strings.Join(lo.Uniq(lost), ", "))
+	}
+
+	movie := dup.Movie1
+	if dup.Movie2.ID == dup.RecommendedDeleteID {
+		movie = dup.Movie2
+	}
+	return movie, nil
+}
+
+// deleteCopy deletes one copy of a duplicate pair, only when it is safe to do so
+func (s *ServerService) deleteCopy(dup jellyfinModels.DuplicateResult, movie jellyfinModels.Movie) (string, error) {
+	if err := checkDeletable(dup); err != nil {
+		return "", err
+	}
+	return s.removeCopy(dup, movie)
+}
+
+// checkDeletable refuses deleting a copy of potential mismatches, and of pairs whose play status differs
+func checkDeletable(dup jellyfinModels.DuplicateResult) error {
+	if !dup.IsDuplicate {
+		return fmt.Errorf("pair is a potential mismatch, not a duplicate")
+	}
+	if !dup.HasIdenticalPlayStatus {
+		return fmt.Errorf("play status differs between copies, synchronize it first")
+	}
+	return nil
+}
+
+// removeCopy deletes one copy of a duplicate pair, once its play status is known to be on the kept copy
+func (s *ServerService) removeCopy(dup jellyfinModels.DuplicateResult, movie jellyfinModels.Movie) (string, error) {
+	if err := checkLinkedDeletion(dup, movie); err != nil {
+		return "", err
+	}
+
+	kept := dup.Movie1
+	if kept.ID == movie.ID {
+		kept = dup.Movie2
+	}
+
+	// The movie comes from the scan, deletion is refused if its file changed since
+	options := DeleteOptions{
+		Expected:    &ExpectedFile{Path: movie.Path, Size: movie.Size()},
+		KeepMovieID: kept.ID,
+	}
+	if err := s.DeleteMovie(movie.ID, options); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("deleted %s", movie.Path), nil
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"jellyfin-duplicate/pkg/cache"
+	"jellyfin-duplicate/pkg/humanize"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrUnknownCache is returned when flushing a cache which does not exist
+var ErrUnknownCache = errors.New("unknown cache")
+
+// CacheStatus is the usage of one of the in-memory caches
+type CacheStatus struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	cache.Stats
+	EstimatedSize string `json:"estimated_size"`
+}
+
+// adminCache is an in-memory cache which can be inspected and flushed
+type adminCache struct {
+	name        string
+	description string
+	stats       func() cache.Stats
+	flush       func() int
+}
+
+// adminCaches lists the in-memory caches of the application. Jellyfin items are not cached:
+// every scan fetches them again, and the latest scan result holds the movies it found.
+func (s *ServerService) adminCaches() []adminCache {
+	caches := []adminCache{
+		{
+			name:        "user_names",
+			description: "Jellyfin user names by ID, filled when fetching users and play status",
+			stats:       s.jellyfinClient.UserNameCacheStats,
+			flush:       s.jellyfinClient.FlushUserNameCache,
+		},
+	}
+	if s.secondaryClient != nil {
+		caches = append(caches, adminCache{
+			name:        "secondary_user_names",
+			description: "User names by ID of the secondary Jellyfin server",
+			stats:       s.secondaryClient.UserNameCacheStats,
+			flush:       s.secondaryClient.FlushUserNameCache,
+		})
+	}
+	return append(caches,
+		adminCache{
+			name:        "users",
+			description: "Users and seen movie counts of the users page, refreshed by every scan",
+			stats:       s.usersCacheStats,
+			flush:       s.flushUsersCache,
+		},
+		adminCache{
+			name:        "avatars",
+			description: "User avatars of the users page and play status discrepancies, fetched again after an hour",
+			stats:       s.avatars.Stats,
+			flush:       s.avatars.Flush,
+		},
+		adminCache{
+			name:        "fingerprints",
+			description: "Content fingerprints of home videos by file, computed again when a file changes",
+			stats:       s.fingerprints.Stats,
+			flush:       s.fingerprints.Flush,
+		},
+		adminCache{
+			name:        "inspections",
+			description: "Stream details of the copies of duplicates read by ffprobe, read again when a file changes",
+			stats:       s.inspections.Stats,
+			flush:       s.inspections.Flush,
+		},
+		adminCache{
+			name:        "scan",
+			description: "Latest scan result, used by the pages and actions until the next scan",
+			stats:       s.scans.Stats,
+			flush:       s.scans.Flush,
+		},
+	)
+}
+
+// CacheStatuses returns the usage of every in-memory cache
+func (s *ServerService) CacheStatuses() []CacheStatus {
+	caches := s.adminCaches()
+	statuses := make([]CacheStatus, 0, len(caches))
+	for _, c := range caches {
+		stats := c.stats()
+		statuses = append(statuses, CacheStatus{
+			Name:          c.name,
+			Description:   c.description,
+			Stats:         stats,
+			EstimatedSize: humanize.Bytes(stats.EstimatedBytes),
+		})
+	}
+	return statuses
+}
+
+// FlushCache empties the named cache, or every cache when the name is empty,
+// and returns the number of entries removed by cache name
+func (s *ServerService) FlushCache(name string) (map[string]int, error) {
+	flushed := make(map[string]int)
+	for _, c := range s.adminCaches() {
+		if name == "" || name == c.name {
+			flushed[c.name] = c.flush()
+		}
+	}
+	if len(flushed) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrUnknownCache, name)
+	}
+
+	logrus.Infof("Flushed caches: %v", flushed)
+	return flushed, nil
+}
+
+// usersCacheStats returns the usage of the users cached for the users page
+func (s *ServerService) usersCacheStats() cache.Stats {
+	s.usersMutex.RLock()
+	defer s.usersMutex.RUnlock()
+	return s.usersLookups.Stats(len(s.users), cache.SizeOf(s.users))
+}
+
+// flushUsersCache forgets the cached users, fetched again on the next users page load or scan
+func (s *ServerService) flushUsersCache() int {
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+	flushed := len(s.users)
+	s.users = nil
+	return flushed
+}
+
+// GET /api/admin/cache
+// GetCaches returns the entry counts, hit rates and memory estimates of the in-memory caches
+func (h *Handler) GetCaches(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"caches": h.serverService.CacheStatuses(),
+	})
+}
+
+// DELETE /api/admin/cache[/:name]
+// FlushCache empties the named cache, or every cache without a name
+func (h *Handler) FlushCache(ctx *gin.Context) {
+	flushed, err := h.serverService.FlushCache(ctx.Param("name"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrUnknownCache) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Cache flushed",
+		"flushed": flushed,
+	})
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/filesystem"
+	"jellyfin-duplicate/pkg/humanize"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// FolderClutter is a redundant file found in the folder of a movie
+type FolderClutter struct {
+	// MovieID and MovieName are empty when the folder holds several movies
+	MovieID   string                `json:"movie_id,omitempty"`
+	MovieName string                `json:"movie_name,omitempty"`
+	Library   string                `json:"library"`
+	Path      string                `json:"path"`
+	Kind      constants.ClutterKind `json:"kind"`
+	Size      int64                 `json:"size"`
+	// DuplicateOf is the subtitle with the same content which is kept, for duplicate subtitles
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// ClutterReport lists the redundant files of the movie folders, with the space freed by deleting them
+type ClutterReport struct {
+	Files            []FolderClutter `json:"files"`
+	ReclaimableSize  int64           `json:"reclaimable_size"`
+	ReclaimableSizeH string          `json:"reclaimable_size_h"`
+	// Folders counts the movie folders, Inaccessible the ones which could not be listed
+	Folders      int `json:"folders"`
+	Inaccessible int `json:"inaccessible"`
+}
+
+// FindFolderClutter lists the redundant files of the folders of the movies: duplicate subtitles, sample videos
+// and leftovers of interrupted downloads. The media folders must be accessible, through deletion.path_mappings;
+// paths are local ones. Nothing is deleted.
+func (s *ServerService) FindFolderClutter(ctx context.Context) (ClutterReport, error) {
+	movies, err := s.jellyfinClient.WithContext(ctx).GetAllMovies()
+	if err != nil {
+		return ClutterReport{}, fmt.Errorf("failed to list the movies of Jellyfin: %v", err)
+	}
+
+	videos := make(map[string]bool, len(movies))
+	folders := make(map[string][]jellyfinModels.Movie)
+	var order []string
+	for _, movie := range movies {
+		if movie.Path == "" {
+			continue
+		}
+		localPath := s.pathMapper.ToLocal(movie.Path)
+		videos[localPath] = true
+		folder := filepath.Dir(localPath)
+		if _, found := folders[folder]; !found {
+			order = append(order, folder)
+		}
+		folders[folder] = append(folders[folder], movie)
+	}
+
+	report := ClutterReport{Files: []FolderClutter{}, Folders: len(order)}
+	for _, folder := range order {
+		if err := ctx.Err(); err != nil {
+			return ClutterReport{}, err
+		}
+		clutter, err := filesystem.FindClutter(folder, videos)
+		if err != nil {
+			logrus.Debugf("Skipping movie folder: %v", err)
+			report.Inaccessible++
+			continue
+		}
+
+		folderMovies := folders[folder]
+		for _, file := range clutter {
+			entry := FolderClutter{
+				Library:     folderMovies[0].LibraryName,
+				Path:        file.Path,
+				Kind:        file.Kind,
+				Size:        file.Size,
+				DuplicateOf: file.DuplicateOf,
+			}
+			// Files of folders shared by several movies cannot be told apart
+			if len(folderMovies) == 1 {
+				entry.MovieID, entry.MovieName = folderMovies[0].ID, folderMovies[0].Name
+			}
+			report.Files = append(report.Files, entry)
+			report.ReclaimableSize += file.Size
+		}
+	}
+	report.ReclaimableSizeH = humanize.Bytes(report.ReclaimableSize)
+
+	if report.Folders > 0 && report.Inaccessible == report.Folders {
+		logrus.Warnf("None of the %d movie folders is accessible, check deletion.path_mappings", report.Folders)
+	}
+	logrus.Infof("Found %d redundant files in %d movie folders, %s reclaimable", len(report.Files), report.Folders, report.ReclaimableSizeH)
+	return report, nil
+}
+
+// GET /api/clutter
+// GetFolderClutter returns the redundant files of the movie folders, as JSON or as a CSV file with format=csv,
+// formatted by the locale parameter
+func (h *Handler) GetFolderClutter(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be json or csv",
+		})
+		return
+	}
+	export, err := ParseCSVExport(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	report, err := h.serverService.FindFolderClutter(ctx.Request.Context())
+	if err != nil {
+		logrus.Errorf("Error finding redundant files of movie folders: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if format == "json" {
+		ctx.JSON(http.StatusOK, report)
+		return
+	}
+	writer := export.Start(ctx, "folder-clutter.csv")
+	records := [][]string{{"kind", "path", export.SizeHeader("size"), "duplicate_of", "movie_id", "movie_name", "library"}}
+	for _, file := range report.Files {
+		records = append(records, []string{string(file.Kind), file.Path, export.Size(file.Size), file.DuplicateOf,
+			file.MovieID, file.MovieName, file.Library})
+	}
+	if err := writer.WriteAll(records); err != nil {
+		logrus.Errorf("Failed to write redundant files: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	confModels "jellyfin-duplicate/configuration/models"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS lets the origins of cors.allowed_origins call the API from the browser, answering their preflight
+// requests. Requests of other origins are left untouched, browsers keeping their pages from reading the answers.
+func CORS(config confModels.CORSConfig) gin.HandlerFunc {
+	anyOrigin := slices.Contains(config.AllowedOrigins, "*")
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(config.MaxAge)
+
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader("Origin")
+		if !anyOrigin {
+			// The answer depends on the origin, caches must not share it between origins
+			ctx.Writer.Header().Add("Vary", "Origin")
+		}
+		if origin == "" || (!anyOrigin && !slices.Contains(config.AllowedOrigins, origin)) {
+			ctx.Next()
+			return
+		}
+
+		if anyOrigin {
+			ctx.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			ctx.Header("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			ctx.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if ctx.Request.Method != http.MethodOptions || ctx.GetHeader("Access-Control-Request-Method") == "" {
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("Access-Control-Allow-Methods", methods)
+		ctx.Header("Access-Control-Allow-Headers", headers)
+		ctx.Header("Access-Control-Max-Age", maxAge)
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
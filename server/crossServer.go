@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ServerComparison lists the movies present on both servers or on only one of them,
+// to follow a migration from a primary server to a secondary one
+type ServerComparison struct {
+	Both          []CrossServerMatch     `json:"both"`
+	OnlyPrimary   []jellyfinModels.Movie `json:"only_primary"`
+	OnlySecondary []jellyfinModels.Movie `json:"only_secondary"`
+}
+
+// CrossServerMatch is a movie found on both servers
+type CrossServerMatch struct {
+	Primary   jellyfinModels.Movie `json:"primary"`
+	Secondary jellyfinModels.Movie `json:"secondary"`
+	// MatchedBy is the key both copies share: tmdb, imdb or name (name and production year)
+	MatchedBy string `json:"matched_by"`
+	// PlayStatusDifferences lists the users, matched by name, who have seen only one of the copies
+	PlayStatusDifferences []CrossServerPlayStatus `json:"play_status_differences,omitempty"`
+}
+
+// CrossServerPlayStatus is the play status of a user on both servers
+type CrossServerPlayStatus struct {
+	UserName          string `json:"user_name"`
+	PlayedOnPrimary   bool   `json:"played_on_primary"`
+	PlayedOnSecondary bool   `json:"played_on_secondary"`
+}
+
+// CompareServers matches the movies of two servers by provider IDs, then by name and production year,
+// and compares the play status of the users existing on both servers
+func CompareServers(primary, secondary *jellyfinClients.Client) (ServerComparison, error) {
+	primaryMovies, err := serverPlayStatus(primary)
+	if err != nil {
+		return ServerComparison{}, fmt.Errorf("primary server: %v", err)
+	}
+	secondaryMovies, err := serverPlayStatus(secondary)
+	if err != nil {
+		return ServerComparison{}, fmt.Errorf("secondary server: %v", err)
+	}
+	logrus.Infof("Comparing %d movies of the primary server with %d movies of the secondary server", len(primaryMovies), len(secondaryMovies))
+
+	// Index the secondary movies by every key they can be matched by
+	index := make(map[string][]int)
+	for i, movie := range secondaryMovies {
+		for _, key := range matchKeys(movie) {
+			index[key.value] = append(index[key.value], i)
+		}
+	}
+
+	var comparison ServerComparison
+	matched := make([]bool, len(secondaryMovies))
+	for _, movie := range primaryMovies {
+		match, found := CrossServerMatch{}, false
+		for _, key := range matchKeys(movie) {
+			for _, i := range index[key.value] {
+				if !matched[i] {
+					matched[i] = true
+					match = CrossServerMatch{Primary: movie, Secondary: secondaryMovies[i], MatchedBy: key.kind}
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+
+		if !found {
+			comparison.OnlyPrimary = append(comparison.OnlyPrimary, movie)
+			continue
+		}
+		match.PlayStatusDifferences = crossServerPlayStatusDifferences(match.Primary, match.Secondary)
+		comparison.Both = append(comparison.Both, match)
+	}
+
+	for i, movie := range secondaryMovies {
+		if !matched[i] {
+			comparison.OnlySecondary = append(comparison.OnlySecondary, movie)
+		}
+	}
+
+	sort.Slice(comparison.Both, func(i, j int) bool { return comparison.Both[i].Primary.Name < comparison.Both[j].Primary.Name })
+	sort.Slice(comparison.OnlyPrimary, func(i, j int) bool { return comparison.OnlyPrimary[i].Name < comparison.OnlyPrimary[j].Name })
+	sort.Slice(comparison.OnlySecondary, func(i, j int) bool { return comparison.OnlySecondary[i].Name < comparison.OnlySecondary[j].Name })
+	return comparison, nil
+}
+
+// serverPlayStatus fetches the movies of a server with the play status of all its users
+func serverPlayStatus(client *jellyfinClients.Client) ([]jellyfinModels.Movie, error) {
+	movies, err := client.GetAllMovies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all movies: %v", err)
+	}
+
+	users, err := client.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %v", err)
+	}
+
+	userSeenMovies, err := client.GetSeenMoviesForAllUsers(users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get seen movies for all users: %v", err)
+	}
+
+	return client.ReconcilePlayStatusWithAllMovies(movies, userSeenMovies, users)
+}
+
+type matchKey struct {
+	kind  string
+	value string
+}
+
+// matchKeys lists the keys a movie is matched by across servers, most reliable first
+func matchKeys(movie jellyfinModels.Movie) []matchKey {
+	var keys []matchKey
+	if movie.ProviderIds.Tmdb != "" {
+		keys = append(keys, matchKey{kind: "tmdb", value: "tmdb:" + movie.ProviderIds.Tmdb})
+	}
+	if movie.ProviderIds.Imdb != "" {
+		keys = append(keys, matchKey{kind: "imdb", value: "imdb:" + strings.ToLower(movie.ProviderIds.Imdb)})
+	}
+	keys = append(keys, matchKey{kind: "name", value: fmt.Sprintf("name:%s-%d", strings.ToLower(movie.Name), movie.ProductionYear)})
+	return keys
+}
+
+// crossServerPlayStatusDifferences compares the play status of the users existing on both servers.
+// User IDs differ from one server to the other, users are matched by name.
+func crossServerPlayStatusDifferences(primary, secondary jellyfinModels.Movie) []CrossServerPlayStatus {
+	secondaryPlayed := make(map[string]bool)
+	for _, status := range secondary.UserPlayStatuses {
+		secondaryPlayed[strings.ToLower(status.UserName)] = status.Played
+	}
+
+	var differences []CrossServerPlayStatus
+	for _, status := range primary.UserPlayStatuses {
+		played, exists := secondaryPlayed[strings.ToLower(status.UserName)]
+		if exists && played != status.Played {
+			differences = append(differences, CrossServerPlayStatus{
+				UserName:          status.UserName,
+				PlayedOnPrimary:   status.Played,
+				PlayedOnSecondary: played,
+			})
+		}
+	}
+	return differences
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/i18n"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// utf8BOM starts the exports of a locale, spreadsheets reading the file as UTF-8 only with it
+const utf8BOM = "\ufeff"
+
+// CSVExport formats the values of the CSV exports. Without locale, they are read by scripts: sizes in bytes,
+// RFC 3339 times and comma separated fields. With a locale, they are read by spreadsheets: sizes in gigabytes
+// with the decimal separator of the locale, dates of the locale, and semicolon separated fields when the decimal
+// separator is a comma.
+type CSVExport struct {
+	// Locale is nil for the exports read by scripts
+	Locale     *i18n.Locale
+	DateFormat constants.ExportDateFormat
+}
+
+// ParseCSVExport reads the locale and date_format query parameters of an export. locale is a supported locale
+// tag, or auto for the locale of the interface, chosen or preferred by the browser through Accept-Language.
+func ParseCSVExport(ctx *gin.Context) (CSVExport, error) {
+	export := CSVExport{DateFormat: constants.ISODateFormat}
+	switch tag := ctx.Query("locale"); tag {
+	case "":
+	case "auto":
+		locale := getLocale(ctx)
+		export.Locale, export.DateFormat = &locale, constants.ShortDateFormat
+	default:
+		locale, found := i18n.Lookup(tag)
+		if !found {
+			return export, fmt.Errorf("locale must be auto or one of %s", strings.Join(lo.Map(i18n.Supported(),
+				func(locale i18n.Locale, _ int) string {
+					return locale.Tag
+				}), ", "))
+		}
+		export.Locale, export.DateFormat = &locale, constants.ShortDateFormat
+	}
+
+	if format := constants.ExportDateFormat(ctx.Query("date_format")); format != "" {
+		if !constants.IsValidExportDateFormat(format) {
+			return export, fmt.Errorf("date_format must be %s, %s or %s", constants.ISODateFormat, constants.ShortDateFormat,
+				constants.MediumDateFormat)
+		}
+		if format != constants.ISODateFormat && export.Locale == nil {
+			return export, fmt.Errorf("date_format %s requires a locale", format)
+		}
+		export.DateFormat = format
+	}
+	return export, nil
+}
+
+// Start answers with a CSV file named fileName and returns its writer
+func (e CSVExport) Start(ctx *gin.Context, fileName string) *csv.Writer {
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	ctx.Status(http.StatusOK)
+
+	writer := csv.NewWriter(ctx.Writer)
+	if e.Locale != nil {
+		ctx.Writer.WriteString(utf8BOM)
+		if e.Locale.DecimalSeparator() == "," {
+			writer.Comma = ';'
+		}
+	}
+	return writer
+}
+
+// SizeHeader returns the header of the size columns
+func (e CSVExport) SizeHeader(name string) string {
+	if e.Locale == nil {
+		return name
+	}
+	return name + "_gb"
+}
+
+// Size formats a size, in bytes without locale and in gigabytes with one
+func (e CSVExport) Size(bytes int64) string {
+	if e.Locale == nil {
+		return strconv.FormatInt(bytes, 10)
+	}
+	return e.Locale.FormatDecimal(float64(bytes)/1e9, 2)
+}
+
+// Time formats an optional time, empty when unknown. Times of a locale are in the timezone of the server.
+func (e CSVExport) Time(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	switch e.DateFormat {
+	case constants.ShortDateFormat:
+		return e.Locale.FormatShortDateTime(value.Local())
+	case constants.MediumDateFormat:
+		return e.Locale.FormatDateTime(value.Local())
+	default:
+		return value.Format(time.RFC3339)
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPprof exposes the Go profiling endpoints under /debug/pprof, protected by the admin token
+func RegisterPprof(routes *gin.RouterGroup, handler *Handler, adminToken string) {
+	debug := routes.Group("/debug/pprof", handler.requireBearerToken(adminToken))
+	debug.GET("/", gin.WrapF(pprof.Index))
+	debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/trace", gin.WrapF(pprof.Trace))
+	// Named profiles (heap, goroutine, allocs...) are served explicitly, as pprof.Index
+	// only resolves them when the application is served at the root
+	debug.GET("/:profile", func(ctx *gin.Context) {
+		pprof.Handler(ctx.Param("profile")).ServeHTTP(ctx.Writer, ctx.Request)
+	})
+}
+
+// requireBearerToken rejects requests without the expected bearer token. Clients sending wrong tokens
+// are locked out like failed sign ins.
+func (h *Handler) requireBearerToken(expected string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if wait, locked := h.throttle.Locked(ctx.ClientIP()); locked {
+			rejectThrottled(ctx, wait)
+			return
+		}
+
+		token, found := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			h.loginFailed(ctx, "", "invalid bearer token for "+ctx.FullPath())
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Valid bearer token required"})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/humanize"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoTrashDir is returned when exporting moves to the trash while no trash directory is configured
+var ErrNoTrashDir = errors.New("deletion.trash_dir is not configured")
+
+// deletionScriptHeader defines the check function of the deletion scripts: a file is only removed if it still
+// has the size it had during the scan, 0 when unknown. The script fails when a file is skipped or not removed.
+const deletionScriptHeader = `set -u
+failed=0
+
+check() {
+    if [ ! -f "$1" ]; then
+        echo "Skipping $1: file not found" >&2
+        return 1
+    fi
+    if [ "$2" != 0 ] && [ "$(wc -c < "$1" | tr -d ' ')" != "$2" ]; then
+        echo "Skipping $1: size changed since the scan" >&2
+        return 1
+    fi
+}
+`
+
+// DeletionScriptRequest is the body of a deletion script export
+type DeletionScriptRequest struct {
+	GroupIDs []string `json:"group_ids" binding:"required,min=1"`
+	// ScanVersion is the version of the scan the groups were selected from, 0 to skip the check
+	ScanVersion int64 `json:"scan_version"`
+	// Move moves the copies into deletion.trash_dir instead of removing them
+	Move bool `json:"move"`
+}
+
+// DeletionScript writes a shell script deleting the lower quality copy of the groups, for users who prefer
+// to review the deletions and run them themselves. The groups the delete_lower_quality action would refuse
+// are listed as comments with the reason, and paths are mapped with deletion.path_mappings.
+func (s *ServerService) DeletionScript(request DeletionScriptRequest) (string, error) {
+	if request.Move && s.config.Deletion.TrashDir == "" {
+		return "", ErrNoTrashDir
+	}
+	scan, err := s.scanForAction(request.ScanVersion)
+	if err != nil {
+		return "", err
+	}
+	duplicatesByID := make(map[string]jellyfinModels.DuplicateResult, len(scan.Duplicates))
+	for _, dup := range scan.Duplicates {
+		duplicatesByID[dup.ID] = dup
+	}
+
+	var script strings.Builder
+	fmt.Fprintln(&script, "#!/bin/sh")
+	fmt.Fprintf(&script, "# Pending deletions exported by jellyfin-duplicate %s on %s from scan %d\n",
+		constants.Version, time.Now().Format("2006-01-02 15:04"), scan.Version)
+	fmt.Fprintln(&script, "# Review every command before running this script. Sidecar files (subtitles, .nfo) are left in place,")
+	fmt.Fprintln(&script, "# and Jellyfin notices the deleted copies on its next library scan.")
+	script.WriteString(deletionScriptHeader + "\n")
+	if request.Move {
+		trash := filepath.Join(s.config.Deletion.TrashDir, time.Now().Format("20060102-150405"))
+		fmt.Fprintf(&script, "TRASH=%s\nmkdir -p \"$TRASH\"\n\n", shellQuote(trash))
+	}
+
+	deleted, freed := 0, int64(0)
+	for index, groupID := range request.GroupIDs {
+		dup, ok := duplicatesByID[groupID]
+		if !ok {
+			writeScriptComment(&script, "Skipped group %s: duplicate group not found\n", groupID)
+			continue
+		}
+		movie, err := recommendedCopy(dup)
+		if err == nil {
+			err = checkDeletable(dup)
+		}
+		if err == nil {
+			err = checkLinkedDeletion(dup, movie)
+		}
+		if err != nil {
+			writeScriptComment(&script, "Skipped %s (%d): %v\n", dup.Movie1.Name, dup.Movie1.ProductionYear, err)
+			continue
+		}
+
+		kept := dup.Movie1
+		if kept.ID == movie.ID {
+			kept = dup.Movie2
+		}
+		localPath := s.pathMapper.ToLocal(movie.Path)
+		path := shellQuote(localPath)
+		writeScriptComment(&script, "%s (%d): keeping %s (%s)", movie.Name, movie.ProductionYear,
+			s.pathMapper.ToLocal(kept.Path), scriptDescription(kept))
+		writeScriptComment(&script, "Deleting the lower quality copy (%s), freeing %s", scriptDescription(movie),
+			humanize.Bytes(dup.ReclaimableSize))
+		if request.Move {
+			// Copies sharing a file name are numbered, so that they do not overwrite each other in the trash
+			destination := shellQuote(fmt.Sprintf("%d-%s", index+1, filepath.Base(localPath)))
+			fmt.Fprintf(&script, "check %s %d && mv -- %s \"$TRASH\"/%s || failed=1\n\n", path, movie.Size(), path, destination)
+		} else {
+			fmt.Fprintf(&script, "check %s %d && rm -- %s || failed=1\n\n", path, movie.Size(), path)
+		}
+		deleted++
+		freed += dup.ReclaimableSize
+	}
+	writeScriptComment(&script, "%d of %d groups, freeing %s", deleted, len(request.GroupIDs), humanize.Bytes(freed))
+	script.WriteString("exit $failed\n")
+
+	logrus.Infof("Exported a deletion script for %d of %d duplicate groups", deleted, len(request.GroupIDs))
+	return script.String(), nil
+}
+
+// writeScriptComment writes a comment line, the line breaks of names and paths being replaced so that they
+// cannot end the comment. A trailing line break adds an empty line.
+func writeScriptComment(script *strings.Builder, format string, args ...any) {
+	text, blank := strings.CutSuffix(fmt.Sprintf(format, args...), "\n")
+	fmt.Fprintf(script, "# %s\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(text))
+	if blank {
+		script.WriteString("\n")
+	}
+}
+
+// scriptDescription summarizes the quality of a copy in the comments of the deletion scripts
+func scriptDescription(movie jellyfinModels.Movie) string {
+	parts := []string{}
+	if resolution := movie.Resolution(); resolution != constants.UnknownResolution {
+		parts = append(parts, string(resolution))
+	}
+	if codec := movie.VideoCodec(); codec != "" {
+		parts = append(parts, codec)
+	}
+	if size := movie.Size(); size > 0 {
+		parts = append(parts, humanize.Bytes(size))
+	}
+	if len(parts) == 0 {
+		return "unknown quality"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// shellQuote quotes a value for POSIX shells, single quotes within being escaped
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// POST /api/duplicates/deletion-script
+// ExportDeletionScript downloads a shell script deleting the lower quality copy of the selected groups,
+// or moving it to the trash with move set
+func (h *Handler) ExportDeletionScript(ctx *gin.Context) {
+	var request DeletionScriptRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	script, err := h.serverService.DeletionScript(request)
+	switch {
+	case errors.Is(err, ErrStaleScan):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	case errors.Is(err, ErrNoTrashDir):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	case err != nil:
+		logrus.Errorf("Error exporting deletion script: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="pending-deletions-%s.sh"`, time.Now().Format("2006-01-02")))
+	ctx.Data(http.StatusOK, "text/x-shellscript; charset=utf-8", []byte(script))
+}
@@ -0,0 +1,284 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	"jellyfin-duplicate/cluster"
+	confModels "jellyfin-duplicate/configuration/models"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/demo"
+	"jellyfin-duplicate/jobs"
+	"jellyfin-duplicate/logs"
+	"jellyfin-duplicate/notifications"
+	"jellyfin-duplicate/server"
+	"jellyfin-duplicate/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// update writes the golden files from the responses instead of comparing them, after an intended change:
+// go test ./server -run TestE2E -update
+var update = flag.Bool("update", false, "write the golden files of TestE2E from the responses")
+
+// maskedValue replaces the values of the responses that differ on every run, see normalizeE2E
+const maskedValue = "<masked>"
+
+// e2eCase is a request of TestE2E, whose response is compared with the golden file of the same name
+type e2eCase struct {
+	Name   string
+	Method string
+	Path   string
+	// Body builds the JSON body of the request from the responses of the previous cases, by name. Nil sends
+	// no body.
+	Body func(responses map[string]any) any
+}
+
+// e2eResponse is the status and the normalized body of a response, as saved in the golden files
+type e2eResponse struct {
+	Status int `json:"status"`
+	Body   any `json:"body"`
+}
+
+// e2eCases are run in order against the same application, the actions changing what the next requests return.
+// Every duplicates request runs a scan, the actions being issued against the scan of the latest one like from
+// the analysis page.
+var e2eCases = []e2eCase{
+	{Name: "duplicates_invalid_query", Method: http.MethodGet, Path: "/api/duplicates?page=0"},
+	{Name: "duplicates_sorted", Method: http.MethodGet, Path: "/api/duplicates?sort=size&order=desc&page_size=2"},
+	{Name: "stats", Method: http.MethodGet, Path: "/api/widget"},
+	{Name: "stats_compact", Method: http.MethodGet, Path: "/api/widget?compact=true"},
+	{Name: "duplicates", Method: http.MethodGet, Path: "/api/duplicates?page_size=5"},
+	{Name: "action_delete_unsynchronized", Method: http.MethodPost, Path: "/api/duplicates/bulk-action",
+		Body: bulkActionBody(constants.DeleteLowerQualityAction, "duplicates", isDiscrepantDuplicate)},
+	{Name: "action_sync_play_status", Method: http.MethodPost, Path: "/api/duplicates/bulk-action",
+		Body: bulkActionBody(constants.SyncPlayStatusAction, "duplicates", isDiscrepantDuplicate)},
+	// The synchronized play status is seen by the next scan
+	{Name: "duplicates_after_sync", Method: http.MethodGet, Path: "/api/duplicates?page_size=5"},
+	{Name: "action_delete_lower_quality", Method: http.MethodPost, Path: "/api/duplicates/bulk-action",
+		Body: bulkActionBody(constants.DeleteLowerQualityAction, "duplicates_after_sync", func(group map[string]any) bool {
+			return group["is_duplicate"] == true && group["has_play_status_discrepancy"] == false
+		})},
+	{Name: "action_ignore", Method: http.MethodPost, Path: "/api/duplicates/bulk-action",
+		Body: bulkActionBody(constants.IgnoreAction, "duplicates_after_sync", func(group map[string]any) bool {
+			return group["is_duplicate"] == false && group["has_play_status_discrepancy"] == false
+		})},
+	{Name: "action_invalid", Method: http.MethodPost, Path: "/api/duplicates/bulk-action",
+		Body: func(map[string]any) any { return map[string]any{"action": "explode", "group_ids": []string{"none"}} }},
+	{Name: "audit", Method: http.MethodGet, Path: "/api/audit"},
+	{Name: "stats_after_actions", Method: http.MethodGet, Path: "/api/widget"},
+	{Name: "duplicates_after_actions", Method: http.MethodGet, Path: "/api/duplicates?page_size=5"},
+}
+
+// bulkActionBody applies the action to the first group matching the filter in the named duplicates response,
+// sending the version of its scan
+func bulkActionBody(action constants.BulkAction, from string, filter func(group map[string]any) bool) func(responses map[string]any) any {
+	return func(responses map[string]any) any {
+		page, _ := responses[from].(map[string]any)
+		items, _ := page["items"].([]any)
+		groupID := ""
+		for _, item := range items {
+			group, _ := item.(map[string]any)
+			if group != nil && filter(group) {
+				groupID, _ = group["id"].(string)
+				break
+			}
+		}
+		return map[string]any{"action": action, "group_ids": []string{groupID}, "scan_version": page["scan_version"]}
+	}
+}
+
+// isDiscrepantDuplicate matches the copies of the same movie whose play status differs, which are deleted once
+// synchronized
+func isDiscrepantDuplicate(group map[string]any) bool {
+	return group["is_duplicate"] == true && group["has_play_status_discrepancy"] == true
+}
+
+// TestE2E runs the routes of the web server against the demo Jellyfin server, and compares the responses of
+// the duplicates, stats and actions APIs with the golden files of testdata/e2e, to catch changes in their shape
+func TestE2E(t *testing.T) {
+	goldenDir, err := filepath.Abs(filepath.Join("testdata", "e2e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testing.Verbose() {
+		logrus.SetLevel(logrus.ErrorLevel)
+	}
+	gin.SetMode(gin.TestMode)
+
+	demoServer, err := demo.Start(demo.Generate())
+	if err != nil {
+		t.Fatalf("Failed to start the demo server: %v", err)
+	}
+	t.Cleanup(func() { demoServer.Close() })
+	router := newE2ERouter(t, demoServer)
+
+	if *update {
+		if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	started := time.Now()
+	responses := map[string]any{}
+	for _, c := range e2eCases {
+		t.Run(c.Name, func(t *testing.T) {
+			response := runE2ECase(t, router, c, responses, started)
+			responses[c.Name] = response.Body
+
+			actual, err := json.MarshalIndent(response, "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to encode the response: %v", err)
+			}
+			actual = append(actual, '\n')
+			path := filepath.Join(goldenDir, c.Name+".json")
+			if *update {
+				if err := os.WriteFile(path, actual, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run go test ./server -run TestE2E -update to write it", err)
+			}
+			if line, want, got, differs := firstDifference(expected, actual); differs {
+				t.Errorf("%s differs at line %d\n  expected: %s\n  actual:   %s", path, line, want, got)
+			}
+		})
+	}
+}
+
+// newE2ERouter sets up the application like the web server does, against the demo server, and returns its
+// router without listening. The configuration of the development environment is used, the golden files
+// depending on its detection settings, without login nor bearer tokens so that every route is open.
+func newE2ERouter(t *testing.T, demoServer *demo.Server) *gin.Engine {
+	// The configuration files are read from the repository root
+	t.Chdir("..")
+	t.Setenv(constants.EnvEnvironment, string(constants.Development))
+	confServices.Override(demoServer.Configure)
+	confServices.Override(func(config *confModels.Config) {
+		config.BasePath = ""
+		config.Auth = confModels.AuthConfig{}
+		config.CORS = confModels.CORSConfig{}
+		config.WidgetToken = ""
+		config.EarlyWarning.WebhookToken = ""
+		config.Debug.AdminToken, config.Debug.Pprof = "", false
+	})
+	config, err := confServices.LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	client := jellyfinClients.NewClient(config.Jellyfin.URL, config.Jellyfin.APIKey, config.Jellyfin.UserID, jellyfinClients.Identity{
+		Client:   config.Device.Client,
+		Device:   config.Device.Name,
+		DeviceID: config.Device.ID,
+		Version:  constants.Version,
+	})
+	if _, err := client.DetectServerVersion(); err != nil {
+		t.Fatalf("Failed to reach the demo server: %v", err)
+	}
+
+	backend, err := storage.OpenBackend(config.Storage.Backend, config.Storage.DSN, config.DataDir)
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	store, err := storage.NewStore(backend, time.Duration(config.Storage.RefreshInterval)*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	var shared *cluster.Cluster
+	notifier := notifications.NewNotifier(config.Notifications)
+	queue := jobs.NewQueue(store, notifier, shared)
+	handler := server.NewHandler(client, config, store, queue, notifier, shared)
+
+	router := gin.New()
+	if err := server.RegisterRoutes(router, handler, config, logs.NewBuffer()); err != nil {
+		t.Fatalf("Failed to configure routes: %v", err)
+	}
+	return router
+}
+
+// runE2ECase sends the request of the case to the router and returns its normalized response
+func runE2ECase(t *testing.T, router *gin.Engine, c e2eCase, responses map[string]any, started time.Time) e2eResponse {
+	var body []byte
+	if c.Body != nil {
+		data, err := json.Marshal(c.Body(responses))
+		if err != nil {
+			t.Fatalf("Failed to encode the request: %v", err)
+		}
+		body = data
+	}
+
+	request := httptest.NewRequest(c.Method, c.Path, bytes.NewReader(body))
+	if c.Body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	var decoded any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("Status %d, the response is not JSON: %v", recorder.Code, err)
+	}
+	return e2eResponse{Status: recorder.Code, Body: normalizeE2E("", decoded, started)}
+}
+
+// normalizeE2E masks the values that differ on every run: the times from the start of the run, those of the
+// scans and actions, while the dates of the demo library are kept, and the IDs generated by the application.
+// The keys are kept, so that the golden files still catch added, removed and renamed fields.
+func normalizeE2E(key string, value any, started time.Time) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, field := range v {
+			v[k] = normalizeE2E(k, field, started)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeE2E(key, item, started)
+		}
+		return v
+	case string:
+		if at, err := time.Parse(time.RFC3339Nano, v); err == nil && !at.Before(started.Truncate(time.Second)) {
+			return maskedValue
+		}
+	}
+	if key == "action_id" || key == "job_id" {
+		return maskedValue
+	}
+	return value
+}
+
+// firstDifference returns the first line, numbered from 1, on which the expected and actual files differ
+func firstDifference(expected []byte, actual []byte) (int, string, string, bool) {
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+	for i := 0; i < max(len(expectedLines), len(actualLines)); i++ {
+		want, got := "<end of file>", "<end of file>"
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if want != got {
+			return i + 1, strings.TrimSpace(want), strings.TrimSpace(got), true
+		}
+	}
+	return 0, "", "", false
+}
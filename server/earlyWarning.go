@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/notifications"
+	"jellyfin-duplicate/pkg/dedupe"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// RecentDuplicate is a recently added movie duplicating a movie of the library
+type RecentDuplicate struct {
+	ID         string               `json:"id"`
+	Added      jellyfinModels.Movie `json:"added"`
+	Existing   jellyfinModels.Movie `json:"existing"`
+	Similarity int                  `json:"similarity"`
+	// IsDuplicate is set when the paths are similar or only differ by extension, other pairs may be different movies sharing a name and year
+	IsDuplicate bool `json:"is_duplicate"`
+	// Severity classifies the pair with scan.severity_rules, routing its notification
+	Severity constants.Severity `json:"severity"`
+}
+
+// FindRecentDuplicates compares the movies added since the given time with the whole library.
+// Play status is not fetched, so the check stays cheap enough to run often.
+func (s *ServerService) FindRecentDuplicates(since time.Time) ([]RecentDuplicate, error) {
+	return s.findAddedDuplicates(func(movie jellyfinModels.Movie) bool {
+		addedAt, ok := movie.AddedAt()
+		return ok && addedAt.After(since)
+	})
+}
+
+// FindItemDuplicates compares a single movie, typically just imported, with the whole library
+func (s *ServerService) FindItemDuplicates(itemID string) ([]RecentDuplicate, error) {
+	return s.findAddedDuplicates(func(movie jellyfinModels.Movie) bool {
+		return strings.EqualFold(movie.ID, itemID)
+	})
+}
+
+// findAddedDuplicates compares the movies matching isAdded with the whole library
+func (s *ServerService) findAddedDuplicates(isAdded func(movie jellyfinModels.Movie) bool) ([]RecentDuplicate, error) {
+	movies, err := s.jellyfinClient.GetAllMovies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all movies: %v", err)
+	}
+
+	recent := make([]bool, len(movies))
+	items := make([]dedupe.Item, len(movies))
+	recentCount := 0
+	for i, movie := range movies {
+		if isAdded(movie) {
+			recent[i] = true
+			recentCount++
+		}
+		items[i] = dedupeItem(movie)
+	}
+	if recentCount == 0 {
+		logrus.Debug("No added movies to check")
+		return nil, nil
+	}
+
+	thresholds := s.tunedThresholds()
+	scanConfig := s.liveConfig().Scan
+	result := dedupe.Find(items, dedupe.Options{
+		MinGroupSize:     scanConfig.MinGroupSize,
+		MaxPairsPerGroup: scanConfig.MaxPairsPerGroup,
+		YearTolerance:    scanConfig.YearTolerance,
+		GroupKey:         groupKey(scanConfig.Grouping),
+		Skip: func(index1, index2 int) bool {
+			return (!recent[index1] && !recent[index2]) || s.isPairDismissed(movies[index1], movies[index2])
+		},
+		PairThreshold: func(index1, index2 int) int {
+			return pairThreshold(thresholds, movies[index1], movies[index2])
+		},
+	})
+
+	var duplicates []RecentDuplicate
+	for _, pair := range result.Pairs {
+		added, existing := movies[pair.Index1], movies[pair.Index2]
+		if !recent[pair.Index1] {
+			added, existing = existing, added
+		}
+		duplicates = append(duplicates, RecentDuplicate{
+			ID:          pair.ID,
+			Added:       added,
+			Existing:    existing,
+			Similarity:  pair.Similarity,
+			IsDuplicate: pair.IsDuplicate,
+			Severity: classify(s.config.Scan.SeverityRules, severityFacts{
+				movie1:      added,
+				movie2:      existing,
+				isDuplicate: pair.IsDuplicate,
+				similarity:  pair.Similarity,
+				sameStem:    pair.SameStem,
+				sameContent: pair.SameContent,
+			}),
+		})
+	}
+
+	logrus.Infof("Checked %d recently added movies, found %d duplicates", recentCount, len(duplicates))
+	return duplicates, nil
+}
+
+// StartEarlyWarning checks the recently added movies on the configured schedule and notifies the duplicates
+// found, each one once, until the returned function is called. Nothing is started without schedule.
+func (s *ServerService) StartEarlyWarning() (stop func()) {
+	if s.schedule == nil {
+		return func() {}
+	}
+	status := s.schedule.Status()
+	logrus.Infof("Checking movies added in the last %d days for duplicates every %d minutes (%s)",
+		s.liveConfig().EarlyWarning.Days, status.Interval, status.Timezone)
+
+	return s.schedule.Start(s.checkRecentDuplicates)
+}
+
+// ScheduleStatus reports the state and next run of the scheduled scan
+func (s *ServerService) ScheduleStatus() ScheduleStatus {
+	if s.schedule == nil {
+		return ScheduleStatus{}
+	}
+	return s.schedule.Status()
+}
+
+// SetSchedulePaused pauses or resumes the scheduled scan
+func (s *ServerService) SetSchedulePaused(paused bool) error {
+	if s.schedule == nil {
+		return ErrNoSchedule
+	}
+	if paused {
+		s.schedule.Pause()
+		logrus.Info("Scheduled scan paused")
+	} else {
+		s.schedule.Resume()
+		logrus.Info("Scheduled scan resumed")
+	}
+	return nil
+}
+
+// checkRecentDuplicates notifies the recently added duplicates not notified yet
+func (s *ServerService) checkRecentDuplicates() {
+	since := time.Now().AddDate(0, 0, -s.liveConfig().EarlyWarning.Days)
+	duplicates, err := s.FindRecentDuplicates(since)
+	if err != nil {
+		logrus.Errorf("Failed to check recently added movies for duplicates: %v", err)
+		return
+	}
+	s.NotifyRecentDuplicates(duplicates)
+}
+
+// NotifyRecentDuplicates notifies the duplicates not notified yet, by the early warning or the Jellyfin webhook
+func (s *ServerService) NotifyRecentDuplicates(duplicates []RecentDuplicate) {
+	s.notifiedMutex.Lock()
+	defer s.notifiedMutex.Unlock()
+
+	for _, duplicate := range duplicates {
+		if s.notifiedDuplicates[duplicate.ID] {
+			continue
+		}
+		s.notifiedDuplicates[duplicate.ID] = true
+
+		s.notifier.Notify(notifications.Event{
+			Type:  constants.RecentDuplicateEvent,
+			Title: fmt.Sprintf("Duplicate added: %s (%d)", duplicate.Added.Name, duplicate.Added.ProductionYear),
+			Message: fmt.Sprintf("%s %s %s already in library %s (%d%% path similarity, %s)",
+				duplicate.Added.Path, lo.Ternary(duplicate.IsDuplicate, "duplicates", "may duplicate"),
+				duplicate.Existing.Path, duplicate.Existing.LibraryName, duplicate.Similarity, duplicate.Severity),
+			Data:     duplicate,
+			Severity: duplicate.Severity,
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/fsreport"
+	storageModels "jellyfin-duplicate/storage/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxExternalReportSize bounds the uploaded reports, which list every duplicate file of the scanned folders
+const maxExternalReportSize = 64 << 20
+
+// ErrExternalReportNotFound is returned when deleting a report which was not imported
+var ErrExternalReportNotFound = errors.New("no report of this format was imported")
+
+// ImportExternalReport reads the report of a filesystem duplicate finder and keeps its groups of identical files
+// found in Jellyfin, through deletion.path_mappings. The next scans pair them as exact duplicates, whatever their
+// names. It replaces the previous report of the format.
+func (s *ServerService) ImportExternalReport(ctx context.Context, format constants.ReportFormat, reader io.Reader) (storageModels.ExternalReport, error) {
+	var groups [][]string
+	var err error
+	switch format {
+	case constants.CzkawkaReport:
+		groups, err = fsreport.ParseCzkawka(reader)
+	case constants.RdfindReport:
+		groups, err = fsreport.ParseRdfind(reader)
+	default:
+		err = fmt.Errorf("invalid report format %s", format)
+	}
+	if err != nil {
+		return storageModels.ExternalReport{}, err
+	}
+
+	movies, err := s.jellyfinClient.WithContext(ctx).GetAllMovies()
+	if err != nil {
+		return storageModels.ExternalReport{}, fmt.Errorf("failed to list the movies of Jellyfin: %v", err)
+	}
+	moviePaths := make(map[string]string, len(movies))
+	for _, movie := range movies {
+		moviePaths[normalizeLookupPath(movie.Path)] = movie.Path
+	}
+
+	report := storageModels.ExternalReport{Format: format, Groups: [][]string{}, ImportedAt: time.Now()}
+	for _, group := range groups {
+		report.Files += len(group)
+		var matched []string
+		seen := map[string]bool{}
+		for _, path := range group {
+			moviePath, found := moviePaths[normalizeLookupPath(s.pathMapper.ToJellyfin(path))]
+			if !found {
+				moviePath, found = moviePaths[normalizeLookupPath(path)]
+			}
+			if found && !seen[moviePath] {
+				seen[moviePath] = true
+				matched = append(matched, moviePath)
+			}
+		}
+		report.Matched += len(matched)
+		if len(matched) > 1 {
+			report.Groups = append(report.Groups, matched)
+		}
+	}
+
+	if err := s.store.SaveExternalReport(report); err != nil {
+		return storageModels.ExternalReport{}, fmt.Errorf("failed to save report: %v", err)
+	}
+	logrus.Infof("Imported %s report: %d files, %d found in Jellyfin, %d groups of identical movies", format, report.Files, report.Matched, len(report.Groups))
+	return report, nil
+}
+
+// ExternalReports returns the imported reports of filesystem duplicate finders
+func (s *ServerService) ExternalReports() []storageModels.ExternalReport {
+	return s.store.ExternalReports()
+}
+
+// DeleteExternalReport removes the report of a format, its pairs being left out of the next scans
+func (s *ServerService) DeleteExternalReport(format constants.ReportFormat) error {
+	found, err := s.store.DeleteExternalReport(format)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrExternalReportNotFound
+	}
+	return nil
+}
+
+// externalFingerprints returns the content fingerprints of the movie files listed by the imported reports, keyed
+// by normalized Jellyfin path: the files of a group share one, so that the detection engine pairs them. A file
+// listed by several reports keeps the fingerprint of the first one.
+func (s *ServerService) externalFingerprints() map[string]string {
+	fingerprints := make(map[string]string)
+	for _, report := range s.store.ExternalReports() {
+		for index, group := range report.Groups {
+			fingerprint := fmt.Sprintf("external:%s:%d", report.Format, index)
+			for _, path := range group {
+				if _, found := fingerprints[normalizeLookupPath(path)]; !found {
+					fingerprints[normalizeLookupPath(path)] = fingerprint
+				}
+			}
+		}
+	}
+	return fingerprints
+}
+
+// POST /api/external-reports/:format
+// ImportExternalReport imports the report of czkawka or rdfind sent as the body, or as the report file of a form
+func (h *Handler) ImportExternalReport(ctx *gin.Context) {
+	format := constants.ReportFormat(ctx.Param("format"))
+	if !constants.IsValidReportFormat(format) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("invalid report format %s, must be '%s' or '%s'", format, constants.CzkawkaReport, constants.RdfindReport),
+		})
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxExternalReportSize)
+	body := io.Reader(ctx.Request.Body)
+	if strings.HasPrefix(ctx.ContentType(), "multipart/form-data") {
+		header, err := ctx.FormFile("report")
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "the form must contain the report file",
+			})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.serverService.ImportExternalReport(ctx.Request.Context(), format, body)
+	if err != nil {
+		logrus.Errorf("Error importing %s report: %v", format, err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("%d groups of identical movies imported, paired by the next scan", len(report.Groups)),
+		"report":  report,
+	})
+}
+
+// GET /api/external-reports
+// GetExternalReports lists the imported reports of filesystem duplicate finders
+func (h *Handler) GetExternalReports(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"reports": h.serverService.ExternalReports(),
+	})
+}
+
+// DELETE /api/external-reports/:format
+// DeleteExternalReport removes an imported report
+func (h *Handler) DeleteExternalReport(ctx *gin.Context) {
+	format := constants.ReportFormat(ctx.Param("format"))
+	if err := h.serverService.DeleteExternalReport(format); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrExternalReportNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("%s report removed", format),
+	})
+}
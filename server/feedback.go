@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/pkg/dedupe"
+	storageModels "jellyfin-duplicate/storage/models"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// ThresholdSuggestion is the duplicate threshold of a library suggested by the pairs marked as not duplicates
+type ThresholdSuggestion struct {
+	Library string `json:"library"`
+	// FalsePositives is the number of pairs of the library marked as not duplicates
+	FalsePositives int `json:"false_positives"`
+	// HighestSimilarity is the highest path similarity among these pairs
+	HighestSimilarity int `json:"highest_similarity"`
+	CurrentThreshold  int `json:"current_threshold"`
+	// SuggestedThreshold is the lowest threshold classifying these pairs as mismatches,
+	// 0 until the library has scan.min_feedback_labels of them
+	SuggestedThreshold int `json:"suggested_threshold"`
+	// Applied is set when scans use the suggested threshold, with scan.auto_tune_threshold
+	Applied bool `json:"applied"`
+}
+
+// markNotDuplicate records a potential duplicate as two different movies, shown as a mismatch from the next scan
+func (s *ServerService) markNotDuplicate(dup jellyfinModels.DuplicateResult) (string, error) {
+	if !dup.IsDuplicate {
+		return "", fmt.Errorf("pair is already a potential mismatch")
+	}
+
+	err := s.store.SaveFalsePositive(storageModels.FalsePositive{
+		Fingerprint: PairFingerprint(dup.Movie1, dup.Movie2),
+		Movie1ID:    dup.Movie1.ID,
+		Movie2ID:    dup.Movie2.ID,
+		MovieName:   dup.Movie1.Name,
+		Libraries:   pairLibraries(dup.Movie1, dup.Movie2),
+		Similarity:  dup.Similarity,
+		MarkedAt:    time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to mark pair as not a duplicate: %v", err)
+	}
+
+	return "pair marked as not a duplicate", nil
+}
+
+// pairLibraries returns the distinct known libraries of two movies
+func pairLibraries(movie1, movie2 jellyfinModels.Movie) []string {
+	return lo.Uniq(lo.Compact([]string{movie1.LibraryName, movie2.LibraryName}))
+}
+
+// FalsePositives returns the pairs marked as not duplicates, most recent first
+func (s *ServerService) FalsePositives() []storageModels.FalsePositive {
+	return s.store.FalsePositives()
+}
+
+// SuggestThresholds suggests a duplicate threshold for each library with pairs marked as not duplicates,
+// libraries sorted by name. A pair spanning two libraries counts for both.
+func (s *ServerService) SuggestThresholds() []ThresholdSuggestion {
+	byLibrary := make(map[string][]storageModels.FalsePositive)
+	for _, pair := range s.store.FalsePositives() {
+		for _, library := range pair.Libraries {
+			byLibrary[library] = append(byLibrary[library], pair)
+		}
+	}
+
+	scanConfig := s.liveConfig().Scan
+	suggestions := make([]ThresholdSuggestion, 0, len(byLibrary))
+	for library, pairs := range byLibrary {
+		suggestion := ThresholdSuggestion{
+			Library:        library,
+			FalsePositives: len(pairs),
+			HighestSimilarity: lo.MaxBy(pairs, func(a, b storageModels.FalsePositive) bool {
+				return a.Similarity > b.Similarity
+			}).Similarity,
+			CurrentThreshold: dedupe.DefaultDuplicateThreshold,
+		}
+		if len(pairs) >= scanConfig.MinFeedbackLabels {
+			// The threshold is only ever raised, and cannot go above identical paths
+			suggestion.SuggestedThreshold = min(max(suggestion.HighestSimilarity+1, suggestion.CurrentThreshold), 100)
+			suggestion.Applied = scanConfig.AutoTuneThreshold && suggestion.SuggestedThreshold > suggestion.CurrentThreshold
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Library < suggestions[j].Library
+	})
+	return suggestions
+}
+
+// tunedThresholds returns the applied duplicate thresholds by library, nil unless scan.auto_tune_threshold is set
+func (s *ServerService) tunedThresholds() map[string]int {
+	if !s.liveConfig().Scan.AutoTuneThreshold {
+		return nil
+	}
+
+	thresholds := make(map[string]int)
+	for _, suggestion := range s.SuggestThresholds() {
+		if suggestion.Applied {
+			thresholds[suggestion.Library] = suggestion.SuggestedThreshold
+		}
+	}
+	return thresholds
+}
+
+// pairThreshold returns the highest tuned threshold of the libraries of two movies, 0 when none is tuned
+func pairThreshold(thresholds map[string]int, movie1, movie2 jellyfinModels.Movie) int {
+	return max(thresholds[movie1.LibraryName], thresholds[movie2.LibraryName])
+}
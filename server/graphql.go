@@ -0,0 +1,551 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/samber/lo"
+)
+
+// graphqlSchema describes the read only data served by /api/graphql. Sizes and bitrates are floats, GraphQL
+// integers being limited to 32 bits.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# Pairs of the scan, filtered, sorted and paginated like /api/duplicates
+	duplicateGroups(search: String, severity: [String!], minUsersAffected: Int, sort: String, order: String,
+		page: Int, pageSize: Int): DuplicateGroupPage!
+	duplicateGroup(id: ID!): DuplicateGroup
+	# Copies of the pairs of the scan, by name
+	movies(search: String): [Movie!]!
+	users: [User!]!
+	# Last scans, most recent first
+	scans: [Scan!]!
+}
+
+type DuplicateGroupPage {
+	items: [DuplicateGroup!]!
+	total: Int!
+	page: Int!
+	pageSize: Int!
+	totalPages: Int!
+	scanVersion: Int!
+}
+
+type DuplicateGroup {
+	id: ID!
+	movie1: Movie!
+	movie2: Movie!
+	isDuplicate: Boolean!
+	similarity: Int!
+	severity: String!
+	# Copy to delete, null when both copies are equivalent
+	recommendedDelete: Movie
+	reclaimableSize: Float!
+	usersAffected: Int!
+	hasPlayStatusDiscrepancy: Boolean!
+	hasPositionConflict: Boolean!
+	playStatusDiscrepancies: [PlayStatusDiscrepancy!]!
+	positionConflicts: [PositionConflict!]!
+}
+
+type Movie {
+	id: ID!
+	name: String!
+	year: Int!
+	path: String!
+	library: String!
+	size: Float!
+	bitrate: Float!
+	# Duration in seconds
+	duration: Int!
+	resolution: String!
+	videoCodec: String!
+	hdr: Boolean!
+	audioLanguages: [String!]!
+	subtitleLanguages: [String!]!
+	addedAt: String
+	playStatuses: [PlayStatus!]!
+	duplicateGroups: [DuplicateGroup!]!
+}
+
+type PlayStatus {
+	user: User!
+	played: Boolean!
+	playCount: Int!
+	lastPlayedDate: String
+	playbackPositionTicks: Float!
+}
+
+type PlayStatusDiscrepancy {
+	user: User!
+	movieToUpdate: Movie!
+	playCount: Int!
+	playCountDelta: Int!
+	lastPlayedDate: String
+}
+
+type PositionConflict {
+	user: User!
+	movie1PositionTicks: Float!
+	movie2PositionTicks: Float!
+}
+
+type User {
+	id: ID!
+	name: String!
+	isAdministrator: Boolean!
+	isDisabled: Boolean!
+	included: Boolean!
+	seenMovies: Int!
+	lastActivityDate: String
+	# Pairs with a play status discrepancy or a position conflict for the user
+	duplicateGroups: [DuplicateGroup!]!
+}
+
+type Scan {
+	# Version of the result of a completed scan, null otherwise
+	version: Int
+	status: String!
+	startedAt: String!
+	finishedAt: String
+	duplicates: Int!
+	error: String
+}
+`
+
+// maxGraphQLDepth bounds the nesting of queries, groups leading to movies leading to groups again
+const maxGraphQLDepth = 10
+
+// newGraphQLSchema parses the schema served by /api/graphql, resolved from the service
+func newGraphQLSchema(service *ServerService) *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchema, &graphqlResolver{service: service}, graphql.MaxDepth(maxGraphQLDepth))
+}
+
+// graphqlScanKey is the context key of the scan shared by the fields of a GraphQL request
+type graphqlScanKey struct{}
+
+// graphqlScan runs the scan of a GraphQL request once, whatever the number of fields reading it
+type graphqlScan struct {
+	once   sync.Once
+	result ScanResult
+	err    error
+}
+
+// graphqlResolver resolves the queries of the GraphQL schema
+type graphqlResolver struct {
+	service *ServerService
+}
+
+// scan returns the scan of the request, run on first use like for /api/duplicates
+func (r *graphqlResolver) scan(ctx context.Context) (ScanResult, error) {
+	shared, found := ctx.Value(graphqlScanKey{}).(*graphqlScan)
+	if !found {
+		return r.service.Scan()
+	}
+	shared.once.Do(func() {
+		shared.result, shared.err = r.service.Scan()
+	})
+	return shared.result, shared.err
+}
+
+// user returns the user of a play status, with the name given by Jellyfin when it is not listed
+func (r *graphqlResolver) user(userID, userName string) (*userResolver, error) {
+	users, err := r.service.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.ID == userID {
+			return &userResolver{root: r, user: user}, nil
+		}
+	}
+	return &userResolver{root: r, user: UserSummary{ID: userID, Name: userName}}, nil
+}
+
+// groupsWhere returns the pairs of the scan matching a condition
+func (r *graphqlResolver) groupsWhere(ctx context.Context, matches func(dup jellyfinModels.DuplicateResult) bool) ([]*groupResolver, error) {
+	scan, err := r.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups := []*groupResolver{}
+	for _, dup := range scan.Duplicates {
+		if matches(dup) {
+			groups = append(groups, &groupResolver{root: r, dup: dup})
+		}
+	}
+	return groups, nil
+}
+
+type duplicateGroupsArgs struct {
+	Search           *string
+	Severity         *[]string
+	MinUsersAffected *int32
+	Sort             *string
+	Order            *string
+	Page             *int32
+	PageSize         *int32
+}
+
+func (r *graphqlResolver) DuplicateGroups(ctx context.Context, args duplicateGroupsArgs) (*groupPageResolver, error) {
+	query := DuplicateQuery{
+		Search:   strings.TrimSpace(lo.FromPtr(args.Search)),
+		Sort:     lo.FromPtrOr(args.Sort, "name"),
+		Order:    lo.FromPtrOr(args.Order, "asc"),
+		Page:     int(lo.FromPtrOr(args.Page, 1)),
+		PageSize: int(lo.FromPtrOr(args.PageSize, defaultPageSize)),
+	}
+	for _, severity := range lo.FromPtr(args.Severity) {
+		if !constants.IsValidSeverity(constants.Severity(severity)) {
+			return nil, fmt.Errorf("severity must be one of %s", strings.Join(lo.Map(constants.Severities,
+				func(severity constants.Severity, _ int) string { return string(severity) }), ", "))
+		}
+		query.Severities = append(query.Severities, constants.Severity(severity))
+	}
+	query.MinUsersAffected = int(lo.FromPtr(args.MinUsersAffected))
+
+	switch {
+	case !lo.Contains(sortKeys, query.Sort):
+		return nil, fmt.Errorf("sort must be one of %s", strings.Join(sortKeys, ", "))
+	case query.Order != "asc" && query.Order != "desc":
+		return nil, fmt.Errorf("order must be asc or desc")
+	case query.Page < 1:
+		return nil, fmt.Errorf("page must be a positive integer")
+	case query.PageSize < 1 || query.PageSize > maxPageSize:
+		return nil, fmt.Errorf("pageSize must be between 1 and %d", maxPageSize)
+	case query.MinUsersAffected < 0:
+		return nil, fmt.Errorf("minUsersAffected must be a positive number of users")
+	}
+
+	scan, err := r.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page := query.Apply(scan.Duplicates)
+	page.ScanVersion = scan.Version
+	return &groupPageResolver{root: r, page: page}, nil
+}
+
+func (r *graphqlResolver) DuplicateGroup(ctx context.Context, args struct{ ID graphql.ID }) (*groupResolver, error) {
+	groups, err := r.groupsWhere(ctx, func(dup jellyfinModels.DuplicateResult) bool {
+		return dup.ID == string(args.ID)
+	})
+	if err != nil || len(groups) == 0 {
+		return nil, err
+	}
+	return groups[0], nil
+}
+
+func (r *graphqlResolver) Movies(ctx context.Context, args struct{ Search *string }) ([]*movieResolver, error) {
+	scan, err := r.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	search := strings.ToLower(strings.TrimSpace(lo.FromPtr(args.Search)))
+
+	seen := map[string]bool{}
+	movies := []*movieResolver{}
+	for _, dup := range scan.Duplicates {
+		for _, movie := range []jellyfinModels.Movie{dup.Movie1, dup.Movie2} {
+			if seen[movie.ID] || !strings.Contains(strings.ToLower(movie.Name), search) {
+				continue
+			}
+			seen[movie.ID] = true
+			movies = append(movies, &movieResolver{root: r, movie: movie})
+		}
+	}
+	sort.SliceStable(movies, func(i, j int) bool {
+		return strings.ToLower(movies[i].movie.Name) < strings.ToLower(movies[j].movie.Name)
+	})
+	return movies, nil
+}
+
+func (r *graphqlResolver) Users() ([]*userResolver, error) {
+	users, err := r.service.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(users, func(user UserSummary, _ int) *userResolver {
+		return &userResolver{root: r, user: user}
+	}), nil
+}
+
+func (r *graphqlResolver) Scans() []*scanResolver {
+	return lo.Map(r.service.ScanHistory(), func(record ScanRecord, _ int) *scanResolver {
+		return &scanResolver{record: record}
+	})
+}
+
+type groupPageResolver struct {
+	root *graphqlResolver
+	page DuplicatePage
+}
+
+func (p *groupPageResolver) Items() []*groupResolver {
+	return lo.Map(p.page.Items, func(dup jellyfinModels.DuplicateResult, _ int) *groupResolver {
+		return &groupResolver{root: p.root, dup: dup}
+	})
+}
+
+func (p *groupPageResolver) Total() int32       { return int32(p.page.Total) }
+func (p *groupPageResolver) Page() int32        { return int32(p.page.Page) }
+func (p *groupPageResolver) PageSize() int32    { return int32(p.page.PageSize) }
+func (p *groupPageResolver) TotalPages() int32  { return int32(p.page.TotalPages) }
+func (p *groupPageResolver) ScanVersion() int32 { return int32(p.page.ScanVersion) }
+
+type groupResolver struct {
+	root *graphqlResolver
+	dup  jellyfinModels.DuplicateResult
+}
+
+func (g *groupResolver) ID() graphql.ID { return graphql.ID(g.dup.ID) }
+func (g *groupResolver) Movie1() *movieResolver {
+	return &movieResolver{root: g.root, movie: g.dup.Movie1}
+}
+func (g *groupResolver) Movie2() *movieResolver {
+	return &movieResolver{root: g.root, movie: g.dup.Movie2}
+}
+func (g *groupResolver) IsDuplicate() bool              { return g.dup.IsDuplicate }
+func (g *groupResolver) Similarity() int32              { return int32(g.dup.Similarity) }
+func (g *groupResolver) Severity() string               { return string(g.dup.Severity) }
+func (g *groupResolver) ReclaimableSize() float64       { return float64(g.dup.ReclaimableSize) }
+func (g *groupResolver) UsersAffected() int32           { return int32(g.dup.UsersAffected) }
+func (g *groupResolver) HasPlayStatusDiscrepancy() bool { return g.dup.HasPlayStatusDiscrepancy }
+func (g *groupResolver) HasPositionConflict() bool      { return g.dup.HasPositionConflict }
+
+func (g *groupResolver) RecommendedDelete() *movieResolver {
+	switch g.dup.RecommendedDeleteID {
+	case g.dup.Movie1.ID:
+		return g.Movie1()
+	case g.dup.Movie2.ID:
+		return g.Movie2()
+	}
+	return nil
+}
+
+// copy returns the copy of the pair with an ID
+func (g *groupResolver) copy(movieID string) *movieResolver {
+	if movieID == g.dup.Movie2.ID {
+		return g.Movie2()
+	}
+	return g.Movie1()
+}
+
+func (g *groupResolver) PlayStatusDiscrepancies() ([]*discrepancyResolver, error) {
+	discrepancies := make([]*discrepancyResolver, 0, len(g.dup.PlayStatusDiscrepancies))
+	for _, discrepancy := range g.dup.PlayStatusDiscrepancies {
+		user, err := g.root.user(discrepancy.UserID, discrepancy.UserName)
+		if err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, &discrepancyResolver{
+			discrepancy:   discrepancy,
+			user:          user,
+			movieToUpdate: g.copy(discrepancy.MovieToUpdate),
+		})
+	}
+	return discrepancies, nil
+}
+
+func (g *groupResolver) PositionConflicts() ([]*positionConflictResolver, error) {
+	conflicts := make([]*positionConflictResolver, 0, len(g.dup.PositionConflicts))
+	for _, conflict := range g.dup.PositionConflicts {
+		user, err := g.root.user(conflict.UserID, conflict.UserName)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, &positionConflictResolver{conflict: conflict, user: user})
+	}
+	return conflicts, nil
+}
+
+type movieResolver struct {
+	root  *graphqlResolver
+	movie jellyfinModels.Movie
+}
+
+func (m *movieResolver) ID() graphql.ID     { return graphql.ID(m.movie.ID) }
+func (m *movieResolver) Name() string       { return m.movie.Name }
+func (m *movieResolver) Year() int32        { return int32(m.movie.ProductionYear) }
+func (m *movieResolver) Path() string       { return m.movie.Path }
+func (m *movieResolver) Library() string    { return m.movie.LibraryName }
+func (m *movieResolver) Size() float64      { return float64(m.movie.Size()) }
+func (m *movieResolver) Bitrate() float64   { return float64(m.movie.Bitrate()) }
+func (m *movieResolver) Duration() int32    { return int32(m.movie.Duration() / time.Second) }
+func (m *movieResolver) Resolution() string { return string(m.movie.Resolution()) }
+func (m *movieResolver) VideoCodec() string { return m.movie.VideoCodec() }
+func (m *movieResolver) Hdr() bool          { return m.movie.IsHDR() }
+func (m *movieResolver) AudioLanguages() []string {
+	return append([]string{}, m.movie.AudioLanguages()...)
+}
+func (m *movieResolver) SubtitleLanguages() []string {
+	return append([]string{}, m.movie.SubtitleLanguages()...)
+}
+func (m *movieResolver) AddedAt() *string { return optionalString(m.movie.DateCreated) }
+
+func (m *movieResolver) PlayStatuses() ([]*playStatusResolver, error) {
+	statuses := make([]*playStatusResolver, 0, len(m.movie.UserPlayStatuses))
+	for _, status := range m.movie.UserPlayStatuses {
+		user, err := m.root.user(status.UserID, status.UserName)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, &playStatusResolver{status: status, user: user})
+	}
+	return statuses, nil
+}
+
+func (m *movieResolver) DuplicateGroups(ctx context.Context) ([]*groupResolver, error) {
+	return m.root.groupsWhere(ctx, func(dup jellyfinModels.DuplicateResult) bool {
+		return dup.Movie1.ID == m.movie.ID || dup.Movie2.ID == m.movie.ID
+	})
+}
+
+type playStatusResolver struct {
+	status jellyfinModels.UserPlayStatus
+	user   *userResolver
+}
+
+func (p *playStatusResolver) User() *userResolver     { return p.user }
+func (p *playStatusResolver) Played() bool            { return p.status.Played }
+func (p *playStatusResolver) PlayCount() int32        { return int32(p.status.PlayCount) }
+func (p *playStatusResolver) LastPlayedDate() *string { return optionalString(p.status.LastPlayedDate) }
+func (p *playStatusResolver) PlaybackPositionTicks() float64 {
+	return float64(p.status.PlaybackPositionTicks)
+}
+
+type discrepancyResolver struct {
+	discrepancy   jellyfinModels.PlayStatusDiscrepancy
+	user          *userResolver
+	movieToUpdate *movieResolver
+}
+
+func (d *discrepancyResolver) User() *userResolver           { return d.user }
+func (d *discrepancyResolver) MovieToUpdate() *movieResolver { return d.movieToUpdate }
+func (d *discrepancyResolver) PlayCount() int32              { return int32(d.discrepancy.PlayCount) }
+func (d *discrepancyResolver) PlayCountDelta() int32         { return int32(d.discrepancy.PlayCountDelta) }
+func (d *discrepancyResolver) LastPlayedDate() *string {
+	return optionalString(d.discrepancy.LastPlayedDate)
+}
+
+type positionConflictResolver struct {
+	conflict jellyfinModels.PositionConflict
+	user     *userResolver
+}
+
+func (p *positionConflictResolver) User() *userResolver { return p.user }
+func (p *positionConflictResolver) Movie1PositionTicks() float64 {
+	return float64(p.conflict.Movie1PositionTicks)
+}
+func (p *positionConflictResolver) Movie2PositionTicks() float64 {
+	return float64(p.conflict.Movie2PositionTicks)
+}
+
+type userResolver struct {
+	root *graphqlResolver
+	user UserSummary
+}
+
+func (u *userResolver) ID() graphql.ID            { return graphql.ID(u.user.ID) }
+func (u *userResolver) Name() string              { return u.user.Name }
+func (u *userResolver) IsAdministrator() bool     { return u.user.IsAdministrator }
+func (u *userResolver) IsDisabled() bool          { return u.user.IsDisabled }
+func (u *userResolver) Included() bool            { return u.user.Included }
+func (u *userResolver) SeenMovies() int32         { return int32(u.user.SeenMovies) }
+func (u *userResolver) LastActivityDate() *string { return optionalString(u.user.LastActivityDate) }
+
+func (u *userResolver) DuplicateGroups(ctx context.Context) ([]*groupResolver, error) {
+	return u.root.groupsWhere(ctx, func(dup jellyfinModels.DuplicateResult) bool {
+		return lo.ContainsBy(dup.PlayStatusDiscrepancies, func(discrepancy jellyfinModels.PlayStatusDiscrepancy) bool {
+			return discrepancy.UserID == u.user.ID
+		}) || lo.ContainsBy(dup.PositionConflicts, func(conflict jellyfinModels.PositionConflict) bool {
+			return conflict.UserID == u.user.ID
+		})
+	})
+}
+
+type scanResolver struct {
+	record ScanRecord
+}
+
+func (s *scanResolver) Status() string    { return string(s.record.Status) }
+func (s *scanResolver) StartedAt() string { return s.record.StartedAt.Format(time.RFC3339) }
+func (s *scanResolver) Duplicates() int32 { return int32(s.record.Duplicates) }
+func (s *scanResolver) Error() *string    { return optionalString(s.record.Error) }
+
+func (s *scanResolver) Version() *int32 {
+	if s.record.Version == 0 {
+		return nil
+	}
+	version := int32(s.record.Version)
+	return &version
+}
+
+func (s *scanResolver) FinishedAt() *string {
+	if s.record.FinishedAt == nil {
+		return nil
+	}
+	return optionalString(s.record.FinishedAt.Format(time.RFC3339))
+}
+
+// optionalString returns nil for an empty string, null in GraphQL
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// graphqlRequest is a GraphQL query, sent as JSON or in the query string of a GET request
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// GET|POST /api/graphql
+// GraphQL runs a query against the read only GraphQL schema of the duplicates, movies, users and scans, so that
+// dashboards fetch the fields they need in one request. Every field reads the same scan.
+func (h *Handler) GraphQL(ctx *gin.Context) {
+	var request graphqlRequest
+	if ctx.Request.Method == http.MethodGet {
+		request.Query = ctx.Query("query")
+		request.OperationName = ctx.Query("operationName")
+		if variables := ctx.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{
+					"error": "variables must be a JSON object",
+				})
+				return
+			}
+		}
+	} else if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+	if strings.TrimSpace(request.Query) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "query is required",
+		})
+		return
+	}
+
+	requestCtx := context.WithValue(ctx.Request.Context(), graphqlScanKey{}, &graphqlScan{})
+	ctx.JSON(http.StatusOK, h.graphql.Exec(requestCtx, request.Query, request.OperationName, request.Variables))
+}
@@ -1,60 +1,166 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"jellyfin-duplicate/auth"
 	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
 	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/cluster"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/i18n"
+	"jellyfin-duplicate/jobs"
+	"jellyfin-duplicate/logs"
+	"jellyfin-duplicate/notifications"
+	"jellyfin-duplicate/storage"
 
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
 	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
 )
 
 type Handler struct {
 	serverService *ServerService
+	config        *confModels.Config
+	jobs          *jobs.Queue
+	// login is nil when users do not sign in, see RegisterLogin
+	login *login
+	// trustedHeader is nil when users are not signed in by a reverse proxy, see RegisterTrustedHeader
+	trustedHeader *trustedHeader
+	// logs keeps the recent log entries, see RegisterLogs
+	logs *logs.Buffer
+	// throttle limits the sign ins and the bearer token attempts of each client
+	throttle *auth.Throttle
+	// graphql is the schema served by /api/graphql
+	graphql *graphql.Schema
 }
 
-func NewHandler(client *jellyfinClients.Client) *Handler {
-	serverService := NewService(client)
-	return &Handler{serverService: serverService}
+// NewHandler creates the handlers of the routes. shared is nil unless replicas of the application share Redis.
+func NewHandler(client *jellyfinClients.Client, config *confModels.Config, store *storage.Store, queue *jobs.Queue,
+	notifier *notifications.Notifier, shared *cluster.Cluster) *Handler {
+	serverService := NewService(client, config, store, notifier, shared)
+	queue.Register(constants.BulkActionJob, serverService.RunBulkActionJob)
+	queue.Register(constants.PlayStatusMigrationJob, serverService.RunPlayStatusMigrationJob)
+	serverService.jobs = queue
+	return &Handler{serverService: serverService, config: config, jobs: queue, throttle: auth.NewThrottle(config.Auth),
+		graphql: newGraphQLSchema(serverService)}
+}
+
+// StartEarlyWarning starts the periodic check of recently added movies, see ServerService.StartEarlyWarning
+func (h *Handler) StartEarlyWarning() (stop func()) {
+	return h.serverService.StartEarlyWarning()
+}
+
+// templateData adds the values shared by every page to the template data
+func (h *Handler) templateData(ctx *gin.Context, data gin.H) gin.H {
+	data["theme"] = getTheme(ctx)
+	data["locale"] = getLocale(ctx)
+	data["locales"] = i18n.Supported()
+	data["basePath"] = h.config.BasePath
+	data["version"] = constants.VersionString()
+	if session, found := currentSession(ctx); found {
+		data["user"] = session
+		// The reverse proxy may offer no logout page
+		data["canLogOut"] = h.login != nil || h.config.Auth.LogoutURL != ""
+	}
+	return data
+}
+
+// getTheme reads the theme preference from its cookie, defaulting to the dark theme
+func getTheme(ctx *gin.Context) constants.Theme {
+	theme, err := ctx.Cookie(constants.ThemeCookie)
+	if err != nil || !isValidTheme(theme) {
+		return constants.DarkTheme
+	}
+	return constants.Theme(theme)
+}
+
+// getLocale reads the locale preference from its cookie, falling back to the languages preferred by the browser
+func getLocale(ctx *gin.Context) i18n.Locale {
+	if tag, err := ctx.Cookie(constants.LocaleCookie); err == nil {
+		if locale, found := i18n.Lookup(tag); found {
+			return locale
+		}
+	}
+	return i18n.Match(ctx.GetHeader("Accept-Language"))
+}
+
+func isValidTheme(theme string) bool {
+	return lo.Contains([]constants.Theme{constants.DarkTheme, constants.LightTheme, constants.AutoTheme}, constants.Theme(theme))
+}
+
+// GET / (outside of the base path)
+// RedirectToBasePath sends requests made to the root to the application base path
+func (h *Handler) RedirectToBasePath(ctx *gin.Context) {
+	ctx.Redirect(http.StatusFound, h.config.BasePath+"/")
+}
+
+// checkScanVersion rejects the request when its optional scanVersion parameter is not the latest scan.
+// It writes the error response and returns false when the request must not proceed.
+func (h *Handler) checkScanVersion(ctx *gin.Context) bool {
+	scanVersion, err := strconv.ParseInt(ctx.DefaultQuery("scanVersion", "0"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid scanVersion format",
+		})
+		return false
+	}
+
+	if err := h.serverService.CheckScanVersion(scanVersion); err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+
+	return true
 }
 
 // GET /
 func (h *Handler) GetHomePage(ctx *gin.Context) {
 	logrus.Info("Handling request for home page")
-	ctx.HTML(http.StatusOK, "home.html", gin.H{})
+	ctx.HTML(http.StatusOK, "home.html", h.templateData(ctx, gin.H{}))
 }
 
 // GET /analysis
 func (h *Handler) GetDuplicatesPage(ctx *gin.Context) {
 	logrus.Info("Handling request for duplicates page")
-	duplicates, err := h.serverService.FindDuplicates()
+
+	query, err := ParseDuplicateQuery(ctx)
+	if err != nil {
+		logrus.Warnf("Invalid duplicates query: %v", err)
+		ctx.HTML(http.StatusBadRequest, "error.html", h.templateData(ctx, gin.H{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	scan, err := h.serverService.Scan()
 	if err != nil {
 		logrus.Errorf("Error finding duplicates: %v", err)
-		ctx.HTML(http.StatusInternalServerError, "error.html", gin.H{
+		ctx.HTML(http.StatusInternalServerError, "error.html", h.templateData(ctx, gin.H{
 			"error": err.Error(),
-		})
+		}))
 		return
 	}
+	duplicates := scan.Duplicates
 
 	logrus.Infof("Found %d duplicate pairs", len(duplicates))
 
-	// Add play status discrepancy information to each duplicate
-	for i, dup := range duplicates {
-		discrepancies := h.serverService.GetPlayStatusDiscrepancies(dup.Movie1, dup.Movie2)
-		if len(discrepancies) > 0 {
-			duplicates[i].PlayStatusDiscrepancies = discrepancies
-			duplicates[i].HasPlayStatusDiscrepancy = true
-		}
-	}
+	page := query.Apply(duplicates)
 
 	// Separate duplicates and mismatches for better UI organization
 	var potentialDuplicates []jellyfinModels.DuplicateResult
 	var potentialMismatches []jellyfinModels.DuplicateResult
 
-	for _, dup := range duplicates {
+	for _, dup := range page.Items {
 		if dup.IsDuplicate {
 			potentialDuplicates = append(potentialDuplicates, dup)
 		} else {
@@ -62,20 +168,115 @@ func (h *Handler) GetDuplicatesPage(ctx *gin.Context) {
 		}
 	}
 
-	logrus.Infof("Rendering duplicates page with %d potential duplicates and %d potential mismatches",
-		len(potentialDuplicates), len(potentialMismatches))
+	logrus.Infof("Rendering duplicates page %d/%d with %d potential duplicates and %d potential mismatches",
+		page.Page, page.TotalPages, len(potentialDuplicates), len(potentialMismatches))
+
+	columns := ParseColumns(ctx)
 
-	ctx.HTML(http.StatusOK, "duplicates.html", gin.H{
-		"duplicates":          duplicates,
+	ctx.HTML(http.StatusOK, "duplicates.html", h.templateData(ctx, gin.H{
+		"duplicates":          page.Items,
 		"potentialDuplicates": potentialDuplicates,
 		"potentialMismatches": potentialMismatches,
+		"totalPairs":          len(duplicates),
+		"page":                page,
+		"query":               query,
+		"columns":             columns,
+		"columnKeys":          columnKeys,
+		"sortKeys":            sortKeys,
+		"severities":          constants.Severities,
+		"prevURL":             pageURL(ctx, query, columns, page.Page-1),
+		"nextURL":             pageURL(ctx, query, columns, page.Page+1),
+		"scanVersion":         scan.Version,
+		"scanWarnings":        scan.Warnings,
+		"maxPairsPerGroup":    h.serverService.liveConfig().Scan.MaxPairsPerGroup,
+		"trashEnabled":        h.config.Deletion.Backend == constants.FilesystemDeletion,
+		"maintenanceWindow":   h.config.Deletion.MaintenanceWindow,
+		"scheduledDeletions":  h.scheduledDeletions(),
+	}))
+}
+
+// pageURL builds the link to another page of the duplicates page, keeping the current parameters
+func pageURL(ctx *gin.Context, query DuplicateQuery, columns map[string]bool, page int) string {
+	values := query.Values(page)
+	if len(columns) != len(columnKeys) {
+		for _, column := range columnKeys {
+			if columns[column] {
+				values.Add("columns", column)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return ctx.Request.URL.Path
+	}
+	return ctx.Request.URL.Path + "?" + values.Encode()
+}
+
+// GET /resolve
+// GetResolvePage shows potential duplicates one at a time for keyboard driven triage
+func (h *Handler) GetResolvePage(ctx *gin.Context) {
+	logrus.Info("Handling request for resolve page")
+
+	index, err := strconv.Atoi(ctx.DefaultQuery("index", "0"))
+	if err != nil || index < 0 {
+		ctx.HTML(http.StatusBadRequest, "error.html", h.templateData(ctx, gin.H{
+			"error": "index must be a positive integer",
+		}))
+		return
+	}
+
+	scan, err := h.serverService.Scan()
+	if err != nil {
+		logrus.Errorf("Error finding duplicates: %v", err)
+		ctx.HTML(http.StatusInternalServerError, "error.html", h.templateData(ctx, gin.H{
+			"error": err.Error(),
+		}))
+		return
+	}
+	duplicates := scan.Duplicates
+
+	// Only potential duplicates are triaged, in a stable order
+	potentialDuplicates := lo.Filter(duplicates, func(dup jellyfinModels.DuplicateResult, _ int) bool {
+		return dup.IsDuplicate
 	})
+	page := DuplicateQuery{Sort: "name", Order: "asc", Page: 1, PageSize: max(len(potentialDuplicates), 1)}.Apply(potentialDuplicates)
+
+	data := gin.H{
+		"index":       index,
+		"total":       len(page.Items),
+		"scanVersion": scan.Version,
+	}
+
+	if index < len(page.Items) {
+		data["dup"] = page.Items[index]
+		data["nextIndex"] = index + 1
+	}
+
+	ctx.HTML(http.StatusOK, "resolve.html", h.templateData(ctx, data))
 }
 
 // GET /api/duplicates
 func (h *Handler) GetDuplicatesJSON(ctx *gin.Context) {
 	logrus.Info("Handling request for duplicates JSON")
-	duplicates, err := h.serverService.FindDuplicates()
+
+	query, err := ParseDuplicateQuery(ctx)
+	if err != nil {
+		logrus.Warnf("Invalid duplicates query: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// The view of another user reveals their play status, only admins may request it
+	asUser := strings.TrimSpace(ctx.Query("asUser"))
+	if asUser != "" && !h.isAdmin(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error": "asUser requires the admin role",
+		})
+		return
+	}
+
+	scan, err := h.serverService.Scan()
 	if err != nil {
 		logrus.Errorf("Error finding duplicates for JSON response: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -84,12 +285,268 @@ func (h *Handler) GetDuplicatesJSON(ctx *gin.Context) {
 		return
 	}
 
-	logrus.Infof("Returning %d duplicates in JSON format", len(duplicates))
-	ctx.JSON(http.StatusOK, duplicates)
+	page := query.Apply(scan.Duplicates)
+	page.ScanVersion = scan.Version
+	page.Warnings = scan.Warnings
+	page.MaxPairsPerGroup = h.serverService.liveConfig().Scan.MaxPairsPerGroup
+
+	if asUser != "" {
+		view, err := h.serverService.UserViewOf(asUser, page.Items)
+		if errors.Is(err, ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			logrus.Errorf("Error reading the view of user %s: %v", asUser, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		page.AsUser = &view
+	}
+
+	logrus.Infof("Returning %d of %d duplicates in JSON format", len(page.Items), page.Total)
+	ctx.JSON(http.StatusOK, page)
+}
+
+// GET /api/mismatches/renames
+// GetMismatchRenames returns the rename suggestions of misnamed movies among potential mismatches,
+// as JSON or as a CSV file with format=csv, whose field separator follows the locale parameter
+func (h *Handler) GetMismatchRenames(ctx *gin.Context) {
+	logrus.Info("Handling request for mismatch rename suggestions")
+	export, err := ParseCSVExport(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	scan, err := h.serverService.Scan()
+	if err != nil {
+		logrus.Errorf("Error finding duplicates for rename suggestions: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	suggestions := MismatchRenames(scan.Duplicates)
+	logrus.Infof("Returning %d rename suggestions", len(suggestions))
+
+	switch ctx.DefaultQuery("format", "json") {
+	case "json":
+		ctx.JSON(http.StatusOK, gin.H{
+			"suggestions":  suggestions,
+			"scan_version": scan.Version,
+		})
+	case "csv":
+		writer := export.Start(ctx, "rename-suggestions.csv")
+		records := [][]string{{"movie_id", "name", "year", "library", "current_path", "suggested_path"}}
+		for _, suggestion := range suggestions {
+			records = append(records, []string{suggestion.MovieID, suggestion.Name, strconv.Itoa(suggestion.Year),
+				suggestion.LibraryName, suggestion.CurrentPath, suggestion.SuggestedPath})
+		}
+		if err := writer.WriteAll(records); err != nil {
+			logrus.Errorf("Failed to write rename suggestions: %v", err)
+		}
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be json or csv",
+		})
+	}
+}
+
+// GET /api/boxsets/overlaps
+// GetBoxSetOverlaps returns the collections sharing most of their movies, with merge suggestions.
+// min_overlap sets the minimum overlap percentage.
+func (h *Handler) GetBoxSetOverlaps(ctx *gin.Context) {
+	logrus.Info("Handling request for overlapping collections")
+
+	minOverlap, err := strconv.ParseFloat(ctx.DefaultQuery("min_overlap", strconv.Itoa(DefaultMinBoxSetOverlap)), 64)
+	if err != nil || minOverlap <= 0 || minOverlap > 100 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "min_overlap must be a percentage between 0 and 100",
+		})
+		return
+	}
+
+	overlaps, err := h.serverService.FindBoxSetOverlaps(minOverlap)
+	if err != nil {
+		logrus.Errorf("Error finding overlapping collections: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"overlaps":    overlaps,
+		"min_overlap": minOverlap,
+	})
+}
+
+// GET /api/feedback/thresholds
+// GetThresholdSuggestions returns the pairs marked as not duplicates and the duplicate thresholds they suggest per library
+func (h *Handler) GetThresholdSuggestions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"false_positives": h.serverService.FalsePositives(),
+		"thresholds":      h.serverService.SuggestThresholds(),
+		"auto_tune":       h.serverService.liveConfig().Scan.AutoTuneThreshold,
+	})
+}
+
+// GET /api/resolutions
+// GetResolutions returns the history of groups resolved manually, with their reason
+func (h *Handler) GetResolutions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"resolutions": h.serverService.Resolutions(),
+	})
+}
+
+// POST /api/webhooks/jellyfin
+// JellyfinWebhook receives the events of the Jellyfin Webhook plugin. A new movie is compared with the
+// library in the background and the duplicates found are notified, other events are ignored.
+func (h *Handler) JellyfinWebhook(ctx *gin.Context) {
+	var event JellyfinWebhookEvent
+	if err := ctx.ShouldBindJSON(&event); err != nil {
+		logrus.Warnf("Invalid Jellyfin webhook event: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "NotificationType is required",
+		})
+		return
+	}
+
+	if !event.IsMovieAdded() {
+		logrus.Debugf("Ignoring Jellyfin webhook event %s for %s item", event.NotificationType, event.ItemType)
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Event ignored",
+		})
+		return
+	}
+
+	logrus.Infof("Movie %s (%s) added, checking it for duplicates", event.Name, event.ItemID)
+	go h.serverService.CheckAddedItem(event.ItemID)
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Checking %s for duplicates", event.Name),
+	})
+}
+
+// GET /api/scan/schedule
+// GetScanSchedule reports the state and next run of the scheduled scan
+func (h *Handler) GetScanSchedule(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.serverService.ScheduleStatus())
+}
+
+// POST /api/scan/schedule/pause
+// PauseScanSchedule skips the scheduled scans until they are resumed
+func (h *Handler) PauseScanSchedule(ctx *gin.Context) {
+	h.setSchedulePaused(ctx, true)
+}
+
+// POST /api/scan/schedule/resume
+// ResumeScanSchedule restarts the scheduled scans
+func (h *Handler) ResumeScanSchedule(ctx *gin.Context) {
+	h.setSchedulePaused(ctx, false)
+}
+
+func (h *Handler) setSchedulePaused(ctx *gin.Context, paused bool) {
+	if err := h.serverService.SetSchedulePaused(paused); err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  lo.Ternary(paused, "Scheduled scan paused", "Scheduled scan resumed"),
+		"schedule": h.serverService.ScheduleStatus(),
+	})
+}
+
+// GET /users
+// GetUsersPage lists the Jellyfin users and lets the user choose which ones are reconciled
+func (h *Handler) GetUsersPage(ctx *gin.Context) {
+	logrus.Info("Handling request for users page")
+
+	users, err := h.serverService.ListUsers()
+	if err != nil {
+		logrus.Errorf("Error listing users: %v", err)
+		ctx.HTML(http.StatusInternalServerError, "error.html", h.templateData(ctx, gin.H{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	ctx.HTML(http.StatusOK, "users.html", h.templateData(ctx, gin.H{
+		"users": users,
+	}))
+}
+
+// GET /api/users
+// GetUsers returns the Jellyfin users with their seen movie counts and reconciliation selection
+func (h *Handler) GetUsers(ctx *gin.Context) {
+	users, err := h.serverService.ListUsers()
+	if err != nil {
+		logrus.Errorf("Error listing users: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"users": users,
+	})
+}
+
+// UserSelectionRequest is the body of a user selection change
+type UserSelectionRequest struct {
+	Included *bool `json:"included" binding:"required"`
+}
+
+// POST /api/users/:id/selection
+// SetUserSelection includes or excludes a user from play status reconciliation
+func (h *Handler) SetUserSelection(ctx *gin.Context) {
+	var request UserSelectionRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "included is required",
+		})
+		return
+	}
+
+	err := h.serverService.SetUserIncluded(ctx.Param("id"), *request.Included)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, ErrNoIncludedUsers):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case err != nil:
+		logrus.Errorf("Error updating user selection: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "User selection updated, it applies from the next scan",
+		})
+	}
 }
 
-// GET /api/delete-movie
-// DeleteMovie handles movie deletion requests
+// POST /api/delete-movie
+// DeleteMovie handles movie deletion requests, whose parameters are given in the query string
 func (h *Handler) DeleteMovie(ctx *gin.Context) {
 	movieID := ctx.Query("movieId")
 
@@ -113,7 +570,43 @@ func (h *Handler) DeleteMovie(ctx *gin.Context) {
 		return
 	}
 
-	err := h.serverService.DeleteMovie(movieID)
+	if !h.checkScanVersion(ctx) {
+		return
+	}
+
+	expected, err := parseExpectedFile(ctx)
+	if err != nil {
+		logrus.Warnf("Invalid expected file for movie %s: %v", movieID, err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// The other copy of the pair, if known, receives the sidecar files of the deleted one
+	keepMovieID := ctx.Query("keepMovieId")
+	if !lo.IsEmpty(keepMovieID) && !IsUUIDFormtatted(keepMovieID) {
+		logrus.Warnf("Invalid keepMovieId format: %s", keepMovieID)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid keepMovieId format",
+		})
+		return
+	}
+
+	err = h.serverService.DeleteMovie(movieID, DeleteOptions{Expected: expected, KeepMovieID: keepMovieID})
+	if errors.Is(err, ErrItemChanged) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	// Deleted outside of the application, its pairs were removed from the latest scan
+	if errors.Is(err, jellyfinClients.ErrNotFound) {
+		ctx.JSON(http.StatusGone, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		logrus.Errorf("Error deleting movie %s: %v", movieID, err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -124,14 +617,39 @@ func (h *Handler) DeleteMovie(ctx *gin.Context) {
 
 	logrus.Infof("Successfully deleted movie %s", movieID)
 
+	message := "Movie deleted successfully"
+	if h.config.Deletion.Backend == constants.FilesystemDeletion {
+		message = "Movie moved to trash successfully"
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Movie deleted successfully",
+		"message": message,
 	})
 }
 
-// GET /api/mark-as-seen
-// MarkMovieAsSeen marks a movie as seen for a specific user
+// parseExpectedFile reads the optional expectedPath and expectedSize query parameters,
+// describing the file shown to the user when the deletion was requested
+func parseExpectedFile(ctx *gin.Context) (*ExpectedFile, error) {
+	path := ctx.Query("expectedPath")
+	size := ctx.Query("expectedSize")
+	if path == "" && size == "" {
+		return nil, nil
+	}
+
+	expected := &ExpectedFile{Path: path}
+	if size != "" {
+		value, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("expectedSize must be a non-negative integer")
+		}
+		expected.Size = value
+	}
+	return expected, nil
+}
+
+// POST /api/mark-as-seen
+// MarkMovieAsSeen marks a movie as seen for a specific user, given in the query string
 func (h *Handler) MarkMovieAsSeen(ctx *gin.Context) {
 	movieID := ctx.Query("movieId")
 	userID := ctx.Query("userId")
@@ -165,8 +683,18 @@ func (h *Handler) MarkMovieAsSeen(ctx *gin.Context) {
 		return
 	}
 
+	if !h.checkScanVersion(ctx) {
+		return
+	}
+
 	err := h.serverService.MarkMovieAsSeen(movieID, userID)
 
+	if errors.Is(err, jellyfinClients.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		logrus.Errorf("Failed to mark movie %s as seen for user %s: %v", movieID, userID, err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -174,9 +702,280 @@ func (h *Handler) MarkMovieAsSeen(ctx *gin.Context) {
 		})
 		return
 	}
+	h.serverService.NotifyMarkedAsSeen(movieID, userID)
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Movie marked as seen successfully",
 	})
 }
+
+// GET /api/set-theme
+// SetTheme stores the theme preference in a cookie
+func (h *Handler) SetTheme(ctx *gin.Context) {
+	theme := ctx.Query("theme")
+
+	if !isValidTheme(theme) {
+		logrus.Warnf("Invalid theme: %s", theme)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "theme must be one of dark, light or auto",
+		})
+		return
+	}
+
+	// Keep the preference for a year
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(constants.ThemeCookie, theme, 365*24*60*60, h.config.BasePath+"/", "", false, false)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Theme updated successfully",
+	})
+}
+
+// GET /api/set-locale
+// SetLocale stores the locale preference in a cookie
+func (h *Handler) SetLocale(ctx *gin.Context) {
+	tag := ctx.Query("locale")
+
+	if _, found := i18n.Lookup(tag); !found {
+		logrus.Warnf("Invalid locale: %s", tag)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "locale must be one of " + strings.Join(lo.Map(i18n.Supported(), func(locale i18n.Locale, _ int) string {
+				return locale.Tag
+			}), ", "),
+		})
+		return
+	}
+
+	// Keep the preference for a year
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(constants.LocaleCookie, tag, 365*24*60*60, h.config.BasePath+"/", "", false, false)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Locale updated successfully",
+	})
+}
+
+// POST /api/duplicates/bulk-action
+// BulkAction applies one action to several duplicate groups and reports the result of each
+func (h *Handler) BulkAction(ctx *gin.Context) {
+	var request BulkActionRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		logrus.Warnf("Invalid bulk action request: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "action and group_ids are required",
+		})
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		logrus.Warnf("Invalid bulk action %s: %v", request.Action, err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	report, err := h.serverService.RunBulkAction(ctx.Request.Context(), request, nil)
+	if errors.Is(err, ErrStaleScan) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error running bulk action %s: %v", request.Action, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	logrus.Infof("Bulk action %s completed: %d succeeded, %d failed", request.Action, report.Succeeded, report.Failed)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success":   report.Failed == 0,
+		"succeeded": report.Succeeded,
+		"failed":    report.Failed,
+		"results":   report.Results,
+		"action_id": report.ActionID,
+	})
+}
+
+// POST /api/actions/:id/rollback
+// RollbackAction restores the play status changed by a bulk action
+func (h *Handler) RollbackAction(ctx *gin.Context) {
+	actionID := ctx.Param("id")
+	logrus.Infof("Handling rollback of action %s", actionID)
+
+	report, err := h.serverService.RollbackAction(actionID)
+	switch {
+	case errors.Is(err, ErrActionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, ErrActionRolledBack):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case err != nil:
+		logrus.Errorf("Error rolling back action %s: %v", actionID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":    err.Error(),
+			"restored": report.Restored,
+			"errors":   report.Errors,
+		})
+	default:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"message":  fmt.Sprintf("%d play status restored", report.Restored),
+			"restored": report.Restored,
+		})
+	}
+}
+
+// JobRequest is the body of a job submission
+type JobRequest struct {
+	Type   constants.JobType `json:"type" binding:"required"`
+	Params json.RawMessage   `json:"params" binding:"required"`
+	// Schedule runs a deletion bulk action in the next deletion.maintenance_window instead of right away
+	Schedule bool `json:"schedule"`
+}
+
+// POST /api/jobs
+// SubmitJob queues a long operation, executed in the background
+func (h *Handler) SubmitJob(ctx *gin.Context) {
+	var request JobRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		logrus.Warnf("Invalid job request: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "type and params are required",
+		})
+		return
+	}
+
+	var params any
+	switch request.Type {
+	case constants.BulkActionJob:
+		var bulkRequest BulkActionRequest
+		if err := json.Unmarshal(request.Params, &bulkRequest); err != nil || len(bulkRequest.GroupIDs) == 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "params must contain action and group_ids",
+			})
+			return
+		}
+		if err := bulkRequest.Validate(); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// Reject outdated selections now rather than when the job runs
+		if err := h.serverService.CheckScanVersion(bulkRequest.ScanVersion); err != nil {
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if request.Schedule {
+			job, err := h.scheduleBulkAction(bulkRequest)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			ctx.JSON(http.StatusAccepted, job)
+			return
+		}
+		params = bulkRequest
+	case constants.PlayStatusMigrationJob:
+		var migrationRequest PlayStatusMigrationRequest
+		if err := json.Unmarshal(request.Params, &migrationRequest); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "params must contain source, users and dry_run",
+			})
+			return
+		}
+		if err := migrationRequest.Validate(); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if !h.serverService.HasSecondaryServer() {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": ErrNoSecondaryServer.Error(),
+			})
+			return
+		}
+		if request.Schedule {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "only deletions can be scheduled",
+			})
+			return
+		}
+		params = migrationRequest
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("invalid job type %s", request.Type),
+		})
+		return
+	}
+
+	job, err := h.jobs.Submit(request.Type, params)
+	if err != nil {
+		logrus.Errorf("Error submitting job %s: %v", request.Type, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// GET /api/jobs
+// GetJobs lists the queued, running and finished jobs, most recent first
+func (h *Handler) GetJobs(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"jobs": h.jobs.List(),
+	})
+}
+
+// GET /api/jobs/:id
+// GetJob returns the status of a job
+func (h *Handler) GetJob(ctx *gin.Context) {
+	job, ok := h.jobs.Get(ctx.Param("id"))
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": jobs.ErrJobNotFound.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
+
+// POST /api/jobs/:id/cancel
+// CancelJob cancels a queued job or stops a running one before its next item
+func (h *Handler) CancelJob(ctx *gin.Context) {
+	job, err := h.jobs.Cancel(ctx.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, jobs.ErrJobFinished):
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"job":     job,
+		})
+	}
+}
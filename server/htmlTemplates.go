@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin/render"
+	"github.com/sirupsen/logrus"
+)
+
+// baseLayout is the template executed to render every page
+const baseLayout = "base"
+
+// HTMLTemplates renders the pages of a templates directory. Each file of pages/ is parsed on top of
+// the layouts/ and partials/ files, so that a page only defines the blocks of the base layout it fills.
+// Pages are rendered by file name, such as "duplicates.html".
+type HTMLTemplates struct {
+	dir   string
+	pages map[string]*template.Template
+	// reload parses the templates again on every render, to see changes without restarting
+	reload bool
+}
+
+// LoadTemplates parses the templates of the directory, reloading them on every render when reload is set
+func LoadTemplates(dir string, reload bool) (*HTMLTemplates, error) {
+	pages, err := parseTemplates(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &HTMLTemplates{dir: dir, pages: pages, reload: reload}, nil
+}
+
+// Instance implements gin's render.HTMLRender
+func (t *HTMLTemplates) Instance(name string, data any) render.Render {
+	pages := t.pages
+	if t.reload {
+		reloaded, err := parseTemplates(t.dir)
+		if err != nil {
+			logrus.Errorf("Failed to reload templates: %v", err)
+		} else {
+			pages = reloaded
+		}
+	}
+
+	page, found := pages[name]
+	if !found {
+		// Executing an empty template reports the unknown page as a render error
+		page = template.New(name)
+	}
+	return render.HTML{Template: page, Name: baseLayout, Data: data}
+}
+
+// parseTemplates parses the layouts and partials once, then each page on its own copy of them
+func parseTemplates(dir string) (map[string]*template.Template, error) {
+	shared := template.New(baseLayout).Funcs(TemplateFuncs())
+	for _, pattern := range []string{"layouts/*.html", "partials/*.html"} {
+		if _, err := shared.ParseGlob(filepath.Join(dir, pattern)); err != nil {
+			return nil, fmt.Errorf("failed to parse %s templates: %v", pattern, err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "pages", "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %v", err)
+	}
+
+	pages := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		page, err := shared.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy layouts for %s: %v", file, err)
+		}
+		if _, err := page.ParseFiles(file); err != nil {
+			return nil, fmt.Errorf("failed to parse page %s: %v", file, err)
+		}
+		pages[filepath.Base(file)] = page
+	}
+	return pages, nil
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RefreshMetadataRequest is the body of POST /api/movies/:id/refresh-metadata
+type RefreshMetadataRequest struct {
+	// Replace drops the metadata and images of the movie instead of completing them
+	Replace bool `json:"replace"`
+}
+
+// IdentifyRequest is the body of POST /api/movies/:id/identify
+type IdentifyRequest struct {
+	// Result is one of the candidates returned by GET /api/movies/:id/identify, sent back as is
+	Result *jellyfinModels.RemoteSearchResult `json:"result"`
+	// ReplaceImages fetches the images of the movie again from the providers of the result
+	ReplaceImages bool `json:"replace_images"`
+}
+
+// RefreshMovieMetadata fetches the metadata of a movie again from the metadata providers of Jellyfin, to fix
+// misidentified movies of potential mismatches without leaving the application. Jellyfin refreshes in the
+// background, the next scan shows the result.
+func (s *ServerService) RefreshMovieMetadata(movieID string, replace bool) error {
+	movie, operator, err := s.jellyfinClient.GetMovieAsOperator(movieID)
+	if err != nil {
+		return fmt.Errorf("failed to get movie: %w", err)
+	}
+
+	if err := s.jellyfinClient.RefreshMetadata(movieID, replace); err != nil {
+		return err
+	}
+	details := "completing metadata"
+	if replace {
+		details = "replacing all metadata and images"
+	}
+	s.recordAudit(constants.RefreshMetadataAudit, movieID, movie.Name, operator, details)
+	return nil
+}
+
+// SearchMovieMetadata lists the candidates of the metadata providers of Jellyfin for a movie. The name and year
+// of the movie are searched when name is empty.
+func (s *ServerService) SearchMovieMetadata(movieID, name string, year int) ([]jellyfinModels.RemoteSearchResult, error) {
+	if name == "" {
+		movie, err := s.jellyfinClient.GetMovie(movieID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get movie: %w", err)
+		}
+		name, year = movie.Name, movie.ProductionYear
+	}
+
+	results, err := s.jellyfinClient.SearchMovieMetadata(movieID, name, year)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []jellyfinModels.RemoteSearchResult{}
+	}
+	return results, nil
+}
+
+// IdentifyMovie identifies a movie as a candidate of SearchMovieMetadata, Jellyfin replacing its metadata with
+// the one of the candidate in the background
+func (s *ServerService) IdentifyMovie(movieID string, result jellyfinModels.RemoteSearchResult, replaceImages bool) error {
+	movie, operator, err := s.jellyfinClient.GetMovieAsOperator(movieID)
+	if err != nil {
+		return fmt.Errorf("failed to get movie: %w", err)
+	}
+
+	if err := s.jellyfinClient.ApplySearchResult(movieID, result, replaceImages); err != nil {
+		return err
+	}
+	s.recordAudit(constants.IdentifyMovieAudit, movieID, movie.Name, operator, describeSearchResult(result))
+	return nil
+}
+
+// describeSearchResult tells the name, year and provider IDs of a candidate, such as "Heat (1995) Tmdb:949"
+func describeSearchResult(result jellyfinModels.RemoteSearchResult) string {
+	description := result.Name
+	if result.ProductionYear > 0 {
+		description += fmt.Sprintf(" (%d)", result.ProductionYear)
+	}
+	for _, provider := range []string{"Tmdb", "Imdb"} {
+		if id := result.ProviderIds[provider]; id != "" {
+			description += fmt.Sprintf(" %s:%s", provider, id)
+		}
+	}
+	return description
+}
+
+// movieErrorStatus returns 404 for movies missing from Jellyfin, 500 for other errors
+func movieErrorStatus(err error) int {
+	if errors.Is(err, jellyfinClients.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// POST /api/movies/:id/refresh-metadata
+// RefreshMovieMetadata fetches the metadata of a movie again, replacing it with replace
+func (h *Handler) RefreshMovieMetadata(ctx *gin.Context) {
+	var request RefreshMetadataRequest
+	// The body is optional, metadata being completed without it
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("invalid request: %v", err),
+			})
+			return
+		}
+	}
+
+	movieID := ctx.Param("id")
+	if err := h.serverService.RefreshMovieMetadata(movieID, request.Replace); err != nil {
+		logrus.Errorf("Error refreshing metadata of movie %s: %v", movieID, err)
+		ctx.JSON(movieErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "metadata refresh started in Jellyfin, the next scan shows the result",
+	})
+}
+
+// GET /api/movies/:id/identify
+// SearchMovieMetadata returns the candidates of the metadata providers of Jellyfin for a movie, searching name
+// and year, or the ones of the movie when name is not given
+func (h *Handler) SearchMovieMetadata(ctx *gin.Context) {
+	name := strings.TrimSpace(ctx.Query("name"))
+	year := 0
+	if value := ctx.Query("year"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "year must be a positive number",
+			})
+			return
+		}
+		year = parsed
+	}
+
+	movieID := ctx.Param("id")
+	results, err := h.serverService.SearchMovieMetadata(movieID, name, year)
+	if err != nil {
+		logrus.Errorf("Error searching metadata of movie %s: %v", movieID, err)
+		ctx.JSON(movieErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
+
+// POST /api/movies/:id/identify
+// IdentifyMovie identifies a movie as one of the candidates of GET /api/movies/:id/identify
+func (h *Handler) IdentifyMovie(ctx *gin.Context) {
+	var request IdentifyRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.Result == nil || request.Result.Name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "result is required, as returned by the identify search",
+		})
+		return
+	}
+
+	movieID := ctx.Param("id")
+	if err := h.serverService.IdentifyMovie(movieID, *request.Result, request.ReplaceImages); err != nil {
+		logrus.Errorf("Error identifying movie %s: %v", movieID, err)
+		ctx.JSON(movieErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("identified as %s, Jellyfin is updating its metadata", describeSearchResult(*request.Result)),
+	})
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JellyfinStatus is the health of the connection to Jellyfin
+type JellyfinStatus struct {
+	Reachable bool `json:"reachable"`
+	// Authenticated is false when the server rejects the API key or the access token
+	Authenticated bool `json:"authenticated"`
+	// LatencyMs is the response time of the public system information, 0 when unreachable
+	LatencyMs  int64     `json:"latency_ms"`
+	ServerName string    `json:"server_name,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// JellyfinStatus checks that Jellyfin answers, measuring its latency, then that it accepts the credentials
+func (s *ServerService) JellyfinStatus() JellyfinStatus {
+	status := JellyfinStatus{CheckedAt: time.Now()}
+
+	start := time.Now()
+	info, err := s.jellyfinClient.GetPublicSystemInfo()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Reachable = true
+	status.LatencyMs = time.Since(start).Milliseconds()
+	status.ServerName = info.ServerName
+	status.Version = info.Version
+
+	if _, err := s.jellyfinClient.GetSystemInfo(); err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Authenticated = true
+	return status
+}
+
+// GET /api/jellyfin/status
+// GetJellyfinStatus reports the reachability, latency, version and authentication of the Jellyfin server.
+// An unhealthy server is reported in the body, the request itself succeeds.
+func (h *Handler) GetJellyfinStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.serverService.JellyfinStatus())
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// LibraryPolicyRequest is the body of a library policy change
+type LibraryPolicyRequest struct {
+	Policy constants.LibraryPolicy `json:"policy" binding:"required"`
+}
+
+// LibraryPolicies returns the policy of each library having one, by name
+func (s *ServerService) LibraryPolicies() map[string]constants.LibraryPolicy {
+	s.policiesMutex.RLock()
+	defer s.policiesMutex.RUnlock()
+	return maps.Clone(s.policies)
+}
+
+// libraryPolicy returns the policy of a library, whose name is compared case-insensitively, manual when it has none
+func (s *ServerService) libraryPolicy(library string) constants.LibraryPolicy {
+	s.policiesMutex.RLock()
+	defer s.policiesMutex.RUnlock()
+	for name, policy := range s.policies {
+		if strings.EqualFold(name, library) {
+			return policy
+		}
+	}
+	return constants.ManualPolicy
+}
+
+// pairPolicy returns the policy of the libraries of both copies of a pair, manual when their policies differ
+func (s *ServerService) pairPolicy(movie1, movie2 jellyfinModels.Movie) constants.LibraryPolicy {
+	policy := s.libraryPolicy(movie1.LibraryName)
+	if s.libraryPolicy(movie2.LibraryName) != policy {
+		return constants.ManualPolicy
+	}
+	return policy
+}
+
+// SetLibraryPolicy changes the policy of a library and writes it to the configuration file, the manual policy
+// removing the library from it. It returns the policies of every library.
+func (s *ServerService) SetLibraryPolicy(library string, policy constants.LibraryPolicy) (map[string]constants.LibraryPolicy, error) {
+	if !constants.IsValidLibraryPolicy(policy) {
+		return nil, fmt.Errorf("invalid policy %s", policy)
+	}
+
+	s.policiesMutex.Lock()
+	defer s.policiesMutex.Unlock()
+
+	policies := lo.OmitBy(s.policies, func(name string, _ constants.LibraryPolicy) bool {
+		return strings.EqualFold(name, library)
+	})
+	if policy != constants.ManualPolicy {
+		policies[library] = policy
+	}
+
+	reloaded, err := confServices.WriteLibraryPolicies(s.config, policies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save library policies: %v", err)
+	}
+	s.policies = reloaded.Scan.LibraryPolicies
+
+	logrus.Infof("Library %s policy set to %s", library, policy)
+	return maps.Clone(s.policies), nil
+}
+
+// autoClean submits a strict sync then delete job for the duplicates of the auto cleaned libraries which can be
+// resolved without an explicit choice. Failures are logged, they never make the scan fail.
+func (s *ServerService) autoClean(result ScanResult) {
+	if s.jobs == nil {
+		return
+	}
+
+	groupIDs := lo.FilterMap(result.Duplicates, func(dup jellyfinModels.DuplicateResult, _ int) (string, bool) {
+		if !dup.IsDuplicate || s.pairPolicy(dup.Movie1, dup.Movie2) != constants.AutoCleanPolicy {
+			return "", false
+		}
+		_, err := recommendedCopy(dup)
+		return dup.ID, err == nil
+	})
+	if len(groupIDs) == 0 {
+		return
+	}
+
+	job, err := s.jobs.Submit(constants.BulkActionJob, BulkActionRequest{
+		Action:      constants.SyncThenDeleteAction,
+		GroupIDs:    groupIDs,
+		ScanVersion: result.Version,
+		Strict:      true,
+	})
+	if err != nil {
+		logrus.Errorf("Failed to submit auto clean of %d duplicates: %v", len(groupIDs), err)
+		return
+	}
+	logrus.Infof("Auto cleaning %d duplicates in job %s", len(groupIDs), job.ID)
+}
+
+// GET /api/library-policies
+// GetLibraryPolicies returns the policy of each library having one, the other libraries are manual
+func (h *Handler) GetLibraryPolicies(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"policies": h.serverService.LibraryPolicies(),
+	})
+}
+
+// PUT /api/library-policies/:library
+// SetLibraryPolicy changes the policy of a library, in the configuration file too
+func (h *Handler) SetLibraryPolicy(ctx *gin.Context) {
+	library := ctx.Param("library")
+
+	var request LibraryPolicyRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "policy is required",
+		})
+		return
+	}
+	if !constants.IsValidLibraryPolicy(request.Policy) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("invalid policy %s, must be %s, %s or %s", request.Policy,
+				constants.ManualPolicy, constants.AutoCleanPolicy, constants.IgnorePolicy),
+		})
+		return
+	}
+
+	policies, err := h.serverService.SetLibraryPolicy(library, request.Policy)
+	if err != nil {
+		logrus.Errorf("Error setting policy of library %s: %v", library, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  fmt.Sprintf("Library %s is now %s", library, request.Policy),
+		"policies": policies,
+	})
+}
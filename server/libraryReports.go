@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/pkg/humanize"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// libraryReportSortKeys lists the values accepted by the sort query parameter of the unwatched and watched reports
+var libraryReportSortKeys = []string{"size", "name", "year", "added", "last_played"}
+
+// LibraryReportQuery holds the filter and sort parameters of the unwatched and watched reports
+type LibraryReportQuery struct {
+	// OlderThan keeps the movies added to the library at least this many years ago, 0 keeps them all
+	OlderThan int
+	// MinSize keeps the movies of at least this many megabytes, 0 keeps them all
+	MinSize int64
+	Sort    string
+	Order   string
+}
+
+// ReportMovie is a movie of the unwatched or watched reports
+type ReportMovie struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Year    int    `json:"year"`
+	Library string `json:"library"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	SizeH   string `json:"size_h"`
+	// AddedAt is when the movie was added to the library, absent when unknown
+	AddedAt *time.Time `json:"added_at,omitempty"`
+	// LastPlayed is the last time a user played the movie, absent when no user did or when unknown
+	LastPlayed *time.Time `json:"last_played,omitempty"`
+}
+
+// MovieReport lists the movies of the unwatched or watched reports, with the space they take
+type MovieReport struct {
+	Movies     []ReportMovie `json:"movies"`
+	TotalSize  int64         `json:"total_size"`
+	TotalSizeH string        `json:"total_size_h"`
+	// Users counts the users whose play status was checked, the ones excluded from reconciliation left out
+	Users int `json:"users"`
+}
+
+// libraryPlayStatus is the play status of the movies of the scanned libraries across users
+type libraryPlayStatus struct {
+	movies []jellyfinModels.Movie
+	users  int
+	// seenBy counts the users who played each movie to the end, started tells the movies a user started
+	seenBy     map[string]int
+	started    map[string]bool
+	lastPlayed map[string]time.Time
+}
+
+// ParseLibraryReportQuery reads and validates the query parameters older_than, min_size, sort and order, sorting
+// by size from the largest movie by default
+func ParseLibraryReportQuery(ctx *gin.Context) (LibraryReportQuery, error) {
+	query := LibraryReportQuery{
+		Sort:  ctx.DefaultQuery("sort", "size"),
+		Order: ctx.DefaultQuery("order", "desc"),
+	}
+
+	if olderThan := ctx.Query("older_than"); olderThan != "" {
+		value, err := strconv.Atoi(olderThan)
+		if err != nil || value < 0 {
+			return query, fmt.Errorf("older_than must be a positive number of years")
+		}
+		query.OlderThan = value
+	}
+
+	if minSize := ctx.Query("min_size"); minSize != "" {
+		value, err := strconv.ParseInt(minSize, 10, 64)
+		if err != nil || value < 0 {
+			return query, fmt.Errorf("min_size must be a positive number of megabytes")
+		}
+		query.MinSize = value
+	}
+
+	if !lo.Contains(libraryReportSortKeys, query.Sort) {
+		return query, fmt.Errorf("sort must be one of %s", strings.Join(libraryReportSortKeys, ", "))
+	}
+
+	if query.Order != "asc" && query.Order != "desc" {
+		return query, fmt.Errorf("order must be asc or desc")
+	}
+
+	return query, nil
+}
+
+// fetchLibraryPlayStatus fetches the movies of the scanned libraries and the play status of every user, the users
+// excluded from reconciliation being left out
+func (s *ServerService) fetchLibraryPlayStatus(ctx context.Context) (libraryPlayStatus, error) {
+	client := s.jellyfinClient.WithContext(ctx)
+	movies, err := client.GetAllMovies()
+	if err != nil {
+		return libraryPlayStatus{}, fmt.Errorf("failed to get all movies: %v", err)
+	}
+	allUsers, err := client.GetAllUsers()
+	if err != nil {
+		return libraryPlayStatus{}, fmt.Errorf("failed to get users: %v", err)
+	}
+	users := s.reconciledUsers(allUsers)
+
+	userSeenMovies, err := client.GetSeenMoviesForAllUsers(users)
+	if err != nil {
+		return libraryPlayStatus{}, fmt.Errorf("failed to get seen movies for all users: %v", err)
+	}
+	userResumableMovies, err := client.GetResumableMoviesForAllUsers(users)
+	if err != nil {
+		return libraryPlayStatus{}, fmt.Errorf("failed to get resumable movies for all users: %v", err)
+	}
+
+	status := libraryPlayStatus{
+		movies:     movies,
+		users:      len(users),
+		seenBy:     make(map[string]int),
+		started:    make(map[string]bool),
+		lastPlayed: make(map[string]time.Time),
+	}
+	for _, user := range users {
+		for _, movie := range userSeenMovies[user.ID] {
+			status.seenBy[movie.ID]++
+			status.started[movie.ID] = true
+			if played, err := time.Parse(time.RFC3339, movie.UserData.LastPlayedDate); err == nil && played.After(status.lastPlayed[movie.ID]) {
+				status.lastPlayed[movie.ID] = played
+			}
+		}
+		for _, movie := range userResumableMovies[user.ID] {
+			status.started[movie.ID] = true
+		}
+	}
+	return status, nil
+}
+
+// UnwatchedMovies lists the movies of the scanned libraries that no user has played or started, a cleanup
+// candidate next to duplicates. With OlderThan, movies whose date of addition is unknown are left out.
+func (s *ServerService) UnwatchedMovies(ctx context.Context, query LibraryReportQuery) (MovieReport, error) {
+	status, err := s.fetchLibraryPlayStatus(ctx)
+	if err != nil {
+		return MovieReport{}, err
+	}
+
+	report := status.report(query, func(movieID string) bool {
+		return !status.started[movieID]
+	})
+	logrus.Infof("Found %d movies no user has played, %s", len(report.Movies), report.TotalSizeH)
+	return report, nil
+}
+
+// WatchedMovies lists the movies of the scanned libraries that every user has played to the end, candidates to
+// archive to cold storage. No movie is listed when no user is checked. With OlderThan, movies whose date of
+// addition is unknown are left out.
+func (s *ServerService) WatchedMovies(ctx context.Context, query LibraryReportQuery) (MovieReport, error) {
+	status, err := s.fetchLibraryPlayStatus(ctx)
+	if err != nil {
+		return MovieReport{}, err
+	}
+
+	report := status.report(query, func(movieID string) bool {
+		return status.users > 0 && status.seenBy[movieID] == status.users
+	})
+	logrus.Infof("Found %d movies every user has played, %s", len(report.Movies), report.TotalSizeH)
+	return report, nil
+}
+
+// report lists the movies kept by keep and by the filters of the query, sorted by the query
+func (p libraryPlayStatus) report(query LibraryReportQuery, keep func(movieID string) bool) MovieReport {
+	cutoff := time.Now().AddDate(-query.OlderThan, 0, 0)
+	report := MovieReport{Movies: []ReportMovie{}, Users: p.users}
+	for _, movie := range p.movies {
+		if !keep(movie.ID) || movie.Size() < query.MinSize*bytesPerMegabyte {
+			continue
+		}
+		entry := ReportMovie{
+			ID:      movie.ID,
+			Name:    movie.Name,
+			Year:    movie.ProductionYear,
+			Library: movie.LibraryName,
+			Path:    movie.Path,
+			Size:    movie.Size(),
+			SizeH:   humanize.Bytes(movie.Size()),
+		}
+		addedAt, known := movie.AddedAt()
+		if known {
+			entry.AddedAt = &addedAt
+		}
+		if query.OlderThan > 0 && (!known || addedAt.After(cutoff)) {
+			continue
+		}
+		if lastPlayed, found := p.lastPlayed[movie.ID]; found {
+			entry.LastPlayed = &lastPlayed
+		}
+		report.Movies = append(report.Movies, entry)
+		report.TotalSize += entry.Size
+	}
+	report.TotalSizeH = humanize.Bytes(report.TotalSize)
+
+	sort.SliceStable(report.Movies, func(i, j int) bool {
+		if query.Order == "desc" {
+			return query.less(report.Movies[j], report.Movies[i])
+		}
+		return query.less(report.Movies[i], report.Movies[j])
+	})
+	return report
+}
+
+// less compares two movies on the sort key, falling back to the name for a stable order
+func (q LibraryReportQuery) less(a, b ReportMovie) bool {
+	switch q.Sort {
+	case "size":
+		if a.Size != b.Size {
+			return a.Size < b.Size
+		}
+	case "year":
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+	case "added":
+		// Movies whose date is unknown come first
+		if addedA, addedB := lo.FromPtr(a.AddedAt), lo.FromPtr(b.AddedAt); !addedA.Equal(addedB) {
+			return addedA.Before(addedB)
+		}
+	case "last_played":
+		if playedA, playedB := lo.FromPtr(a.LastPlayed), lo.FromPtr(b.LastPlayed); !playedA.Equal(playedB) {
+			return playedA.Before(playedB)
+		}
+	}
+	return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+}
+
+// GET /api/unwatched
+// GetUnwatchedMovies returns the movies no user has played, as JSON or as a CSV file with format=csv.
+// older_than and min_size filter them, sort and order sort them.
+func (h *Handler) GetUnwatchedMovies(ctx *gin.Context) {
+	h.writeMovieReport(ctx, "unwatched-movies.csv", h.serverService.UnwatchedMovies)
+}
+
+// GET /api/watched
+// GetWatchedMovies returns the movies every user has played, candidates to archive, as JSON or as a CSV file
+// with format=csv. older_than and min_size filter them, sort and order sort them.
+func (h *Handler) GetWatchedMovies(ctx *gin.Context) {
+	h.writeMovieReport(ctx, "watched-movies.csv", h.serverService.WatchedMovies)
+}
+
+// writeMovieReport parses the query of a movie report, builds it and writes it as JSON, or as a CSV file named
+// fileName with format=csv, formatted by the locale and date_format parameters
+func (h *Handler) writeMovieReport(ctx *gin.Context, fileName string,
+	build func(context.Context, LibraryReportQuery) (MovieReport, error)) {
+	format := ctx.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "format must be json or csv",
+		})
+		return
+	}
+	query, err := ParseLibraryReportQuery(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	export, err := ParseCSVExport(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	report, err := build(ctx.Request.Context(), query)
+	if err != nil {
+		logrus.Errorf("Error building movie report: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if format == "json" {
+		ctx.JSON(http.StatusOK, report)
+		return
+	}
+	writer := export.Start(ctx, fileName)
+	records := [][]string{{"movie_id", "name", "year", "library", "path", export.SizeHeader("size"), "added_at", "last_played"}}
+	for _, movie := range report.Movies {
+		records = append(records, []string{movie.ID, movie.Name, strconv.Itoa(movie.Year), movie.Library, movie.Path,
+			export.Size(movie.Size), export.Time(movie.AddedAt), export.Time(movie.LastPlayed)})
+	}
+	if err := writer.WriteAll(records); err != nil {
+		logrus.Errorf("Failed to write movie report: %v", err)
+	}
+}
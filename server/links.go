@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/filesystem"
+
+	"github.com/sirupsen/logrus"
+)
+
+// detectLink checks if both copies are the same file on disk, nil when they are different files,
+// when scan.detect_links is disabled or when a file cannot be read
+func (s *ServerService) detectLink(movie1, movie2 jellyfinModels.Movie) *jellyfinModels.FileLink {
+	if !s.liveConfig().Scan.DetectLinks || movie1.Path == "" || movie2.Path == "" {
+		return nil
+	}
+
+	path1, path2 := s.pathMapper.ToLocal(movie1.Path), s.pathMapper.ToLocal(movie2.Path)
+	link, linked, err := filesystem.DetectLink(path1, path2)
+	if err != nil {
+		logrus.Debugf("Failed to compare files of %s: %v", movie1.Name, err)
+		return nil
+	}
+	if !linked {
+		return nil
+	}
+
+	fileLink := &jellyfinModels.FileLink{Type: link.Type}
+	switch link.Symlink {
+	case path1:
+		fileLink.SymlinkID = movie1.ID
+	case path2:
+		fileLink.SymlinkID = movie2.ID
+	}
+	logrus.Debugf("Copies of %s are the same file (%s): %s and %s", movie1.Name, link.Type, movie1.Path, movie2.Path)
+	return fileLink
+}
+
+// checkLinkedDeletion refuses to delete the target of a symbolic link, which would break the link.
+// Deleting one of two hard links is allowed, it only frees no space.
+func checkLinkedDeletion(dup jellyfinModels.DuplicateResult, movie jellyfinModels.Movie) error {
+	if dup.Link == nil || dup.Link.Type != constants.SymbolicLink || dup.Link.SymlinkID == "" || dup.Link.SymlinkID == movie.ID {
+		return nil
+	}
+	return fmt.Errorf("the other copy is a symbolic link to this file, delete the link instead")
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	confModels "jellyfin-duplicate/configuration/models"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Servers serve the application on every listen address of the configuration
+type Servers struct {
+	servers []*http.Server
+	errors  chan error
+}
+
+// binding is an address served by a handler, over HTTPS when tlsConfig is set
+type binding struct {
+	address   string
+	handler   http.Handler
+	tlsConfig *tls.Config
+}
+
+// Listen serves handler on every listen address, over HTTPS when TLS is configured. With ACME, the challenges
+// are answered on their own address. Every address is bound before returning, so that an address in use is
+// reported right away.
+func Listen(config *confModels.Config, handler http.Handler) (*Servers, error) {
+	var tlsConfig *tls.Config
+	var bindings []binding
+	switch {
+	case config.TLS.ACME.Enabled():
+		manager := newCertificateManager(config.TLS.ACME)
+		tlsConfig = manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		// Other requests are redirected to HTTPS
+		bindings = append(bindings, binding{address: config.TLS.ACME.ChallengeAddress, handler: manager.HTTPHandler(nil)})
+	case config.TLS.Enabled():
+		certificate, err := tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	}
+	for _, address := range config.Listen {
+		bindings = append(bindings, binding{address: address, handler: handler, tlsConfig: tlsConfig})
+	}
+
+	listeners := make([]net.Listener, 0, len(bindings))
+	for _, binding := range bindings {
+		listener, err := net.Listen("tcp", binding.address)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %v", binding.address, err)
+		}
+		if binding.tlsConfig != nil {
+			listener = tls.NewListener(listener, binding.tlsConfig)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	servers := &Servers{errors: make(chan error, len(listeners))}
+	for i, listener := range listeners {
+		server := &http.Server{Handler: bindings[i].handler, TLSConfig: bindings[i].tlsConfig}
+		servers.servers = append(servers.servers, server)
+		go func() {
+			if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				servers.errors <- fmt.Errorf("failed to serve %s: %v", listener.Addr(), err)
+			}
+		}()
+	}
+	return servers, nil
+}
+
+// newCertificateManager provisions and renews the certificates of the configured domains, on the first
+// HTTPS request for each of them
+func newCertificateManager(config confModels.ACMEConfig) *autocert.Manager {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(config.CacheDir),
+		HostPolicy: autocert.HostWhitelist(config.Domains...),
+		Email:      config.Email,
+	}
+	if config.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: config.DirectoryURL}
+	}
+	return manager
+}
+
+// Errors receives the error of a server which stopped, the other ones keep serving
+func (s *Servers) Errors() <-chan error {
+	return s.errors
+}
+
+// Shutdown stops every server gracefully, waiting for the active requests until ctx is done
+func (s *Servers) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, server := range s.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// URLs returns the URL of the application on every listen address, with localhost for the addresses
+// of every interface, or the domains of the certificates provisioned through ACME
+func URLs(config *confModels.Config) []string {
+	scheme := "http"
+	if config.TLS.Enabled() {
+		scheme = "https"
+	}
+
+	urls := make([]string, 0, len(config.Listen))
+	for _, address := range config.Listen {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			// The addresses are validated when the configuration is loaded
+			continue
+		}
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host = "localhost"
+		}
+
+		hosts := []string{host}
+		if config.TLS.ACME.Enabled() {
+			hosts = config.TLS.ACME.Domains
+		}
+		for _, host := range hosts {
+			hostPort := net.JoinHostPort(host, port)
+			if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+				hostPort = host
+			}
+			urls = append(urls, fmt.Sprintf("%s://%s%s", scheme, hostPort, config.BasePath))
+		}
+	}
+	return urls
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"jellyfin-duplicate/logs"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// logLevels lists the levels accepted by the level query parameter, most severe first
+var logLevels = []logrus.Level{logrus.ErrorLevel, logrus.WarnLevel, logrus.InfoLevel, logrus.DebugLevel, logrus.TraceLevel}
+
+// streamHeartbeat is the interval of the keep-alive events of the event streams, so that proxies do not
+// close them while nothing happens
+const streamHeartbeat = 30 * time.Second
+
+// RegisterLogs exposes the recent log entries kept by the buffer in the /admin/logs page and under /api/logs
+func RegisterLogs(routes *gin.RouterGroup, handler *Handler, buffer *logs.Buffer) {
+	handler.logs = buffer
+	routes.GET("/admin/logs", handler.GetLogsPage)
+	routes.GET("/api/logs", handler.GetLogs)
+	routes.GET("/api/logs/stream", handler.StreamLogs)
+}
+
+// parseLogLevel reads the level query parameter, entries less severe are filtered out. Every entry is kept
+// by default, down to the configured log level.
+func parseLogLevel(ctx *gin.Context) (logrus.Level, error) {
+	value := ctx.Query("level")
+	if value == "" {
+		return logrus.TraceLevel, nil
+	}
+	level, err := logrus.ParseLevel(value)
+	if err != nil || level < logrus.ErrorLevel {
+		return level, fmt.Errorf("level must be error, warning, info, debug or trace")
+	}
+	return level, nil
+}
+
+// GET /admin/logs
+// GetLogsPage shows the recent log entries and follows the new ones, to debug failed scans without shell access
+func (h *Handler) GetLogsPage(ctx *gin.Context) {
+	level, err := parseLogLevel(ctx)
+	if err != nil {
+		ctx.HTML(http.StatusBadRequest, "error.html", h.templateData(ctx, gin.H{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	ctx.HTML(http.StatusOK, "logs.html", h.templateData(ctx, gin.H{
+		"entries":   h.logs.Entries(level),
+		"level":     level.String(),
+		"logLevels": logLevels,
+	}))
+}
+
+// GET /api/logs
+// GetLogs returns the recent log entries at the requested level or more severe, oldest first
+func (h *Handler) GetLogs(ctx *gin.Context) {
+	level, err := parseLogLevel(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"entries": h.logs.Entries(level),
+	})
+}
+
+// GET /api/logs/stream
+// StreamLogs sends the new log entries at the requested level or more severe as server-sent events
+func (h *Handler) StreamLogs(ctx *gin.Context) {
+	level, err := parseLogLevel(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	entries, unsubscribe := h.logs.Subscribe()
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	// Headers are sent right away for the browser to see the stream open, buffering proxies such as nginx
+	// would hold the events back
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	ctx.Stream(func(io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			ctx.SSEvent("ping", time.Now().Unix())
+		case entry := <-entries:
+			if entry.AtLeast(level) {
+				ctx.SSEvent("log", entry)
+			}
+		}
+		return true
+	})
+}
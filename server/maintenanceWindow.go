@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	storageModels "jellyfin-duplicate/storage/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNoMaintenanceWindow is returned when a deletion is scheduled while no maintenance window is configured
+var ErrNoMaintenanceWindow = errors.New("no maintenance window configured (deletion.maintenance_window)")
+
+// ScheduledDeletion is a deletion bulk action waiting for the maintenance window
+type ScheduledDeletion struct {
+	JobID  string               `json:"job_id"`
+	Action constants.BulkAction `json:"action"`
+	Groups int                  `json:"groups"`
+	// RunAt is the start of the window, RunBefore its end, absent when the window has no end
+	RunAt     time.Time  `json:"run_at"`
+	RunBefore *time.Time `json:"run_before,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// isDeletionAction checks if a bulk action deletes copies, and can be scheduled in the maintenance window
+func isDeletionAction(action constants.BulkAction) bool {
+	switch action {
+	case constants.DeleteLowerQualityAction, constants.KeepFirstAction, constants.KeepSecondAction,
+		constants.SyncThenDeleteAction:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextMaintenanceWindow returns the start and end of the window scheduled deletions run in: the current one
+// when it is open, starting now, otherwise the next one. The end is nil when the window has no end.
+func nextMaintenanceWindow(config confModels.MaintenanceWindowConfig, now time.Time) (time.Time, *time.Time, error) {
+	if !config.Enabled() {
+		return time.Time{}, nil, ErrNoMaintenanceWindow
+	}
+	location := time.Local
+	if config.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(config.Timezone); err != nil {
+			return time.Time{}, nil, fmt.Errorf("invalid timezone %s: %v", config.Timezone, err)
+		}
+	}
+
+	now = now.In(location)
+	start, err := atTimeOfDay(now, config.Start)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if config.End == "" {
+		if start.Before(now) {
+			start = start.AddDate(0, 0, 1)
+		}
+		return start, nil, nil
+	}
+
+	end, err := atTimeOfDay(start, config.End)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if !end.After(start) {
+		// The window goes over midnight, such as from 23:00 to 02:00
+		end = end.AddDate(0, 0, 1)
+	}
+	// The window opened yesterday may still be open
+	if yesterdayEnd := end.AddDate(0, 0, -1); now.Before(yesterdayEnd) {
+		return now, &yesterdayEnd, nil
+	}
+	if !now.Before(start) && now.Before(end) {
+		return now, &end, nil
+	}
+	if !now.Before(end) {
+		start, end = start.AddDate(0, 0, 1), end.AddDate(0, 0, 1)
+	}
+	return start, &end, nil
+}
+
+// atTimeOfDay returns the time of the day of day at an HH:MM time of day
+func atTimeOfDay(day time.Time, timeOfDay string) (time.Time, error) {
+	parsed, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day %s: must be HH:MM", timeOfDay)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, day.Location()), nil
+}
+
+// scheduleBulkAction queues a deletion bulk action for the maintenance window. The scan version is not checked
+// when the job runs, as scans run in the meantime: a fresh scan checks the groups are still duplicates instead.
+func (h *Handler) scheduleBulkAction(request BulkActionRequest) (storageModels.Job, error) {
+	if !isDeletionAction(request.Action) {
+		return storageModels.Job{}, fmt.Errorf("only deletions can be scheduled, not %s", request.Action)
+	}
+	runAt, runBefore, err := nextMaintenanceWindow(h.config.Deletion.MaintenanceWindow, time.Now())
+	if err != nil {
+		return storageModels.Job{}, err
+	}
+
+	request.ScanVersion = 0
+	return h.jobs.Schedule(constants.BulkActionJob, request, runAt, runBefore)
+}
+
+// scheduledDeletions lists the deletions waiting for the maintenance window, the next one first
+func (h *Handler) scheduledDeletions() []ScheduledDeletion {
+	deletions := make([]ScheduledDeletion, 0)
+	jobs := h.jobs.List()
+	// Jobs are listed most recent first
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := jobs[i]
+		if job.Type != constants.BulkActionJob || job.Status != constants.JobQueued || job.RunAt == nil {
+			continue
+		}
+		var request BulkActionRequest
+		if err := json.Unmarshal(job.Params, &request); err != nil {
+			continue
+		}
+		deletions = append(deletions, ScheduledDeletion{
+			JobID:     job.ID,
+			Action:    request.Action,
+			Groups:    len(request.GroupIDs),
+			RunAt:     *job.RunAt,
+			RunBefore: job.RunBefore,
+			CreatedAt: job.CreatedAt,
+		})
+	}
+	return deletions
+}
+
+// GET /api/deletions/scheduled
+// GetScheduledDeletions lists the deletions waiting for the maintenance window, cancelled through
+// POST /api/jobs/:id/cancel
+func (h *Handler) GetScheduledDeletions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"deletions": h.scheduledDeletions(),
+	})
+}
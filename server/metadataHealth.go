@@ -0,0 +1,213 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// metadataCheckWeight is the part of the metadata score of each of the 4 checks, out of 100
+	metadataCheckWeight = 25
+	// defaultMaxMetadataScore lists the movies failing at least one check, unless max_score is given
+	defaultMaxMetadataScore = 100 - metadataCheckWeight
+)
+
+// ignoredTitleWords are left out when looking for the title of a movie in its path, files often dropping them
+var ignoredTitleWords = map[string]bool{"a": true, "an": true, "the": true, "and": true}
+
+// MetadataHealth is the metadata completeness of a movie, computed by every scan
+type MetadataHealth struct {
+	MovieID string `json:"movie_id"`
+	Name    string `json:"name"`
+	Year    int    `json:"year"`
+	Library string `json:"library"`
+	Path    string `json:"path"`
+	// Score goes from 0, every check failed, to 100
+	Score  int                       `json:"score"`
+	Issues []constants.MetadataIssue `json:"issues"`
+	// IdentifyURL opens the movie in Jellyfin, whose Identify menu entry searches its metadata again
+	IdentifyURL string `json:"identify_url"`
+}
+
+// scoreMetadata checks that a movie has provider IDs, a year and a poster, and that its path contains its title
+func scoreMetadata(movie jellyfinModels.Movie) MetadataHealth {
+	health := MetadataHealth{
+		MovieID: movie.ID,
+		Name:    movie.Name,
+		Year:    movie.ProductionYear,
+		Library: movie.LibraryName,
+		Path:    movie.Path,
+		Issues:  []constants.MetadataIssue{},
+	}
+	if movie.ProviderIds.Tmdb == "" && movie.ProviderIds.Imdb == "" {
+		health.Issues = append(health.Issues, constants.MissingProviderIDs)
+	}
+	if movie.ProductionYear == 0 {
+		health.Issues = append(health.Issues, constants.MissingYear)
+	}
+	if movie.ImageTags["Primary"] == "" {
+		health.Issues = append(health.Issues, constants.MissingPoster)
+	}
+	if !pathMatchesTitle(movie.Path, movie.Name) {
+		health.Issues = append(health.Issues, constants.PathMismatch)
+	}
+	health.Score = 100 - len(health.Issues)*metadataCheckWeight
+	return health
+}
+
+// pathMatchesTitle checks if every word of a title is found in the names of the file or its folder, whatever
+// their case, order and accents
+func pathMatchesTitle(filePath, title string) bool {
+	// Jellyfin may run on Windows
+	filePath = strings.ReplaceAll(filePath, `\`, "/")
+	fileName := path.Base(filePath)
+	names := path.Base(path.Dir(filePath)) + " " + strings.TrimSuffix(fileName, path.Ext(fileName))
+
+	pathWords := make(map[string]bool)
+	for _, word := range titleWords(names) {
+		pathWords[word] = true
+	}
+	for _, word := range titleWords(title) {
+		if !ignoredTitleWords[word] && !pathWords[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// titleWords splits a name into lowercase words of letters and digits, without accents
+func titleWords(name string) []string {
+	unaccented, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil {
+		unaccented = name
+	}
+	return strings.FieldsFunc(strings.ToLower(unaccented), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// cacheMetadataHealth scores the movies fetched by the last scan. Home videos are left out, their titles being
+// file names.
+func (s *ServerService) cacheMetadataHealth(movies []jellyfinModels.Movie) {
+	health := make([]MetadataHealth, 0, len(movies))
+	for _, movie := range movies {
+		if !movie.HomeVideo {
+			health = append(health, scoreMetadata(movie))
+		}
+	}
+
+	s.metadataMutex.Lock()
+	defer s.metadataMutex.Unlock()
+	s.metadata = health
+}
+
+// MetadataHealth returns the movies of the last scan whose metadata score is at most maxScore, the lowest score
+// first, scanning when no scan ran yet
+func (s *ServerService) MetadataHealth(maxScore int) ([]MetadataHealth, error) {
+	s.metadataMutex.RLock()
+	health := s.metadata
+	s.metadataMutex.RUnlock()
+	if health == nil {
+		if _, err := s.Scan(); err != nil {
+			return nil, err
+		}
+		s.metadataMutex.RLock()
+		health = s.metadata
+		s.metadataMutex.RUnlock()
+	}
+
+	low := []MetadataHealth{}
+	for _, movie := range health {
+		if movie.Score <= maxScore {
+			movie.IdentifyURL = s.jellyfinItemURL(movie.MovieID)
+			low = append(low, movie)
+		}
+	}
+	sort.SliceStable(low, func(i, j int) bool {
+		if low[i].Score != low[j].Score {
+			return low[i].Score < low[j].Score
+		}
+		return strings.ToLower(low[i].Name) < strings.ToLower(low[j].Name)
+	})
+	return low, nil
+}
+
+// jellyfinItemURL returns the page of an item in the web client of Jellyfin
+func (s *ServerService) jellyfinItemURL(itemID string) string {
+	return fmt.Sprintf("%s/web/#/details?id=%s", strings.TrimSuffix(s.config.Jellyfin.URL, "/"), url.QueryEscape(itemID))
+}
+
+// parseMaxMetadataScore reads the max_score query parameter
+func parseMaxMetadataScore(ctx *gin.Context) (int, error) {
+	maxScore, err := strconv.Atoi(ctx.DefaultQuery("max_score", strconv.Itoa(defaultMaxMetadataScore)))
+	if err != nil || maxScore < 0 || maxScore > 100 {
+		return 0, fmt.Errorf("max_score must be a score between 0 and 100")
+	}
+	return maxScore, nil
+}
+
+// GET /metadata
+// GetMetadataPage lists the movies with incomplete metadata, with links to identify them in Jellyfin
+func (h *Handler) GetMetadataPage(ctx *gin.Context) {
+	maxScore, err := parseMaxMetadataScore(ctx)
+	if err != nil {
+		ctx.HTML(http.StatusBadRequest, "error.html", h.templateData(ctx, gin.H{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	movies, err := h.serverService.MetadataHealth(maxScore)
+	if err != nil {
+		logrus.Errorf("Error scoring metadata: %v", err)
+		ctx.HTML(http.StatusInternalServerError, "error.html", h.templateData(ctx, gin.H{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	ctx.HTML(http.StatusOK, "metadata.html", h.templateData(ctx, gin.H{
+		"movies":   movies,
+		"maxScore": maxScore,
+	}))
+}
+
+// GET /api/metadata/health
+// GetMetadataHealth returns the movies whose metadata score is at most max_score, 75 by default
+func (h *Handler) GetMetadataHealth(ctx *gin.Context) {
+	maxScore, err := parseMaxMetadataScore(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	movies, err := h.serverService.MetadataHealth(maxScore)
+	if err != nil {
+		logrus.Errorf("Error scoring metadata: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"movies":    movies,
+		"max_score": maxScore,
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/utils"
+	"sort"
+)
+
+// RenameSuggestion is the path a misnamed movie file should be moved to, following the Jellyfin naming rules
+type RenameSuggestion struct {
+	MovieID       string `json:"movie_id"`
+	Name          string `json:"name"`
+	Year          int    `json:"year"`
+	LibraryName   string `json:"library_name"`
+	CurrentPath   string `json:"current_path"`
+	SuggestedPath string `json:"suggested_path"`
+}
+
+// SuggestRename returns the rename suggestion of a movie, or an empty path when it is correctly named
+func SuggestRename(movie jellyfinModels.Movie) string {
+	suggestedPath, _ := utils.SuggestMoviePath(movie.Path, movie.Name, movie.ProductionYear)
+	return suggestedPath
+}
+
+// MismatchRenames lists the rename suggestions of the movies of potential mismatches. Mismatched
+// movies share a name and year while their files are named differently, one of them is often misnamed.
+func MismatchRenames(duplicates []jellyfinModels.DuplicateResult) []RenameSuggestion {
+	seen := make(map[string]bool)
+	var suggestions []RenameSuggestion
+	for _, dup := range duplicates {
+		if dup.IsDuplicate {
+			continue
+		}
+
+		for _, movie := range []jellyfinModels.Movie{dup.Movie1, dup.Movie2} {
+			if seen[movie.ID] {
+				continue
+			}
+			seen[movie.ID] = true
+
+			if suggestedPath := SuggestRename(movie); suggestedPath != "" {
+				suggestions = append(suggestions, RenameSuggestion{
+					MovieID:       movie.ID,
+					Name:          movie.Name,
+					Year:          movie.ProductionYear,
+					LibraryName:   movie.LibraryName,
+					CurrentPath:   movie.Path,
+					SuggestedPath: suggestedPath,
+				})
+			}
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].CurrentPath < suggestions[j].CurrentPath
+	})
+	return suggestions
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// OrphanedUserData is a watched entry of a user pointing at a movie which no longer exists, counted as seen by
+// reconciliation until it is cleaned up
+type OrphanedUserData struct {
+	// ID identifies the entry by user and movie, to clean it up
+	ID             string                 `json:"id"`
+	UserID         string                 `json:"user_id"`
+	UserName       string                 `json:"user_name"`
+	MovieID        string                 `json:"movie_id"`
+	MovieName      string                 `json:"movie_name"`
+	Path           string                 `json:"path"`
+	PlayCount      int                    `json:"play_count"`
+	LastPlayedDate string                 `json:"last_played_date,omitempty"`
+	Reason         constants.OrphanReason `json:"reason"`
+}
+
+// findOrphanedUserData compares the movies seen by each user with the movies of the scanned libraries and their
+// files. Movies missing from the libraries are only reported when every library is scanned, as the ones of the
+// other libraries cannot be told apart. Files are only checked when at least one of them is found, the media
+// folders being otherwise not accessible.
+func (s *ServerService) findOrphanedUserData(allMovies []jellyfinModels.Movie, userSeenMovies map[string][]jellyfinModels.Movie,
+	users []jellyfinModels.User) []OrphanedUserData {
+	inventory := lo.KeyBy(allMovies, func(movie jellyfinModels.Movie) string { return movie.ID })
+	checkInventory := len(s.config.Scan.Libraries) == 0
+
+	orphans := []OrphanedUserData{}
+	var watchedFiles []OrphanedUserData
+	fileExists := map[string]bool{}
+	for _, user := range users {
+		for _, seen := range userSeenMovies[user.ID] {
+			entry := OrphanedUserData{
+				ID:             user.ID + "_" + seen.ID,
+				UserID:         user.ID,
+				UserName:       user.Name,
+				MovieID:        seen.ID,
+				MovieName:      seen.Name,
+				Path:           seen.Path,
+				PlayCount:      seen.UserData.PlayCount,
+				LastPlayedDate: seen.UserData.LastPlayedDate,
+			}
+			movie, found := inventory[seen.ID]
+			switch {
+			case !found && checkInventory:
+				entry.Reason = constants.OrphanMissingItem
+				orphans = append(orphans, entry)
+			case found && movie.Path != "":
+				if _, checked := fileExists[movie.Path]; !checked {
+					// Errors other than a missing file, such as a denied access, do not tell the file is gone
+					_, err := os.Stat(s.pathMapper.ToLocal(movie.Path))
+					fileExists[movie.Path] = !errors.Is(err, os.ErrNotExist)
+				}
+				entry.Path = movie.Path
+				watchedFiles = append(watchedFiles, entry)
+			}
+		}
+	}
+
+	if lo.Contains(lo.Values(fileExists), true) {
+		for _, entry := range watchedFiles {
+			if !fileExists[entry.Path] {
+				entry.Reason = constants.OrphanMissingFile
+				orphans = append(orphans, entry)
+			}
+		}
+	} else if len(fileExists) > 0 {
+		logrus.Debug("No watched file found, media folders are not accessible: watched files are not checked")
+	}
+
+	if len(orphans) > 0 {
+		logrus.Warnf("Found %d watched entries pointing at missing movies", len(orphans))
+	}
+	return orphans
+}
+
+// cacheOrphanedUserData records the orphaned entries found by the last scan
+func (s *ServerService) cacheOrphanedUserData(orphans []OrphanedUserData) {
+	s.orphansMutex.Lock()
+	defer s.orphansMutex.Unlock()
+	s.orphans = orphans
+}
+
+// OrphanedUserData returns the orphaned entries found by the last scan, scanning when no scan ran yet
+func (s *ServerService) OrphanedUserData() ([]OrphanedUserData, error) {
+	s.orphansMutex.RLock()
+	orphans := s.orphans
+	s.orphansMutex.RUnlock()
+	if orphans != nil {
+		return orphans, nil
+	}
+
+	if _, err := s.Scan(); err != nil {
+		return nil, err
+	}
+	s.orphansMutex.RLock()
+	defer s.orphansMutex.RUnlock()
+	return s.orphans, nil
+}
+
+// CleanOrphanedUserData clears the played state of the orphaned entries with the given IDs, every entry found by
+// the last scan when none is given. Entries of movies Jellyfin no longer knows have nothing left to clear.
+// It returns the number of entries cleaned up, and the errors of the other ones.
+func (s *ServerService) CleanOrphanedUserData(ids []string) (int, []string, error) {
+	orphans, err := s.OrphanedUserData()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	cleaned := map[string]bool{}
+	errs := []string{}
+	for _, orphan := range orphans {
+		if len(ids) > 0 && !slices.Contains(ids, orphan.ID) {
+			continue
+		}
+		err := s.jellyfinClient.MarkMovieAsUnplayed(orphan.MovieID, orphan.UserID)
+		if errors.Is(err, jellyfinClients.ErrNotFound) {
+			s.pruneMissingItem(orphan.MovieID)
+			err = nil
+		}
+		if err != nil {
+			logrus.Errorf("Failed to clean up watched entry of %s for %s: %v", orphan.MovieName, orphan.UserName, err)
+			errs = append(errs, fmt.Sprintf("%s for %s: %v", orphan.MovieName, orphan.UserName, err))
+			continue
+		}
+		s.recordAudit(constants.ClearOrphanAudit, orphan.MovieID, orphan.MovieName, "", "for "+orphan.UserName)
+		cleaned[orphan.ID] = true
+	}
+
+	s.orphansMutex.Lock()
+	defer s.orphansMutex.Unlock()
+	s.orphans = lo.Filter(s.orphans, func(orphan OrphanedUserData, _ int) bool { return !cleaned[orphan.ID] })
+	return len(cleaned), errs, nil
+}
+
+// GET /api/orphans
+// GetOrphanedUserData lists the watched entries of users pointing at movies which no longer exist
+func (h *Handler) GetOrphanedUserData(ctx *gin.Context) {
+	orphans, err := h.serverService.OrphanedUserData()
+	if err != nil {
+		logrus.Errorf("Error finding orphaned watched entries: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"orphans": orphans,
+	})
+}
+
+// POST /api/orphans/cleanup
+// CleanOrphanedUserData clears the played state of orphaned watched entries, the ones listed by ID in the body
+// or all of them
+func (h *Handler) CleanOrphanedUserData(ctx *gin.Context) {
+	var request struct {
+		IDs []string `json:"ids"`
+	}
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid request: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	cleaned, errs, err := h.serverService.CleanOrphanedUserData(request.IDs)
+	if err != nil {
+		logrus.Errorf("Error cleaning up orphaned watched entries: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": len(errs) == 0,
+		"message": fmt.Sprintf("%d watched entries cleaned up", cleaned),
+		"cleaned": cleaned,
+		"errors":  errs,
+	})
+}
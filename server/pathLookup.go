@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/schema"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrPathNotFound is returned when no movie of Jellyfin has the looked up path
+var ErrPathNotFound = errors.New("no Jellyfin movie has this path")
+
+// PathLookup is the Jellyfin movie of a file, with its duplicate pairs
+type PathLookup struct {
+	// JellyfinPath is the path of the file as seen by Jellyfin, LocalPath as seen by this application
+	JellyfinPath string      `json:"jellyfin_path"`
+	LocalPath    string      `json:"local_path"`
+	Movie        schema.Item `json:"movie"`
+	// Groups are the pairs of the latest scan with the movie, empty when it has no duplicate
+	Groups []schema.Group `json:"groups"`
+	// ScanVersion is the version of the latest scan, 0 when no scan completed and the pairs are not known
+	ScanVersion int64 `json:"scan_version"`
+}
+
+// LookupPath finds the movie of a path, given as seen by Jellyfin or by this application through
+// deletion.path_mappings. The movies of the latest scan are searched first, then the libraries.
+// It never starts a scan.
+func (s *ServerService) LookupPath(ctx context.Context, path string) (PathLookup, error) {
+	candidates := []string{normalizeLookupPath(path), normalizeLookupPath(s.pathMapper.ToJellyfin(path))}
+	matches := func(movie jellyfinModels.Movie) bool {
+		moviePath := normalizeLookupPath(movie.Path)
+		return moviePath == candidates[0] || moviePath == candidates[1]
+	}
+
+	var movie *jellyfinModels.Movie
+	var duplicates []jellyfinModels.DuplicateResult
+	scan, scanned := s.scans.Latest()
+	for _, dup := range scan.Duplicates {
+		for _, candidate := range []jellyfinModels.Movie{dup.Movie1, dup.Movie2} {
+			if matches(candidate) {
+				movie = &candidate
+				duplicates = append(duplicates, dup)
+			}
+		}
+	}
+
+	if movie == nil {
+		movies, err := s.jellyfinClient.WithContext(ctx).GetAllMovies()
+		if err != nil {
+			return PathLookup{}, fmt.Errorf("failed to list the movies of Jellyfin: %v", err)
+		}
+		for i := range movies {
+			if matches(movies[i]) {
+				movie = &movies[i]
+				break
+			}
+		}
+	}
+	if movie == nil {
+		return PathLookup{}, ErrPathNotFound
+	}
+
+	groups, _ := schema.FromDuplicates(duplicates)
+	lookup := PathLookup{
+		JellyfinPath: movie.Path,
+		LocalPath:    s.pathMapper.ToLocal(movie.Path),
+		Movie:        schema.NewItem(*movie),
+		Groups:       groups,
+	}
+	if scanned {
+		lookup.ScanVersion = scan.Version
+	}
+	return lookup, nil
+}
+
+// normalizeLookupPath compares Windows and Unix paths alike, ignoring a trailing separator
+func normalizeLookupPath(path string) string {
+	return strings.TrimSuffix(strings.ReplaceAll(strings.TrimSpace(path), `\`, "/"), "/")
+}
+
+// GET /api/movies/by-path
+// GetMovieByPath returns the Jellyfin movie of the ?path= file and its duplicate pairs in the latest scan,
+// for scripts and for files reported by other tools
+func (h *Handler) GetMovieByPath(ctx *gin.Context) {
+	path := ctx.Query("path")
+	if strings.TrimSpace(path) == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "path is required",
+		})
+		return
+	}
+
+	lookup, err := h.serverService.LookupPath(ctx.Request.Context(), path)
+	switch {
+	case errors.Is(err, ErrPathNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusOK, lookup)
+	}
+}
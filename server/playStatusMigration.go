@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/jobs"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoSecondaryServer is returned when a cross-server operation is requested without secondary server
+var ErrNoSecondaryServer = errors.New("no secondary server configured")
+
+const (
+	PrimaryServer   = "primary"
+	SecondaryServer = "secondary"
+)
+
+// PlayStatusMigrationRequest is the parameters of a play status migration job
+type PlayStatusMigrationRequest struct {
+	// Source is the server the play status is copied from, primary (default) or secondary
+	Source string `json:"source"`
+	// Users are the names of the users to migrate, every user existing on both servers when empty
+	Users []string `json:"users"`
+	// DryRun only reports the changes, without applying them
+	DryRun bool `json:"dry_run"`
+}
+
+// Validate checks the request and fills its defaults
+func (r *PlayStatusMigrationRequest) Validate() error {
+	if r.Source == "" {
+		r.Source = PrimaryServer
+	}
+	if r.Source != PrimaryServer && r.Source != SecondaryServer {
+		return fmt.Errorf("source must be %s or %s", PrimaryServer, SecondaryServer)
+	}
+	return nil
+}
+
+// PlayStatusMigrationChange is the user data copied to a movie of the destination server
+type PlayStatusMigrationChange struct {
+	UserName      string `json:"user_name"`
+	MovieName     string `json:"movie_name"`
+	Year          int    `json:"year"`
+	DestinationID string `json:"destination_id"`
+	MarkPlayed    bool   `json:"mark_played,omitempty"`
+	MarkFavorite  bool   `json:"mark_favorite,omitempty"`
+	// PlaybackPositionTicks is the resume position to set, 0 when unchanged
+	PlaybackPositionTicks int64  `json:"playback_position_ticks,omitempty"`
+	Applied               bool   `json:"applied"`
+	Error                 string `json:"error,omitempty"`
+}
+
+// PlayStatusMigrationReport is the result of a play status migration job
+type PlayStatusMigrationReport struct {
+	DryRun        bool                        `json:"dry_run"`
+	Users         []string                    `json:"users"`
+	MatchedMovies int                         `json:"matched_movies"`
+	Applied       int                         `json:"applied"`
+	Failed        int                         `json:"failed"`
+	Changes       []PlayStatusMigrationChange `json:"changes"`
+}
+
+// HasSecondaryServer tells whether a secondary server is configured for cross-server operations
+func (s *ServerService) HasSecondaryServer() bool {
+	return s.secondaryClient != nil
+}
+
+// RunPlayStatusMigrationJob is the job runner of play status migrations
+func (s *ServerService) RunPlayStatusMigrationJob(ctx context.Context, params json.RawMessage, progress jobs.Progress) (any, error) {
+	var request PlayStatusMigrationRequest
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, fmt.Errorf("invalid play status migration parameters: %v", err)
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	report, err := s.MigratePlayStatus(ctx, request, progress)
+	if err != nil {
+		return report, err
+	}
+	if report.Failed > 0 {
+		return report, fmt.Errorf("%d of %d changes failed", report.Failed, len(report.Changes))
+	}
+	return report, nil
+}
+
+// MigratePlayStatus copies the watched state, favorites and playback positions of the selected users
+// from the source server to the destination server. Movies are matched by provider IDs, users by name.
+// User data is only ever added: nothing is marked as unplayed and positions only move forward.
+func (s *ServerService) MigratePlayStatus(ctx context.Context, request PlayStatusMigrationRequest, progress jobs.Progress) (PlayStatusMigrationReport, error) {
+	report := PlayStatusMigrationReport{DryRun: request.DryRun}
+	if s.secondaryClient == nil {
+		return report, ErrNoSecondaryServer
+	}
+	if _, err := s.secondaryClient.DetectServerVersion(); err != nil {
+		logrus.Warnf("Failed to detect secondary server version: %v", err)
+	}
+
+	source, destination := s.jellyfinClient, s.secondaryClient
+	if request.Source == SecondaryServer {
+		source, destination = destination, source
+	}
+
+	userPairs, err := migrationUsers(source, destination, request.Users)
+	if err != nil {
+		return report, err
+	}
+
+	// Plan every change first, so that progress reflects the work to do
+	for _, users := range userPairs {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		changes, matched, err := planPlayStatusMigration(source, destination, users[0], users[1])
+		if err != nil {
+			return report, err
+		}
+		report.Users = append(report.Users, users[0].Name)
+		report.MatchedMovies = max(report.MatchedMovies, matched)
+		report.Changes = append(report.Changes, changes...)
+	}
+	logrus.Infof("Play status migration from the %s server: %d changes for %d users (dry run: %t)",
+		request.Source, len(report.Changes), len(userPairs), request.DryRun)
+
+	if request.DryRun {
+		return report, nil
+	}
+
+	userIDs := make(map[string]string, len(userPairs))
+	for _, users := range userPairs {
+		userIDs[users[0].Name] = users[1].ID
+	}
+
+	for index := range report.Changes {
+		if err := ctx.Err(); err != nil {
+			logrus.Infof("Play status migration cancelled after %d of %d changes", index, len(report.Changes))
+			return report, err
+		}
+		if progress != nil {
+			progress(index, len(report.Changes))
+		}
+
+		change := &report.Changes[index]
+		if err := applyPlayStatusChange(destination, userIDs[change.UserName], *change); err != nil {
+			logrus.Warnf("Failed to migrate play status of %s for %s: %v", change.MovieName, change.UserName, err)
+			change.Error = err.Error()
+			report.Failed++
+			continue
+		}
+		change.Applied = true
+		report.Applied++
+	}
+
+	if progress != nil {
+		progress(len(report.Changes), len(report.Changes))
+	}
+	return report, nil
+}
+
+// migrationUsers pairs the users of both servers by name, restricted to the selected names when given
+func migrationUsers(source, destination *jellyfinClients.Client, names []string) ([][2]jellyfinModels.User, error) {
+	sourceUsers, err := source.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users of the source server: %v", err)
+	}
+	destinationUsers, err := destination.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users of the destination server: %v", err)
+	}
+
+	destinationByName := lo.KeyBy(destinationUsers, func(user jellyfinModels.User) string {
+		return strings.ToLower(user.Name)
+	})
+	restricted := len(names) > 0
+	selected := lo.SliceToMap(names, func(name string) (string, bool) {
+		return strings.ToLower(name), true
+	})
+
+	var pairs [][2]jellyfinModels.User
+	for _, user := range sourceUsers {
+		name := strings.ToLower(user.Name)
+		destinationUser, exists := destinationByName[name]
+		if restricted && !selected[name] {
+			continue
+		}
+		if !exists {
+			if restricted {
+				return nil, fmt.Errorf("user %s does not exist on the destination server", user.Name)
+			}
+			continue
+		}
+		delete(selected, name)
+		pairs = append(pairs, [2]jellyfinModels.User{user, destinationUser})
+	}
+
+	for name := range selected {
+		return nil, fmt.Errorf("user %s does not exist on the source server", name)
+	}
+	return pairs, nil
+}
+
+// planPlayStatusMigration lists the user data to copy for a user. It returns the changes and the number of matched movies.
+func planPlayStatusMigration(source, destination *jellyfinClients.Client, sourceUser, destinationUser jellyfinModels.User) ([]PlayStatusMigrationChange, int, error) {
+	sourceMovies, err := source.GetUserMovies(sourceUser.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get movies of user %s on the source server: %v", sourceUser.Name, err)
+	}
+	destinationMovies, err := destination.GetUserMovies(destinationUser.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get movies of user %s on the destination server: %v", destinationUser.Name, err)
+	}
+
+	// Only provider IDs are reliable across servers, movies without them are not migrated
+	index := make(map[string]jellyfinModels.Movie)
+	for _, movie := range destinationMovies {
+		for _, key := range providerKeys(movie) {
+			index[key] = movie
+		}
+	}
+
+	var changes []PlayStatusMigrationChange
+	matched := 0
+	for _, movie := range sourceMovies {
+		target, found := jellyfinModels.Movie{}, false
+		for _, key := range providerKeys(movie) {
+			if target, found = index[key]; found {
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		matched++
+
+		change := PlayStatusMigrationChange{
+			UserName:      sourceUser.Name,
+			MovieName:     movie.Name,
+			Year:          movie.ProductionYear,
+			DestinationID: target.ID,
+			MarkPlayed:    movie.UserData.Played && !target.UserData.Played,
+			MarkFavorite:  movie.UserData.IsFavorite && !target.UserData.IsFavorite,
+		}
+		if !movie.UserData.Played && !target.UserData.Played &&
+			movie.UserData.PlaybackPositionTicks > target.UserData.PlaybackPositionTicks {
+			change.PlaybackPositionTicks = movie.UserData.PlaybackPositionTicks
+		}
+
+		if change.MarkPlayed || change.MarkFavorite || change.PlaybackPositionTicks > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes, matched, nil
+}
+
+// providerKeys lists the provider IDs a movie is matched by across servers
+func providerKeys(movie jellyfinModels.Movie) []string {
+	return lo.Filter(lo.Map(matchKeys(movie), func(key matchKey, _ int) string {
+		return lo.Ternary(key.kind != "name", key.value, "")
+	}), func(key string, _ int) bool {
+		return key != ""
+	})
+}
+
+// applyPlayStatusChange writes a planned change to the destination server
+func applyPlayStatusChange(destination *jellyfinClients.Client, userID string, change PlayStatusMigrationChange) error {
+	if change.MarkPlayed {
+		if err := destination.MarkMovieAsPlayed(change.DestinationID, userID, change.MovieName, change.UserName); err != nil {
+			return err
+		}
+	}
+	if change.MarkFavorite {
+		if err := destination.MarkMovieAsFavorite(change.DestinationID, userID); err != nil {
+			return err
+		}
+	}
+	if change.PlaybackPositionTicks > 0 {
+		if err := destination.SetPlaybackPosition(change.DestinationID, userID, change.PlaybackPositionTicks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
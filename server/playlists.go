@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// PlaylistIndex maps the ID of movies to the playlists referencing them
+type PlaylistIndex map[string][]jellyfinModels.Playlist
+
+// loadPlaylistIndex reads every playlist, an empty index is returned when playlists are unavailable. The requests
+// are aborted when ctx is cancelled.
+func (s *ServerService) loadPlaylistIndex(ctx context.Context) PlaylistIndex {
+	index := make(PlaylistIndex)
+	client := s.jellyfinClient.WithContext(ctx)
+
+	playlists, err := client.GetPlaylists()
+	if err != nil {
+		// Playlists only add information, the scan goes on without them
+		logrus.Warnf("Failed to get playlists, skipping playlist references: %v", err)
+		return index
+	}
+
+	for _, playlist := range playlists {
+		entries, err := client.GetPlaylistEntries(playlist.ID)
+		if err != nil {
+			logrus.Warnf("Failed to get items of playlist %s: %v", playlist.Name, err)
+			continue
+		}
+
+		// A movie listed several times is referenced once
+		for _, movieID := range lo.Uniq(lo.Map(entries, func(entry jellyfinModels.PlaylistEntry, _ int) string {
+			return entry.ID
+		})) {
+			index[movieID] = append(index[movieID], playlist)
+		}
+	}
+
+	logrus.Infof("Indexed %d playlists", len(playlists))
+	return index
+}
+
+// playlistEntryRef is an entry of a movie about to be deleted in a playlist, and its position
+type playlistEntryRef struct {
+	playlist jellyfinModels.Playlist
+	entry    jellyfinModels.PlaylistEntry
+	position int
+}
+
+// findPlaylistEntries returns the entries of a movie to be replaced by the kept copy, one per playlist, before
+// the movie is deleted: Jellyfin removes the entries of deleted items from playlists, losing their positions.
+// Playlists already containing the kept copy are left out, the deleted entry disappears with the movie.
+// Nothing is changed, so that a failed deletion leaves the playlists as they were.
+func (s *ServerService) findPlaylistEntries(movieID, keptID string) ([]playlistEntryRef, error) {
+	playlists, err := s.jellyfinClient.GetPlaylists()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []playlistEntryRef
+	for _, playlist := range playlists {
+		entries, err := s.jellyfinClient.GetPlaylistEntries(playlist.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		position := lo.IndexOf(lo.Map(entries, func(entry jellyfinModels.PlaylistEntry, _ int) string {
+			return entry.ID
+		}), movieID)
+		if position < 0 || lo.ContainsBy(entries, func(entry jellyfinModels.PlaylistEntry) bool {
+			return entry.ID == keptID
+		}) {
+			continue
+		}
+		refs = append(refs, playlistEntryRef{playlist: playlist, entry: entries[position], position: position})
+	}
+
+	return refs, nil
+}
+
+// repointPlaylists puts the kept copy at the position the entries of the deleted movie had, once it is deleted.
+// The movie is gone whatever happens here, a failure leaving the playlist without it is only logged.
+func (s *ServerService) repointPlaylists(movieID, keptID string, refs []playlistEntryRef) {
+	for _, ref := range refs {
+		if err := s.repointEntry(ref.playlist, ref.entry, ref.position, keptID); err != nil {
+			logrus.Errorf("Failed to reference %s instead of %s in playlist %s: %v", keptID, movieID, ref.playlist.Name, err)
+			continue
+		}
+		logrus.Infof("Playlist %s now references %s instead of %s", ref.playlist.Name, keptID, movieID)
+		s.recordAudit(constants.RepointPlaylistAudit, movieID, ref.entry.Name, s.config.Jellyfin.UserID,
+			fmt.Sprintf("playlist %s now references %s", ref.playlist.Name, keptID))
+	}
+}
+
+// repointEntry adds the kept copy to the playlist and moves it to the position of the entry, which is removed
+// when still there: Jellyfin only drops it once it notices the deletion, after a trashed file for instance
+func (s *ServerService) repointEntry(playlist jellyfinModels.Playlist, entry jellyfinModels.PlaylistEntry, position int, keptID string) error {
+	if err := s.jellyfinClient.AddToPlaylist(playlist.ID, keptID); err != nil {
+		return err
+	}
+
+	entries, err := s.jellyfinClient.GetPlaylistEntries(playlist.ID)
+	if err != nil {
+		return err
+	}
+	added, found := lo.Find(entries, func(candidate jellyfinModels.PlaylistEntry) bool {
+		return candidate.ID == keptID
+	})
+	if !found {
+		return fmt.Errorf("kept copy %s not found in playlist %s after being added", keptID, playlist.Name)
+	}
+
+	if err := s.jellyfinClient.MovePlaylistEntry(playlist.ID, added.PlaylistItemID, position); err != nil {
+		return err
+	}
+	if !lo.ContainsBy(entries, func(candidate jellyfinModels.PlaylistEntry) bool {
+		return candidate.PlaylistItemID == entry.PlaylistItemID
+	}) {
+		return nil
+	}
+	return s.jellyfinClient.RemoveFromPlaylist(playlist.ID, entry.PlaylistItemID)
+}
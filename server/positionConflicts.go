@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	storageModels "jellyfin-duplicate/storage/models"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// applyPlaybackPositions sets the playback position of the users who stopped watching a movie before the end
+func applyPlaybackPositions(movies []jellyfinModels.Movie, userResumableMovies map[string][]jellyfinModels.Movie) {
+	positions := make(map[string]map[string]int64, len(userResumableMovies))
+	for userID, resumable := range userResumableMovies {
+		positions[userID] = make(map[string]int64, len(resumable))
+		for _, movie := range resumable {
+			positions[userID][movie.ID] = movie.UserData.PlaybackPositionTicks
+		}
+	}
+
+	for i := range movies {
+		for j, status := range movies[i].UserPlayStatuses {
+			movies[i].UserPlayStatuses[j].PlaybackPositionTicks = positions[status.UserID][movies[i].ID]
+		}
+	}
+}
+
+// positionConflicts lists the users who stopped watching both copies at different positions
+func positionConflicts(movie1, movie2 jellyfinModels.Movie) []jellyfinModels.PositionConflict {
+	movie2Statuses := lo.KeyBy(movie2.UserPlayStatuses, func(status jellyfinModels.UserPlayStatus) string { return status.UserID })
+
+	var conflicts []jellyfinModels.PositionConflict
+	for _, status := range movie1.UserPlayStatuses {
+		other := movie2Statuses[status.UserID]
+		if status.PlaybackPositionTicks > 0 && other.PlaybackPositionTicks > 0 && status.PlaybackPositionTicks != other.PlaybackPositionTicks {
+			conflicts = append(conflicts, jellyfinModels.PositionConflict{
+				UserID:              status.UserID,
+				UserName:            status.UserName,
+				Movie1PositionTicks: status.PlaybackPositionTicks,
+				Movie2PositionTicks: other.PlaybackPositionTicks,
+			})
+		}
+	}
+	return conflicts
+}
+
+// checkPositionChoice checks that the copy whose positions are kept is chosen for a pair with position conflicts
+func checkPositionChoice(dup jellyfinModels.DuplicateResult, keepPositionOf string) error {
+	if !dup.HasPositionConflict {
+		return nil
+	}
+	if keepPositionOf == "" {
+		userNames := lo.Map(dup.PositionConflicts, func(conflict jellyfinModels.PositionConflict, _ int) string { return conflict.UserName })
+		return fmt.Errorf("both copies are partially watched by %s, choose the copy whose position to keep", strings.Join(userNames, ", "))
+	}
+	if keepPositionOf != dup.Movie1.ID && keepPositionOf != dup.Movie2.ID {
+		return fmt.Errorf("the copy whose position to keep is not a copy of the pair")
+	}
+	return nil
+}
+
+// syncPositions sets the position of each conflicted user on the copy keepPositionOf to the other copy,
+// recording the previous play status in the journal before changing it
+func (s *ServerService) syncPositions(dup jellyfinModels.DuplicateResult, keepPositionOf string, journal *storageModels.ActionJournal) error {
+	target := dup.Movie1
+	if target.ID == keepPositionOf {
+		target = dup.Movie2
+	}
+
+	for _, conflict := range dup.PositionConflicts {
+		position := conflict.Movie1PositionTicks
+		if keepPositionOf == dup.Movie2.ID {
+			position = conflict.Movie2PositionTicks
+		}
+
+		previous, err := s.jellyfinClient.GetUserItemData(target.ID, conflict.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to record play status for user %s: %v", conflict.UserName, err)
+		}
+		journal.Entries = append(journal.Entries, storageModels.PlayStateEntry{
+			MovieID:               target.ID,
+			MovieName:             target.Name,
+			UserID:                conflict.UserID,
+			UserName:              conflict.UserName,
+			Played:                previous.Played,
+			PlaybackPositionTicks: previous.PlaybackPositionTicks,
+			PlayCount:             previous.PlayCount,
+			LastPlayedDate:        previous.LastPlayedDate,
+			Change:                constants.SetPositionAudit,
+		})
+
+		if err := s.jellyfinClient.SetPlaybackPosition(target.ID, conflict.UserID, position); err != nil {
+			return fmt.Errorf("failed to sync playback position for user %s: %v", conflict.UserName, err)
+		}
+		// Play status is written on behalf of the admin user, operators only resolve items
+		s.recordAudit(constants.SetPositionAudit, target.ID, target.Name, s.config.Jellyfin.UserID,
+			fmt.Sprintf("for %s, at %s", conflict.UserName, formatTicks(position)))
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// sortKeys lists the values accepted by the sort query parameter
+var sortKeys = []string{"name", "similarity", "size", "year", "library", "severity", "users_affected"}
+
+// columnKeys lists the optional columns of the duplicates page
+var columnKeys = []string{"path", "year", "similarity", "size", "library", "tracks", "play_status", "dates"}
+
+// DuplicateQuery holds the search, media filters, sort and pagination parameters of duplicate listings
+type DuplicateQuery struct {
+	Search string
+	// Resolution, Codec and HDR filter the pairs on the video track of their copies, ignored when empty
+	Resolution constants.Resolution
+	Codec      string
+	HDR        *bool
+	// BothCopies requires both copies to match the media filters, instead of at least one
+	BothCopies bool
+	// MinSize keeps the pairs freeing at least this many megabytes when a copy is deleted, ignored when 0
+	MinSize  int64
+	Sort     string
+	Order    string
+	Page     int
+	PageSize int
+	// Severities keeps the pairs of these severities, all when empty
+	Severities []constants.Severity
+	// MinUsersAffected keeps the pairs with discrepancies for at least this many users, ignored when 0
+	MinUsersAffected int
+}
+
+// DuplicatePage is a page of duplicate results
+type DuplicatePage struct {
+	Items      []jellyfinModels.DuplicateResult `json:"items"`
+	Total      int                              `json:"total"`
+	Page       int                              `json:"page"`
+	PageSize   int                              `json:"page_size"`
+	TotalPages int                              `json:"total_pages"`
+	// ScanVersion identifies the scan the results come from, to be sent back with actions
+	ScanVersion int64 `json:"scan_version"`
+	// Warnings lists the groups whose pairs were not all compared, above MaxPairsPerGroup
+	Warnings         []ScanWarning `json:"warnings"`
+	MaxPairsPerGroup int           `json:"max_pairs_per_group"`
+	// AsUser is the view of the movies of the page by the user requested with asUser
+	AsUser *UserView `json:"as_user,omitempty"`
+}
+
+// ParseDuplicateQuery reads and validates the query parameters q, resolution, codec, hdr, match, min_size, severity,
+// min_users_affected, sort, order, page and page_size
+func ParseDuplicateQuery(ctx *gin.Context) (DuplicateQuery, error) {
+	query := DuplicateQuery{
+		Search:     strings.TrimSpace(ctx.Query("q")),
+		Resolution: constants.Resolution(strings.ToLower(ctx.Query("resolution"))),
+		Codec:      strings.ToLower(strings.TrimSpace(ctx.Query("codec"))),
+		Sort:       ctx.DefaultQuery("sort", "name"),
+		Order:      ctx.DefaultQuery("order", "asc"),
+		Page:       1,
+		PageSize:   defaultPageSize,
+	}
+
+	if query.Resolution != constants.UnknownResolution && !lo.Contains(constants.Resolutions, query.Resolution) {
+		return query, fmt.Errorf("resolution must be one of %s", strings.Join(lo.Map(constants.Resolutions,
+			func(resolution constants.Resolution, _ int) string { return string(resolution) }), ", "))
+	}
+
+	if hdr := ctx.Query("hdr"); hdr != "" {
+		value, err := strconv.ParseBool(hdr)
+		if err != nil {
+			return query, fmt.Errorf("hdr must be true or false")
+		}
+		query.HDR = &value
+	}
+
+	switch ctx.DefaultQuery("match", "any") {
+	case "any":
+	case "both":
+		query.BothCopies = true
+	default:
+		return query, fmt.Errorf("match must be any or both")
+	}
+
+	if minSize := ctx.Query("min_size"); minSize != "" {
+		value, err := strconv.ParseInt(minSize, 10, 64)
+		if err != nil || value < 0 {
+			return query, fmt.Errorf("min_size must be a positive number of megabytes")
+		}
+		query.MinSize = value
+	}
+
+	for _, value := range ctx.QueryArray("severity") {
+		for _, severity := range strings.Split(value, ",") {
+			if severity = strings.ToLower(strings.TrimSpace(severity)); severity == "" {
+				continue
+			}
+			if !constants.IsValidSeverity(constants.Severity(severity)) {
+				return query, fmt.Errorf("severity must be one of %s", strings.Join(lo.Map(constants.Severities,
+					func(severity constants.Severity, _ int) string { return string(severity) }), ", "))
+			}
+			query.Severities = append(query.Severities, constants.Severity(severity))
+		}
+	}
+
+	if minUsers := ctx.Query("min_users_affected"); minUsers != "" {
+		value, err := strconv.Atoi(minUsers)
+		if err != nil || value < 0 {
+			return query, fmt.Errorf("min_users_affected must be a positive number of users")
+		}
+		query.MinUsersAffected = value
+	}
+
+	if !lo.Contains(sortKeys, query.Sort) {
+		return query, fmt.Errorf("sort must be one of %s", strings.Join(sortKeys, ", "))
+	}
+
+	if query.Order != "asc" && query.Order != "desc" {
+		return query, fmt.Errorf("order must be asc or desc")
+	}
+
+	if page := ctx.Query("page"); page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil || value < 1 {
+			return query, fmt.Errorf("page must be a positive integer")
+		}
+		query.Page = value
+	}
+
+	if pageSize := ctx.Query("page_size"); pageSize != "" {
+		value, err := strconv.Atoi(pageSize)
+		if err != nil || value < 1 || value > maxPageSize {
+			return query, fmt.Errorf("page_size must be between 1 and %d", maxPageSize)
+		}
+		query.PageSize = value
+	}
+
+	return query, nil
+}
+
+// Apply filters, sorts and paginates duplicate results
+func (q DuplicateQuery) Apply(duplicates []jellyfinModels.DuplicateResult) DuplicatePage {
+	filtered := lo.Filter(duplicates, func(dup jellyfinModels.DuplicateResult, _ int) bool {
+		return q.matches(dup)
+	})
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if q.Order == "desc" {
+			return q.less(filtered[j], filtered[i])
+		}
+		return q.less(filtered[i], filtered[j])
+	})
+
+	total := len(filtered)
+	totalPages := (total + q.PageSize - 1) / q.PageSize
+	start := min((q.Page-1)*q.PageSize, total)
+	end := min(start+q.PageSize, total)
+
+	return DuplicatePage{
+		Items:      filtered[start:end],
+		Total:      total,
+		Page:       q.Page,
+		PageSize:   q.PageSize,
+		TotalPages: totalPages,
+	}
+}
+
+// Values returns the query as URL values, omitting defaults, to build links to other pages
+func (q DuplicateQuery) Values(page int) url.Values {
+	values := url.Values{}
+	if q.Search != "" {
+		values.Set("q", q.Search)
+	}
+	if q.Resolution != constants.UnknownResolution {
+		values.Set("resolution", string(q.Resolution))
+	}
+	if q.Codec != "" {
+		values.Set("codec", q.Codec)
+	}
+	if q.HDR != nil {
+		values.Set("hdr", strconv.FormatBool(*q.HDR))
+	}
+	if q.BothCopies {
+		values.Set("match", "both")
+	}
+	if q.MinSize > 0 {
+		values.Set("min_size", strconv.FormatInt(q.MinSize, 10))
+	}
+	for _, severity := range q.Severities {
+		values.Add("severity", string(severity))
+	}
+	if q.MinUsersAffected > 0 {
+		values.Set("min_users_affected", strconv.Itoa(q.MinUsersAffected))
+	}
+	if q.Sort != "name" {
+		values.Set("sort", q.Sort)
+	}
+	if q.Order != "asc" {
+		values.Set("order", q.Order)
+	}
+	if q.PageSize != defaultPageSize {
+		values.Set("page_size", strconv.Itoa(q.PageSize))
+	}
+	if page > 1 {
+		values.Set("page", strconv.Itoa(page))
+	}
+	return values
+}
+
+// matches checks the reclaimable size, the severity, the users affected and the media filters, then if the
+// search term appears in the name or path of either movie
+func (q DuplicateQuery) matches(dup jellyfinModels.DuplicateResult) bool {
+	if q.MinSize > 0 && dup.ReclaimableSize < q.MinSize*bytesPerMegabyte {
+		return false
+	}
+	if dup.UsersAffected < q.MinUsersAffected {
+		return false
+	}
+	if len(q.Severities) > 0 && !lo.Contains(q.Severities, dup.Severity) {
+		return false
+	}
+
+	first, second := q.matchesMedia(dup.Movie1), q.matchesMedia(dup.Movie2)
+	matched := first || second
+	if q.BothCopies {
+		matched = first && second
+	}
+	if !matched {
+		return false
+	}
+
+	if q.Search == "" {
+		return true
+	}
+
+	search := strings.ToLower(q.Search)
+	for _, movie := range []jellyfinModels.Movie{dup.Movie1, dup.Movie2} {
+		if strings.Contains(strings.ToLower(movie.Name), search) || strings.Contains(strings.ToLower(movie.Path), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMedia checks the video track of a copy against the media filters
+func (q DuplicateQuery) matchesMedia(movie jellyfinModels.Movie) bool {
+	if q.Resolution != constants.UnknownResolution && movie.Resolution() != q.Resolution {
+		return false
+	}
+	if q.Codec != "" && movie.VideoCodec() != q.Codec {
+		return false
+	}
+	if q.HDR != nil && movie.IsHDR() != *q.HDR {
+		return false
+	}
+	return true
+}
+
+// less compares two duplicates on the sort key, falling back to the name for a stable order
+func (q DuplicateQuery) less(a, b jellyfinModels.DuplicateResult) bool {
+	switch q.Sort {
+	case "similarity":
+		if a.Similarity != b.Similarity {
+			return a.Similarity < b.Similarity
+		}
+	case "size":
+		sizeA := a.Movie1.Size() + a.Movie2.Size()
+		sizeB := b.Movie1.Size() + b.Movie2.Size()
+		if sizeA != sizeB {
+			return sizeA < sizeB
+		}
+	case "year":
+		if a.Movie1.ProductionYear != b.Movie1.ProductionYear {
+			return a.Movie1.ProductionYear < b.Movie1.ProductionYear
+		}
+	case "library":
+		if a.Movie1.LibraryName != b.Movie1.LibraryName {
+			return a.Movie1.LibraryName < b.Movie1.LibraryName
+		}
+	case "severity":
+		if rankA, rankB := severityRank(a.Severity), severityRank(b.Severity); rankA != rankB {
+			return rankA < rankB
+		}
+	case "users_affected":
+		if a.UsersAffected != b.UsersAffected {
+			return a.UsersAffected < b.UsersAffected
+		}
+	}
+	return strings.ToLower(a.Movie1.Name) < strings.ToLower(b.Movie1.Name)
+}
+
+// ParseColumns reads the comma separated list of visible columns, defaulting to all columns
+func ParseColumns(ctx *gin.Context) map[string]bool {
+	columns := make(map[string]bool)
+
+	requested := ctx.QueryArray("columns")
+	if len(requested) == 0 {
+		for _, column := range columnKeys {
+			columns[column] = true
+		}
+		return columns
+	}
+
+	for _, value := range requested {
+		for _, column := range strings.Split(value, ",") {
+			if lo.Contains(columnKeys, column) {
+				columns[column] = true
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+)
+
+// bytesPerMegabyte converts the size thresholds of the configuration and of the query parameters,
+// in binary megabytes like the sizes displayed
+const bytesPerMegabyte = 1024 * 1024
+
+// reclaimableSize is the space freed by deleting a copy of the pair: the recommended copy when set, otherwise
+// the smallest one. Linked copies are a single file, deleting one frees nothing.
+func reclaimableSize(movie1, movie2 jellyfinModels.Movie, recommendedDeleteID string, link *jellyfinModels.FileLink) int64 {
+	switch {
+	case link != nil:
+		return 0
+	case recommendedDeleteID == movie1.ID:
+		return movie1.Size()
+	case recommendedDeleteID == movie2.ID:
+		return movie2.Size()
+	}
+	return min(movie1.Size(), movie2.Size())
+}
+
+// belowMinReclaimableSize checks the size freed by a pair against deletion.min_reclaimable_size.
+// Unknown sizes are never below the threshold, they are left to the user's judgement.
+func (s *ServerService) belowMinReclaimableSize(size int64) bool {
+	threshold := s.liveConfig().Deletion.MinReclaimableSize
+	return threshold > 0 && size > 0 && size < threshold*bytesPerMegabyte
+}
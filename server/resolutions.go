@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	storageModels "jellyfin-duplicate/storage/models"
+	"strings"
+	"time"
+)
+
+// resolveManually records a pair cleaned up outside of the application, leaving it out of the results.
+// Jellyfin is not called: the files were already handled, on the filesystem for instance.
+func (s *ServerService) resolveManually(dup jellyfinModels.DuplicateResult, note string) (string, error) {
+	err := s.store.SaveResolution(storageModels.Resolution{
+		ID:          dup.ID,
+		Fingerprint: PairFingerprint(dup.Movie1, dup.Movie2),
+		Movie1ID:    dup.Movie1.ID,
+		Movie2ID:    dup.Movie2.ID,
+		MovieName:   dup.Movie1.Name,
+		Movie1Path:  dup.Movie1.Path,
+		Movie2Path:  dup.Movie2.Path,
+		Reason:      strings.TrimSpace(note),
+		ResolvedAt:  time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to record resolution: %v", err)
+	}
+
+	return "group resolved manually", nil
+}
+
+// Resolutions returns the pairs resolved outside of the application, most recent first
+func (s *ServerService) Resolutions() []storageModels.Resolution {
+	return s.store.Resolutions()
+}
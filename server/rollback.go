@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	storageModels "jellyfin-duplicate/storage/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// actionsKept is the number of rollback journals kept, the oldest ones are dropped
+const actionsKept = 50
+
+var (
+	ErrActionNotFound   = errors.New("action not found")
+	ErrActionRolledBack = errors.New("action already rolled back")
+)
+
+// RollbackReport is the result of a rollback
+type RollbackReport struct {
+	ActionID string   `json:"action_id"`
+	Restored int      `json:"restored"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// RollbackAction restores the play status recorded before a bulk action, in reverse order.
+// Entries that fail are reported and the action can be rolled back again to retry them.
+func (s *ServerService) RollbackAction(id string) (RollbackReport, error) {
+	report := RollbackReport{ActionID: id}
+
+	journal, found := s.store.Action(id)
+	if !found {
+		return report, fmt.Errorf("%w: %s", ErrActionNotFound, id)
+	}
+	if journal.RolledBackAt != nil {
+		return report, fmt.Errorf("%w at %s", ErrActionRolledBack, journal.RolledBackAt.Format(time.RFC3339))
+	}
+
+	for i := len(journal.Entries) - 1; i >= 0; i-- {
+		entry := journal.Entries[i]
+		if err := s.restorePlayStatus(entry, "rollback of action "+id); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.Restored++
+	}
+
+	if len(report.Errors) > 0 {
+		return report, fmt.Errorf("%d of %d play status could not be restored", len(report.Errors), len(journal.Entries))
+	}
+
+	rolledBackAt := time.Now()
+	journal.RolledBackAt = &rolledBackAt
+	if err := s.store.SaveAction(journal, actionsKept); err != nil {
+		return report, fmt.Errorf("failed to save rollback of action %s: %v", id, err)
+	}
+
+	logrus.Infof("Rolled back action %s (%s): %d play status restored", id, journal.Action, report.Restored)
+	return report, nil
+}
+
+// restorePlayStatus writes back the play status recorded in a journal entry, reason is recorded in the audit log
+func (s *ServerService) restorePlayStatus(entry storageModels.PlayStateEntry, reason string) error {
+	err := s.jellyfinClient.UpdateUserItemData(entry.MovieID, entry.UserID, jellyfinModels.UserItemData{
+		Played:                entry.Played,
+		PlaybackPositionTicks: entry.PlaybackPositionTicks,
+		PlayCount:             entry.PlayCount,
+		LastPlayedDate:        entry.LastPlayedDate,
+	})
+	if err != nil {
+		logrus.Warnf("Failed to restore play status of %s for %s: %v", entry.MovieName, entry.UserName, err)
+		return fmt.Errorf("%s for %s: %v", entry.MovieName, entry.UserName, err)
+	}
+
+	// Play status is written on behalf of the admin user, operators only resolve items
+	s.recordAudit(constants.RestorePlayStatusAudit, entry.MovieID, entry.MovieName, s.config.Jellyfin.UserID,
+		fmt.Sprintf("for %s, %s", entry.UserName, reason))
+	return nil
+}
+
+func newActionID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(bytes)
+}
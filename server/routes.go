@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"jellyfin-duplicate/auth"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/logs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RegisterRoutes serves the pages and the API of the handler on the router, under the base path, along with
+// the login, webhook, widget and debug endpoints enabled by the configuration
+func RegisterRoutes(r *gin.Engine, handler *Handler, config *confModels.Config, buffer *logs.Buffer) error {
+	routes := r.Group(config.BasePath)
+	if config.BasePath != "" {
+		logrus.Infof("Serving application under %s", config.BasePath)
+		r.GET("/", handler.RedirectToBasePath)
+	}
+	if config.Auth.Enabled() {
+		provider, err := auth.NewProvider(config.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to set up OpenID Connect login: %v", err)
+		}
+		if err := RegisterLogin(routes, handler, provider); err != nil {
+			return fmt.Errorf("failed to set up OpenID Connect login: %v", err)
+		}
+		logrus.Infof("Login required through %s", config.Auth.DiscoveryURL)
+	}
+	if config.Auth.HeaderEnabled() {
+		if err := RegisterTrustedHeader(routes, handler); err != nil {
+			return fmt.Errorf("failed to set up reverse proxy login: %v", err)
+		}
+		logrus.Infof("Login delegated to the proxies %v through the %s header", config.Auth.TrustedProxies, config.Auth.TrustedHeader)
+	}
+	// Signed in users may browse the results, actions require the admin role.
+	// Both are open to everyone when login is disabled. Actions are never served to GET requests, which the
+	// Lax session cookie is sent with from the links of other sites.
+	viewer := routes.Group("", handler.RequireSession)
+	admin := viewer.Group("", handler.RequireAdmin)
+	viewer.GET("/", handler.GetHomePage)
+	viewer.GET("/analysis", handler.GetDuplicatesPage)
+	viewer.GET("/resolve", handler.GetResolvePage)
+	viewer.GET("/users", handler.GetUsersPage)
+	viewer.GET("/metadata", handler.GetMetadataPage)
+	viewer.GET("/api/duplicates", handler.GetDuplicatesJSON)
+	viewer.GET("/api/movies/by-path", handler.GetMovieByPath)
+	viewer.GET("/api/graphql", handler.GraphQL)
+	viewer.POST("/api/graphql", handler.GraphQL)
+	admin.POST("/api/duplicates/bulk-action", handler.BulkAction)
+	admin.POST("/api/duplicates/sync-and-delete", handler.SyncThenDelete)
+	admin.POST("/api/duplicates/deletion-script", handler.ExportDeletionScript)
+	viewer.GET("/api/library-policies", handler.GetLibraryPolicies)
+	admin.PUT("/api/library-policies/:library", handler.SetLibraryPolicy)
+	admin.POST("/api/actions/:id/rollback", handler.RollbackAction)
+	viewer.GET("/api/scan/result", handler.GetScanResult)
+	viewer.GET("/api/scan/schedule", handler.GetScanSchedule)
+	viewer.GET("/api/scan/history", handler.GetScanHistory)
+	viewer.GET("/api/scan/progress", handler.GetScanProgress)
+	viewer.GET("/api/scan/progress/stream", handler.StreamScanProgress)
+	admin.POST("/api/scan/cancel", handler.CancelScan)
+	admin.POST("/api/scan/schedule/pause", handler.PauseScanSchedule)
+	admin.POST("/api/scan/schedule/resume", handler.ResumeScanSchedule)
+	admin.POST("/api/mark-as-seen", handler.MarkMovieAsSeen)
+	viewer.GET("/api/mismatches/renames", handler.GetMismatchRenames)
+	viewer.GET("/api/boxsets/overlaps", handler.GetBoxSetOverlaps)
+	viewer.GET("/api/feedback/thresholds", handler.GetThresholdSuggestions)
+	viewer.GET("/api/resolutions", handler.GetResolutions)
+	viewer.GET("/api/versions", handler.GetVersionPairs)
+	admin.DELETE("/api/versions/:fingerprint", handler.DeleteVersionPair)
+	viewer.GET("/api/orphans", handler.GetOrphanedUserData)
+	viewer.GET("/api/clutter", handler.GetFolderClutter)
+	viewer.GET("/api/unwatched", handler.GetUnwatchedMovies)
+	viewer.GET("/api/watched", handler.GetWatchedMovies)
+	viewer.GET("/api/metadata/health", handler.GetMetadataHealth)
+	admin.POST("/api/movies/:id/refresh-metadata", handler.RefreshMovieMetadata)
+	admin.GET("/api/movies/:id/identify", handler.SearchMovieMetadata)
+	admin.POST("/api/movies/:id/identify", handler.IdentifyMovie)
+	admin.POST("/api/orphans/cleanup", handler.CleanOrphanedUserData)
+	admin.GET("/api/audit", handler.GetAuditLog)
+	viewer.GET("/api/users", handler.GetUsers)
+	viewer.GET("/api/users/:id/avatar", handler.GetUserAvatar)
+	viewer.GET("/api/jellyfin/status", handler.GetJellyfinStatus)
+	admin.GET("/api/system/info", handler.GetSystemInfo)
+	admin.GET("/api/admin/settings", handler.GetSettings)
+	admin.PUT("/api/admin/settings", handler.UpdateSettings)
+	admin.POST("/api/users/:id/selection", handler.SetUserSelection)
+	admin.POST("/api/delete-movie", handler.DeleteMovie)
+	viewer.GET("/api/set-theme", handler.SetTheme)
+	viewer.GET("/api/set-locale", handler.SetLocale)
+	admin.POST("/api/jobs", handler.SubmitJob)
+	viewer.GET("/api/external-reports", handler.GetExternalReports)
+	admin.POST("/api/external-reports/:format", handler.ImportExternalReport)
+	admin.DELETE("/api/external-reports/:format", handler.DeleteExternalReport)
+	viewer.GET("/api/jobs", handler.GetJobs)
+	viewer.GET("/api/jobs/:id", handler.GetJob)
+	viewer.GET("/api/deletions/scheduled", handler.GetScheduledDeletions)
+	admin.POST("/api/jobs/:id/cancel", handler.CancelJob)
+	RegisterLogs(admin, handler, buffer)
+	// Bearer token endpoints are called by other programs, which do not sign in
+	if config.EarlyWarning.WebhookToken != "" {
+		logrus.Infof("Jellyfin webhook enabled at %s/api/webhooks/jellyfin", config.BasePath)
+		RegisterJellyfinWebhook(routes, handler, config.EarlyWarning.WebhookToken)
+	}
+	if config.WidgetToken != "" {
+		logrus.Infof("Dashboard widget report enabled with a bearer token at %s/api/widget", config.BasePath)
+	}
+	RegisterWidget(routes, viewer, handler, config.WidgetToken)
+	if config.Debug.AdminToken != "" {
+		logrus.Infof("Admin endpoints enabled under %s/api/admin", config.BasePath)
+		RegisterAdmin(routes, handler, config.Debug.AdminToken)
+	}
+	if config.Debug.Pprof {
+		logrus.Warnf("Profiling endpoints enabled under %s/debug/pprof", config.BasePath)
+		RegisterPprof(routes, handler, config.Debug.AdminToken)
+	}
+	return nil
+}
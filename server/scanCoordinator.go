@@ -0,0 +1,399 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/cluster"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/cache"
+	"jellyfin-duplicate/pkg/dedupe"
+	"jellyfin-duplicate/storage"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrStaleScan is returned when an action refers to a scan which is not the latest one anymore
+var ErrStaleScan = errors.New("results are outdated, a newer scan is available: reload the page and try again")
+
+var (
+	// ErrScanCancelled is returned by a scan cancelled before it completed
+	ErrScanCancelled = errors.New("the scan was cancelled")
+	// ErrNoRunningScan is returned when cancelling while no scan is running
+	ErrNoRunningScan = errors.New("no scan is running")
+)
+
+// scanHistorySize is the number of scans kept in the history, the oldest ones being removed
+const scanHistorySize = 20
+
+// ScanResult is the outcome of a duplicate scan
+type ScanResult struct {
+	Version    int64
+	ScannedAt  time.Time
+	Duplicates []jellyfinModels.DuplicateResult
+	Warnings   []ScanWarning
+}
+
+// ScanWarning reports a group of movies whose pairs were not all compared
+type ScanWarning = dedupe.Warning
+
+// ScanRecord describes a scan of the history
+type ScanRecord struct {
+	// Version is the version of the result of a completed scan, 0 otherwise
+	Version    int64                `json:"version,omitempty"`
+	Status     constants.ScanStatus `json:"status"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+	Duplicates int                  `json:"duplicates"`
+	Error      string               `json:"error,omitempty"`
+	// APICalls is the number of Jellyfin calls made by the scan, APICallsByEndpoint counts them by method and
+	// path, their IDs replaced by {id}
+	APICalls           int            `json:"api_calls"`
+	APICallsByEndpoint map[string]int `json:"api_calls_by_endpoint,omitempty"`
+}
+
+// ScanCoordinator serializes scans and keeps the latest result with an increasing version,
+// so that actions issued against an older scan can be detected. Replicas sharing Redis scan one after the
+// other and share the latest result, the history and progress being the ones of each replica.
+type ScanCoordinator struct {
+	scanMutex  sync.Mutex
+	stateMutex sync.RWMutex
+	version    int64
+	latest     *ScanResult
+	// latestKey identifies the parameters of the latest scan
+	latestKey string
+	// cluster is nil unless replicas share Redis, and revision is the revision of the shared result last
+	// read or written
+	cluster  *cluster.Cluster
+	revision int64
+	// store numbers the scans unless replicas share Redis, and previousVersion is the version of the latest scan
+	// of the previous runs, whose result is gone
+	store           *storage.Store
+	previousVersion int64
+	// lookups of the latest result, for the cache admin
+	lookups cache.Counter
+	// cancel stops the running scan, nil when no scan is running
+	cancel context.CancelFunc
+	// history lists the last scans, oldest first, the running one last
+	history []ScanRecord
+	// progress follows the phases of the running scan
+	progress *ScanProgressTracker
+	// flights are the running scan and the ones waiting for it, by key
+	flights map[string]*scanFlight
+}
+
+// scanFlight is a running or waiting scan, whose result is shared with the callers asking for the same scan
+// meanwhile
+type scanFlight struct {
+	done   chan struct{}
+	result ScanResult
+	err    error
+}
+
+// NewScanCoordinator creates a coordinator, shared with the other replicas through a cluster when not nil. The
+// scans are otherwise numbered in the store, so that versions keep increasing across restarts.
+func NewScanCoordinator(store *storage.Store, shared *cluster.Cluster) *ScanCoordinator {
+	coordinator := &ScanCoordinator{progress: NewScanProgressTracker(), cluster: shared, store: store,
+		flights: make(map[string]*scanFlight)}
+	if shared != nil {
+		coordinator.subscribe()
+		return coordinator
+	}
+
+	version, err := store.ScanVersion()
+	if err != nil {
+		logrus.Warnf("Failed to read the version of the previous scans: %v", err)
+	}
+	coordinator.previousVersion = version
+	return coordinator
+}
+
+// Run executes the scan, waiting for any scan already in progress to finish first. When a scan with the same
+// key is running or waiting, its result is returned instead of scanning again, and shared is set. The scan stops when its
+// context is cancelled through Cancel, and ErrScanCancelled is returned to every caller sharing it.
+func (c *ScanCoordinator) Run(key string, scan func(ctx context.Context) ([]jellyfinModels.DuplicateResult, []ScanWarning, error)) (result ScanResult, shared bool, err error) {
+	// The flight is registered before waiting for the running scan, so that the callers asking for the same scan
+	// meanwhile wait for it instead of scanning again once it is their turn
+	c.stateMutex.Lock()
+	if flight, found := c.flights[key]; found {
+		c.stateMutex.Unlock()
+		logrus.Debug("Waiting for the running scan instead of scanning again")
+		<-flight.done
+		return flight.result, true, flight.err
+	}
+	flight := &scanFlight{done: make(chan struct{})}
+	c.flights[key] = flight
+	c.stateMutex.Unlock()
+	defer func() {
+		c.stateMutex.Lock()
+		delete(c.flights, key)
+		c.stateMutex.Unlock()
+		flight.result, flight.err = result, err
+		close(flight.done)
+	}()
+
+	c.scanMutex.Lock()
+	defer c.scanMutex.Unlock()
+
+	if c.cluster != nil {
+		// A scan with the same key completed by another replica while waiting is shared
+		c.sync()
+		seen := c.currentVersion()
+		unlock, err := c.cluster.Lock(context.Background(), scanLock)
+		if err != nil {
+			return ScanResult{}, false, fmt.Errorf("failed to wait for the scans of other replicas: %v", err)
+		}
+		defer unlock()
+
+		c.sync()
+		c.stateMutex.RLock()
+		if c.version > seen && c.latest != nil && c.latestKey == key {
+			result = *c.latest
+			c.stateMutex.RUnlock()
+			logrus.Debug("Sharing the scan completed by another replica instead of scanning again")
+			return result, true, nil
+		}
+		c.stateMutex.RUnlock()
+	}
+
+	result, err = c.scan(key, scan)
+	return result, false, err
+}
+
+// currentVersion returns the version of the latest scan
+func (c *ScanCoordinator) currentVersion() int64 {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.version
+}
+
+// scan executes the scan and records its result as the latest one, the scan mutex being held
+func (c *ScanCoordinator) scan(key string, scan func(ctx context.Context) ([]jellyfinModels.DuplicateResult, []ScanWarning, error)) (ScanResult, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.start(cancel)
+	defer c.progress.Finish()
+
+	duplicates, warnings, err := scan(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ErrScanCancelled
+		}
+		c.finish(err, 0, 0)
+		return ScanResult{}, err
+	}
+	version, err := c.nextVersion()
+	if err != nil {
+		c.finish(err, 0, 0)
+		return ScanResult{}, err
+	}
+
+	c.stateMutex.Lock()
+	c.version = version
+	c.latest = &ScanResult{
+		Version:    c.version,
+		ScannedAt:  time.Now(),
+		Duplicates: duplicates,
+		Warnings:   warnings,
+	}
+	c.latestKey = key
+	c.publish()
+	result := *c.latest
+	c.stateMutex.Unlock()
+
+	c.finish(nil, result.Version, len(duplicates))
+	logrus.Debugf("Scan version %d completed with %d duplicate pairs", result.Version, len(duplicates))
+	return result, nil
+}
+
+// Exclusive runs change between scans, waiting for the running scan to finish first
+func (c *ScanCoordinator) Exclusive(change func()) {
+	c.scanMutex.Lock()
+	defer c.scanMutex.Unlock()
+	change()
+}
+
+// start records the running scan in the history
+func (c *ScanCoordinator) start(cancel context.CancelFunc) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	c.cancel = cancel
+	c.history = append(c.history, ScanRecord{Status: constants.ScanRunning, StartedAt: time.Now()})
+	if len(c.history) > scanHistorySize {
+		c.history = c.history[len(c.history)-scanHistorySize:]
+	}
+}
+
+// finish records the outcome of the running scan in the history
+func (c *ScanCoordinator) finish(err error, version int64, duplicates int) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	c.cancel = nil
+	now := time.Now()
+	record := &c.history[len(c.history)-1]
+	record.FinishedAt = &now
+	record.Version = version
+	record.Duplicates = duplicates
+	switch {
+	case err == nil:
+		record.Status = constants.ScanCompleted
+	case errors.Is(err, ErrScanCancelled):
+		record.Status = constants.ScanCancelled
+	default:
+		record.Status = constants.ScanFailed
+		record.Error = err.Error()
+	}
+}
+
+// RecordAPICalls records the Jellyfin calls of the running scan in the history
+func (c *ScanCoordinator) RecordAPICalls(total int, byEndpoint map[string]int) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if len(c.history) == 0 {
+		return
+	}
+	record := &c.history[len(c.history)-1]
+	record.APICalls = total
+	record.APICallsByEndpoint = byEndpoint
+}
+
+// Cancel stops the running scan: its pending Jellyfin requests are aborted and the callers waiting for
+// it get ErrScanCancelled, letting the next scan start
+func (c *ScanCoordinator) Cancel() error {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if c.cancel == nil {
+		return c.cancelShared()
+	}
+	c.cancel()
+	logrus.Info("Cancelling the running scan")
+	return nil
+}
+
+// cancelShared asks the replica running a scan to cancel it, ErrNoRunningScan being returned when none is
+func (c *ScanCoordinator) cancelShared() error {
+	if c.cluster == nil {
+		return ErrNoRunningScan
+	}
+	running, err := c.cluster.Locked(scanLock)
+	if err != nil {
+		return fmt.Errorf("failed to check the scans of other replicas: %v", err)
+	}
+	if !running {
+		return ErrNoRunningScan
+	}
+	if err := c.cluster.Publish(scanCancelChannel, ""); err != nil {
+		return fmt.Errorf("failed to cancel the scan of another replica: %v", err)
+	}
+	logrus.Info("Asked the other replicas to cancel their running scan")
+	return nil
+}
+
+// History returns the last scans, most recent first
+func (c *ScanCoordinator) History() []ScanRecord {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	history := make([]ScanRecord, len(c.history))
+	for i, record := range c.history {
+		history[len(c.history)-1-i] = record
+	}
+	return history
+}
+
+// Latest returns the latest scan result, if any
+func (c *ScanCoordinator) Latest() (ScanResult, bool) {
+	c.sync()
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	if c.latest == nil {
+		c.lookups.Miss()
+		return ScanResult{}, false
+	}
+	c.lookups.Hit()
+	return *c.latest, true
+}
+
+// Stats returns the usage of the latest result, cached until the next scan or a flush
+func (c *ScanCoordinator) Stats() cache.Stats {
+	c.sync()
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	if c.latest == nil {
+		return c.lookups.Stats(0, 0)
+	}
+	return c.lookups.Stats(1, cache.SizeOf(c.latest))
+}
+
+// Flush forgets the latest result and returns the number of results removed.
+// The version is kept, so that actions issued against the flushed scan are still rejected once a new one runs.
+func (c *ScanCoordinator) Flush() int {
+	c.sync()
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if c.latest == nil {
+		return 0
+	}
+	c.latest = nil
+	c.publish()
+	return 1
+}
+
+// Prune removes the pairs involving an item from the latest result and returns how many were removed.
+// The version is kept: the remaining pairs are unchanged, so actions issued against them stay valid.
+func (c *ScanCoordinator) Prune(itemID string) int {
+	c.sync()
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if c.latest == nil {
+		return 0
+	}
+	kept := lo.Reject(c.latest.Duplicates, func(dup jellyfinModels.DuplicateResult, _ int) bool {
+		return dup.Movie1.ID == itemID || dup.Movie2.ID == itemID
+	})
+	pruned := len(c.latest.Duplicates) - len(kept)
+	// The result is copied, as callers of Latest may still read the previous duplicates
+	latest := *c.latest
+	latest.Duplicates = kept
+	c.latest = &latest
+	if pruned > 0 {
+		c.publish()
+	}
+	return pruned
+}
+
+// FromPreviousRun reports whether a version was issued before the application started. Its scan is gone, so
+// actions issued against it can only be rejected or checked against a fresh scan.
+func (c *ScanCoordinator) FromPreviousRun(version int64) bool {
+	return version != 0 && version <= c.previousVersion
+}
+
+// Check verifies that the version is the latest scan version.
+// A zero version means the caller did not provide one and is always accepted.
+func (c *ScanCoordinator) Check(version int64) error {
+	if version == 0 {
+		return nil
+	}
+
+	c.sync()
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	if version != c.version {
+		logrus.Warnf("Rejecting action issued against scan version %d, latest is %d", version, c.version)
+		return ErrStaleScan
+	}
+	return nil
+}
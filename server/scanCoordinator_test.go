@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/storage"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestScanCoordinator returns a coordinator numbering its scans in a temporary data directory
+func newTestScanCoordinator(t *testing.T) *ScanCoordinator {
+	backend, err := storage.OpenBackend("", "", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewStore(backend, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewScanCoordinator(store, nil)
+}
+
+// blockingScan returns a scan counting its runs, which finishes once release is closed
+func blockingScan(runs *atomic.Int32, release chan struct{}) func(ctx context.Context) ([]jellyfinModels.DuplicateResult, []ScanWarning, error) {
+	return func(ctx context.Context) ([]jellyfinModels.DuplicateResult, []ScanWarning, error) {
+		runs.Add(1)
+		<-release
+		return nil, nil, nil
+	}
+}
+
+// runConcurrently starts Run for each key at the same moment, and returns how many results were shared once the
+// scans are released
+func runConcurrently(c *ScanCoordinator, keys []string, scan func(ctx context.Context) ([]jellyfinModels.DuplicateResult, []ScanWarning, error), release chan struct{}) int {
+	var wg sync.WaitGroup
+	var sharedResults atomic.Int32
+	start := make(chan struct{})
+	for _, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, shared, _ := c.Run(key, scan); shared {
+				sharedResults.Add(1)
+			}
+		}()
+	}
+	close(start)
+	// Every caller reaches Run before the scans finish
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return int(sharedResults.Load())
+}
+
+func TestScanCoordinatorSharesConcurrentScans(t *testing.T) {
+	c := newTestScanCoordinator(t)
+	var runs atomic.Int32
+	release := make(chan struct{})
+
+	shared := runConcurrently(c, []string{"key", "key"}, blockingScan(&runs, release), release)
+	if runs.Load() != 1 || shared != 1 {
+		t.Errorf("Expected a single scan shared with the other caller, got %d scans and %d shared results", runs.Load(), shared)
+	}
+}
+
+func TestScanCoordinatorSharesWaitingScans(t *testing.T) {
+	c := newTestScanCoordinator(t)
+	var runs atomic.Int32
+	release := make(chan struct{})
+
+	// The scan of another key runs first, the callers of the same key wait for it together
+	running := make(chan struct{})
+	go func() {
+		_, _, _ = c.Run("other", func(ctx context.Context) ([]jellyfinModels.DuplicateResult, []ScanWarning, error) {
+			close(running)
+			<-release
+			return nil, nil, nil
+		})
+	}()
+	<-running
+
+	shared := runConcurrently(c, []string{"key", "key", "key"}, blockingScan(&runs, release), release)
+	if runs.Load() != 1 || shared != 2 {
+		t.Errorf("Expected a single scan shared with the other callers, got %d scans and %d shared results", runs.Load(), shared)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CancelScan cancels the running scan, ErrNoRunningScan when no scan is running
+func (s *ServerService) CancelScan() error {
+	return s.scans.Cancel()
+}
+
+// ScanHistory returns the last scans, most recent first
+func (s *ServerService) ScanHistory() []ScanRecord {
+	return s.scans.History()
+}
+
+// POST /api/scan/cancel
+// CancelScan cancels the running scan: its Jellyfin requests are aborted and it is recorded as cancelled
+func (h *Handler) CancelScan(ctx *gin.Context) {
+	if err := h.serverService.CancelScan(); err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Scan cancelled",
+	})
+}
+
+// GET /api/scan/history
+// GetScanHistory lists the last scans with their status, most recent first
+func (h *Handler) GetScanHistory(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"scans": h.serverService.ScanHistory(),
+	})
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"io"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// scanProgressInterval is the minimum interval between two progress events within a phase
+	scanProgressInterval = 500 * time.Millisecond
+	// scanProgressBacklog is the number of events waiting for a slow subscriber before the next ones are dropped
+	scanProgressBacklog = 10
+)
+
+// ScanProgress is the state of the running scan
+type ScanProgress struct {
+	Running bool                `json:"running"`
+	Phase   constants.ScanPhase `json:"phase,omitempty"`
+	Done    int                 `json:"done"`
+	Total   int                 `json:"total"`
+	// Throughput is the number of items processed per second since the start of the phase
+	Throughput float64 `json:"throughput"`
+	// ETA is the estimated number of seconds left in the phase, absent until items are processed
+	ETA *int `json:"eta,omitempty"`
+}
+
+// ScanProgressTracker follows the phases of the running scan and forwards their progress to subscribers.
+// It implements jellyfinClients.FetchProgress, so that the total of the fetching phase grows with the
+// TotalRecordCount of each paged request.
+type ScanProgressTracker struct {
+	mutex          sync.Mutex
+	running        bool
+	phase          constants.ScanPhase
+	phaseStartedAt time.Time
+	done, total    int
+	publishedAt    time.Time
+	subscribers    map[chan ScanProgress]struct{}
+}
+
+func NewScanProgressTracker() *ScanProgressTracker {
+	return &ScanProgressTracker{subscribers: make(map[chan ScanProgress]struct{})}
+}
+
+// Start begins a phase of the running scan with total items to process, 0 when they are not known yet
+func (t *ScanProgressTracker) Start(phase constants.ScanPhase, total int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.running = true
+	t.phase, t.phaseStartedAt = phase, time.Now()
+	t.done, t.total = 0, total
+	t.publish(true)
+}
+
+// AddTotal adds items to process in the current phase
+func (t *ScanProgressTracker) AddTotal(total int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.total += total
+	t.publish(false)
+}
+
+// Advance reports items processed in the current phase
+func (t *ScanProgressTracker) Advance(count int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.done += count
+	t.publish(t.done == t.total)
+}
+
+// Finish reports the end of the running scan, whatever its outcome
+func (t *ScanProgressTracker) Finish() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.running = false
+	t.phase, t.done, t.total = "", 0, 0
+	t.publish(true)
+}
+
+// Progress returns the state of the running scan
+func (t *ScanProgressTracker) Progress() ScanProgress {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.progress()
+}
+
+// progress estimates the throughput and time left of the current phase from the items processed so far
+func (t *ScanProgressTracker) progress() ScanProgress {
+	progress := ScanProgress{Running: t.running, Phase: t.phase, Done: t.done, Total: t.total}
+	elapsed := time.Since(t.phaseStartedAt).Seconds()
+	if !t.running || t.done == 0 || elapsed <= 0 {
+		return progress
+	}
+
+	progress.Throughput = float64(t.done) / elapsed
+	if t.total >= t.done {
+		eta := int(float64(t.total-t.done)/progress.Throughput + 0.5)
+		progress.ETA = &eta
+	}
+	return progress
+}
+
+// publish sends the progress to the subscribers, at most every scanProgressInterval unless forced
+func (t *ScanProgressTracker) publish(force bool) {
+	now := time.Now()
+	if !force && now.Sub(t.publishedAt) < scanProgressInterval {
+		return
+	}
+	t.publishedAt = now
+
+	// The scan must never wait for a subscriber, a slow one misses events
+	progress := t.progress()
+	for subscriber := range t.subscribers {
+		select {
+		case subscriber <- progress:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the progress events, until unsubscribe is called
+func (t *ScanProgressTracker) Subscribe() (events <-chan ScanProgress, unsubscribe func()) {
+	subscriber := make(chan ScanProgress, scanProgressBacklog)
+
+	t.mutex.Lock()
+	t.subscribers[subscriber] = struct{}{}
+	t.mutex.Unlock()
+
+	return subscriber, func() {
+		t.mutex.Lock()
+		delete(t.subscribers, subscriber)
+		t.mutex.Unlock()
+	}
+}
+
+// ScanProgress returns the state of the running scan
+func (s *ServerService) ScanProgress() ScanProgress {
+	return s.scans.progress.Progress()
+}
+
+// SubscribeScanProgress returns a channel receiving the progress of the scans, until unsubscribe is called
+func (s *ServerService) SubscribeScanProgress() (events <-chan ScanProgress, unsubscribe func()) {
+	return s.scans.progress.Subscribe()
+}
+
+// GET /api/scan/progress
+// GetScanProgress returns the phase, throughput and estimated time left of the running scan
+func (h *Handler) GetScanProgress(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.serverService.ScanProgress())
+}
+
+// GET /api/scan/progress/stream
+// StreamScanProgress sends the progress of the running scans as server-sent events, starting with the current one
+func (h *Handler) StreamScanProgress(ctx *gin.Context) {
+	events, unsubscribe := h.serverService.SubscribeScanProgress()
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.SSEvent("progress", h.serverService.ScanProgress())
+	ctx.Writer.Flush()
+
+	ctx.Stream(func(io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			ctx.SSEvent("ping", time.Now().Unix())
+		case progress := <-events:
+			ctx.SSEvent("progress", progress)
+		}
+		return true
+	})
+}
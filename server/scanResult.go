@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/notifications"
+	"jellyfin-duplicate/schema"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// ScanResultSchema converts a scan result into the versioned schema, with the information of the Jellyfin server
+func (s *ServerService) ScanResultSchema(result ScanResult) schema.ScanResult {
+	groups, actions := schema.FromDuplicates(result.Duplicates)
+	scanResult := schema.ScanResult{
+		SchemaVersion:      schema.CurrentVersion,
+		GeneratedAt:        result.ScannedAt,
+		ApplicationVersion: constants.Version,
+		ScanVersion:        result.Version,
+		Groups:             groups,
+		Actions:            actions,
+		Warnings:           append([]schema.Warning{}, result.Warnings...),
+	}
+
+	// The server is only described, a failure leaves its fields empty
+	info, err := s.jellyfinClient.GetPublicSystemInfo()
+	if err != nil {
+		logrus.Warnf("Failed to describe the Jellyfin server in the scan result: %v", err)
+	} else {
+		scanResult.Server = schema.Server{ID: info.ID, Name: info.ServerName, Version: info.Version}
+	}
+	return scanResult
+}
+
+// publishScanResult persists the result of a scan and sends it to the webhook. Failures are logged, they never
+// make the scan fail.
+func (s *ServerService) publishScanResult(result ScanResult) {
+	scanResult := s.ScanResultSchema(result)
+
+	if err := s.store.SaveScanResult(scanResult); err != nil {
+		logrus.Warnf("Failed to persist scan result: %v", err)
+	}
+
+	duplicates := lo.CountBy(scanResult.Groups, func(group schema.Group) bool { return group.IsDuplicate })
+	severities := lo.CountValuesBy(scanResult.Groups, func(group schema.Group) constants.Severity { return group.Severity })
+	s.notifier.Notify(notifications.Event{
+		Type:  constants.ScanCompletedEvent,
+		Title: "Duplicate scan completed",
+		Message: fmt.Sprintf("%d duplicate pairs (%d exact, %d probable, %d versions), %d potential mismatches, %d recommended actions",
+			duplicates, severities[constants.ExactSeverity], severities[constants.ProbableSeverity], severities[constants.VersionSeverity],
+			len(scanResult.Groups)-duplicates, len(scanResult.Actions)),
+		Data: scanResult,
+	})
+}
+
+// LatestScanResult returns the latest scan result in the versioned schema. Before the first scan of the
+// application, the result persisted by the previous run is returned, otherwise a scan is run.
+func (s *ServerService) LatestScanResult() (schema.ScanResult, error) {
+	if result, found := s.knownScanResult(); found {
+		return result, nil
+	}
+
+	result, err := s.Scan()
+	if err != nil {
+		return schema.ScanResult{}, err
+	}
+	return s.ScanResultSchema(result), nil
+}
+
+// knownScanResult returns the latest scan result, or the one persisted by the previous run before the first scan
+// of the application, false when there is none
+func (s *ServerService) knownScanResult() (schema.ScanResult, bool) {
+	if result, found := s.scans.Latest(); found {
+		return s.ScanResultSchema(result), true
+	}
+
+	persisted, found, err := s.store.LatestScanResult()
+	if err != nil {
+		logrus.Warnf("Ignoring persisted scan result: %v", err)
+	}
+	return persisted, found
+}
+
+// GET /api/scan/result
+// GetScanResult returns the latest scan result in the versioned schema, as a file to download with download=true
+func (h *Handler) GetScanResult(ctx *gin.Context) {
+	result, err := h.serverService.LatestScanResult()
+	if err != nil {
+		logrus.Errorf("Error getting scan result: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if ctx.Query("download") == "true" {
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="scan-result-%s.json"`,
+			result.GeneratedAt.Format("2006-01-02")))
+	}
+	ctx.JSON(http.StatusOK, result)
+}
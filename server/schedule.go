@@ -0,0 +1,221 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoSchedule is returned when the schedule is controlled while no scheduled scan is configured
+var ErrNoSchedule = errors.New("no scheduled scan configured")
+
+// scheduleLease is the name of the lease held by the replica running the scheduled tasks
+const scheduleLease = "schedule"
+
+// Lease is held by one replica at a time, until it expires
+type Lease interface {
+	// AcquireLease takes the lease of a name for ttl, or extends it when this replica holds it, and reports
+	// whether this replica holds it
+	AcquireLease(name string, ttl time.Duration) (bool, error)
+}
+
+// Schedule runs a task every interval. With a start time, runs are aligned on the wall clock of its
+// timezone: they start every day at that time, then follow each other every interval until the next day.
+// Without start time, the task runs when the schedule starts then every interval.
+type Schedule struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	location *time.Location
+	// aligned is set when a start time is configured
+	aligned                bool
+	startHour, startMinute int
+	paused                 bool
+	nextRun                time.Time
+	lastRun                *time.Time
+	// lease is nil unless replicas share the schedule, and elsewhere is set when the last run was left to
+	// the replica holding it
+	lease     Lease
+	elsewhere bool
+}
+
+// ScheduleStatus describes the state of a schedule
+type ScheduleStatus struct {
+	Enabled  bool   `json:"enabled"`
+	Paused   bool   `json:"paused"`
+	Interval int    `json:"interval"`
+	Timezone string `json:"timezone"`
+	StartAt  string `json:"start_at,omitempty"`
+	// RunElsewhere is set when the last run was left to another replica, which holds the schedule
+	RunElsewhere bool `json:"run_elsewhere,omitempty"`
+	// NextRun is in the schedule timezone, absent when disabled or paused
+	NextRun *time.Time `json:"next_run,omitempty"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+}
+
+// NewSchedule creates a schedule running every interval minutes in the timezone, Local when empty.
+// startAt is an optional HH:MM time of day aligning the runs.
+func NewSchedule(interval int, timezone, startAt string) (*Schedule, error) {
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %s: %v", timezone, err)
+		}
+	}
+
+	schedule := &Schedule{
+		interval: time.Duration(interval) * time.Minute,
+		location: location,
+	}
+	if startAt != "" {
+		start, err := time.Parse("15:04", startAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time %s: must be HH:MM", startAt)
+		}
+		schedule.aligned = true
+		schedule.startHour, schedule.startMinute = start.Hour(), start.Minute()
+	}
+	return schedule, nil
+}
+
+// Share runs the task on one of the replicas sharing the lease: the one holding it, taken for one and a half
+// intervals and extended by each of its runs, so that another replica takes over once it stopped
+func (s *Schedule) Share(lease Lease) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lease = lease
+}
+
+// Start runs the task on the schedule until the returned function is called
+func (s *Schedule) Start(task func()) (stop func()) {
+	now := time.Now()
+	s.mutex.Lock()
+	s.nextRun = now
+	if s.aligned {
+		s.nextRun = s.next(now)
+	}
+	s.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			s.mutex.Lock()
+			timer := time.NewTimer(time.Until(s.nextRun))
+			s.mutex.Unlock()
+
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			s.mutex.Lock()
+			paused := s.paused
+			ranAt := time.Now()
+			s.nextRun = s.next(ranAt)
+			s.mutex.Unlock()
+
+			if paused {
+				logrus.Debug("Scheduled scan skipped, the schedule is paused")
+				continue
+			}
+			if !s.holdLease() {
+				continue
+			}
+			s.mutex.Lock()
+			s.lastRun = &ranAt
+			s.mutex.Unlock()
+			task()
+		}
+	}()
+	return func() { close(done) }
+}
+
+// holdLease checks that this replica runs the task, taking or extending the lease of the schedule
+func (s *Schedule) holdLease() bool {
+	s.mutex.Lock()
+	lease := s.lease
+	s.mutex.Unlock()
+	if lease == nil {
+		return true
+	}
+
+	held, err := lease.AcquireLease(scheduleLease, s.interval*3/2)
+	if err != nil {
+		logrus.Warnf("Scheduled scan skipped, failed to check which replica runs it: %v", err)
+		return false
+	}
+	if !held {
+		logrus.Debug("Scheduled scan skipped, another replica runs it")
+	}
+	s.mutex.Lock()
+	s.elsewhere = !held
+	s.mutex.Unlock()
+	return held
+}
+
+// next returns the first run strictly after the given time
+func (s *Schedule) next(after time.Time) time.Time {
+	if !s.aligned {
+		return after.Add(s.interval)
+	}
+
+	// Runs restart from the start time every day, so that they stay at the same wall clock times
+	// across daylight saving changes. Starting from the previous day covers times before today's start.
+	local := after.In(s.location)
+	run := time.Date(local.Year(), local.Month(), local.Day()-1, s.startHour, s.startMinute, 0, 0, s.location)
+	for !run.After(after) {
+		nextDay := time.Date(run.Year(), run.Month(), run.Day()+1, s.startHour, s.startMinute, 0, 0, s.location)
+		run = run.Add(s.interval)
+		if !run.Before(nextDay) {
+			run = nextDay
+		}
+	}
+	return run
+}
+
+// Pause skips the runs until Resume is called
+func (s *Schedule) Pause() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.paused = true
+}
+
+// Resume restarts the runs, from the next scheduled time
+func (s *Schedule) Resume() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.paused = false
+}
+
+// Status reports the state and next run of the schedule
+func (s *Schedule) Status() ScheduleStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := ScheduleStatus{
+		Enabled:  true,
+		Paused:   s.paused,
+		Interval: int(s.interval / time.Minute),
+		Timezone: s.location.String(),
+		LastRun:  s.lastRun,
+		// The last run of another replica is not known
+		RunElsewhere: s.elsewhere,
+	}
+	if s.aligned {
+		status.StartAt = fmt.Sprintf("%02d:%02d", s.startHour, s.startMinute)
+	}
+	if !s.paused && !s.nextRun.IsZero() {
+		nextRun := s.nextRun.In(s.location)
+		status.NextRun = &nextRun
+	}
+	if status.LastRun != nil {
+		lastRun := status.LastRun.In(s.location)
+		status.LastRun = &lastRun
+	}
+	return status
+}
@@ -1,101 +1,538 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
 	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	traktClients "jellyfin-duplicate/client/trakt/http"
+	"jellyfin-duplicate/cluster"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/filesystem"
+	"jellyfin-duplicate/jobs"
+	"jellyfin-duplicate/notifications"
+	"jellyfin-duplicate/pkg/cache"
+	"jellyfin-duplicate/pkg/dedupe"
+	"jellyfin-duplicate/pkg/humanize"
+	"jellyfin-duplicate/storage"
 	"jellyfin-duplicate/utils"
+	"os"
+	"sync"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
 )
 
 type ServerService struct {
 	jellyfinClient *jellyfinClients.Client
+	config         *confModels.Config
+	// configMutex guards the settings of config changed at runtime through the admin API, see liveConfig
+	configMutex sync.RWMutex
+	pathMapper  *filesystem.PathMapper
+	trash       *filesystem.Trash
+	store       *storage.Store
+	scans       *ScanCoordinator
+	// traktClient is nil when no Trakt account is connected
+	traktClient *traktClients.Client
+	// secondaryClient is nil when no secondary server is configured
+	secondaryClient *jellyfinClients.Client
+	notifier        *notifications.Notifier
+	// notifiedDuplicates are the recently added duplicates already notified, so that each one is notified once
+	notifiedMutex      sync.Mutex
+	notifiedDuplicates map[string]bool
+	// schedule is nil when the early warning is disabled
+	schedule *Schedule
+	// users are cached by the last scan, for the users page
+	usersMutex   sync.RWMutex
+	users        []UserSummary
+	usersLookups cache.Counter
+	// policies are the library policies, changed through the admin API
+	policiesMutex sync.RWMutex
+	policies      map[string]constants.LibraryPolicy
+	// jobs runs the auto clean of libraries, set once the job runners are registered
+	jobs *jobs.Queue
+	// avatars are the user avatars by user ID, see UserAvatar
+	avatars *cache.Cache[string, avatar]
+	// fingerprinter is nil unless home videos are scanned, and fingerprints are the content fingerprints
+	// of home videos by file, see contentFingerprint
+	fingerprinter *filesystem.Fingerprinter
+	fingerprints  *cache.Cache[string, string]
+	// ffprobe is nil when media are not inspected or it is not available, and inspections are the stream
+	// details of the copies of duplicates by file, see inspect
+	ffprobe     *filesystem.FFprobe
+	inspections *cache.Cache[string, filesystem.Inspection]
+	// orphans are the watched entries pointing at missing items found by the last scan, nil before the first scan
+	orphansMutex sync.RWMutex
+	orphans      []OrphanedUserData
+	// metadata are the metadata scores of the movies of the last scan, nil before the first scan
+	metadataMutex sync.RWMutex
+	metadata      []MetadataHealth
 }
 
-func NewService(client *jellyfinClients.Client) *ServerService {
-	return &ServerService{jellyfinClient: client}
+// NewService creates the service of the handlers. With a cluster, the avatars and the latest scan result are
+// shared with the other replicas.
+func NewService(client *jellyfinClients.Client, config *confModels.Config, store *storage.Store, notifier *notifications.Notifier,
+	shared *cluster.Cluster) *ServerService {
+	service := &ServerService{
+		jellyfinClient:     client,
+		config:             config,
+		store:              store,
+		notifier:           notifier,
+		notifiedDuplicates: make(map[string]bool),
+		scans:              NewScanCoordinator(store, shared),
+		pathMapper:         filesystem.NewPathMapper(config.Deletion.PathMappings),
+		trash:              filesystem.NewTrash(config.Deletion.TrashDir),
+		policies:           config.Scan.LibraryPolicies,
+		avatars:            cache.NewRemote[string, avatar](sharedCache(shared, "avatars", avatarTTL)),
+		fingerprints:       cache.New[string, string](),
+		inspections:        cache.New[string, filesystem.Inspection](),
+	}
+	if config.Scan.HomeVideos || config.Scan.InspectMedia {
+		ffprobe, err := filesystem.NewFFprobe(config.Scan.FFprobePath)
+		if err != nil {
+			logrus.Warnf("%v: home videos are fingerprinted by partial checksums and media are not inspected", err)
+		}
+		if config.Scan.HomeVideos {
+			service.fingerprinter = filesystem.NewFingerprinter(ffprobe)
+		}
+		if config.Scan.InspectMedia {
+			service.ffprobe = ffprobe
+		}
+	}
+	if config.Trakt.Enabled() {
+		service.traktClient = traktClients.NewClient(config.Trakt.ClientID, config.Trakt.AccessToken)
+	}
+	if config.EarlyWarning.Interval > 0 {
+		// The configuration is validated when loaded
+		schedule, err := NewSchedule(config.EarlyWarning.Interval, config.EarlyWarning.Timezone, config.EarlyWarning.StartAt)
+		if err != nil {
+			logrus.Errorf("Invalid early warning schedule, disabling it: %v", err)
+		}
+		if schedule != nil {
+			schedule.Share(schedulerLease(store, shared))
+		}
+		service.schedule = schedule
+	}
+	if config.SecondaryJellyfin.Configured() {
+		service.secondaryClient = jellyfinClients.NewClient(config.SecondaryJellyfin.URL, config.SecondaryJellyfin.APIKey,
+			config.SecondaryJellyfin.UserID, client.Identity())
+	}
+	return service
 }
 
-// GetMultiUserPlayStatus fetches play status for all users using the optimized approach
-func (s *ServerService) GetMultiUserPlayStatus() ([]jellyfinModels.Movie, error) {
+// schedulerLease returns the lease electing the replica running the scheduled tasks: through Redis when replicas
+// share it, otherwise through the storage backend, always held when it is not shared
+func schedulerLease(store *storage.Store, shared *cluster.Cluster) Lease {
+	if shared != nil {
+		return shared
+	}
+	return store
+}
+
+// sharedCache returns the cache of the replicas named name, nil to cache in memory without cluster
+func sharedCache(shared *cluster.Cluster, name string, ttl time.Duration) cache.Remote {
+	if shared == nil {
+		return nil
+	}
+	return shared.Cache(name, ttl)
+}
+
+// GetMultiUserPlayStatus fetches play status for all users using the optimized approach. The Jellyfin
+// requests are aborted when ctx is cancelled.
+func (s *ServerService) GetMultiUserPlayStatus(ctx context.Context) ([]jellyfinModels.Movie, error) {
+	// The total of the fetching phase grows as the pages of each library and user are requested
+	s.scans.progress.Start(constants.ScanFetching, 0)
+	client := s.jellyfinClient.WithContext(ctx).WithProgress(s.scans.progress)
+
 	// Get all movies
-	allMovies, err := s.jellyfinClient.GetAllMovies()
+	allMovies, err := client.GetAllMovies()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all movies: %v", err)
 	}
 
 	// Get all users
-	users, err := s.jellyfinClient.GetAllUsers()
+	allUsers, err := client.GetAllUsers()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users: %v", err)
 	}
 
+	// Users excluded from reconciliation are left out of play status comparisons
+	users := s.reconciledUsers(allUsers)
+	if len(users) < len(allUsers) {
+		logrus.Infof("Reconciling play status of %d of %d users", len(users), len(allUsers))
+	}
+
 	// Fetch seen movies for all users in parallel
-	userSeenMovies, err := s.jellyfinClient.GetSeenMoviesForAllUsers(users)
+	userSeenMovies, err := client.GetSeenMoviesForAllUsers(users)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get seen movies for all users: %v", err)
 	}
+	s.cacheUsers(allUsers, userSeenMovies)
+	s.cacheOrphanedUserData(s.findOrphanedUserData(allMovies, userSeenMovies, users))
+
+	// Playback positions tell the copies a user stopped watching before the end
+	userResumableMovies, err := client.GetResumableMoviesForAllUsers(users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resumable movies for all users: %v", err)
+	}
 
 	// Reconcile play status with all movies
-	moviesWithPlayStatus, err := s.jellyfinClient.ReconcilePlayStatusWithAllMovies(allMovies, userSeenMovies, users)
+	moviesWithPlayStatus, err := client.ReconcilePlayStatusWithAllMovies(allMovies, userSeenMovies, users)
 	if err != nil {
 		return nil, fmt.Errorf("failed to reconcile play status: %v", err)
 	}
+	applyPlaybackPositions(moviesWithPlayStatus, userResumableMovies)
 
 	return moviesWithPlayStatus, nil
 }
 
-func (s *ServerService) FindDuplicates() ([]jellyfinModels.DuplicateResult, error) {
+// Scan finds duplicates through the scan coordinator, so that concurrent scans are serialized and versioned, and
+// concurrent requests for the same scan share one computation
+func (s *ServerService) Scan() (ScanResult, error) {
+	if interval := s.config.Debug.MemoryLogInterval; interval > 0 {
+		stop := utils.LogMemoryUsage("Scan", time.Duration(interval)*time.Second)
+		defer stop()
+	}
+	result, shared, err := s.scans.Run(s.scanKey(), s.FindDuplicates)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	// The caller which ran the scan already published it
+	if !shared {
+		s.publishScanResult(result)
+		s.autoClean(result)
+	}
+	return result, nil
+}
+
+// scanKey identifies the parameters of a scan, the scan configuration: requests arriving while a scan with the
+// same parameters runs share its result
+func (s *ServerService) scanKey() string {
+	key, err := json.Marshal(s.liveConfig().Scan)
+	if err != nil {
+		return ""
+	}
+	return string(key)
+}
+
+// CheckScanVersion verifies that an action refers to the latest scan
+func (s *ServerService) CheckScanVersion(version int64) error {
+	return s.scans.Check(version)
+}
+
+// scanForAction returns the scan an action refers to: the latest one when a version is given,
+// otherwise a fresh scan
+func (s *ServerService) scanForAction(version int64) (ScanResult, error) {
+	if version == 0 {
+		return s.Scan()
+	}
+
+	if err := s.scans.Check(version); err != nil {
+		return ScanResult{}, err
+	}
+
+	scan, ok := s.scans.Latest()
+	if !ok {
+		return ScanResult{}, ErrStaleScan
+	}
+	return scan, nil
+}
+
+// FindDuplicates compares the movies sharing a name and year. Groups producing more pairs than
+// the configured cap are only partially compared, and reported in the returned warnings. The scan
+// stops with the error of ctx once it is cancelled.
+func (s *ServerService) FindDuplicates(ctx context.Context) ([]jellyfinModels.DuplicateResult, []ScanWarning, error) {
 	logrus.Info("Starting duplicate detection process...")
+	// The Jellyfin calls of the scan are counted, and limited by scan.max_api_calls
+	budget := s.newCallBudget()
+	ctx = jellyfinClients.WithCallBudget(ctx, budget)
+	defer func() {
+		s.scans.RecordAPICalls(budget.Total(), budget.ByEndpoint())
+	}()
 	// Get all movies with multi-user play status from Jellyfin
-	movies, err := s.GetMultiUserPlayStatus()
+	movies, err := s.GetMultiUserPlayStatus(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
 
 	logrus.Infof("Analyzing %d movies for duplicates", len(movies))
+	s.cacheMetadataHealth(movies)
 
-	var duplicates []jellyfinModels.DuplicateResult
-
-	// Create a map to group movies by their Name and ProductionYear
-	movieMap := make(map[string][]jellyfinModels.Movie)
+	traktWatched := s.loadTraktWatched()
+	playlists := s.loadPlaylistIndex(ctx)
 
-	for _, movie := range movies {
-		// Use Name-ProductionYear as the key
-		// This handles cases where movies have the same name but different years
-		key := fmt.Sprintf("%s-%d", movie.Name, movie.ProductionYear)
+	// Identical files found by filesystem duplicate finders are paired whatever their names
+	externalFingerprints := s.externalFingerprints()
 
-		movieMap[key] = append(movieMap[key], movie)
+	items := make([]dedupe.Item, len(movies))
+	s.scans.progress.Start(constants.ScanAnalyzing, len(movies))
+	for i := range movies {
+		// Fingerprinting reads the files, which may take a while on large libraries
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		s.scans.progress.Advance(1)
+		movies[i].Playlists = playlists[movies[i].ID]
+		if _, part, ok := dedupe.ParseMultiPart(movies[i].Path); ok {
+			movies[i].Part = part
+		}
+		movies[i].FileModified = s.fileModified(movies[i])
+		movies[i].ContentFingerprint = s.contentFingerprint(movies[i])
+		if movies[i].ContentFingerprint == "" {
+			movies[i].ContentFingerprint = externalFingerprints[normalizeLookupPath(movies[i].Path)]
+		}
+		items[i] = dedupeItem(movies[i])
 	}
 
-	// Find duplicates by checking groups with more than one movie
-	logrus.Infof("Found %d unique movie groups", len(movieMap))
-	for _, group := range movieMap {
-		if len(group) > 1 {
-			// Compare all pairs in the group
-			for i := 0; i < len(group); i++ {
-				for j := i + 1; j < len(group); j++ {
-					similarity := utils.CalculatePathSimilarity(group[i].Path, group[j].Path)
-					isDuplicate := similarity >= 95
-
-					// Check if movies have identical play status
-					hasIdenticalPlayStatus := s.HasIdenticalPlayStatus(group[i], group[j])
+	thresholds := s.tunedThresholds()
+	scanConfig := s.liveConfig().Scan
+	recommend := keepRuleRecommendation(scanConfig.KeepRule)
+	result := dedupe.Find(items, dedupe.Options{
+		MinGroupSize:     scanConfig.MinGroupSize,
+		MaxPairsPerGroup: scanConfig.MaxPairsPerGroup,
+		YearTolerance:    scanConfig.YearTolerance,
+		GroupKey:         groupKey(scanConfig.Grouping),
+		Skip: func(index1, index2 int) bool {
+			return s.isPairDismissed(movies[index1], movies[index2])
+		},
+		PairThreshold: func(index1, index2 int) int {
+			return pairThreshold(thresholds, movies[index1], movies[index2])
+		},
+		Recommend: recommend,
+	})
+	logrus.Infof("Found %d unique movie groups", result.Groups)
+	for _, warning := range result.Warnings {
+		logrus.Warnf("Pair cap reached: %s", warning)
+	}
 
-					duplicates = append(duplicates, jellyfinModels.DuplicateResult{
-						Movie1:                 group[i],
-						Movie2:                 group[j],
-						IsDuplicate:            isDuplicate,
-						Similarity:             similarity,
-						HasIdenticalPlayStatus: hasIdenticalPlayStatus,
-					})
+	duplicates := make([]jellyfinModels.DuplicateResult, 0, len(result.Pairs))
+	s.scans.progress.Start(constants.ScanComparing, len(result.Pairs))
+	for _, pair := range result.Pairs {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		s.scans.progress.Advance(1)
+		movie1, movie2 := movies[pair.Index1], movies[pair.Index2]
+		discrepancies := s.GetPlayStatusDiscrepancies(movie1, movie2)
+
+		// The copies of duplicates are inspected to recommend the copy to delete from their actual streams
+		if pair.IsDuplicate && s.ffprobe != nil {
+			movie1.Inspection, movie2.Inspection = s.inspect(movie1), s.inspect(movie2)
+			if movie1.Inspection != nil && movie2.Inspection != nil {
+				pair.RecommendedDeleteID = ""
+				if item, ok := recommend(inspectedItem(movie1), inspectedItem(movie2)); ok {
+					pair.RecommendedDeleteID = item.ID
 				}
 			}
 		}
+
+		// Pairs marked as not duplicates are kept as mismatches, they may still be misnamed files
+		if pair.IsDuplicate && s.store.IsFalsePositive(PairFingerprint(movie1, movie2)) {
+			pair.IsDuplicate = false
+			pair.RecommendedDeleteID = ""
+		}
+		// Linked copies are a single file, neither has a better quality
+		link := s.detectLink(movie1, movie2)
+		if link != nil {
+			pair.RecommendedDeleteID = ""
+		}
+		reclaimable := reclaimableSize(movie1, movie2, pair.RecommendedDeleteID, link)
+		belowMinSize := s.belowMinReclaimableSize(reclaimable)
+		if belowMinSize {
+			pair.RecommendedDeleteID = ""
+		}
+
+		duplicate := jellyfinModels.DuplicateResult{
+			ID:                       pair.ID,
+			Movie1:                   movie1,
+			Movie2:                   movie2,
+			IsDuplicate:              pair.IsDuplicate,
+			Similarity:               pair.Similarity,
+			HasIdenticalPlayStatus:   s.HasIdenticalPlayStatus(movie1, movie2),
+			RecommendedDeleteID:      pair.RecommendedDeleteID,
+			Tracks:                   CompareTracks(movie1, movie2, pair.RecommendedDeleteID),
+			PlayStatusDiscrepancies:  discrepancies,
+			HasPlayStatusDiscrepancy: len(discrepancies) > 0,
+			TraktDiscrepancies:       s.GetTraktDiscrepancies(movie1, movie2, traktWatched),
+			Link:                     link,
+			ReclaimableSize:          reclaimable,
+			BelowMinReclaimableSize:  belowMinSize,
+			SameStem:                 pair.SameStem,
+			SameContent:              pair.SameContent,
+		}
+		duplicate.Severity = s.duplicateSeverity(duplicate)
+		duplicate.UsersAffected = usersAffected(duplicate.PlayStatusDiscrepancies, duplicate.TraktDiscrepancies)
+		duplicate.PositionConflicts = positionConflicts(movie1, movie2)
+		duplicate.HasPositionConflict = len(duplicate.PositionConflicts) > 0
+		duplicates = append(duplicates, duplicate)
+	}
+
+	logrus.Infof("Duplicate detection completed. Found %d duplicate pairs with %d Jellyfin calls", len(duplicates), budget.Total())
+	s.checkAPICallGrowth(budget.Total())
+	return duplicates, result.Warnings, nil
+}
+
+// dedupeItem describes a movie for the detection engine
+func dedupeItem(movie jellyfinModels.Movie) dedupe.Item {
+	return dedupe.Item{
+		ID:                 movie.ID,
+		Name:               movie.Name,
+		Year:               movie.ProductionYear,
+		Path:               movie.Path,
+		Size:               movie.Size(),
+		Bitrate:            bitrate(movie),
+		Date:               movie.FileDate(),
+		ProviderIDs:        providerIDs(movie),
+		ContentFingerprint: movie.ContentFingerprint,
+	}
+}
+
+// inspectedItem describes an inspected movie for the detection engine, with the streams read by ffprobe
+func inspectedItem(movie jellyfinModels.Movie) dedupe.Item {
+	item := dedupeItem(movie)
+	if movie.Inspection.VideoBitrate > 0 {
+		item.Bitrate = movie.Inspection.VideoBitrate
+	}
+	item.Interlaced = movie.Inspection.Interlaced
+	item.AudioChannels = movie.Inspection.AudioChannels
+	return item
+}
+
+// providerIDs lists the external IDs of a movie, prefixed with their provider
+func providerIDs(movie jellyfinModels.Movie) []string {
+	var ids []string
+	if movie.ProviderIds.Tmdb != "" {
+		ids = append(ids, "tmdb:"+movie.ProviderIds.Tmdb)
+	}
+	if movie.ProviderIds.Imdb != "" {
+		ids = append(ids, "imdb:"+movie.ProviderIds.Imdb)
+	}
+	return ids
+}
+
+// groupKey returns how the detection engine groups the movies compared with each other
+func groupKey(grouping constants.Grouping) func(item dedupe.Item) string {
+	if grouping == constants.FolderGrouping {
+		return dedupe.FolderGroupKey
+	}
+	return dedupe.Item.GroupKey
+}
+
+// keepRuleRecommendation returns how the detection engine picks the copy to delete with a keep rule
+func keepRuleRecommendation(rule constants.KeepRule) func(item1, item2 dedupe.Item) (dedupe.Item, bool) {
+	switch rule {
+	case constants.NewestKeepRule:
+		return dedupe.RecommendOlder
+	case constants.OldestKeepRule:
+		return dedupe.RecommendNewer
+	default:
+		return dedupe.Recommend
+	}
+}
+
+// fileModified reads the modification date of the file of a movie, through the path mappings, empty when
+// the file cannot be read
+func (s *ServerService) fileModified(movie jellyfinModels.Movie) string {
+	if movie.Path == "" {
+		return ""
+	}
+	info, err := os.Stat(s.pathMapper.ToLocal(movie.Path))
+	if err != nil {
+		return ""
+	}
+	return info.ModTime().UTC().Format(time.RFC3339)
+}
+
+// contentFingerprint identifies the recording of a home video, through the path mappings, empty for movies or
+// when the file cannot be read. Fingerprints are cached by file until its size or modification date changes.
+func (s *ServerService) contentFingerprint(movie jellyfinModels.Movie) string {
+	if s.fingerprinter == nil || !movie.HomeVideo || movie.Path == "" {
+		return ""
+	}
+	localPath := s.pathMapper.ToLocal(movie.Path)
+	key, err := fileCacheKey(localPath)
+	if err != nil {
+		logrus.Debugf("Failed to read %s to fingerprint it: %v", localPath, err)
+		return ""
+	}
+	if fingerprint, found := s.fingerprints.Get(key); found {
+		return fingerprint
+	}
+	fingerprint, err := s.fingerprinter.Fingerprint(localPath, time.Duration(movie.RunTimeTicks)*jellyfinModels.TickDuration)
+	if err != nil {
+		logrus.Warnf("Failed to fingerprint %s: %v", movie.Path, err)
+		return ""
+	}
+	s.fingerprints.Set(key, fingerprint)
+	return fingerprint
+}
+
+// inspect reads the streams of the file of a movie with ffprobe, through the path mappings, nil when the file
+// cannot be read. Inspections are cached by file until its size or modification date changes.
+func (s *ServerService) inspect(movie jellyfinModels.Movie) *jellyfinModels.MediaInspection {
+	if movie.Path == "" {
+		return nil
+	}
+	localPath := s.pathMapper.ToLocal(movie.Path)
+	key, err := fileCacheKey(localPath)
+	if err != nil {
+		logrus.Debugf("Failed to read %s to inspect it: %v", localPath, err)
+		return nil
+	}
+
+	inspection, found := s.inspections.Get(key)
+	if !found {
+		inspection, err = s.ffprobe.Inspect(localPath)
+		if err != nil {
+			logrus.Warnf("Failed to inspect %s: %v", movie.Path, err)
+			return nil
+		}
+		s.inspections.Set(key, inspection)
 	}
+	return &jellyfinModels.MediaInspection{
+		VideoBitrate:  inspection.VideoBitrate,
+		Interlaced:    inspection.Interlaced,
+		AudioChannels: inspection.AudioChannels,
+		AudioLayout:   inspection.AudioLayout,
+	}
+}
+
+// fileCacheKey identifies the version of a local file by its path, size and modification date, to cache
+// what is read from it
+func fileCacheKey(localPath string) (string, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s|%d|%d", localPath, info.Size(), info.ModTime().UnixNano()), nil
+}
 
-	logrus.Infof("Duplicate detection completed. Found %d duplicate pairs", len(duplicates))
-	return duplicates, nil
+// PairFingerprint builds a stable identifier for the content of a pair of movies, independent of their
+// order and of their item IDs, so that decisions on the pair survive Jellyfin rescans
+func PairFingerprint(movie1, movie2 jellyfinModels.Movie) string {
+	return dedupe.PairID(movie1.Fingerprint(), movie2.Fingerprint())
+}
+
+// isPairDismissed checks if a pair was ignored, resolved manually, kept as intentional versions or is in
+// libraries ignoring their duplicates, and is left out of the results
+func (s *ServerService) isPairDismissed(movie1, movie2 jellyfinModels.Movie) bool {
+	fingerprint := PairFingerprint(movie1, movie2)
+	return s.store.IsPairIgnored(fingerprint, dedupe.PairID(movie1.ID, movie2.ID)) || s.store.IsPairResolved(fingerprint) ||
+		s.store.IsVersionPair(fingerprint) || s.pairPolicy(movie1, movie2) == constants.IgnorePolicy
+}
+
+func bitrate(movie jellyfinModels.Movie) int64 {
+	if len(movie.MediaSources) == 0 {
+		return 0
+	}
+	return movie.MediaSources[0].Bitrate
 }
 
 // HasIdenticalPlayStatus checks if two movies have identical play status for all users
@@ -170,68 +607,230 @@ func (s *ServerService) GetPlayStatusDiscrepancies(movie1, movie2 jellyfinModels
 	var discrepancies []jellyfinModels.PlayStatusDiscrepancy
 
 	// Create maps for quick lookup
-	movie1SeenBy := make(map[string]bool)
-	movie2SeenBy := make(map[string]bool)
+	movie1Statuses := lo.KeyBy(movie1.UserPlayStatuses, func(status jellyfinModels.UserPlayStatus) string { return status.UserID })
+	movie2Statuses := lo.KeyBy(movie2.UserPlayStatuses, func(status jellyfinModels.UserPlayStatus) string { return status.UserID })
 
+	// Check if movie1 is seen by users who haven't seen movie2
 	for _, status := range movie1.UserPlayStatuses {
-		if status.Played {
-			movie1SeenBy[status.UserID] = true
+		if other := movie2Statuses[status.UserID]; status.Played && !other.Played {
+			discrepancies = append(discrepancies, newDiscrepancy(status, other, movie2))
 		}
 	}
 
+	// Check if movie2 is seen by users who haven't seen movie1
 	for _, status := range movie2.UserPlayStatuses {
-		if status.Played {
-			movie2SeenBy[status.UserID] = true
+		if other := movie1Statuses[status.UserID]; status.Played && !other.Played {
+			discrepancies = append(discrepancies, newDiscrepancy(status, other, movie1))
 		}
 	}
 
-	// Check if movie1 is seen by users who haven't seen movie2
-	for _, status := range movie1.UserPlayStatuses {
-		if status.Played && !movie2SeenBy[status.UserID] {
-			discrepancies = append(discrepancies, jellyfinModels.PlayStatusDiscrepancy{
-				UserID:        status.UserID,
-				UserName:      status.UserName,
-				MovieToUpdate: movie2.ID,
-				MovieName:     movie2.Name,
-			})
+	return discrepancies
+}
+
+// usersAffected counts the distinct users of the discrepancies of a pair
+func usersAffected(discrepancies ...[]jellyfinModels.PlayStatusDiscrepancy) int {
+	users := make(map[string]bool)
+	for _, list := range discrepancies {
+		for _, discrepancy := range list {
+			users[discrepancy.UserID] = true
 		}
 	}
+	return len(users)
+}
 
-	// Check if movie2 is seen by users who haven't seen movie1
-	for _, status := range movie2.UserPlayStatuses {
-		if status.Played && !movie1SeenBy[status.UserID] {
-			discrepancies = append(discrepancies, jellyfinModels.PlayStatusDiscrepancy{
-				UserID:        status.UserID,
-				UserName:      status.UserName,
-				MovieToUpdate: movie1.ID,
-				MovieName:     movie1.Name,
-			})
-		}
+// newDiscrepancy reports a user who has seen a copy but not movieToUpdate, with the plays of both copies
+func newDiscrepancy(seen, other jellyfinModels.UserPlayStatus, movieToUpdate jellyfinModels.Movie) jellyfinModels.PlayStatusDiscrepancy {
+	return jellyfinModels.PlayStatusDiscrepancy{
+		UserID:         seen.UserID,
+		UserName:       seen.UserName,
+		MovieToUpdate:  movieToUpdate.ID,
+		MovieName:      movieToUpdate.Name,
+		PlayCount:      seen.PlayCount,
+		PlayCountDelta: seen.PlayCount - other.PlayCount,
+		LastPlayedDate: seen.LastPlayedDate,
 	}
+}
 
-	return discrepancies
+// ErrItemChanged is returned when the file of a movie changed since it was shown to the user
+var ErrItemChanged = errors.New("movie file changed since the last scan")
+
+// ExpectedFile is the file a destructive action was requested for, as shown to the user.
+// An empty path or a zero size is not checked.
+type ExpectedFile struct {
+	Path string
+	Size int64
 }
 
-func (s *ServerService) DeleteMovie(movieID string) error {
+// check compares the expected file with the current state of the movie
+func (e ExpectedFile) check(movie jellyfinModels.Movie) error {
+	if e.Path != "" && e.Path != movie.Path {
+		return fmt.Errorf("%w: path is now %s instead of %s, reload the page and try again", ErrItemChanged, movie.Path, e.Path)
+	}
+	if e.Size > 0 && e.Size != movie.Size() {
+		return fmt.Errorf("%w: size is now %s instead of %s, reload the page and try again",
+			ErrItemChanged, humanize.Bytes(movie.Size()), humanize.Bytes(e.Size))
+	}
+	return nil
+}
 
-	// Call Jellyfin API to delete the movie
-	err := s.jellyfinClient.DeleteMovie(movieID)
+// DeleteOptions are the safety checks and preservation steps of a deletion
+type DeleteOptions struct {
+	// Expected is the file shown to the user, the movie is only deleted if it did not change
+	Expected *ExpectedFile
+	// KeepMovieID is the copy kept instead of the deleted one, which receives its missing sidecar files
+	KeepMovieID string
+}
+
+// DeleteMovie deletes a movie, after checking it did not change and transferring its sidecar files
+// to the kept copy when configured, then points the playlists to the kept copy once the movie is deleted
+func (s *ServerService) DeleteMovie(movieID string, options DeleteOptions) error {
+
+	// Resolve the movie before deletion, its path and library are unknown to Jellyfin afterwards
+	movie, operator, err := s.jellyfinClient.GetMovieAsOperator(movieID)
+	if errors.Is(err, jellyfinClients.ErrNotFound) {
+		s.pruneMissingItem(movieID)
+		return fmt.Errorf("movie was already deleted: %w", err)
+	}
+	if err != nil {
+		if s.config.Deletion.Backend == constants.FilesystemDeletion || options.Expected != nil {
+			return fmt.Errorf("failed to get movie: %v", err)
+		}
+		logrus.Warnf("Failed to get movie %s, no refresh will be triggered after deletion: %v", movieID, err)
+		movie = jellyfinModels.Movie{ID: movieID}
+	}
+
+	if options.Expected != nil {
+		if err := options.Expected.check(movie); err != nil {
+			logrus.Warnf("Refusing to delete movie %s (%s): %v", movie.Name, movieID, err)
+			return err
+		}
+	}
+
+	if s.config.Deletion.TransferSidecars && options.KeepMovieID != "" {
+		if err := s.transferSidecars(movie, options.KeepMovieID); err != nil {
+			logrus.Errorf("Failed to transfer sidecar files of movie %s (%s): %v", movie.Name, movieID, err)
+			return fmt.Errorf("failed to transfer sidecar files, movie not deleted: %v", err)
+		}
+	}
+
+	// Jellyfin removes the entries of deleted items from playlists, their positions are read first and the kept
+	// copy replaces them once the movie is deleted
+	var playlistEntries []playlistEntryRef
+	if s.config.Deletion.RepointPlaylists && options.KeepMovieID != "" {
+		playlistEntries, err = s.findPlaylistEntries(movieID, options.KeepMovieID)
+		if err != nil {
+			logrus.Errorf("Failed to read playlists of movie %s (%s): %v", movie.Name, movieID, err)
+			return fmt.Errorf("failed to read playlists, movie not deleted: %v", err)
+		}
+	}
+
+	libraryID := ""
+	if s.config.Deletion.Refresh == constants.LibraryRefresh {
+		if library, err := s.jellyfinClient.GetItemLibrary(movieID); err != nil {
+			logrus.Warnf("Failed to find library of movie %s: %v", movieID, err)
+		} else {
+			libraryID = library.ID
+		}
+	}
+
+	// Move the file to the trash when Jellyfin is not allowed to delete it
+	audit := constants.DeleteMovieAudit
+	if s.config.Deletion.Backend == constants.FilesystemDeletion {
+		audit = constants.TrashMovieAudit
+		err = s.trashMovie(movie)
+	} else {
+		// Call Jellyfin API to delete the movie
+		err = s.jellyfinClient.DeleteMovie(movieID)
+	}
+	if errors.Is(err, jellyfinClients.ErrNotFound) {
+		s.pruneMissingItem(movieID)
+		return fmt.Errorf("movie was already deleted: %w", err)
+	}
 	if err != nil {
 		logrus.Errorf("Failed to delete movie %s: %v", movieID, err)
 		return fmt.Errorf("failed to delete movie: %v", err)
 	}
+	s.recordAudit(audit, movieID, movie.Name, operator, movie.Path)
+	s.repointPlaylists(movieID, options.KeepMovieID, playlistEntries)
+
+	s.refreshAfterDeletion(movie, libraryID)
 
 	return nil
 }
 
+// transferSidecars copies the sidecar files of the movie about to be deleted next to the kept copy
+func (s *ServerService) transferSidecars(movie jellyfinModels.Movie, keepMovieID string) error {
+	if movie.Path == "" {
+		return fmt.Errorf("movie %s has no path", movie.ID)
+	}
+
+	kept, err := s.jellyfinClient.GetMovie(keepMovieID)
+	if err != nil {
+		return fmt.Errorf("failed to get kept movie: %v", err)
+	}
+	if kept.Path == "" {
+		return fmt.Errorf("kept movie %s has no path", kept.ID)
+	}
+
+	copied, err := filesystem.TransferSidecars(s.pathMapper.ToLocal(movie.Path), s.pathMapper.ToLocal(kept.Path))
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Transferred %d sidecar files from %s to %s", len(copied), movie.Path, kept.Path)
+	return nil
+}
+
+// trashMovie moves the movie file to the trash directory
+func (s *ServerService) trashMovie(movie jellyfinModels.Movie) error {
+	if movie.Path == "" {
+		return fmt.Errorf("movie %s has no path", movie.ID)
+	}
+
+	localPath := s.pathMapper.ToLocal(movie.Path)
+	if _, err := s.trash.Move(localPath); err != nil {
+		logrus.Errorf("Failed to trash movie %s (%s): %v", movie.Name, movie.ID, err)
+		return fmt.Errorf("failed to trash movie: %v", err)
+	}
+
+	return nil
+}
+
+// refreshAfterDeletion asks Jellyfin to rescan what was affected by a deletion, according to the configuration.
+// The item is already gone, so a failed refresh only delays Jellyfin noticing it.
+func (s *ServerService) refreshAfterDeletion(movie jellyfinModels.Movie, libraryID string) {
+	switch s.config.Deletion.Refresh {
+	case constants.FolderRefresh:
+		if movie.Path == "" {
+			return
+		}
+		folder := filesystem.JellyfinDir(movie.Path)
+		if err := s.jellyfinClient.NotifyMediaUpdated(folder, "Modified"); err != nil {
+			logrus.Warnf("Failed to trigger refresh of folder %s: %v", folder, err)
+		}
+	case constants.LibraryRefresh:
+		if libraryID == "" {
+			return
+		}
+		if err := s.jellyfinClient.RefreshItem(libraryID); err != nil {
+			logrus.Warnf("Failed to trigger refresh of library %s: %v", libraryID, err)
+		}
+	}
+}
+
 func (s *ServerService) MarkMovieAsSeen(movieID, userID string) error {
 
 	// Get movie and user names for better logging
 	movieName := movieID // fallback to ID if name retrieval fails
 	userName := userID   // fallback to ID if name retrieval fails
 
-	if retrievedMovieName, err := s.jellyfinClient.GetMovieName(movieID); err == nil {
-		movieName = retrievedMovieName
+	// The operator whose access resolved the movie is recorded in the audit log
+	operator := ""
+	if movie, movieOperator, err := s.jellyfinClient.GetMovieAsOperator(movieID); err == nil {
+		operator = movieOperator
+		if movie.Name != "" {
+			movieName = movie.Name
+		}
 	}
 
 	if retrievedUserName, err := s.jellyfinClient.GetUserName(userID); err == nil {
@@ -240,14 +839,27 @@ func (s *ServerService) MarkMovieAsSeen(movieID, userID string) error {
 
 	// Call Jellyfin API to mark movie as played
 	err := s.jellyfinClient.MarkMovieAsPlayed(movieID, userID, movieName, userName)
+	if errors.Is(err, jellyfinClients.ErrNotFound) {
+		s.pruneMissingItem(movieID)
+		return fmt.Errorf("movie was deleted from Jellyfin: %w", err)
+	}
 	if err != nil {
 		logrus.Errorf("Failed to mark movie %s (%s) as played for user %s (%s): %v", movieName, movieID, userName, userID, err)
 		return fmt.Errorf("failed to mark movie as played: %v", err)
 	}
+	s.recordAudit(constants.MarkPlayedAudit, movieID, movieName, operator, "for "+userName)
 
 	return nil
 }
 
+// pruneMissingItem removes the pairs of an item deleted outside of the application from the latest scan,
+// so that they are not offered again before the next scan
+func (s *ServerService) pruneMissingItem(movieID string) {
+	if pruned := s.scans.Prune(movieID); pruned > 0 {
+		logrus.Warnf("Movie %s was deleted outside of the application, removed %d pairs from the latest scan", movieID, pruned)
+	}
+}
+
 func IsUUIDFormtatted(id string) bool {
 	if len(id) < 32 || len(id) > 36 {
 		return false
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	confModels "jellyfin-duplicate/configuration/models"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/storage"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Setting is a setting which can be changed through the admin API
+type Setting struct {
+	Key string `json:"key"`
+	// Value is the value in use, the one of the configuration file unless changed through the API
+	Value json.RawMessage `json:"value"`
+	// StoredValue is the value changed through the API, absent when the configuration file applies. It differs
+	// from Value for the settings read at startup until the application restarts.
+	StoredValue json.RawMessage `json:"stored_value,omitempty"`
+	Restart     bool            `json:"restart"`
+}
+
+// liveConfig returns a copy of the configuration. The settings changed through the admin API are applied to the
+// shared configuration while requests read it, they are read through this copy outside of the scans.
+func (s *ServerService) liveConfig() confModels.Config {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	return *s.config
+}
+
+// Settings returns the settings which can be changed through the admin API, with their values
+func (s *ServerService) Settings() ([]Setting, error) {
+	stored, err := confServices.StoredSettings(s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	config := s.liveConfig()
+	settings := make([]Setting, 0, len(confServices.TunableSettings))
+	for _, tunable := range confServices.TunableSettings {
+		value, err := confServices.SettingValue(&config, tunable.Key)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, Setting{Key: tunable.Key, Value: value, StoredValue: stored[tunable.Key], Restart: tunable.Restart})
+	}
+	return settings, nil
+}
+
+// UpdateSettings stores the changed settings by key, a null value restoring the one of the configuration file.
+// The settings read at startup are returned, the other ones are applied once the running scan finishes, while
+// no request reads them.
+func (s *ServerService) UpdateSettings(changes map[string]json.RawMessage) ([]string, error) {
+	reloaded, err := confServices.SaveSettings(s.config, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	restartRequired := []string{}
+	s.scans.Exclusive(func() {
+		s.configMutex.Lock()
+		defer s.configMutex.Unlock()
+		for _, tunable := range confServices.TunableSettings {
+			if _, changed := changes[tunable.Key]; !changed {
+				continue
+			}
+			if tunable.Restart {
+				restartRequired = append(restartRequired, tunable.Key)
+				continue
+			}
+			// The reloaded configuration was validated, its values apply as they are
+			value, err := confServices.SettingValue(reloaded, tunable.Key)
+			if err == nil {
+				err = confServices.ApplySetting(s.config, tunable.Key, value)
+			}
+			if err != nil {
+				logrus.Errorf("Failed to apply setting %s: %v", tunable.Key, err)
+			}
+		}
+		s.notifier.SetMuted(s.config.Notifications.Muted)
+	})
+
+	logrus.Infof("%d settings changed through the admin API, %d apply after a restart", len(changes), len(restartRequired))
+	return restartRequired, nil
+}
+
+// GET /api/admin/settings
+// GetSettings lists the settings which can be changed at runtime, with their values
+func (h *Handler) GetSettings(ctx *gin.Context) {
+	settings, err := h.serverService.Settings()
+	if err != nil {
+		logrus.Errorf("Error reading settings: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"settings": settings,
+	})
+}
+
+// PUT /api/admin/settings
+// UpdateSettings changes settings from an object of values by key, kept by the storage backend so that they
+// survive restarts. A null value restores the value of the configuration file.
+func (h *Handler) UpdateSettings(ctx *gin.Context) {
+	var changes map[string]json.RawMessage
+	if err := ctx.ShouldBindJSON(&changes); err != nil || len(changes) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "an object of setting values by key is required",
+		})
+		return
+	}
+
+	restartRequired, err := h.serverService.UpdateSettings(changes)
+	if errors.Is(err, confServices.ErrInvalidSettings) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": "settings were changed by another instance, reload them and try again",
+		})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Error saving settings: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.serverService.Settings()
+	if err != nil {
+		logrus.Errorf("Error reading settings: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"message":          fmt.Sprintf("%d settings saved", len(changes)),
+		"settings":         settings,
+		"restart_required": restartRequired,
+	})
+}
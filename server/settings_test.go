@@ -0,0 +1,56 @@
+package server_test
+
+import (
+	"fmt"
+	"jellyfin-duplicate/demo"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// TestSettingsConcurrentRequests changes the settings while they are read, for go test -race to catch the
+// settings applied while other requests read the configuration
+func TestSettingsConcurrentRequests(t *testing.T) {
+	if !testing.Verbose() {
+		logrus.SetLevel(logrus.ErrorLevel)
+	}
+	gin.SetMode(gin.TestMode)
+
+	demoServer, err := demo.Start(demo.Generate())
+	if err != nil {
+		t.Fatalf("Failed to start the demo server: %v", err)
+	}
+	t.Cleanup(func() { demoServer.Close() })
+	router := newE2ERouter(t, demoServer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"scan.max_pairs_per_group": %d, "notifications.muted": %t, "early_warning.days": %d}`, 100+i, i%2 == 0, 1+i)
+			request := httptest.NewRequest(http.MethodPut, "/api/admin/settings", strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			// Concurrent changes of the stored settings are rejected, to be made again
+			if recorder.Code != http.StatusOK && recorder.Code != http.StatusConflict {
+				t.Errorf("PUT returned status %d: %s", recorder.Code, recorder.Body)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/admin/settings", nil))
+			if recorder.Code != http.StatusOK {
+				t.Errorf("GET returned status %d: %s", recorder.Code, recorder.Body)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,476 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"jellyfin-duplicate/auth"
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	conf_models "jellyfin-duplicate/configuration/models"
+	confServices "jellyfin-duplicate/configuration/services"
+	"jellyfin-duplicate/constants"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// setupTokenHeader carries the setup token of the API calls of the setup wizard
+const setupTokenHeader = "X-Setup-Token"
+
+// setupShutdownTimeout is how long the setup wizard waits for its last requests before the application starts
+const setupShutdownTimeout = 10 * time.Second
+
+// setupHandler serves the setup wizard, shown instead of the application while Jellyfin is not configured
+type setupHandler struct {
+	config *conf_models.Config
+	// pages renders the templates with the theme, locale and base path of the application
+	pages *Handler
+	// token is printed in the logs, so that only who can read them configures the application
+	token string
+	// throttle locks out the clients sending wrong tokens
+	throttle *auth.Throttle
+	// done receives the configuration once the setup is completed
+	done chan *conf_models.Config
+
+	// completeMutex serializes the completions, the first one starts the application
+	completeMutex sync.Mutex
+	completed     bool
+
+	mutex sync.Mutex
+	// pending are the Quick Connect requests waiting for approval, approved the credentials they were issued,
+	// both keyed by their secret. Access tokens never leave the server.
+	pending  map[string]pendingQuickConnect
+	approved map[string]conf_models.JellyfinCredentials
+}
+
+// pendingQuickConnect is a Quick Connect request, polled with the client which initiated it
+type pendingQuickConnect struct {
+	serverURL string
+	client    *jellyfinClients.Client
+}
+
+// setupConnection is a Jellyfin server and how to authenticate to it, an API key or an approved Quick Connect request
+type setupConnection struct {
+	URL    string                       `json:"url"`
+	Method constants.JellyfinAuthMethod `json:"method"`
+	APIKey string                       `json:"api_key"`
+	// Secret identifies the approved Quick Connect request, whose access token is kept by the server
+	Secret string `json:"secret"`
+	// UserID is the admin user the application acts on behalf of, the first one by default
+	UserID string `json:"user_id"`
+}
+
+// setupSession is a tested connection to Jellyfin, with the credentials to store
+type setupSession struct {
+	credentials conf_models.JellyfinCredentials
+	admins      []jellyfinModels.User
+	server      jellyfinModels.SystemInfo
+	client      *jellyfinClients.Client
+}
+
+// RunSetup serves the setup wizard until it is completed, and returns the configuration it wrote.
+// It is called when Jellyfin is not configured, instead of failing to start.
+func RunSetup(config *conf_models.Config) (*conf_models.Config, error) {
+	templates, err := LoadTemplates("server/templates", gin.IsDebugging())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load templates: %v", err)
+	}
+
+	setup := &setupHandler{
+		config:   config,
+		pages:    &Handler{config: config},
+		token:    newSetupToken(),
+		throttle: auth.NewThrottle(config.Auth),
+		done:     make(chan *conf_models.Config, 1),
+		pending:  make(map[string]pendingQuickConnect),
+		approved: make(map[string]conf_models.JellyfinCredentials),
+	}
+
+	r := gin.Default()
+	if err := r.SetTrustedProxies(config.Auth.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid auth.trusted_proxies: %v", err)
+	}
+	r.HTMLRender = templates
+	routes := r.Group(config.BasePath)
+	if config.BasePath != "" {
+		r.GET("/", setup.RedirectToSetup)
+	}
+	routes.GET("/", setup.RedirectToSetup)
+	routes.GET("/setup", setup.GetSetupPage)
+	api := routes.Group("/api/setup", setup.requireSetupToken)
+	api.POST("/connect", setup.Connect)
+	api.POST("/quick-connect", setup.InitiateQuickConnect)
+	api.GET("/quick-connect/:secret", setup.GetQuickConnectState)
+	api.POST("/complete", setup.Complete)
+
+	servers, err := Listen(config, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve the setup wizard: %v", err)
+	}
+	for _, url := range URLs(config) {
+		logrus.Warnf("Setup required, open %s/setup?token=%s to configure the application", url, setup.token)
+	}
+
+	var configured *conf_models.Config
+	select {
+	case configured = <-setup.done:
+	case err := <-servers.Errors():
+		return nil, fmt.Errorf("failed to serve the setup wizard: %v", err)
+	}
+
+	// The response completing the setup is sent before the application takes over the port
+	ctx, cancel := context.WithTimeout(context.Background(), setupShutdownTimeout)
+	defer cancel()
+	if err := servers.Shutdown(ctx); err != nil {
+		logrus.Warnf("Failed to stop the setup wizard: %v", err)
+	}
+	logrus.Info("Setup completed")
+	return configured, nil
+}
+
+// requireSetupToken rejects the API calls without the setup token
+func (s *setupHandler) requireSetupToken(ctx *gin.Context) {
+	if !s.checkToken(ctx, ctx.GetHeader(setupTokenHeader)) {
+		if !ctx.IsAborted() {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Valid setup token required"})
+		}
+		return
+	}
+	ctx.Next()
+}
+
+// checkToken verifies the setup token of a request, locking the client out after repeated wrong tokens.
+// The request is rejected with 429 Too Many Requests while the client is locked out.
+func (s *setupHandler) checkToken(ctx *gin.Context, token string) bool {
+	client := ctx.ClientIP()
+	if wait, locked := s.throttle.Locked(client); locked {
+		rejectThrottled(ctx, wait)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+		return true
+	}
+	if s.throttle.Fail(client) {
+		logrus.Warnf("Locking out %s for %d minutes after repeated wrong setup tokens", client, s.config.Auth.LockoutDuration)
+	}
+	return false
+}
+
+// RedirectToSetup sends every page to the setup wizard, keeping the token of the link
+func (s *setupHandler) RedirectToSetup(ctx *gin.Context) {
+	location := s.config.BasePath + "/setup"
+	if ctx.Request.URL.RawQuery != "" {
+		location += "?" + ctx.Request.URL.RawQuery
+	}
+	ctx.Redirect(http.StatusFound, location)
+}
+
+// GET /setup
+// GetSetupPage renders the setup wizard, for the link with the token printed in the logs
+func (s *setupHandler) GetSetupPage(ctx *gin.Context) {
+	if !s.checkToken(ctx, ctx.Query("token")) {
+		if ctx.IsAborted() {
+			return
+		}
+		ctx.HTML(http.StatusUnauthorized, "error.html", s.pages.templateData(ctx, gin.H{
+			"error": "Setup required: open the setup link printed in the application logs",
+		}))
+		return
+	}
+
+	ctx.HTML(http.StatusOK, "setup.html", s.pages.templateData(ctx, gin.H{
+		"token":      s.token,
+		"serverURL":  s.config.Jellyfin.URL,
+		"apiKeyAuth": constants.APIKeyAuth,
+		"quickAuth":  constants.QuickConnectAuth,
+		"scan":       s.config.Scan,
+		"deletion":   s.config.Deletion,
+	}))
+}
+
+// POST /api/setup/connect
+// Connect tests a connection to Jellyfin, and returns its admin users and the libraries of the chosen one
+func (s *setupHandler) Connect(ctx *gin.Context) {
+	var connection setupConnection
+	if err := ctx.ShouldBindJSON(&connection); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
+		return
+	}
+
+	session, err := s.connect(connection)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	libraries, err := session.client.GetLibraries()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to list libraries: %v", err)})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Connected to %s (Jellyfin %s)", session.server.ServerName, session.server.Version),
+		"user_id": session.credentials.UserID,
+		"users": lo.Map(session.admins, func(user jellyfinModels.User, _ int) gin.H {
+			return gin.H{"id": user.ID, "name": user.Name}
+		}),
+		"libraries": lo.Map(libraries, func(library jellyfinModels.Library, _ int) string {
+			return library.Name
+		}),
+	})
+}
+
+// POST /api/setup/quick-connect
+// InitiateQuickConnect requests a Quick Connect code, to be approved by an administrator from Jellyfin
+func (s *setupHandler) InitiateQuickConnect(ctx *gin.Context) {
+	var request struct {
+		URL string `json:"url"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	serverURL := normalizeServerURL(request.URL)
+	if serverURL == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "the Jellyfin URL is required"})
+		return
+	}
+
+	// The requests are not authenticated, the token is issued for the device identity of the application
+	client := s.newClient(serverURL, "", "")
+	if _, err := client.DetectServerVersion(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to reach %s: %v", serverURL, err)})
+		return
+	}
+	enabled, err := client.IsQuickConnectEnabled()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to check Quick Connect: %v", err)})
+		return
+	}
+	if !enabled {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "Quick Connect is disabled: enable it in the Jellyfin dashboard (General > Quick Connect), or use an API key",
+		})
+		return
+	}
+
+	quickConnect, err := client.InitiateQuickConnect()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to initiate Quick Connect: %v", err)})
+		return
+	}
+
+	s.mutex.Lock()
+	s.pending[quickConnect.Secret] = pendingQuickConnect{serverURL: serverURL, client: client}
+	s.mutex.Unlock()
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"code":    quickConnect.Code,
+		"secret":  quickConnect.Secret,
+	})
+}
+
+// GET /api/setup/quick-connect/:secret
+// GetQuickConnectState checks if the Quick Connect code was approved, the access token is then issued
+// and kept by the server
+func (s *setupHandler) GetQuickConnectState(ctx *gin.Context) {
+	secret := ctx.Param("secret")
+
+	s.mutex.Lock()
+	credentials, approved := s.approved[secret]
+	pending, found := s.pending[secret]
+	s.mutex.Unlock()
+	if approved {
+		ctx.JSON(http.StatusOK, gin.H{"success": true, "authenticated": true, "user": credentials.UserName})
+		return
+	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "unknown Quick Connect request, request a new code"})
+		return
+	}
+
+	state, err := pending.client.GetQuickConnectState(secret)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to check the approval of the code: %v", err)})
+		return
+	}
+	if !state.Authenticated {
+		ctx.JSON(http.StatusOK, gin.H{"success": true, "authenticated": false})
+		return
+	}
+
+	authentication, err := pending.client.AuthenticateWithQuickConnect(secret)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to get an access token: %v", err)})
+		return
+	}
+	// Listing users and deleting items require an administrator
+	if !authentication.User.Policy.IsAdministrator {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%s is not an administrator, approve a new code with an administrator account", authentication.User.Name),
+		})
+		return
+	}
+
+	s.mutex.Lock()
+	delete(s.pending, secret)
+	s.approved[secret] = conf_models.JellyfinCredentials{
+		Method:      constants.QuickConnectAuth,
+		ServerURL:   pending.serverURL,
+		AccessToken: authentication.AccessToken,
+		UserID:      authentication.User.ID,
+		UserName:    authentication.User.Name,
+	}
+	s.mutex.Unlock()
+
+	ctx.JSON(http.StatusOK, gin.H{"success": true, "authenticated": true, "user": authentication.User.Name})
+}
+
+// POST /api/setup/complete
+// Complete tests the connection again, stores the credentials, writes the choices to the configuration file
+// and starts the application
+func (s *setupHandler) Complete(ctx *gin.Context) {
+	var request struct {
+		setupConnection
+		Choices confServices.SetupChoices `json:"choices"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body: " + err.Error()})
+		return
+	}
+
+	s.completeMutex.Lock()
+	defer s.completeMutex.Unlock()
+	if s.completed {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "the setup is already completed"})
+		return
+	}
+
+	session, err := s.connect(request.setupConnection)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credentials := session.credentials
+	credentials.AuthorizedAt = time.Now()
+	if err := confServices.SaveJellyfinCredentials(s.config.DataDir, credentials); err != nil {
+		logrus.Errorf("Error completing setup: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	configured, err := confServices.WriteSetupChoices(s.config, request.Choices)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Environment variables take precedence over the stored credentials
+	if err := confServices.CheckJellyfinCredentials(configured); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("the stored credentials are not used: %v", err)})
+		return
+	}
+
+	s.completed = true
+	s.done <- configured
+	logrus.Infof("Setup completed by %s for %s", credentials.UserName, credentials.ServerURL)
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Setup completed, the application is starting",
+	})
+}
+
+// connect tests a connection: the server is reached, the credentials list its users, and the admin user is chosen
+func (s *setupHandler) connect(connection setupConnection) (setupSession, error) {
+	serverURL := normalizeServerURL(connection.URL)
+	if serverURL == "" {
+		return setupSession{}, fmt.Errorf("the Jellyfin URL is required")
+	}
+
+	credentials := conf_models.JellyfinCredentials{Method: connection.Method, ServerURL: serverURL}
+	switch connection.Method {
+	case constants.APIKeyAuth:
+		credentials.AccessToken = strings.TrimSpace(connection.APIKey)
+		if credentials.AccessToken == "" {
+			return setupSession{}, fmt.Errorf("the API key is required")
+		}
+	case constants.QuickConnectAuth:
+		s.mutex.Lock()
+		approved, found := s.approved[connection.Secret]
+		s.mutex.Unlock()
+		if !found || approved.ServerURL != serverURL {
+			return setupSession{}, fmt.Errorf("the Quick Connect code of %s is not approved yet", serverURL)
+		}
+		credentials = approved
+	default:
+		return setupSession{}, fmt.Errorf("unknown authentication method %q", connection.Method)
+	}
+
+	probe := s.newClient(serverURL, credentials.AccessToken, "")
+	info, err := probe.GetPublicSystemInfo()
+	if err != nil {
+		return setupSession{}, fmt.Errorf("failed to reach %s: %v", serverURL, err)
+	}
+	users, err := probe.GetAllUsers()
+	if err != nil {
+		return setupSession{}, fmt.Errorf("failed to list users, check the credentials: %v", err)
+	}
+	admins := lo.Filter(users, func(user jellyfinModels.User, _ int) bool {
+		return user.Policy.IsAdministrator && !user.Policy.IsDisabled
+	})
+	if len(admins) == 0 {
+		return setupSession{}, fmt.Errorf("no enabled administrator found on %s", serverURL)
+	}
+
+	// The chosen user, then the one who approved the Quick Connect code, then the first administrator
+	admin, found := lo.Find(admins, func(user jellyfinModels.User) bool { return user.ID == connection.UserID })
+	if !found {
+		admin, found = lo.Find(admins, func(user jellyfinModels.User) bool { return user.ID == credentials.UserID })
+	}
+	if !found {
+		admin = admins[0]
+	}
+	credentials.UserID = admin.ID
+	credentials.UserName = admin.Name
+
+	client := s.newClient(serverURL, credentials.AccessToken, credentials.UserID)
+	if _, err := client.DetectServerVersion(); err != nil {
+		logrus.Warnf("Failed to detect Jellyfin version, using legacy API style: %v", err)
+	}
+	return setupSession{credentials: credentials, admins: admins, server: info, client: client}, nil
+}
+
+func (s *setupHandler) newClient(serverURL, apiKey, userID string) *jellyfinClients.Client {
+	return jellyfinClients.NewClient(serverURL, apiKey, userID, jellyfinClients.Identity{
+		Client:   s.config.Device.Client,
+		Device:   s.config.Device.Name,
+		DeviceID: s.config.Device.ID,
+		Version:  constants.Version,
+	})
+}
+
+// normalizeServerURL removes the spaces and trailing slash of a server URL, so that it is stored the same way
+func normalizeServerURL(serverURL string) string {
+	return strings.TrimSuffix(strings.TrimSpace(serverURL), "/")
+}
+
+func newSetupToken() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(bytes)
+}
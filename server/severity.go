@@ -0,0 +1,84 @@
+package server
+
+import (
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// maxSameDurationDifference is the duration difference under which two copies have the same duration, editions
+// of a movie differing by several minutes
+const maxSameDurationDifference = 2 * time.Minute
+
+// severityFacts describes a pair for the severity rules, found by a scan or by the early warning
+type severityFacts struct {
+	movie1      jellyfinModels.Movie
+	movie2      jellyfinModels.Movie
+	isDuplicate bool
+	similarity  int
+	sameStem    bool
+	sameContent bool
+	linked      bool
+}
+
+// classify returns the severity of the first rule matching the pair, mismatch when none does
+func classify(rules []confModels.SeverityRule, facts severityFacts) constants.Severity {
+	for _, rule := range rules {
+		if ruleMatches(rule, facts) {
+			return rule.Severity
+		}
+	}
+	return constants.MismatchSeverity
+}
+
+// ruleMatches checks every condition set on a rule. Conditions on the size, duration or resolution of copies
+// never match when it is unknown for a copy.
+func ruleMatches(rule confModels.SeverityRule, facts severityFacts) bool {
+	movie1, movie2 := facts.movie1, facts.movie2
+	difference := time.Duration(movie1.RunTimeTicks-movie2.RunTimeTicks) * jellyfinModels.TickDuration
+	conditions := []struct {
+		expected *bool
+		actual   bool
+		known    bool
+	}{
+		{rule.IsDuplicate, facts.isDuplicate, true},
+		{rule.SameSize, movie1.Size() == movie2.Size(), movie1.Size() > 0 && movie2.Size() > 0},
+		{rule.SameDuration, difference.Abs() < maxSameDurationDifference, movie1.RunTimeTicks > 0 && movie2.RunTimeTicks > 0},
+		{rule.SameResolution, movie1.Resolution() == movie2.Resolution(),
+			movie1.Resolution() != constants.UnknownResolution && movie2.Resolution() != constants.UnknownResolution},
+		{rule.SameStem, facts.sameStem, true},
+		{rule.SameContent, facts.sameContent, true},
+		{rule.Linked, facts.linked, true},
+	}
+	for _, condition := range conditions {
+		if condition.expected != nil && (!condition.known || *condition.expected != condition.actual) {
+			return false
+		}
+	}
+	return facts.similarity >= rule.MinSimilarity
+}
+
+// duplicateSeverity classifies a pair found by a scan
+func (s *ServerService) duplicateSeverity(dup jellyfinModels.DuplicateResult) constants.Severity {
+	return classify(s.config.Scan.SeverityRules, severityFacts{
+		movie1:      dup.Movie1,
+		movie2:      dup.Movie2,
+		isDuplicate: dup.IsDuplicate,
+		similarity:  dup.Similarity,
+		sameStem:    dup.SameStem,
+		sameContent: dup.SameContent,
+		linked:      dup.Link != nil,
+	})
+}
+
+// severityRank orders the severities from the most severe, unknown severities last
+func severityRank(severity constants.Severity) int {
+	rank := lo.IndexOf(constants.Severities, severity)
+	if rank == -1 {
+		return len(constants.Severities)
+	}
+	return rank
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Names of the values, lock and channel shared by the replicas through Redis
+const (
+	// scanLock is held by the replica running a scan
+	scanLock = "scan"
+	// scanVersionKey numbers the scans of every replica
+	scanVersionKey = "scan:version"
+	// scanRevisionKey increases on every change of the shared latest result, so that it is read only when changed
+	scanRevisionKey = "scan:revision"
+	// scanLatestKey holds the sharedScan
+	scanLatestKey = "scan:latest"
+	// scanCancelChannel asks the replica running a scan to cancel it
+	scanCancelChannel = "scan:cancel"
+)
+
+// sharedScan is the latest scan result shared by the replicas
+type sharedScan struct {
+	Revision int64 `json:"revision"`
+	Version  int64 `json:"version"`
+	// Key identifies the parameters of the scan, so that a replica waiting for the same scan shares its result
+	Key string `json:"key"`
+	// Latest is nil once flushed
+	Latest *ScanResult `json:"latest"`
+}
+
+// nextVersion returns the version of a completed scan, numbered across replicas when they share Redis and in
+// the store otherwise
+func (c *ScanCoordinator) nextVersion() (int64, error) {
+	var version int64
+	var err error
+	if c.cluster == nil {
+		version, err = c.store.NextScanVersion()
+	} else {
+		version, err = c.cluster.Increment(scanVersionKey)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to number the scan: %v", err)
+	}
+	return version, nil
+}
+
+// publish shares the latest result with the other replicas, the state mutex being held
+func (c *ScanCoordinator) publish() {
+	if c.cluster == nil {
+		return
+	}
+	revision, err := c.cluster.Increment(scanRevisionKey)
+	if err == nil {
+		var data []byte
+		data, err = json.Marshal(sharedScan{Revision: revision, Version: c.version, Key: c.latestKey, Latest: c.latest})
+		if err == nil {
+			err = c.cluster.Set(scanLatestKey, data, 0)
+		}
+	}
+	if err != nil {
+		logrus.Warnf("Failed to share the latest scan result with the other replicas: %v", err)
+		return
+	}
+	c.revision = revision
+}
+
+// sync reads the latest result shared by the other replicas, when it changed since last read
+func (c *ScanCoordinator) sync() {
+	if c.cluster == nil {
+		return
+	}
+	data, found, err := c.cluster.Get(scanRevisionKey)
+	if err != nil {
+		logrus.Warnf("Failed to read the scan revision of the other replicas: %v", err)
+		return
+	}
+	revision, _ := strconv.ParseInt(string(data), 10, 64)
+	c.stateMutex.RLock()
+	changed := found && revision != c.revision
+	c.stateMutex.RUnlock()
+	if !changed {
+		return
+	}
+
+	data, found, err = c.cluster.Get(scanLatestKey)
+	var shared sharedScan
+	if err == nil && found {
+		err = json.Unmarshal(data, &shared)
+	}
+	if err != nil || !found {
+		logrus.Warnf("Failed to read the latest scan result of the other replicas: %v", err)
+		return
+	}
+
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	// The result may have been changed by this replica since the revision was read
+	if shared.Revision <= c.revision {
+		return
+	}
+	c.revision = shared.Revision
+	c.version = max(c.version, shared.Version)
+	c.latest, c.latestKey = shared.Latest, shared.Key
+	logrus.Debugf("Loaded scan version %d shared by another replica", shared.Version)
+}
+
+// subscribe cancels the scan of this replica when another replica is asked to
+func (c *ScanCoordinator) subscribe() {
+	c.cluster.Subscribe(scanCancelChannel, func(string) {
+		c.stateMutex.Lock()
+		defer c.stateMutex.Unlock()
+		if c.cancel != nil {
+			c.cancel()
+			logrus.Info("Cancelling the running scan, as asked through another replica")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	storageModels "jellyfin-duplicate/storage/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// SyncThenDeleteRequest is the body of a sync then delete request
+type SyncThenDeleteRequest struct {
+	GroupIDs []string `json:"group_ids" binding:"required,min=1"`
+	// ScanVersion is the version of the scan the groups were selected from, 0 to skip the check
+	ScanVersion int64 `json:"scan_version"`
+	// Strict restores the synchronized play status of a group when its deletion fails
+	Strict bool `json:"strict"`
+	// Positions are the IDs of the copies whose playback positions are kept by group ID, required for the groups
+	// with position conflicts
+	Positions map[string]string `json:"positions"`
+}
+
+// syncThenDelete copies the play status of the recommended copy to the kept one, checks that Jellyfin applied it,
+// and only then deletes the recommended copy. Users who have only seen the kept copy are left as they are, the
+// deleted copy does not need their play status. The playback positions of the deleted copy are copied when it is
+// the copy keepPositionOf, which is required when users stopped watching both copies at different positions.
+// When the deletion fails, the synchronized play status is kept and can be rolled back with the action, unless
+// strict is set: it is then restored right away and removed from the journal.
+func (s *ServerService) syncThenDelete(dup jellyfinModels.DuplicateResult, strict bool, keepPositionOf string,
+	journal *storageModels.ActionJournal) (string, error) {
+	if !dup.IsDuplicate {
+		return "", fmt.Errorf("pair is a potential mismatch, not a duplicate")
+	}
+	movie, err := recommendedCopy(dup)
+	if err != nil {
+		return "", err
+	}
+	if err := checkPositionChoice(dup, keepPositionOf); err != nil {
+		return "", err
+	}
+
+	kept := dup.Movie1
+	if kept.ID == movie.ID {
+		kept = dup.Movie2
+	}
+
+	discrepancies := lo.Filter(s.GetPlayStatusDiscrepancies(dup.Movie1, dup.Movie2),
+		func(discrepancy jellyfinModels.PlayStatusDiscrepancy, _ int) bool {
+			return discrepancy.MovieToUpdate == kept.ID
+		})
+
+	synced := len(journal.Entries)
+	err = s.syncDiscrepancies(discrepancies, journal)
+	if err == nil {
+		err = s.verifyPlayed(kept, discrepancies)
+	}
+	if err == nil && dup.HasPositionConflict && keepPositionOf == movie.ID {
+		err = s.syncPositions(dup, keepPositionOf, journal)
+	}
+	if err != nil {
+		return "", s.failSyncThenDelete(fmt.Errorf("movie not deleted: %v", err), strict, journal, synced)
+	}
+
+	message, err := s.removeCopy(dup, movie)
+	if err != nil {
+		return "", s.failSyncThenDelete(err, strict, journal, synced)
+	}
+
+	if len(discrepancies) == 0 {
+		return message, nil
+	}
+	return fmt.Sprintf("play status synchronized for %d users, %s", len(discrepancies), message), nil
+}
+
+// verifyPlayed reads the play status back from Jellyfin, to check that the kept copy is seen by every synchronized user
+func (s *ServerService) verifyPlayed(kept jellyfinModels.Movie, discrepancies []jellyfinModels.PlayStatusDiscrepancy) error {
+	for _, discrepancy := range discrepancies {
+		data, err := s.jellyfinClient.GetUserItemData(kept.ID, discrepancy.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to check play status for user %s: %v", discrepancy.UserName, err)
+		}
+		if !data.Played {
+			return fmt.Errorf("play status was not synchronized for user %s", discrepancy.UserName)
+		}
+	}
+	return nil
+}
+
+// failSyncThenDelete returns the error of a failed sync then delete. In strict mode, the play status changed since
+// the synced journal entry is restored first, and the restored entries are removed from the journal. The entries
+// which could not be restored are kept, so that the action can still be rolled back.
+func (s *ServerService) failSyncThenDelete(err error, strict bool, journal *storageModels.ActionJournal, synced int) error {
+	if !strict || len(journal.Entries) == synced {
+		return err
+	}
+
+	var failed []storageModels.PlayStateEntry
+	var errs []string
+	for i := len(journal.Entries) - 1; i >= synced; i-- {
+		entry := journal.Entries[i]
+		if restoreErr := s.restorePlayStatus(entry, fmt.Sprintf("%s failed", constants.SyncThenDeleteAction)); restoreErr != nil {
+			failed = append([]storageModels.PlayStateEntry{entry}, failed...)
+			errs = append(errs, restoreErr.Error())
+		}
+	}
+	journal.Entries = append(journal.Entries[:synced], failed...)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%v, and play status could not be restored: %s", err, strings.Join(errs, "; "))
+	}
+	return fmt.Errorf("%v, play status restored", err)
+}
+
+// POST /api/duplicates/sync-and-delete
+// SyncThenDelete submits a job synchronizing the play status of the recommended copy of each group to the kept
+// copy, then deleting the recommended copy
+func (h *Handler) SyncThenDelete(ctx *gin.Context) {
+	var request SyncThenDeleteRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		logrus.Warnf("Invalid sync then delete request: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "group_ids is required",
+		})
+		return
+	}
+
+	// Reject outdated selections now rather than when the job runs
+	if err := h.serverService.CheckScanVersion(request.ScanVersion); err != nil {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	job, err := h.jobs.Submit(constants.BulkActionJob, BulkActionRequest{
+		Action:      constants.SyncThenDeleteAction,
+		GroupIDs:    request.GroupIDs,
+		ScanVersion: request.ScanVersion,
+		Strict:      request.Strict,
+		Positions:   request.Positions,
+	})
+	if err != nil {
+		logrus.Errorf("Error submitting sync then delete job: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, job)
+}
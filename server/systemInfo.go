@@ -0,0 +1,124 @@
+package server
+
+import (
+	jellyfinClients "jellyfin-duplicate/client/jellyfin/http"
+	confModels "jellyfin-duplicate/configuration/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/cache"
+	"jellyfin-duplicate/pkg/humanize"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redacted replaces the secrets of the configuration in the system information
+const redacted = "[redacted]"
+
+// startedAt is when the application started, to report its uptime
+var startedAt = time.Now()
+
+// SystemInfo is the self-check report of the application, for bug reports and dashboards
+type SystemInfo struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit,omitempty"`
+	BuildDate string    `json:"build_date,omitempty"`
+	GoVersion string    `json:"go_version"`
+	Platform  string    `json:"platform"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime"`
+	// Config is the loaded configuration, its secrets replaced with "[redacted]"
+	Config   confModels.Config `json:"config"`
+	Features map[string]bool   `json:"features"`
+	// ScanCache is the usage of the latest scan result cache
+	ScanCache     cache.Stats    `json:"scan_cache"`
+	ScanCacheSize string         `json:"scan_cache_size"`
+	Schedule      ScheduleStatus `json:"schedule"`
+	Jellyfin      JellyfinStatus `json:"jellyfin"`
+	// Compatibility is how the requests are adapted to the version of the Jellyfin server
+	Compatibility jellyfinClients.Compatibility `json:"compatibility"`
+}
+
+// SystemInfo reports the version, configuration, features and state of the application, checking Jellyfin
+func (s *ServerService) SystemInfo() SystemInfo {
+	scanCache := s.scans.Stats()
+	return SystemInfo{
+		Version:       constants.Version,
+		Commit:        constants.BuildCommit(),
+		BuildDate:     constants.BuildDate,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		StartedAt:     startedAt,
+		Uptime:        humanize.Duration(time.Since(startedAt)),
+		Config:        redactConfig(s.liveConfig()),
+		Features:      s.features(),
+		ScanCache:     scanCache,
+		ScanCacheSize: humanize.Bytes(scanCache.EstimatedBytes),
+		Schedule:      s.ScheduleStatus(),
+		Jellyfin:      s.JellyfinStatus(),
+		Compatibility: s.jellyfinClient.Compatibility(),
+	}
+}
+
+// features lists the optional features of the application, and whether they are enabled
+func (s *ServerService) features() map[string]bool {
+	config := s.liveConfig()
+	return map[string]bool{
+		"login":              config.Auth.Enabled() || config.Auth.HeaderEnabled(),
+		"trusted_header":     config.Auth.HeaderEnabled(),
+		"tls":                config.TLS.Enabled(),
+		"cors":               config.CORS.Enabled(),
+		"acme":               config.TLS.ACME.Enabled(),
+		"trakt":              config.Trakt.Enabled(),
+		"secondary_jellyfin": s.secondaryClient != nil,
+		"early_warning":      s.schedule != nil,
+		"jellyfin_webhook":   config.EarlyWarning.WebhookToken != "",
+		"notifications":      config.Notifications.WebhookURL != "",
+		"admin_api":          config.Debug.AdminToken != "",
+		"widget_token":       config.WidgetToken != "",
+		"pprof":              config.Debug.Pprof,
+		"detect_links":       config.Scan.DetectLinks,
+		"auto_tune":          config.Scan.AutoTuneThreshold,
+	}
+}
+
+// redactConfig replaces the secrets of a configuration which would be encoded. The secrets read from the
+// environment are never encoded.
+func redactConfig(config confModels.Config) confModels.Config {
+	if config.Jellyfin.APIKey != "" {
+		config.Jellyfin.APIKey = redacted
+	}
+	// Webhook URLs often hold a token, such as Discord or Slack ones
+	if config.Notifications.WebhookURL != "" {
+		config.Notifications.WebhookURL = redacted
+	}
+	if len(config.Notifications.SeverityWebhooks) > 0 {
+		webhooks := make(map[constants.Severity]string, len(config.Notifications.SeverityWebhooks))
+		for severity, url := range config.Notifications.SeverityWebhooks {
+			if url != "" {
+				url = redacted
+			}
+			webhooks[severity] = url
+		}
+		config.Notifications.SeverityWebhooks = webhooks
+	}
+	if len(config.Notifications.UserWebhooks) > 0 {
+		webhooks := make(map[string]string, len(config.Notifications.UserWebhooks))
+		for user, url := range config.Notifications.UserWebhooks {
+			if url != "" {
+				url = redacted
+			}
+			webhooks[user] = url
+		}
+		config.Notifications.UserWebhooks = webhooks
+	}
+	return config
+}
+
+// GET /api/system/info
+// GetSystemInfo returns the self-check report of the application: version, build, configuration without its
+// secrets, enabled features, scan cache, schedule and Jellyfin compatibility
+func (h *Handler) GetSystemInfo(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.serverService.SystemInfo())
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/i18n"
+	"jellyfin-duplicate/pkg/humanize"
+	"strings"
+	"time"
+)
+
+// TemplateFuncs returns the functions available in HTML templates
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"formatBytes":    humanize.Bytes,
+		"formatBitrate":  humanize.Bitrate,
+		"formatDuration": humanize.Duration,
+		"formatTicks":    formatTicks,
+		"formatPercent":  formatPercent,
+		"formatNumber":   formatNumber,
+		"formatDate":     formatDate,
+		"formatDateTime": formatDateTime,
+		"dict":           dict,
+		"list":           list,
+		"join":           strings.Join,
+		"suggestRename":  SuggestRename,
+	}
+}
+
+// formatTicks formats a Jellyfin duration or playback position expressed in ticks
+func formatTicks(ticks int64) string {
+	return humanize.Duration(time.Duration(ticks) * jellyfinModels.TickDuration)
+}
+
+// formatPercent formats a percentage given as an integer or a float, such as a similarity or an overlap
+func formatPercent(value any) (string, error) {
+	switch number := value.(type) {
+	case int:
+		return humanize.Percent(float64(number)), nil
+	case int64:
+		return humanize.Percent(float64(number)), nil
+	case float64:
+		return humanize.Percent(number), nil
+	default:
+		return "", fmt.Errorf("formatPercent expects a number, got %T", value)
+	}
+}
+
+// formatNumber formats a count, or a float with one decimal, following the locale
+func formatNumber(locale i18n.Locale, value any) (string, error) {
+	switch number := value.(type) {
+	case int:
+		return locale.FormatNumber(float64(number), 0), nil
+	case int64:
+		return locale.FormatNumber(float64(number), 0), nil
+	case float64:
+		return locale.FormatNumber(number, 1), nil
+	default:
+		return "", fmt.Errorf("formatNumber expects a number, got %T", value)
+	}
+}
+
+// formatDate formats the day of a time, or of an RFC 3339 date as returned by Jellyfin, following the locale
+func formatDate(locale i18n.Locale, value any) (string, error) {
+	return formatTime(value, locale.FormatDate)
+}
+
+// formatDateTime formats a time, or an RFC 3339 date as returned by Jellyfin, following the locale
+func formatDateTime(locale i18n.Locale, value any) (string, error) {
+	return formatTime(value, locale.FormatDateTime)
+}
+
+// formatTime formats a time in the server timezone, dates that cannot be parsed being returned as they are
+func formatTime(value any, format func(t time.Time) string) (string, error) {
+	switch date := value.(type) {
+	case time.Time:
+		return format(date.Local()), nil
+	case *time.Time:
+		if date == nil {
+			return "", nil
+		}
+		return format(date.Local()), nil
+	case string:
+		t, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			return date, nil
+		}
+		return format(t.Local()), nil
+	default:
+		return "", fmt.Errorf("expected a time or a date string, got %T", value)
+	}
+}
+
+// list builds a slice from its arguments, to range over a fixed set of values
+func list(values ...any) []any {
+	return values
+}
+
+// dict builds a map from key/value pairs, to pass several values to a sub template
+func dict(values ...any) (map[string]any, error) {
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("dict expects an even number of arguments")
+	}
+
+	result := make(map[string]any, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings")
+		}
+		result[key] = values[i+1]
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ThrottleLogin rejects the sign in attempts of clients above the rate limit or locked out after repeated failures
+func (h *Handler) ThrottleLogin(ctx *gin.Context) {
+	if wait, allowed := h.throttle.Allow(ctx.ClientIP()); !allowed {
+		ctx.Header("Retry-After", retryAfter(wait))
+		ctx.Abort()
+		h.renderLoginError(ctx, http.StatusTooManyRequests, fmt.Errorf("too many attempts, try again in %s seconds", retryAfter(wait)))
+		return
+	}
+	ctx.Next()
+}
+
+// loginFailed counts a failed sign in of the client, locking it out after repeated failures, and records it
+// in the audit log. name is who the client tried to sign in as, empty when unknown.
+func (h *Handler) loginFailed(ctx *gin.Context, name, reason string) {
+	client := ctx.ClientIP()
+	locked := h.throttle.Fail(client)
+	if locked {
+		logrus.Warnf("Locking out %s for %d minutes after %d failed sign ins", client, h.config.Auth.LockoutDuration, h.config.Auth.MaxFailedLogins)
+	}
+	h.serverService.recordLoginFailure(client, name, reason, locked)
+}
+
+// rejectThrottled rejects the request of a locked out client, telling it when to try again
+func rejectThrottled(ctx *gin.Context, wait time.Duration) {
+	ctx.Header("Retry-After", retryAfter(wait))
+	ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+}
+
+// retryAfter formats a wait as the seconds of a Retry-After header, rounded up
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+
+	"github.com/samber/lo"
+)
+
+// CompareTracks compares the audio and subtitle languages of both copies of a pair and lists the
+// languages only available in the copy recommended for deletion. It returns nil when the tracks
+// of a copy are unknown, as languages could not be compared.
+func CompareTracks(movie1, movie2 jellyfinModels.Movie, recommendedDeleteID string) *jellyfinModels.TrackComparison {
+	if !movie1.HasStreams() || !movie2.HasStreams() {
+		return nil
+	}
+
+	comparison := &jellyfinModels.TrackComparison{
+		Movie1: trackInventory(movie1),
+		Movie2: trackInventory(movie2),
+	}
+
+	deleted, kept := comparison.Movie1, comparison.Movie2
+	switch recommendedDeleteID {
+	case movie1.ID:
+	case movie2.ID:
+		deleted, kept = kept, deleted
+	default:
+		return comparison
+	}
+
+	comparison.LostAudioLanguages = lostLanguages(deleted.AudioLanguages, kept.AudioLanguages)
+	comparison.LostSubtitleLanguages = lostLanguages(deleted.SubtitleLanguages, kept.SubtitleLanguages)
+	return comparison
+}
+
+func trackInventory(movie jellyfinModels.Movie) jellyfinModels.TrackInventory {
+	return jellyfinModels.TrackInventory{
+		AudioLanguages:    movie.AudioLanguages(),
+		SubtitleLanguages: movie.SubtitleLanguages(),
+	}
+}
+
+// lostLanguages returns the known languages of deleted missing from kept
+func lostLanguages(deleted, kept []string) []string {
+	return lo.Filter(deleted, func(language string, _ int) bool {
+		return language != jellyfinModels.UndefinedLanguage && !lo.Contains(kept, language)
+	})
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	traktModels "jellyfin-duplicate/client/trakt/models"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TraktWatched indexes the movies watched on Trakt by provider IDs, and by title and year
+// for movies without provider IDs
+type TraktWatched struct {
+	keys map[string]bool
+}
+
+func NewTraktWatched(watched []traktModels.WatchedMovie) *TraktWatched {
+	index := &TraktWatched{keys: make(map[string]bool)}
+	for _, item := range watched {
+		if item.Movie.IDs.Imdb != "" {
+			index.keys["imdb:"+item.Movie.IDs.Imdb] = true
+		}
+		if item.Movie.IDs.Tmdb != 0 {
+			index.keys["tmdb:"+strconv.Itoa(item.Movie.IDs.Tmdb)] = true
+		}
+		index.keys[titleKey(item.Movie.Title, item.Movie.Year)] = true
+	}
+	return index
+}
+
+// IsWatched checks if Trakt reports the movie as watched
+func (w *TraktWatched) IsWatched(movie jellyfinModels.Movie) bool {
+	if movie.ProviderIds.Imdb != "" || movie.ProviderIds.Tmdb != "" {
+		return w.keys["imdb:"+movie.ProviderIds.Imdb] || w.keys["tmdb:"+movie.ProviderIds.Tmdb]
+	}
+	return w.keys[titleKey(movie.Name, movie.ProductionYear)]
+}
+
+func titleKey(title string, year int) string {
+	return fmt.Sprintf("title:%s-%d", strings.ToLower(title), year)
+}
+
+// loadTraktWatched fetches the Trakt watched history, nil when Trakt is not enabled or unavailable
+func (s *ServerService) loadTraktWatched() *TraktWatched {
+	if s.traktClient == nil {
+		return nil
+	}
+
+	watched, err := s.traktClient.GetWatchedMovies()
+	if err != nil {
+		// Trakt only adds information, the scan goes on without it
+		logrus.Warnf("Failed to get Trakt watched history, skipping Trakt cross-check: %v", err)
+		return nil
+	}
+	return NewTraktWatched(watched)
+}
+
+// GetTraktDiscrepancies lists the copies Trakt reports as watched while Jellyfin does not,
+// for the Jellyfin user owning the Trakt account
+func (s *ServerService) GetTraktDiscrepancies(movie1, movie2 jellyfinModels.Movie, watched *TraktWatched) []jellyfinModels.PlayStatusDiscrepancy {
+	if watched == nil {
+		return nil
+	}
+
+	userID := s.config.Trakt.JellyfinUserID
+	var discrepancies []jellyfinModels.PlayStatusDiscrepancy
+	for _, movie := range []jellyfinModels.Movie{movie1, movie2} {
+		if !watched.IsWatched(movie) {
+			continue
+		}
+
+		userName := userID
+		played := false
+		for _, status := range movie.UserPlayStatuses {
+			if status.UserID == userID {
+				userName = status.UserName
+				played = status.Played
+			}
+		}
+
+		if !played {
+			discrepancies = append(discrepancies, jellyfinModels.PlayStatusDiscrepancy{
+				UserID:        userID,
+				UserName:      userName,
+				MovieToUpdate: movie.ID,
+				MovieName:     movie.Name,
+			})
+		}
+	}
+	return discrepancies
+}
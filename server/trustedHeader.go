@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"jellyfin-duplicate/auth"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// trustedHeader signs users in from the header set by an authenticating reverse proxy
+type trustedHeader struct {
+	// proxies are the networks of the proxies allowed to set the header
+	proxies []*net.IPNet
+}
+
+// RegisterTrustedHeader delegates login to the reverse proxy in front of the application, which gives the signed
+// in user in the auth.trusted_header header. Routes registered with RequireSession and RequireAdmin are protected
+// from then on, and /auth/logout leads to the logout page of the proxy.
+func RegisterTrustedHeader(routes *gin.RouterGroup, handler *Handler) error {
+	proxies, err := parseNetworks(handler.config.Auth.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	handler.trustedHeader = &trustedHeader{proxies: proxies}
+	routes.GET("/auth/logout", handler.TrustedHeaderLogout)
+	return nil
+}
+
+// parseNetworks reads addresses and CIDR ranges, an address being a network of its own
+func parseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %s", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy range %s: %v", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// isTrusted checks if the connection comes from one of the proxies. The address of the connection is used
+// rather than X-Forwarded-For, which the client sets as well.
+func (t *trustedHeader) isTrusted(remoteIP string) bool {
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, network := range t.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireTrustedHeader signs the user given by the proxy in, rejecting requests which did not go through it
+func (h *Handler) requireTrustedHeader(ctx *gin.Context) {
+	config := h.config.Auth
+	if !h.trustedHeader.isTrusted(ctx.RemoteIP()) {
+		logrus.Warnf("Rejecting request from %s, which is not one of the trusted proxies", ctx.RemoteIP())
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Requests must go through the authenticating proxy"})
+		return
+	}
+
+	name := strings.TrimSpace(ctx.GetHeader(config.TrustedHeader))
+	if name == "" {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Login required"})
+		return
+	}
+	var groups []string
+	if config.GroupsHeader != "" {
+		groups = splitHeaderList(ctx.GetHeader(config.GroupsHeader))
+	}
+
+	role, allowed := config.RoleForUser(name, groups)
+	if !allowed {
+		logrus.Warnf("Rejecting %s, neither allowed by name nor member of an allowed group: %v", name, groups)
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": name + " is not allowed to use this application"})
+		return
+	}
+
+	ctx.Set(sessionContextKey, auth.Session{Subject: name, Name: name, Role: role})
+	ctx.Next()
+}
+
+// splitHeaderList splits a comma separated header, leaving out empty entries
+func splitHeaderList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// GET /auth/logout
+// TrustedHeaderLogout redirects to the logout page of the proxy, the application keeping no session of its own
+func (h *Handler) TrustedHeaderLogout(ctx *gin.Context) {
+	if h.config.Auth.LogoutURL != "" {
+		ctx.Redirect(http.StatusFound, h.config.Auth.LogoutURL)
+		return
+	}
+	ctx.Redirect(http.StatusFound, h.config.BasePath+"/")
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/notifications"
+	storageModels "jellyfin-duplicate/storage/models"
+	"strings"
+)
+
+// WatchedChange is a movie whose play status was changed for a user while resolving a duplicate
+type WatchedChange struct {
+	MovieID   string `json:"movie_id"`
+	MovieName string `json:"movie_name"`
+	// Change is mark_played or set_position
+	Change constants.AuditAction `json:"change"`
+}
+
+// notifyWatchedChanges sends a watched_state_changed event about each user whose play status was changed,
+// listing the movies of the user, when notifications.notify_users is enabled. Entries without change come from
+// journals of older versions, and are skipped.
+func (s *ServerService) notifyWatchedChanges(entries []storageModels.PlayStateEntry) {
+	if !s.config.Notifications.NotifyUsers {
+		return
+	}
+
+	var userIDs []string
+	userNames := make(map[string]string)
+	changes := make(map[string][]WatchedChange)
+	for _, entry := range entries {
+		if entry.Change == "" {
+			continue
+		}
+		if _, found := changes[entry.UserID]; !found {
+			userIDs = append(userIDs, entry.UserID)
+			userNames[entry.UserID] = entry.UserName
+		}
+		changes[entry.UserID] = append(changes[entry.UserID], WatchedChange{
+			MovieID:   entry.MovieID,
+			MovieName: entry.MovieName,
+			Change:    entry.Change,
+		})
+	}
+
+	for _, userID := range userIDs {
+		s.notifier.Notify(notifications.Event{
+			Type:     constants.WatchedStateChangedEvent,
+			Title:    fmt.Sprintf("Watched movies of %s updated", userNames[userID]),
+			Message:  describeWatchedChanges(changes[userID]),
+			Data:     changes[userID],
+			UserID:   userID,
+			UserName: userNames[userID],
+		})
+	}
+}
+
+// NotifyMarkedAsSeen sends a watched_state_changed event about a user for whom a copy was marked as seen from
+// the analysis page, when notifications.notify_users is enabled
+func (s *ServerService) NotifyMarkedAsSeen(movieID, userID string) {
+	if !s.config.Notifications.NotifyUsers {
+		return
+	}
+
+	entry := storageModels.PlayStateEntry{MovieID: movieID, MovieName: movieID, UserID: userID, UserName: userID,
+		Change: constants.MarkPlayedAudit}
+	if movie, err := s.jellyfinClient.GetMovie(movieID); err == nil && movie.Name != "" {
+		entry.MovieName = movie.Name
+	}
+	if userName, err := s.jellyfinClient.GetUserName(userID); err == nil {
+		entry.UserName = userName
+	}
+	s.notifyWatchedChanges([]storageModels.PlayStateEntry{entry})
+}
+
+// describeWatchedChanges tells a user which movies were marked as watched and which playback positions were set,
+// and why
+func describeWatchedChanges(changes []WatchedChange) string {
+	var played, positions []string
+	for _, change := range changes {
+		if change.Change == constants.SetPositionAudit {
+			positions = append(positions, change.MovieName)
+		} else {
+			played = append(played, change.MovieName)
+		}
+	}
+
+	var sentences []string
+	if len(played) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Marked as watched: %s, as you watched another copy of the same movie.",
+			strings.Join(played, ", ")))
+	}
+	if len(positions) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Playback position set from the other copy: %s.", strings.Join(positions, ", ")))
+	}
+	return strings.Join(sentences, " ")
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// UserView is what the Jellyfin of a user shows of the movies of a page, read from the user-scoped Items
+// endpoint rather than from the play status reconciled by the scan
+type UserView struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name"`
+	// Items maps the IDs of the movies of the page to the data of the user
+	Items map[string]UserViewItem `json:"items"`
+}
+
+// UserViewItem is the data of a user for a movie
+type UserViewItem struct {
+	// Visible is false for the movies the user cannot access, their other fields are empty
+	Visible               bool   `json:"visible"`
+	Played                bool   `json:"played"`
+	PlayCount             int    `json:"play_count"`
+	PlaybackPositionTicks int64  `json:"playback_position_ticks"`
+	LastPlayedDate        string `json:"last_played_date,omitempty"`
+	IsFavorite            bool   `json:"is_favorite"`
+}
+
+// UserViewOf returns the view of the movies of the duplicates by the user with the given ID or name,
+// the name being compared case-insensitively
+func (s *ServerService) UserViewOf(user string, duplicates []jellyfinModels.DuplicateResult) (UserView, error) {
+	users, err := s.jellyfinClient.GetAllUsers()
+	if err != nil {
+		return UserView{}, fmt.Errorf("failed to get users: %v", err)
+	}
+	found, ok := lo.Find(users, func(candidate jellyfinModels.User) bool {
+		return candidate.ID == user || strings.EqualFold(candidate.Name, user)
+	})
+	if !ok {
+		return UserView{}, fmt.Errorf("%w: %s", ErrUserNotFound, user)
+	}
+
+	movies, err := s.jellyfinClient.GetUserMovies(found.ID)
+	if err != nil {
+		return UserView{}, fmt.Errorf("failed to get movies of user %s: %v", found.Name, err)
+	}
+	userData := make(map[string]jellyfinModels.UserItemData, len(movies))
+	for _, movie := range movies {
+		userData[movie.ID] = movie.UserData
+	}
+
+	view := UserView{UserID: found.ID, UserName: found.Name, Items: make(map[string]UserViewItem)}
+	for _, dup := range duplicates {
+		for _, movie := range []jellyfinModels.Movie{dup.Movie1, dup.Movie2} {
+			data, visible := userData[movie.ID]
+			view.Items[movie.ID] = UserViewItem{
+				Visible:               visible,
+				Played:                data.Played,
+				PlayCount:             data.PlayCount,
+				PlaybackPositionTicks: data.PlaybackPositionTicks,
+				LastPlayedDate:        data.LastPlayedDate,
+				IsFavorite:            data.IsFavorite,
+			}
+		}
+	}
+
+	logrus.Infof("Read the view of %d movies by user %s", len(view.Items), found.Name)
+	return view, nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	storageModels "jellyfin-duplicate/storage/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrNoIncludedUsers = errors.New("at least one user must be included in reconciliation")
+)
+
+// UserSummary is a Jellyfin user with the number of movies they have seen
+type UserSummary struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	LastActivityDate string `json:"last_activity_date,omitempty"`
+	IsAdministrator  bool   `json:"is_administrator"`
+	IsDisabled       bool   `json:"is_disabled"`
+	// SeenMovies is only counted for users included in reconciliation
+	SeenMovies int  `json:"seen_movies"`
+	Included   bool `json:"included"`
+}
+
+// reconciledUsers filters out the users excluded from play status reconciliation
+func (s *ServerService) reconciledUsers(users []jellyfinModels.User) []jellyfinModels.User {
+	return lo.Filter(users, func(user jellyfinModels.User, _ int) bool {
+		return !s.store.IsUserExcluded(user.ID)
+	})
+}
+
+// cacheUsers records the users and their seen movie counts fetched by the last scan
+func (s *ServerService) cacheUsers(users []jellyfinModels.User, userSeenMovies map[string][]jellyfinModels.Movie) {
+	summaries := make([]UserSummary, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, UserSummary{
+			ID:               user.ID,
+			Name:             user.Name,
+			LastActivityDate: user.LastActivityDate,
+			IsAdministrator:  user.Policy.IsAdministrator,
+			IsDisabled:       user.Policy.IsDisabled,
+			SeenMovies:       len(userSeenMovies[user.ID]),
+			Included:         !s.store.IsUserExcluded(user.ID),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return strings.ToLower(summaries[i].Name) < strings.ToLower(summaries[j].Name)
+	})
+
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+	s.users = summaries
+}
+
+// ListUsers returns the users cached by the last scan, fetching them from Jellyfin when no scan ran yet
+func (s *ServerService) ListUsers() ([]UserSummary, error) {
+	s.usersMutex.RLock()
+	users := s.users
+	s.usersMutex.RUnlock()
+	if users != nil {
+		s.usersLookups.Hit()
+		return users, nil
+	}
+	s.usersLookups.Miss()
+
+	allUsers, err := s.jellyfinClient.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %v", err)
+	}
+
+	userSeenMovies, err := s.jellyfinClient.GetSeenMoviesForAllUsers(s.reconciledUsers(allUsers))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get seen movies for all users: %v", err)
+	}
+
+	s.cacheUsers(allUsers, userSeenMovies)
+
+	s.usersMutex.RLock()
+	defer s.usersMutex.RUnlock()
+	return s.users, nil
+}
+
+// SetUserIncluded includes or excludes a user from play status reconciliation.
+// The change applies from the next scan.
+func (s *ServerService) SetUserIncluded(userID string, included bool) error {
+	users, err := s.jellyfinClient.GetAllUsers()
+	if err != nil {
+		return fmt.Errorf("failed to get users: %v", err)
+	}
+
+	user, found := lo.Find(users, func(user jellyfinModels.User) bool { return user.ID == userID })
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+	}
+
+	if included {
+		err = s.store.IncludeUser(userID)
+	} else {
+		remaining := lo.CountBy(s.reconciledUsers(users), func(other jellyfinModels.User) bool { return other.ID != userID })
+		if remaining == 0 {
+			return ErrNoIncludedUsers
+		}
+		err = s.store.ExcludeUser(storageModels.ExcludedUser{UserID: userID, UserName: user.Name, ExcludedAt: time.Now()})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save user selection: %v", err)
+	}
+
+	logrus.Infof("User %s %s play status reconciliation", user.Name, lo.Ternary(included, "included in", "excluded from"))
+
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+	for i := range s.users {
+		if s.users[i].ID == userID {
+			s.users[i].Included = included
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	jellyfinModels "jellyfin-duplicate/client/jellyfin/models"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/humanize"
+	storageModels "jellyfin-duplicate/storage/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrVersionPairNotFound is returned when removing the label of a pair which was not kept as intentional versions
+var ErrVersionPairNotFound = errors.New("pair is not kept as intentional versions")
+
+// keepVersions labels a pair as intentional versions of a single title, such as 4K and HD copies, leaving it
+// out of the duplicates. With merge, the copies are first merged in Jellyfin, which shows a single title with a
+// version picker: nothing is labeled when the merge fails.
+func (s *ServerService) keepVersions(dup jellyfinModels.DuplicateResult, merge bool) (string, error) {
+	if dup.Link != nil {
+		return "", fmt.Errorf("both copies are the same file (%s), they cannot be versions", dup.Link.Type)
+	}
+
+	if merge {
+		if err := s.jellyfinClient.MergeVersions(dup.Movie1.ID, dup.Movie2.ID); err != nil {
+			return "", err
+		}
+		s.recordAudit(constants.MergeVersionsAudit, dup.Movie1.ID, dup.Movie1.Name, s.config.Jellyfin.UserID,
+			fmt.Sprintf("%s with %s", dup.Movie1.Path, dup.Movie2.Path))
+	}
+
+	err := s.store.SaveVersionPair(storageModels.VersionPair{
+		ID:          dup.ID,
+		Fingerprint: PairFingerprint(dup.Movie1, dup.Movie2),
+		Movie1ID:    dup.Movie1.ID,
+		Movie2ID:    dup.Movie2.ID,
+		MovieName:   dup.Movie1.Name,
+		Movie1Path:  dup.Movie1.Path,
+		Movie2Path:  dup.Movie2.Path,
+		Size:        dup.Movie1.Size() + dup.Movie2.Size(),
+		Merged:      merge,
+		LabeledAt:   time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to record intentional versions: %v", err)
+	}
+
+	if merge {
+		return "kept as intentional versions, merged in Jellyfin", nil
+	}
+	return "kept as intentional versions", nil
+}
+
+// VersionPairs returns the pairs kept as intentional versions, most recent first, with their combined size
+func (s *ServerService) VersionPairs() ([]storageModels.VersionPair, int64) {
+	pairs := s.store.VersionPairs()
+	var size int64
+	for _, pair := range pairs {
+		size += pair.Size
+	}
+	return pairs, size
+}
+
+// DeleteVersionPair removes the intentional versions label of a pair, found again as a duplicate by the next scan.
+// Merged copies stay merged in Jellyfin, until split from the item page of Jellyfin.
+func (s *ServerService) DeleteVersionPair(fingerprint string) error {
+	found, err := s.store.DeleteVersionPair(fingerprint)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrVersionPairNotFound
+	}
+	return nil
+}
+
+// GET /api/versions
+// GetVersionPairs lists the pairs kept as intentional versions, with the disk space used by both copies
+func (h *Handler) GetVersionPairs(ctx *gin.Context) {
+	pairs, size := h.serverService.VersionPairs()
+	ctx.JSON(http.StatusOK, gin.H{
+		"versions":     pairs,
+		"total_size":   size,
+		"total_size_h": humanize.Bytes(size),
+	})
+}
+
+// DELETE /api/versions/:fingerprint
+// DeleteVersionPair removes the intentional versions label of a pair
+func (h *Handler) DeleteVersionPair(ctx *gin.Context) {
+	if err := h.serverService.DeleteVersionPair(ctx.Param("fingerprint")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrVersionPairNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "intentional versions label removed, the pair is found again by the next scan",
+	})
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RegisterJellyfinWebhook exposes the endpoint of the Jellyfin Webhook plugin, protected by the webhook token
+func RegisterJellyfinWebhook(routes *gin.RouterGroup, handler *Handler, webhookToken string) {
+	routes.POST("/api/webhooks/jellyfin", handler.requireBearerToken(webhookToken), handler.JellyfinWebhook)
+}
+
+// JellyfinWebhookEvent is the payload sent by the Jellyfin Webhook plugin. Only the fields
+// used here are read, so the default template and minimal custom ones both work.
+type JellyfinWebhookEvent struct {
+	NotificationType string `json:"NotificationType" binding:"required"`
+	ItemID           string `json:"ItemId"`
+	ItemType         string `json:"ItemType"`
+	Name             string `json:"Name"`
+}
+
+// IsMovieAdded tells whether the event reports a new movie
+func (e JellyfinWebhookEvent) IsMovieAdded() bool {
+	return e.NotificationType == "ItemAdded" && e.ItemType == "Movie" && e.ItemID != ""
+}
+
+// CheckAddedItem compares a newly imported movie with the library and notifies the duplicates found
+func (s *ServerService) CheckAddedItem(itemID string) {
+	// The plugin may send IDs with or without dashes
+	itemID = strings.ToLower(strings.ReplaceAll(itemID, "-", ""))
+
+	duplicates, err := s.FindItemDuplicates(itemID)
+	if err != nil {
+		logrus.Errorf("Failed to check added movie %s for duplicates: %v", itemID, err)
+		return
+	}
+	s.NotifyRecentDuplicates(duplicates)
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"cmp"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/pkg/humanize"
+	"jellyfin-duplicate/schema"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultWidgetTop is the number of top offenders of the widget report, unless ?top= is given
+	defaultWidgetTop = 5
+	// maxWidgetTop bounds ?top=, widgets showing a handful of entries
+	maxWidgetTop = 50
+)
+
+// RegisterWidget serves the widget report to dashboards, which may call it from the browser on another origin.
+// When widgetToken is set, dashboards send it as a bearer token instead of signing in, which they cannot do
+// when login is enabled.
+func RegisterWidget(routes, viewer *gin.RouterGroup, handler *Handler, widgetToken string) {
+	routes.OPTIONS("/api/widget", allowCrossOrigin)
+	if widgetToken != "" {
+		routes.GET("/api/widget", allowCrossOrigin, handler.requireBearerToken(widgetToken), handler.GetWidget)
+		return
+	}
+	viewer.GET("/api/widget", allowCrossOrigin, handler.GetWidget)
+}
+
+// allowCrossOrigin lets pages of any origin read the widget report, answering the preflight requests of the
+// bearer token. Cookies are not allowed, so that other pages cannot read it with the session of the user.
+// The origins of cors.allowed_origins are already answered by CORS, with their own settings.
+func allowCrossOrigin(ctx *gin.Context) {
+	if ctx.Writer.Header().Get("Access-Control-Allow-Origin") != "" {
+		return
+	}
+	ctx.Header("Access-Control-Allow-Origin", "*")
+	ctx.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
+	ctx.Header("Access-Control-Allow-Headers", "Authorization")
+	ctx.Header("Access-Control-Max-Age", "86400")
+	if ctx.Request.Method == http.MethodOptions {
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// WidgetHeadline holds the headline numbers of the latest scan, returned alone with ?compact=true for widgets
+// showing a few figures
+type WidgetHeadline struct {
+	// Duplicates counts the duplicate pairs
+	Duplicates int `json:"duplicates"`
+	// Discrepancies counts the duplicate pairs with a play status discrepancy
+	Discrepancies int `json:"discrepancies"`
+	// ReclaimableGB is the space freed by deleting a copy of every duplicate pair, in gigabytes (10^9 bytes)
+	// rounded to one decimal
+	ReclaimableGB float64 `json:"reclaimable_gb"`
+	// LastScan is absent until a scan completed
+	LastScan *time.Time `json:"last_scan,omitempty"`
+}
+
+// WidgetReport summarizes the latest scan with flat fields, as read by the custom API widgets of dashboards
+// such as Homepage or Homarr
+type WidgetReport struct {
+	WidgetHeadline
+	// Mismatches counts the pairs sharing a name whose paths differ
+	Mismatches         int `json:"mismatches"`
+	Exact              int `json:"exact"`
+	Probable           int `json:"probable"`
+	Versions           int `json:"versions"`
+	RecommendedActions int `json:"recommended_actions"`
+	// ReclaimableSize is ReclaimableGB in bytes, ReclaimableSizeH formats it
+	ReclaimableSize  int64  `json:"reclaimable_size"`
+	ReclaimableSizeH string `json:"reclaimable_size_h"`
+	// ScanVersion is 0 until a scan completed
+	ScanVersion int64 `json:"scan_version"`
+	// TopOffenders are the duplicate pairs reclaiming the most space
+	TopOffenders []WidgetOffender `json:"top_offenders"`
+	Task         WidgetTask       `json:"task"`
+}
+
+// WidgetOffender is a pair of the top offenders of the widget report
+type WidgetOffender struct {
+	GroupID          string             `json:"group_id"`
+	Name             string             `json:"name"`
+	Year             int                `json:"year"`
+	Library          string             `json:"library"`
+	Severity         constants.Severity `json:"severity,omitempty"`
+	ReclaimableSize  int64              `json:"reclaimable_size"`
+	ReclaimableSizeH string             `json:"reclaimable_size_h"`
+}
+
+// WidgetTask describes the scan like a Jellyfin scheduled task (GET /ScheduledTasks), so that dashboards
+// already showing the tasks of Jellyfin read it the same way
+type WidgetTask struct {
+	Name     string `json:"Name"`
+	Key      string `json:"Key"`
+	Category string `json:"Category"`
+	// State is Idle or Running
+	State                     string `json:"State"`
+	CurrentProgressPercentage *int   `json:"CurrentProgressPercentage,omitempty"`
+	// LastExecutionResult is absent until a scan finished
+	LastExecutionResult *WidgetTaskResult `json:"LastExecutionResult,omitempty"`
+}
+
+// WidgetTaskResult is the last finished scan, as the last execution of a Jellyfin scheduled task
+type WidgetTaskResult struct {
+	StartTimeUtc time.Time `json:"StartTimeUtc"`
+	EndTimeUtc   time.Time `json:"EndTimeUtc"`
+	// Status is Completed, Failed or Cancelled
+	Status       string `json:"Status"`
+	ErrorMessage string `json:"ErrorMessage,omitempty"`
+}
+
+// taskStatuses are the Jellyfin task statuses of the finished scans
+var taskStatuses = map[constants.ScanStatus]string{
+	constants.ScanCompleted: "Completed",
+	constants.ScanFailed:    "Failed",
+	constants.ScanCancelled: "Cancelled",
+}
+
+// WidgetReport summarizes the latest scan result with its top offenders. It never starts a scan, dashboards
+// refreshing every few seconds: the counts are 0 until the first scan.
+func (s *ServerService) WidgetReport(top int) WidgetReport {
+	report := WidgetReport{ReclaimableSizeH: humanize.Bytes(0), TopOffenders: []WidgetOffender{}, Task: s.widgetTask()}
+	result, found := s.knownScanResult()
+	if !found {
+		return report
+	}
+
+	report.LastScan = &result.GeneratedAt
+	report.ScanVersion = result.ScanVersion
+	report.RecommendedActions = len(result.Actions)
+	var duplicates []schema.Group
+	for _, group := range result.Groups {
+		if !group.IsDuplicate {
+			report.Mismatches++
+			continue
+		}
+		report.Duplicates++
+		duplicates = append(duplicates, group)
+		switch group.Severity {
+		case constants.ExactSeverity:
+			report.Exact++
+		case constants.ProbableSeverity:
+			report.Probable++
+		case constants.VersionSeverity:
+			report.Versions++
+		}
+		if len(group.PlayStatusDiscrepancies) > 0 {
+			report.Discrepancies++
+		}
+		report.ReclaimableSize += group.ReclaimableSize
+	}
+	report.ReclaimableSizeH = humanize.Bytes(report.ReclaimableSize)
+	report.ReclaimableGB = math.Round(float64(report.ReclaimableSize)/1e8) / 10
+
+	// Mismatches are left out, their copies being possibly different movies
+	slices.SortStableFunc(duplicates, func(a, b schema.Group) int {
+		return cmp.Compare(b.ReclaimableSize, a.ReclaimableSize)
+	})
+	for _, group := range duplicates[:min(top, len(duplicates))] {
+		if group.ReclaimableSize == 0 {
+			break
+		}
+		item := group.Items[0]
+		report.TopOffenders = append(report.TopOffenders, WidgetOffender{
+			GroupID:          group.ID,
+			Name:             item.Name,
+			Year:             item.Year,
+			Library:          item.Library,
+			Severity:         group.Severity,
+			ReclaimableSize:  group.ReclaimableSize,
+			ReclaimableSizeH: humanize.Bytes(group.ReclaimableSize),
+		})
+	}
+	return report
+}
+
+// widgetTask describes the running scan, or the last finished one, as a Jellyfin scheduled task
+func (s *ServerService) widgetTask() WidgetTask {
+	task := WidgetTask{Name: "Scan for duplicate movies", Key: "JellyfinDuplicateScan", Category: "Library", State: "Idle"}
+	if progress := s.ScanProgress(); progress.Running {
+		task.State = "Running"
+		if progress.Total > 0 {
+			percentage := progress.Done * 100 / progress.Total
+			task.CurrentProgressPercentage = &percentage
+		}
+	}
+
+	for _, record := range s.ScanHistory() {
+		status, finished := taskStatuses[record.Status]
+		if !finished || record.FinishedAt == nil {
+			continue
+		}
+		task.LastExecutionResult = &WidgetTaskResult{
+			StartTimeUtc: record.StartedAt.UTC(),
+			EndTimeUtc:   record.FinishedAt.UTC(),
+			Status:       status,
+			ErrorMessage: record.Error,
+		}
+		break
+	}
+	return task
+}
+
+// GET /api/widget
+// GetWidget returns the counts of the latest scan, its top offenders and the state of the scan for dashboard
+// widgets, ?top= setting the number of top offenders. ?compact=true returns the headline numbers only.
+func (h *Handler) GetWidget(ctx *gin.Context) {
+	if compact, _ := strconv.ParseBool(ctx.Query("compact")); compact {
+		ctx.JSON(http.StatusOK, h.serverService.WidgetReport(0).WidgetHeadline)
+		return
+	}
+
+	top, err := strconv.Atoi(ctx.DefaultQuery("top", strconv.Itoa(defaultWidgetTop)))
+	if err != nil || top < 0 || top > maxWidgetTop {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "top must be a number between 0 and " + strconv.Itoa(maxWidgetTop)})
+		return
+	}
+	ctx.JSON(http.StatusOK, h.serverService.WidgetReport(top))
+}
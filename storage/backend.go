@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"jellyfin-duplicate/constants"
+)
+
+// Documents persisted by the backends, each one a JSON document
+const (
+	stateDocument = "state"
+	// scanDocument holds the latest scan result, apart from the state as it is large and rewritten by every scan
+	scanDocument = "scan"
+	// scanVersionDocument holds the version of the latest scan, so that versions are never reused after a restart
+	scanVersionDocument = "scan-version"
+	// SettingsDocument holds the settings changed through the admin API
+	SettingsDocument = "settings"
+)
+
+// AnyRevision saves a document whatever its revision
+const AnyRevision int64 = -1
+
+// ErrConflict is returned when saving a document another instance saved since it was loaded
+var ErrConflict = errors.New("document was changed by another instance")
+
+// Backend persists the documents of the application. Each save of a document increases its revision, so that
+// instances sharing a backend never overwrite the changes of each other.
+type Backend interface {
+	// Load returns a document and its revision, a nil document and revision 0 when it was never saved
+	Load(name string) ([]byte, int64, error)
+	// Save replaces a document when its revision is still revision, 0 for a document never saved, and returns
+	// its new revision. ErrConflict is returned when it was saved since, unless revision is AnyRevision.
+	Save(name string, data []byte, revision int64) (int64, error)
+	// Revision returns the revision of a document, 0 when it was never saved
+	Revision(name string) (int64, error)
+	// Shared is true when other instances may use the backend, whose changes are then read periodically
+	Shared() bool
+	Close() error
+	// String describes where the documents are, without credentials
+	String() string
+}
+
+// OpenBackend opens the storage backend of the configuration: JSON files in the data directory, or the SQLite or
+// Postgres database of dsn
+func OpenBackend(backend constants.StorageBackend, dsn string, dataDir string) (Backend, error) {
+	switch backend {
+	case constants.FileStorage, "":
+		return newFileBackend(dataDir)
+	case constants.SQLiteStorage:
+		return newSQLiteBackend(dsn)
+	case constants.PostgresStorage:
+		return newPostgresBackend(dsn)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %s", backend)
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileBackend writes each document as a JSON file of the data directory. A single instance uses the directory,
+// documents have revision 1 once saved.
+type fileBackend struct {
+	dir string
+}
+
+func newFileBackend(dir string) (*fileBackend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory %s: %v", dir, err)
+	}
+	return &fileBackend{dir: dir}, nil
+}
+
+// path returns the file of a document, such as state.json
+func (b *fileBackend) path(name string) string {
+	return filepath.Join(b.dir, name+".json")
+}
+
+func (b *fileBackend) Load(name string) ([]byte, int64, error) {
+	data, err := os.ReadFile(b.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %v", b.path(name), err)
+	}
+	return data, 1, nil
+}
+
+func (b *fileBackend) Save(name string, data []byte, _ int64) (int64, error) {
+	if err := writeFile(b.path(name), data); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (b *fileBackend) Revision(name string) (int64, error) {
+	if _, err := os.Stat(b.path(name)); err != nil {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (b *fileBackend) Shared() bool {
+	return false
+}
+
+func (b *fileBackend) Close() error {
+	return nil
+}
+
+func (b *fileBackend) String() string {
+	return b.dir
+}
+
+// writeFile writes to a temporary file first so that a crash never leaves a truncated file
+func writeFile(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// lease is held by one instance sharing a backend until it expires
+type lease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AcquireLease takes the lease of a name for ttl, or extends it when this instance holds it, and reports whether
+// this instance holds it. An instance whose backend is not shared always holds it.
+func (s *Store) AcquireLease(name string, ttl time.Duration) (bool, error) {
+	if !s.backend.Shared() {
+		return true, nil
+	}
+
+	document := "lease-" + name
+	data, revision, err := s.backend.Load(document)
+	if err != nil {
+		return false, fmt.Errorf("failed to read lease %s: %v", name, err)
+	}
+	now := time.Now()
+	if data != nil {
+		var current lease
+		if err := json.Unmarshal(data, &current); err != nil {
+			return false, fmt.Errorf("failed to parse lease %s: %v", name, err)
+		}
+		if current.Holder != s.instanceID && now.Before(current.ExpiresAt) {
+			return false, nil
+		}
+	}
+
+	data, err = json.Marshal(lease{Holder: s.instanceID, ExpiresAt: now.Add(ttl)})
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize lease %s: %v", name, err)
+	}
+	// Another instance taking the lease meanwhile changed its revision
+	_, err = s.backend.Save(document, data, revision)
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save lease %s: %v", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// documents lists every document persisted by the backends
+var documents = []string{stateDocument, scanDocument, scanVersionDocument, SettingsDocument}
+
+// Migrate copies the documents of a backend to another one, such as the JSON files of the data directory to a
+// database, and returns the names of the copied ones. Documents already saved in the destination are kept,
+// unless force is set.
+func Migrate(from, to Backend, force bool) ([]string, error) {
+	var copied []string
+	for _, name := range documents {
+		data, _, err := from.Load(name)
+		if err != nil {
+			return copied, fmt.Errorf("failed to read %s from %s: %v", name, from, err)
+		}
+		if data == nil {
+			continue
+		}
+
+		revision := int64(0)
+		if force {
+			revision = AnyRevision
+		}
+		_, err = to.Save(name, data, revision)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return copied, fmt.Errorf("failed to write %s to %s: %v", name, to, err)
+		}
+		copied = append(copied, name)
+	}
+	return copied, nil
+}
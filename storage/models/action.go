@@ -0,0 +1,29 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+	"time"
+)
+
+// ActionJournal records the play status overwritten by a bulk action, so that it can be rolled back
+type ActionJournal struct {
+	ID           string               `json:"id"`
+	Action       constants.BulkAction `json:"action"`
+	Entries      []PlayStateEntry     `json:"entries"`
+	CreatedAt    time.Time            `json:"created_at"`
+	RolledBackAt *time.Time           `json:"rolled_back_at,omitempty"`
+}
+
+// PlayStateEntry is the play status of a movie for a user before it was changed
+type PlayStateEntry struct {
+	MovieID               string `json:"movie_id"`
+	MovieName             string `json:"movie_name"`
+	UserID                string `json:"user_id"`
+	UserName              string `json:"user_name"`
+	Played                bool   `json:"played"`
+	PlaybackPositionTicks int64  `json:"playback_position_ticks"`
+	PlayCount             int    `json:"play_count"`
+	LastPlayedDate        string `json:"last_played_date,omitempty"`
+	// Change is the change made, mark_played or set_position, empty in the journals of older versions
+	Change constants.AuditAction `json:"change,omitempty"`
+}
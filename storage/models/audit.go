@@ -0,0 +1,18 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+	"time"
+)
+
+// AuditEntry records a change made to Jellyfin or to the media files, and the identity it was made as
+type AuditEntry struct {
+	Action   constants.AuditAction `json:"action"`
+	ItemID   string                `json:"item_id"`
+	ItemName string                `json:"item_name"`
+	// OperatorID and OperatorName are the Jellyfin user whose access resolved the item, empty when none could
+	OperatorID   string    `json:"operator_id"`
+	OperatorName string    `json:"operator_name"`
+	Details      string    `json:"details,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
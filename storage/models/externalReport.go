@@ -0,0 +1,19 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+	"time"
+)
+
+// ExternalReport is the report of a filesystem duplicate finder, whose identical movie files are paired by the
+// scans whatever their names
+type ExternalReport struct {
+	Format constants.ReportFormat `json:"format"`
+	// Groups are the Jellyfin paths of identical movie files, the files of the report unknown to Jellyfin being
+	// left out with the groups of less than two movies
+	Groups [][]string `json:"groups"`
+	// Files is the number of files listed by the report, Matched the number of them found in Jellyfin
+	Files      int       `json:"files"`
+	Matched    int       `json:"matched"`
+	ImportedAt time.Time `json:"imported_at"`
+}
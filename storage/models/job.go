@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"jellyfin-duplicate/constants"
+	"time"
+)
+
+// Job is a long operation executed in the background
+type Job struct {
+	ID     string              `json:"id"`
+	Type   constants.JobType   `json:"type"`
+	Status constants.JobStatus `json:"status"`
+	Params json.RawMessage     `json:"params"`
+	// Progress is the number of processed items out of Total
+	Progress  int             `json:"progress"`
+	Total     int             `json:"total"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	// RunAt delays a queued job until that time, RunBefore fails it when it could not start before that time
+	RunAt      *time.Time `json:"run_at,omitempty"`
+	RunBefore  *time.Time `json:"run_before,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// IsFinished checks if the job reached a final status
+func (j Job) IsFinished() bool {
+	return j.Status == constants.JobSucceeded || j.Status == constants.JobFailed || j.Status == constants.JobCancelled
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"jellyfin-duplicate/constants"
+	"time"
+)
+
+// State is everything persisted by the application
+type State struct {
+	// IgnoredPairs are keyed by fingerprint, or by pair ID for pairs ignored by older versions
+	IgnoredPairs map[string]IgnoredPair `json:"ignored_pairs"`
+	Jobs         map[string]Job         `json:"jobs"`
+	// ExcludedUsers are keyed by user ID, users are included in reconciliation by default
+	ExcludedUsers map[string]ExcludedUser `json:"excluded_users"`
+	// Actions are the rollback journals of bulk actions, keyed by ID
+	Actions map[string]ActionJournal `json:"actions"`
+	// FalsePositives are the pairs marked as not duplicates, keyed by fingerprint
+	FalsePositives map[string]FalsePositive `json:"false_positives"`
+	// Resolutions are the pairs resolved outside of the application, keyed by fingerprint
+	Resolutions map[string]Resolution `json:"resolutions"`
+	// AuditLog lists the changes made to Jellyfin or to the media files, oldest first
+	AuditLog []AuditEntry `json:"audit_log"`
+	// ExternalReports are the imported reports of filesystem duplicate finders, keyed by format
+	ExternalReports map[constants.ReportFormat]ExternalReport `json:"external_reports"`
+	// Versions are the pairs kept as intentional versions of a single title, keyed by fingerprint
+	Versions map[string]VersionPair `json:"versions"`
+}
+
+// VersionPair is a duplicate pair the user kept as versions of a single title, such as 4K and HD copies.
+// It is left out of the results from then on, its disk usage is still reported.
+type VersionPair struct {
+	ID          string `json:"id"`
+	Fingerprint string `json:"fingerprint"`
+	Movie1ID    string `json:"movie1_id"`
+	Movie2ID    string `json:"movie2_id"`
+	MovieName   string `json:"movie_name"`
+	Movie1Path  string `json:"movie1_path"`
+	Movie2Path  string `json:"movie2_path"`
+	// Size is the combined size of both copies, part of the fingerprint: a copy whose size changes is paired again
+	Size int64 `json:"size"`
+	// Merged is true when the copies were merged as versions in Jellyfin
+	Merged    bool      `json:"merged"`
+	LabeledAt time.Time `json:"labeled_at"`
+}
+
+// Resolution is a duplicate pair the user resolved outside of the application, such as on the filesystem.
+// It is left out of the results from then on.
+type Resolution struct {
+	ID          string    `json:"id"`
+	Fingerprint string    `json:"fingerprint"`
+	Movie1ID    string    `json:"movie1_id"`
+	Movie2ID    string    `json:"movie2_id"`
+	MovieName   string    `json:"movie_name"`
+	Movie1Path  string    `json:"movie1_path"`
+	Movie2Path  string    `json:"movie2_path"`
+	Reason      string    `json:"reason"`
+	ResolvedAt  time.Time `json:"resolved_at"`
+}
+
+// FalsePositive is a potential duplicate the user marked as two different movies, used as a negative
+// example to suggest the duplicate threshold of its libraries
+type FalsePositive struct {
+	Fingerprint string `json:"fingerprint"`
+	Movie1ID    string `json:"movie1_id"`
+	Movie2ID    string `json:"movie2_id"`
+	MovieName   string `json:"movie_name"`
+	// Libraries are the distinct libraries of both movies
+	Libraries  []string  `json:"libraries"`
+	Similarity int       `json:"similarity"`
+	MarkedAt   time.Time `json:"marked_at"`
+}
+
+// ExcludedUser is a Jellyfin user whose play status is left out of reconciliation
+type ExcludedUser struct {
+	UserID     string    `json:"user_id"`
+	UserName   string    `json:"user_name"`
+	ExcludedAt time.Time `json:"excluded_at"`
+}
+
+// IgnoredPair is a duplicate pair the user chose to ignore
+type IgnoredPair struct {
+	ID string `json:"id"`
+	// Fingerprint identifies the content of the pair, the pair is still ignored when its item IDs change.
+	// It is empty for pairs ignored by older versions, which are matched by ID until they are found again.
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Movie1ID    string    `json:"movie1_id"`
+	Movie2ID    string    `json:"movie2_id"`
+	MovieName   string    `json:"movie_name"`
+	IgnoredAt   time.Time `json:"ignored_at"`
+}
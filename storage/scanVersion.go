@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ScanVersion returns the version of the latest scan, of this run or of a previous one, 0 before the first scan
+func (s *Store) ScanVersion() (int64, error) {
+	s.scanMutex.Lock()
+	defer s.scanMutex.Unlock()
+
+	version, _, err := s.loadScanVersion()
+	return version, err
+}
+
+// NextScanVersion numbers a completed scan, one more than the latest version saved by this instance or another
+// one sharing the backend
+func (s *Store) NextScanVersion() (int64, error) {
+	s.scanMutex.Lock()
+	defer s.scanMutex.Unlock()
+
+	var version, revision int64
+	var err error
+	for attempt := 0; attempt < jobSaveAttempts; attempt++ {
+		version, revision, err = s.loadScanVersion()
+		if err != nil {
+			return 0, err
+		}
+		version++
+
+		data, marshalErr := json.Marshal(version)
+		if marshalErr != nil {
+			return 0, fmt.Errorf("failed to serialize scan version: %v", marshalErr)
+		}
+		// Another instance numbering a scan meanwhile changed its revision
+		if _, err = s.backend.Save(scanVersionDocument, data, revision); !errors.Is(err, ErrConflict) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save scan version: %v", err)
+	}
+	return version, nil
+}
+
+// loadScanVersion reads the version of the latest scan and its revision. Before the version was saved apart,
+// it is the one of the persisted scan result.
+func (s *Store) loadScanVersion() (version int64, revision int64, err error) {
+	data, revision, err := s.backend.Load(scanVersionDocument)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read scan version: %v", err)
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &version); err != nil {
+			return 0, 0, fmt.Errorf("failed to parse scan version from %s: %v", s.backend, err)
+		}
+		return version, revision, nil
+	}
+
+	data, _, err = s.backend.Load(scanDocument)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read scan result: %v", err)
+	}
+	if data == nil {
+		return 0, revision, nil
+	}
+	var result struct {
+		ScanVersion int64 `json:"scan_version"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse scan result from %s: %v", s.backend, err)
+	}
+	return result.ScanVersion, revision, nil
+}
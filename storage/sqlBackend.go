@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// sqlBackend stores the documents in the documents table of a SQLite or Postgres database, created when missing
+type sqlBackend struct {
+	db          *sql.DB
+	description string
+	// placeholder returns the query parameter at a position from 1, "?" for SQLite and "$1" for Postgres
+	placeholder func(position int) string
+}
+
+// newSQLiteBackend opens a SQLite database, a path or a file: URI. The database waits for the locks of other
+// processes instead of failing right away.
+func newSQLiteBackend(dsn string) (*sqlBackend, error) {
+	path := strings.TrimPrefix(strings.SplitN(dsn, "?", 2)[0], "file:")
+	if !strings.Contains(dsn, "_pragma=") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %v", path, err)
+	}
+	// SQLite allows a single writer, one connection avoids waiting for the locks of the same process
+	db.SetMaxOpenConns(1)
+	backend := &sqlBackend{db: db, description: "SQLite database " + path, placeholder: func(int) string { return "?" }}
+	return backend, backend.createTable("BLOB", "TEXT")
+}
+
+// newPostgresBackend opens a Postgres database from its connection URL or keyword/value string
+func newPostgresBackend(dsn string) (*sqlBackend, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Postgres database: %v", err)
+	}
+	backend := &sqlBackend{db: db, description: "Postgres database " + postgresHost(dsn), placeholder: func(position int) string {
+		return fmt.Sprintf("$%d", position)
+	}}
+	return backend, backend.createTable("BYTEA", "TIMESTAMPTZ")
+}
+
+// postgresHost returns the host and database of a connection URL, without its credentials
+func postgresHost(dsn string) string {
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.Host == "" {
+		return "(connection string)"
+	}
+	return parsed.Host + parsed.Path
+}
+
+func (b *sqlBackend) createTable(dataType, timeType string) error {
+	_, err := b.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS documents (
+	name VARCHAR(64) PRIMARY KEY,
+	data %s NOT NULL,
+	revision BIGINT NOT NULL,
+	updated_at %s NOT NULL
+)`, dataType, timeType))
+	if err != nil {
+		b.db.Close()
+		return fmt.Errorf("failed to create the documents table of the %s: %v", b.description, err)
+	}
+	return nil
+}
+
+func (b *sqlBackend) Load(name string) ([]byte, int64, error) {
+	var data []byte
+	var revision int64
+	err := b.db.QueryRow(fmt.Sprintf("SELECT data, revision FROM documents WHERE name = %s", b.placeholder(1)), name).
+		Scan(&data, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s from the %s: %v", name, b.description, err)
+	}
+	return data, revision, nil
+}
+
+func (b *sqlBackend) Save(name string, data []byte, revision int64) (int64, error) {
+	p := b.placeholder
+	var query string
+	args := []any{name, data, time.Now().UTC()}
+	switch {
+	case revision == AnyRevision:
+		query = fmt.Sprintf(`INSERT INTO documents (name, data, revision, updated_at) VALUES (%s, %s, 1, %s)
+ON CONFLICT (name) DO UPDATE SET data = excluded.data, revision = documents.revision + 1, updated_at = excluded.updated_at
+RETURNING revision`, p(1), p(2), p(3))
+	case revision == 0:
+		query = fmt.Sprintf(`INSERT INTO documents (name, data, revision, updated_at) VALUES (%s, %s, 1, %s)
+ON CONFLICT (name) DO NOTHING RETURNING revision`, p(1), p(2), p(3))
+	default:
+		query = fmt.Sprintf(`UPDATE documents SET data = %s, revision = revision + 1, updated_at = %s
+WHERE name = %s AND revision = %s RETURNING revision`, p(1), p(2), p(3), p(4))
+		args = []any{data, time.Now().UTC(), name, revision}
+	}
+
+	var saved int64
+	err := b.db.QueryRow(query, args...).Scan(&saved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to save %s: %w", name, ErrConflict)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save %s to the %s: %v", name, b.description, err)
+	}
+	return saved, nil
+}
+
+func (b *sqlBackend) Revision(name string) (int64, error) {
+	var revision int64
+	err := b.db.QueryRow(fmt.Sprintf("SELECT revision FROM documents WHERE name = %s", b.placeholder(1)), name).Scan(&revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the revision of %s from the %s: %v", name, b.description, err)
+	}
+	return revision, nil
+}
+
+// Shared is true for databases, a SQLite database may be shared by the instances of a host or of a network share
+func (b *sqlBackend) Shared() bool {
+	return true
+}
+
+func (b *sqlBackend) Close() error {
+	return b.db.Close()
+}
+
+func (b *sqlBackend) String() string {
+	return b.description
+}
@@ -0,0 +1,553 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jellyfin-duplicate/constants"
+	"jellyfin-duplicate/schema"
+	"jellyfin-duplicate/storage/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// jobSaveAttempts is how many times a job is saved when other instances changed the state meanwhile
+const jobSaveAttempts = 3
+
+// ErrJobTaken is returned when starting a job another instance started or cancelled
+var ErrJobTaken = errors.New("job was started or cancelled by another instance")
+
+// Store holds the application state in memory and persists it through a backend. With a shared backend, the
+// changes of other instances are read periodically, and a change conflicting with one of them is rejected.
+type Store struct {
+	backend Backend
+	mutex   sync.RWMutex
+	state   models.State
+	// revision is the revision of the state in the backend when it was last loaded or saved
+	revision int64
+	// scanMutex serializes the writes of the scan result
+	scanMutex sync.Mutex
+	stop      chan struct{}
+	// instanceID identifies this instance in the leases it holds
+	instanceID string
+}
+
+// NewStore loads the state from a backend. With a shared backend, the state is loaded again every
+// refreshInterval when another instance changed it, until Close.
+func NewStore(backend Backend, refreshInterval time.Duration) (*Store, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate instance ID: %v", err)
+	}
+	store := &Store{backend: backend, stop: make(chan struct{}), instanceID: hex.EncodeToString(id)}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("State loaded from %s (%d ignored pairs, %d jobs)", backend, len(store.state.IgnoredPairs), len(store.state.Jobs))
+	if backend.Shared() && refreshInterval > 0 {
+		go store.follow(refreshInterval)
+	}
+	return store, nil
+}
+
+// load reads the state from the backend, the caller must hold the write lock once the store is shared
+func (s *Store) load() error {
+	data, revision, err := s.backend.Load(stateDocument)
+	if err != nil {
+		return fmt.Errorf("failed to read state: %v", err)
+	}
+	state := models.State{}
+	if data != nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("failed to parse state from %s: %v", s.backend, err)
+		}
+	}
+
+	if state.IgnoredPairs == nil {
+		state.IgnoredPairs = make(map[string]models.IgnoredPair)
+	}
+	if state.Jobs == nil {
+		state.Jobs = make(map[string]models.Job)
+	}
+	if state.ExcludedUsers == nil {
+		state.ExcludedUsers = make(map[string]models.ExcludedUser)
+	}
+	if state.Actions == nil {
+		state.Actions = make(map[string]models.ActionJournal)
+	}
+	if state.FalsePositives == nil {
+		state.FalsePositives = make(map[string]models.FalsePositive)
+	}
+	if state.Resolutions == nil {
+		state.Resolutions = make(map[string]models.Resolution)
+	}
+	if state.ExternalReports == nil {
+		state.ExternalReports = make(map[constants.ReportFormat]models.ExternalReport)
+	}
+	if state.Versions == nil {
+		state.Versions = make(map[string]models.VersionPair)
+	}
+
+	s.state, s.revision = state, revision
+	return nil
+}
+
+// follow loads the state again when another instance saved it, until Close
+func (s *Store) follow(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		revision, err := s.backend.Revision(stateDocument)
+		if err != nil {
+			logrus.Warnf("Failed to check the state of other instances: %v", err)
+			continue
+		}
+		s.mutex.Lock()
+		if revision != s.revision {
+			logrus.Debugf("State changed by another instance, loading revision %d", revision)
+			if err := s.load(); err != nil {
+				logrus.Warnf("Failed to load the state changed by another instance: %v", err)
+			}
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// Close stops following the changes of other instances and closes the backend
+func (s *Store) Close() error {
+	close(s.stop)
+	return s.backend.Close()
+}
+
+// save writes the state through the backend, the caller must hold the write lock. When another instance saved
+// the state since it was loaded, its state is loaded and the change is dropped, to be made again.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.state, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize state: %v", err)
+	}
+	revision, err := s.backend.Save(stateDocument, data, s.revision)
+	if errors.Is(err, ErrConflict) {
+		if loadErr := s.load(); loadErr != nil {
+			return fmt.Errorf("%w, and failed to load it again: %v", err, loadErr)
+		}
+		return fmt.Errorf("%w, it was loaded again: retry", err)
+	}
+	if err != nil {
+		return err
+	}
+	s.revision = revision
+	return nil
+}
+
+// SaveScanResult persists the latest scan result, replacing the previous one
+func (s *Store) SaveScanResult(result schema.ScanResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to serialize scan result: %v", err)
+	}
+
+	s.scanMutex.Lock()
+	defer s.scanMutex.Unlock()
+	_, err = s.backend.Save(scanDocument, data, AnyRevision)
+	return err
+}
+
+// LatestScanResult loads the persisted scan result, converted to the current schema when written by an older
+// version. found is false when no scan was persisted yet.
+func (s *Store) LatestScanResult() (result schema.ScanResult, found bool, err error) {
+	s.scanMutex.Lock()
+	data, _, err := s.backend.Load(scanDocument)
+	s.scanMutex.Unlock()
+
+	if err != nil {
+		return schema.ScanResult{}, false, fmt.Errorf("failed to read scan result: %v", err)
+	}
+	if data == nil {
+		return schema.ScanResult{}, false, nil
+	}
+
+	result, err = schema.Decode(data)
+	if err != nil {
+		return schema.ScanResult{}, false, fmt.Errorf("failed to load scan result from %s: %v", s.backend, err)
+	}
+	return result, true, nil
+}
+
+// IgnorePair records a duplicate pair as ignored, keyed by its fingerprint
+func (s *Store) IgnorePair(pair models.IgnoredPair) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.IgnoredPairs[pair.Fingerprint] = pair
+	return s.save()
+}
+
+// IsPairIgnored checks if a duplicate pair was ignored. Pairs ignored by older versions are matched
+// by their ID, and are keyed by their fingerprint from then on.
+func (s *Store) IsPairIgnored(fingerprint, id string) bool {
+	s.mutex.RLock()
+	_, ignored := s.state.IgnoredPairs[fingerprint]
+	legacyPair, legacyIgnored := s.state.IgnoredPairs[id]
+	s.mutex.RUnlock()
+
+	if ignored {
+		return true
+	}
+	if !legacyIgnored || legacyPair.Fingerprint != "" {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	logrus.Infof("Keying ignored pair %s by its fingerprint", id)
+	legacyPair.Fingerprint = fingerprint
+	delete(s.state.IgnoredPairs, id)
+	s.state.IgnoredPairs[fingerprint] = legacyPair
+	if err := s.save(); err != nil {
+		logrus.Warnf("Failed to save fingerprint of ignored pair %s: %v", id, err)
+	}
+	return true
+}
+
+// IgnoredPairs returns all ignored pairs, most recent first
+func (s *Store) IgnoredPairs() []models.IgnoredPair {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	pairs := make([]models.IgnoredPair, 0, len(s.state.IgnoredPairs))
+	for _, pair := range s.state.IgnoredPairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].IgnoredAt.After(pairs[j].IgnoredAt)
+	})
+	return pairs
+}
+
+// ExcludeUser leaves a user out of play status reconciliation
+func (s *Store) ExcludeUser(user models.ExcludedUser) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.ExcludedUsers[user.UserID] = user
+	return s.save()
+}
+
+// IncludeUser includes a previously excluded user in play status reconciliation again
+func (s *Store) IncludeUser(userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, excluded := s.state.ExcludedUsers[userID]; !excluded {
+		return nil
+	}
+	delete(s.state.ExcludedUsers, userID)
+	return s.save()
+}
+
+// IsUserExcluded checks if a user is left out of play status reconciliation
+func (s *Store) IsUserExcluded(userID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, excluded := s.state.ExcludedUsers[userID]
+	return excluded
+}
+
+// SaveJob creates or updates a job. As a started job is only written by the instance running it, it is saved
+// again over the changes of other instances.
+func (s *Store) SaveJob(job models.Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var err error
+	for attempt := 0; attempt < jobSaveAttempts; attempt++ {
+		s.state.Jobs[job.ID] = job
+		if err = s.save(); !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// StartJob saves a queued job as started, unless another instance started or cancelled it since it was read
+func (s *Store) StartJob(job models.Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var err error
+	for attempt := 0; attempt < jobSaveAttempts; attempt++ {
+		if current, found := s.state.Jobs[job.ID]; !found || current.Status != constants.JobQueued {
+			return ErrJobTaken
+		}
+		s.state.Jobs[job.ID] = job
+		if err = s.save(); !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// Job returns a job by ID
+func (s *Store) Job(id string) (models.Job, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	job, ok := s.state.Jobs[id]
+	return job, ok
+}
+
+// Jobs returns all jobs, oldest first
+func (s *Store) Jobs() []models.Job {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	jobs := make([]models.Job, 0, len(s.state.Jobs))
+	for _, job := range s.state.Jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// PruneJobs removes the oldest finished jobs, keeping at most keep of them
+func (s *Store) PruneJobs(keep int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var finished []models.Job
+	for _, job := range s.state.Jobs {
+		if job.IsFinished() {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= keep {
+		return nil
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreatedAt.After(finished[j].CreatedAt)
+	})
+	for _, job := range finished[keep:] {
+		delete(s.state.Jobs, job.ID)
+	}
+	return s.save()
+}
+
+// SaveAction records the rollback journal of a bulk action, dropping the oldest ones beyond keep
+func (s *Store) SaveAction(action models.ActionJournal, keep int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.Actions[action.ID] = action
+
+	if len(s.state.Actions) > keep {
+		actions := make([]models.ActionJournal, 0, len(s.state.Actions))
+		for _, action := range s.state.Actions {
+			actions = append(actions, action)
+		}
+		sort.Slice(actions, func(i, j int) bool {
+			return actions[i].CreatedAt.After(actions[j].CreatedAt)
+		})
+		for _, action := range actions[keep:] {
+			delete(s.state.Actions, action.ID)
+		}
+	}
+	return s.save()
+}
+
+// Action returns the rollback journal of a bulk action by ID
+func (s *Store) Action(id string) (models.ActionJournal, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	action, ok := s.state.Actions[id]
+	return action, ok
+}
+
+// SaveFalsePositive records a pair marked as not a duplicate, keyed by its fingerprint
+func (s *Store) SaveFalsePositive(pair models.FalsePositive) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.FalsePositives[pair.Fingerprint] = pair
+	return s.save()
+}
+
+// IsFalsePositive checks if a pair was marked as not a duplicate
+func (s *Store) IsFalsePositive(fingerprint string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, found := s.state.FalsePositives[fingerprint]
+	return found
+}
+
+// FalsePositives returns the pairs marked as not duplicates, most recent first
+func (s *Store) FalsePositives() []models.FalsePositive {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	pairs := make([]models.FalsePositive, 0, len(s.state.FalsePositives))
+	for _, pair := range s.state.FalsePositives {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].MarkedAt.After(pairs[j].MarkedAt)
+	})
+	return pairs
+}
+
+// SaveResolution records a pair as resolved outside of the application, keyed by its fingerprint
+func (s *Store) SaveResolution(resolution models.Resolution) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.Resolutions[resolution.Fingerprint] = resolution
+	return s.save()
+}
+
+// IsPairResolved checks if a pair was resolved outside of the application
+func (s *Store) IsPairResolved(fingerprint string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, resolved := s.state.Resolutions[fingerprint]
+	return resolved
+}
+
+// Resolutions returns the pairs resolved outside of the application, most recent first
+func (s *Store) Resolutions() []models.Resolution {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	resolutions := make([]models.Resolution, 0, len(s.state.Resolutions))
+	for _, resolution := range s.state.Resolutions {
+		resolutions = append(resolutions, resolution)
+	}
+	sort.Slice(resolutions, func(i, j int) bool {
+		return resolutions[i].ResolvedAt.After(resolutions[j].ResolvedAt)
+	})
+	return resolutions
+}
+
+// RecordAudit appends an entry to the audit log, dropping the oldest ones beyond keep
+func (s *Store) RecordAudit(entry models.AuditEntry, keep int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.AuditLog = append(s.state.AuditLog, entry)
+	if len(s.state.AuditLog) > keep {
+		s.state.AuditLog = s.state.AuditLog[len(s.state.AuditLog)-keep:]
+	}
+	return s.save()
+}
+
+// AuditLog returns the audit log, most recent first
+func (s *Store) AuditLog() []models.AuditEntry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries := make([]models.AuditEntry, len(s.state.AuditLog))
+	for i, entry := range s.state.AuditLog {
+		entries[len(entries)-1-i] = entry
+	}
+	return entries
+}
+
+// SaveExternalReport records the report of a filesystem duplicate finder, replacing the previous one of its format
+func (s *Store) SaveExternalReport(report models.ExternalReport) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.ExternalReports[report.Format] = report
+	return s.save()
+}
+
+// DeleteExternalReport removes the report of a format, found is false when none was imported
+func (s *Store) DeleteExternalReport(format constants.ReportFormat) (found bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.state.ExternalReports[format]; !found {
+		return false, nil
+	}
+	delete(s.state.ExternalReports, format)
+	return true, s.save()
+}
+
+// ExternalReports returns the imported reports of filesystem duplicate finders, sorted by format
+func (s *Store) ExternalReports() []models.ExternalReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	reports := make([]models.ExternalReport, 0, len(s.state.ExternalReports))
+	for _, report := range s.state.ExternalReports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Format < reports[j].Format
+	})
+	return reports
+}
+
+// SaveVersionPair records a pair as intentional versions of a single title, keyed by its fingerprint
+func (s *Store) SaveVersionPair(pair models.VersionPair) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.state.Versions[pair.Fingerprint] = pair
+	return s.save()
+}
+
+// IsVersionPair checks if a pair was kept as intentional versions
+func (s *Store) IsVersionPair(fingerprint string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, found := s.state.Versions[fingerprint]
+	return found
+}
+
+// DeleteVersionPair removes the intentional versions label of a pair, found is false when it was not labeled
+func (s *Store) DeleteVersionPair(fingerprint string) (found bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.state.Versions[fingerprint]; !found {
+		return false, nil
+	}
+	delete(s.state.Versions, fingerprint)
+	return true, s.save()
+}
+
+// VersionPairs returns the pairs kept as intentional versions, most recent first
+func (s *Store) VersionPairs() []models.VersionPair {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	pairs := make([]models.VersionPair, 0, len(s.state.Versions))
+	for _, pair := range s.state.Versions {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].LabeledAt.After(pairs[j].LabeledAt)
+	})
+	return pairs
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"jellyfin-duplicate/pkg/humanize"
+	"runtime"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogMemoryUsage logs the memory usage of the process every interval until the returned function is called
+func LogMemoryUsage(label string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				logrus.Infof("%s memory usage: heap %s, system %s, %d goroutines, %d GC cycles",
+					label, humanize.Bytes(int64(stats.HeapAlloc)), humanize.Bytes(int64(stats.Sys)),
+					runtime.NumGoroutine(), stats.NumGC)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
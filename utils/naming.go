@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"jellyfin-duplicate/pkg/dedupe"
+	"path"
+	"strings"
+)
+
+// fileNameReplacer removes the characters not allowed in file names on Windows or Linux
+var fileNameReplacer = strings.NewReplacer(`<`, "", `>`, "", `"`, "", `?`, "", `*`, "", `:`, " -", `/`, "-", `\`, "-", `|`, "-")
+
+// CanonicalMovieName returns the folder and file name Jellyfin expects for a movie
+// Example: "Alien: Resurrection", 1997 → "Alien - Resurrection (1997)"
+func CanonicalMovieName(name string, year int) string {
+	return fmt.Sprintf("%s (%d)", strings.Join(strings.Fields(fileNameReplacer.Replace(name)), " "), year)
+}
+
+// SuggestMoviePath returns the path of a movie file following the Jellyfin naming rules,
+// "Name (Year)/Name (Year).ext". Suffixes after the canonical name, such as version labels,
+// part markers or provider IDs, are kept. It returns false when the path already follows the rules,
+// or when the movie has no year to name it after.
+func SuggestMoviePath(filePath, name string, year int) (string, bool) {
+	if strings.TrimSpace(name) == "" || year == 0 {
+		return "", false
+	}
+	canonical := CanonicalMovieName(name, year)
+
+	// Jellyfin may run on Windows, both separators are handled and the one used by the path is kept
+	fileSeparator := strings.LastIndexAny(filePath, `/\`)
+	if fileSeparator < 0 {
+		return "", false
+	}
+	separator := filePath[fileSeparator : fileSeparator+1]
+	folderPath, fileName := filePath[:fileSeparator], filePath[fileSeparator+1:]
+	folderSeparator := strings.LastIndexAny(folderPath, `/\`)
+	parentPath, folderName := folderPath[:folderSeparator+1], folderPath[folderSeparator+1:]
+
+	extension := path.Ext(fileName)
+	baseName := strings.TrimSuffix(fileName, extension)
+
+	suggestedFolder := folderName
+	if !hasCanonicalName(folderName, canonical) {
+		suggestedFolder = canonical
+	}
+
+	suggestedBase := baseName
+	if !hasCanonicalName(baseName, canonical) {
+		suggestedBase = canonical
+		if _, part, ok := dedupe.ParseMultiPart(filePath); ok {
+			suggestedBase = fmt.Sprintf("%s - part%d", canonical, part)
+		}
+	}
+
+	if suggestedFolder == folderName && suggestedBase == baseName {
+		return "", false
+	}
+	return parentPath + suggestedFolder + separator + suggestedBase + extension, true
+}
+
+// hasCanonicalName checks if a file or folder name is the canonical name, optionally followed by a suffix
+func hasCanonicalName(name, canonical string) bool {
+	return name == canonical || strings.HasPrefix(name, canonical+" ")
+}